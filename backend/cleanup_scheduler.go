@@ -2,9 +2,11 @@ package main
 
 import (
 	"log"
+	"strings"
 	"time"
 
 	"groupie-backend/database"
+	"groupie-backend/models"
 	"groupie-backend/services"
 )
 
@@ -32,6 +34,56 @@ func cleanupReservations() {
 	if count > 0 {
 		log.Printf("🧹 Cleaned up %d expired reservation(s)", count)
 	}
+
+	sessions, err := services.CleanupExpiredSessions()
+	if err != nil {
+		log.Printf("❌ Error cleaning up sessions: %v", err)
+		return
+	}
+
+	if sessions > 0 {
+		log.Printf("🧹 Cleaned up %d expired session(s)", sessions)
+	}
+
+	tokens, err := services.CleanupOneTimeTokens()
+	if err != nil {
+		log.Printf("❌ Error cleaning up one-time tokens: %v", err)
+		return
+	}
+
+	if tokens > 0 {
+		log.Printf("🧹 Cleaned up %d expired one-time token(s)", tokens)
+	}
+
+	links, err := services.CleanupIdentityLinkRequests()
+	if err != nil {
+		log.Printf("❌ Error cleaning up identity link requests: %v", err)
+		return
+	}
+
+	if links > 0 {
+		log.Printf("🧹 Cleaned up %d expired identity link request(s)", links)
+	}
+
+	handoffs, err := services.CleanupOAuthHandoffCodes()
+	if err != nil {
+		log.Printf("❌ Error cleaning up OAuth handoff codes: %v", err)
+		return
+	}
+
+	if handoffs > 0 {
+		log.Printf("🧹 Cleaned up %d expired OAuth handoff code(s)", handoffs)
+	}
+
+	attempts, err := services.CleanupLoginAttempts()
+	if err != nil {
+		log.Printf("❌ Error cleaning up login attempts: %v", err)
+		return
+	}
+
+	if attempts > 0 {
+		log.Printf("🧹 Cleaned up %d old login attempt(s)", attempts)
+	}
 }
 
 func StartStatsLogger() {
@@ -49,22 +101,49 @@ func StartStatsLogger() {
 func logStats() {
 	var totalReservations int
 	var paidReservations int
-	var revenue float64
 
 	err := database.DB.QueryRow(`
 		SELECT 
 			COUNT(*) as total,
-			COUNT(CASE WHEN status = 'paid' THEN 1 END) as paid,
-			COALESCE(SUM(CASE WHEN status = 'paid' THEN total_price ELSE 0 END), 0) as revenue
+			COUNT(CASE WHEN status = 'paid' THEN 1 END) as paid
 		FROM reservations
 		WHERE created_at >= NOW() - INTERVAL '24 hours'
-	`).Scan(&totalReservations, &paidReservations, &revenue)
+	`).Scan(&totalReservations, &paidReservations)
 
 	if err != nil {
 		log.Printf("❌ Error fetching stats: %v", err)
 		return
 	}
 
-	log.Printf("📊 Last 24h Stats: %d reservations (%d paid) - Revenue: %.2f€",
-		totalReservations, paidReservations, revenue)
+	// Revenu par devise : les montants de devises différentes ne s'additionnent pas
+	rows, err := database.DB.Query(`
+		SELECT currency, SUM(total_price)
+		FROM reservations
+		WHERE status = 'paid'
+		AND created_at >= NOW() - INTERVAL '24 hours'
+		GROUP BY currency
+		ORDER BY currency
+	`)
+	if err != nil {
+		log.Printf("❌ Error fetching revenue stats: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	var revenues []string
+	for rows.Next() {
+		var currency string
+		var revenue int64
+		if err := rows.Scan(&currency, &revenue); err != nil {
+			log.Printf("❌ Error scanning revenue stats: %v", err)
+			return
+		}
+		revenues = append(revenues, models.FormatAmount(revenue, currency))
+	}
+	if len(revenues) == 0 {
+		revenues = append(revenues, "0")
+	}
+
+	log.Printf("📊 Last 24h Stats: %d reservations (%d paid) - Revenue: %s",
+		totalReservations, paidReservations, strings.Join(revenues, ", "))
 }
@@ -0,0 +1,309 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey identifie le verrou consultatif Postgres pris pendant les
+// migrations, pour que deux réplicas qui démarrent en même temps ne se marchent pas dessus
+const migrationLockKey int64 = 727274001
+
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration est un couple de scripts up/down numérotés dans database/migrations
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus décrit l'état d'une migration connue par rapport à schema_migrations
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // le fichier a changé depuis son application
+}
+
+type appliedMigration struct {
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Migrator applique les migrations embarquées sur une base Postgres
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := LoadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// LoadMigrations découvre les fichiers NNN_nom.up.sql / NNN_nom.down.sql et les trie par version
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names: %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applique toutes les migrations en attente et retourne celles concernées
+func (m *Migrator) Up(ctx context.Context, dryRun bool) ([]Migration, error) {
+	var pending []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn, !dryRun)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			a, ok := applied[migration.Version]
+			if !ok {
+				pending = append(pending, migration)
+				continue
+			}
+			if a.Checksum != migration.Checksum {
+				return fmt.Errorf("migration %03d_%s was modified after being applied (checksum mismatch)",
+					migration.Version, migration.Name)
+			}
+		}
+
+		for _, migration := range pending {
+			if dryRun {
+				log.Printf("📝 [dry-run] Would apply migration %03d_%s:\n%s", migration.Version, migration.Name, migration.Up)
+				continue
+			}
+
+			err := runInTx(ctx, conn, migration.Up, `
+				INSERT INTO schema_migrations (version, name, checksum, applied_at)
+				VALUES ($1, $2, $3, NOW())
+			`, migration.Version, migration.Name, migration.Checksum)
+			if err != nil {
+				return fmt.Errorf("migration %03d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("✅ Migration %03d_%s applied", migration.Version, migration.Name)
+		}
+
+		return nil
+	})
+
+	return pending, err
+}
+
+// Down annule les `steps` dernières migrations appliquées
+func (m *Migrator) Down(ctx context.Context, steps int, dryRun bool) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be greater than 0")
+	}
+
+	var reverted []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn, !dryRun)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %03d_%s has no down script", migration.Version, migration.Name)
+			}
+
+			reverted = append(reverted, migration)
+			if dryRun {
+				log.Printf("📝 [dry-run] Would revert migration %03d_%s:\n%s", migration.Version, migration.Name, migration.Down)
+				continue
+			}
+
+			err := runInTx(ctx, conn, migration.Down, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("revert of migration %03d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("↩️  Migration %03d_%s reverted", migration.Version, migration.Name)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status retourne l'état de chaque migration connue
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	applied, err := m.applied(ctx, conn, false)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if a, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.AppliedAt
+			status.Modified = a.Checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// withLock exécute fn sur une connexion dédiée qui détient le verrou consultatif
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	log.Println("🔒 Waiting for migration lock...")
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	return fn(conn)
+}
+
+// applied lit schema_migrations, en créant la table si create est vrai
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn, create bool) (map[int]appliedMigration, error) {
+	if create {
+		_, err := conn.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INTEGER PRIMARY KEY,
+				name TEXT NOT NULL,
+				checksum TEXT NOT NULL,
+				applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+			)
+		`)
+		if err != nil {
+			return nil, fmt.Errorf("error creating schema_migrations: %w", err)
+		}
+	}
+
+	applied := make(map[int]appliedMigration)
+
+	var exists bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error checking schema_migrations: %w", err)
+	}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning schema_migrations: %w", err)
+		}
+		applied[version] = a
+	}
+
+	return applied, rows.Err()
+}
+
+// runInTx exécute le script puis la mise à jour de schema_migrations dans une même transaction
+func runInTx(ctx context.Context, conn *sql.Conn, script string, historyQuery string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, historyQuery, args...); err != nil {
+		return fmt.Errorf("error updating schema_migrations: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS email_verification_tokens;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS reservations;
+DROP TABLE IF EXISTS concerts;
+DROP TABLE IF EXISTS artists;
+DROP TABLE IF EXISTS activity_logs;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_activity_logs_action;
+DROP INDEX IF EXISTS idx_activity_logs_created_at;
+DROP INDEX IF EXISTS idx_activity_logs_user_id;
+DROP INDEX IF EXISTS idx_users_oauth;
+DROP INDEX IF EXISTS idx_email_verification_tokens_user_id;
+DROP INDEX IF EXISTS idx_password_reset_tokens_user_id;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS oauth_id,
+DROP COLUMN IF EXISTS oauth_provider;
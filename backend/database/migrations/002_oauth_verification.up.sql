@@ -0,0 +1,19 @@
+-- Migration: Add OAuth fields and auth indexes
+-- Version: 2.0
+-- Date: 2025-01-13
+-- Les tables password_reset_tokens, email_verification_tokens et activity_logs
+-- sont créées par 001_initial_schema.
+
+-- Add OAuth fields to users table
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS oauth_provider VARCHAR(50),
+ADD COLUMN IF NOT EXISTS oauth_id VARCHAR(255);
+
+-- Add indexes for performance
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user_id ON email_verification_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_users_oauth ON users(oauth_provider, oauth_id);
+
+CREATE INDEX IF NOT EXISTS idx_activity_logs_user_id ON activity_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_activity_logs_created_at ON activity_logs(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_activity_logs_action ON activity_logs(action);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_reservations_created_at;
+
+ALTER TABLE artists DROP COLUMN IF EXISTS like_count;
+ALTER TABLE artists DROP COLUMN IF EXISTS view_count;
@@ -0,0 +1,5 @@
+-- Migration: Compteurs analytics sur les artistes
+ALTER TABLE artists ADD COLUMN IF NOT EXISTS view_count INTEGER DEFAULT 0;
+ALTER TABLE artists ADD COLUMN IF NOT EXISTS like_count INTEGER DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_reservations_created_at ON reservations(created_at);
@@ -0,0 +1,10 @@
+DROP VIEW IF EXISTS reservation_stats;
+DROP TRIGGER IF EXISTS set_reservation_timestamp ON reservations;
+DROP FUNCTION IF EXISTS update_reservation_timestamp();
+DROP FUNCTION IF EXISTS cleanup_expired_reservations();
+
+DROP INDEX IF EXISTS idx_reservations_payment_status;
+DROP INDEX IF EXISTS idx_status;
+DROP INDEX IF EXISTS idx_stripe_intent;
+
+ALTER TABLE reservations DROP COLUMN IF EXISTS payment_status;
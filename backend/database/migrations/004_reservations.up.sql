@@ -1,33 +1,13 @@
 -- Migration pour les réservations et paiements Stripe
-CREATE TABLE IF NOT EXISTS reservations (
-    id SERIAL PRIMARY KEY,
-    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    concert_id INTEGER NOT NULL REFERENCES concerts(id) ON DELETE CASCADE,
-    
-    -- Type et quantité
-    ticket_type VARCHAR(20) DEFAULT 'standard', -- 'standard' ou 'vip'
-    quantity INTEGER NOT NULL CHECK (quantity > 0),
-    total_price DECIMAL(10, 2) NOT NULL,
-    
-    -- Statuts
-    status VARCHAR(20) DEFAULT 'pending', -- 'pending', 'paid', 'cancelled', 'expired'
-    payment_status VARCHAR(20) DEFAULT 'pending', -- 'pending', 'succeeded', 'failed', 'refunded'
-    
-    -- Identifiants Stripe
-    stripe_payment_intent_id VARCHAR(255),
-    stripe_payment_status VARCHAR(50),
-    
-    -- Timestamps
-    created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP DEFAULT NOW(),
-    expires_at TIMESTAMP DEFAULT NOW() + INTERVAL '15 minutes'
-);
+-- La table reservations est créée par 001_initial_schema : on ajoute ici
+-- le statut de paiement utilisé par le service de paiement et le dashboard.
+ALTER TABLE reservations
+ADD COLUMN IF NOT EXISTS payment_status VARCHAR(20) DEFAULT 'pending'; -- 'pending', 'succeeded', 'failed', 'refunded'
 
 -- Index pour performances
-CREATE INDEX IF NOT EXISTS idx_user_reservations ON reservations(user_id);
-CREATE INDEX IF NOT EXISTS idx_concert_reservations ON reservations(concert_id);
 CREATE INDEX IF NOT EXISTS idx_stripe_intent ON reservations(stripe_payment_intent_id);
 CREATE INDEX IF NOT EXISTS idx_status ON reservations(status, payment_status);
+CREATE INDEX IF NOT EXISTS idx_reservations_payment_status ON reservations(payment_status);
 
 -- Fonction pour nettoyer automatiquement les réservations expirées
 CREATE OR REPLACE FUNCTION cleanup_expired_reservations()
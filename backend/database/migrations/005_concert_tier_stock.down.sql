@@ -0,0 +1,9 @@
+ALTER TABLE reservations
+ALTER COLUMN ticket_type DROP NOT NULL,
+ALTER COLUMN ticket_type DROP DEFAULT;
+
+ALTER TABLE concerts
+DROP CONSTRAINT IF EXISTS chk_concerts_available_vip,
+DROP CONSTRAINT IF EXISTS chk_concerts_available_standard,
+ALTER COLUMN available_vip DROP NOT NULL,
+ALTER COLUMN available_standard DROP NOT NULL;
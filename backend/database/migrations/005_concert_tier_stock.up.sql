@@ -0,0 +1,18 @@
+-- Migration: Stock par tarif (standard / vip)
+-- Le service de paiement décrémente available_standard ou available_vip
+-- selon le ticket_type de la réservation : on garantit qu'ils ne passent jamais sous zéro.
+UPDATE concerts SET available_standard = 0 WHERE available_standard IS NULL OR available_standard < 0;
+UPDATE concerts SET available_vip = 0 WHERE available_vip IS NULL OR available_vip < 0;
+
+ALTER TABLE concerts
+ALTER COLUMN available_standard SET NOT NULL,
+ALTER COLUMN available_vip SET NOT NULL,
+ADD CONSTRAINT chk_concerts_available_standard CHECK (available_standard >= 0),
+ADD CONSTRAINT chk_concerts_available_vip CHECK (available_vip >= 0);
+
+-- Chaque réservation porte un tarif, nécessaire pour rendre le stock au bon endroit
+UPDATE reservations SET ticket_type = 'standard' WHERE ticket_type IS NULL;
+
+ALTER TABLE reservations
+ALTER COLUMN ticket_type SET DEFAULT 'standard',
+ALTER COLUMN ticket_type SET NOT NULL;
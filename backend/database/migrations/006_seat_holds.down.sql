@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_reservations_pending_expiry;
+
+-- Rendre les places bloquées au stock avant de supprimer les colonnes
+UPDATE concerts
+SET available_standard = available_standard + held_standard,
+    available_vip = available_vip + held_vip;
+
+ALTER TABLE concerts
+DROP COLUMN IF EXISTS held_vip,
+DROP COLUMN IF EXISTS held_standard;
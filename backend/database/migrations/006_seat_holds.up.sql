@@ -0,0 +1,30 @@
+-- Migration: Places bloquées pendant le checkout
+-- Une réservation 'pending' déplace ses places de available_<tarif> vers held_<tarif>
+-- jusqu'au paiement (vente) ou à l'expiration (retour au stock).
+ALTER TABLE concerts
+ADD COLUMN IF NOT EXISTS held_standard INTEGER NOT NULL DEFAULT 0 CHECK (held_standard >= 0),
+ADD COLUMN IF NOT EXISTS held_vip INTEGER NOT NULL DEFAULT 0 CHECK (held_vip >= 0);
+
+-- Les réservations déjà en attente n'avaient rien bloqué : on expire celles qui
+-- sont dépassées et on bloque les autres dans la limite du stock restant.
+UPDATE reservations r
+SET status = 'expired', updated_at = NOW()
+WHERE r.status = 'pending' AND r.expires_at < NOW();
+
+WITH pending AS (
+    SELECT concert_id,
+           COALESCE(SUM(quantity) FILTER (WHERE ticket_type = 'standard'), 0) AS standard,
+           COALESCE(SUM(quantity) FILTER (WHERE ticket_type = 'vip'), 0) AS vip
+    FROM reservations
+    WHERE status = 'pending'
+    GROUP BY concert_id
+)
+UPDATE concerts c
+SET available_standard = c.available_standard - LEAST(p.standard, c.available_standard),
+    held_standard = LEAST(p.standard, c.available_standard),
+    available_vip = c.available_vip - LEAST(p.vip, c.available_vip),
+    held_vip = LEAST(p.vip, c.available_vip)
+FROM pending p
+WHERE c.id = p.concert_id;
+
+CREATE INDEX IF NOT EXISTS idx_reservations_pending_expiry ON reservations(expires_at) WHERE status = 'pending';
@@ -0,0 +1,14 @@
+-- Migration: Remboursements Stripe
+CREATE TABLE IF NOT EXISTS refunds (
+    id SERIAL PRIMARY KEY,
+    reservation_id INTEGER NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+    stripe_refund_id VARCHAR(255) NOT NULL UNIQUE,
+    amount BIGINT NOT NULL CHECK (amount > 0), -- en centimes
+    currency VARCHAR(3) NOT NULL DEFAULT 'eur',
+    status VARCHAR(30) NOT NULL, -- 'pending', 'requires_action', 'succeeded', 'failed', 'canceled'
+    reason VARCHAR(50),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_reservation_id ON refunds(reservation_id);
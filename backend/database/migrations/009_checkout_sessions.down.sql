@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_reservations_checkout_session;
+ALTER TABLE reservations DROP COLUMN IF EXISTS stripe_checkout_session_id;
@@ -0,0 +1,5 @@
+-- Migration: Lien entre réservations et sessions Stripe Checkout
+-- Une session peut porter plusieurs réservations (une par tarif)
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS stripe_checkout_session_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_reservations_checkout_session ON reservations(stripe_checkout_session_id);
@@ -0,0 +1,23 @@
+DROP VIEW IF EXISTS reservation_stats;
+
+ALTER TABLE reservations
+ALTER COLUMN total_price TYPE DECIMAL(10,2) USING total_price / 100.0,
+DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE concerts
+DROP CONSTRAINT IF EXISTS chk_concerts_vip_price,
+DROP CONSTRAINT IF EXISTS chk_concerts_standard_price,
+ALTER COLUMN vip_price TYPE DECIMAL(10,2) USING vip_price / 100.0,
+ALTER COLUMN standard_price TYPE DECIMAL(10,2) USING standard_price / 100.0,
+DROP COLUMN IF EXISTS currency;
+
+CREATE OR REPLACE VIEW reservation_stats AS
+SELECT 
+    DATE(created_at) as date,
+    COUNT(*) as total_reservations,
+    COUNT(CASE WHEN status = 'paid' THEN 1 END) as paid_reservations,
+    SUM(CASE WHEN status = 'paid' THEN total_price ELSE 0 END) as revenue,
+    AVG(CASE WHEN status = 'paid' THEN total_price END) as avg_order_value
+FROM reservations
+GROUP BY DATE(created_at)
+ORDER BY date DESC;
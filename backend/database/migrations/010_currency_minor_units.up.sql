@@ -0,0 +1,35 @@
+-- Migration: Devise par concert et montants en unités mineures (entiers)
+-- Les prix DECIMAL en euros deviennent des BIGINT en centimes, comme chez Stripe.
+-- Les devises sans décimale (JPY...) sont stockées en unités entières.
+
+-- La vue reservation_stats dépend de total_price : on la recrée après conversion
+DROP VIEW IF EXISTS reservation_stats;
+
+ALTER TABLE concerts
+ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'eur',
+ALTER COLUMN standard_price TYPE BIGINT USING ROUND(standard_price * 100),
+ALTER COLUMN vip_price TYPE BIGINT USING ROUND(vip_price * 100),
+ADD CONSTRAINT chk_concerts_standard_price CHECK (standard_price >= 0),
+ADD CONSTRAINT chk_concerts_vip_price CHECK (vip_price >= 0);
+
+-- La devise d'une réservation est figée au moment de l'achat
+ALTER TABLE reservations
+ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'eur',
+ALTER COLUMN total_price TYPE BIGINT USING ROUND(total_price * 100);
+
+UPDATE reservations r
+SET currency = c.currency
+FROM concerts c
+WHERE r.concert_id = c.id;
+
+CREATE OR REPLACE VIEW reservation_stats AS
+SELECT 
+    DATE(created_at) as date,
+    currency,
+    COUNT(*) as total_reservations,
+    COUNT(CASE WHEN status = 'paid' THEN 1 END) as paid_reservations,
+    SUM(CASE WHEN status = 'paid' THEN total_price ELSE 0 END) as revenue,
+    AVG(CASE WHEN status = 'paid' THEN total_price END) as avg_order_value
+FROM reservations
+GROUP BY DATE(created_at), currency
+ORDER BY date DESC;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_reservations_promo_code;
+
+ALTER TABLE reservations
+DROP COLUMN IF EXISTS discount_amount,
+DROP COLUMN IF EXISTS promo_code_id;
+
+DROP TABLE IF EXISTS promo_codes;
@@ -0,0 +1,30 @@
+-- Migration: Codes promo (préventes, réductions)
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE, -- toujours en majuscules
+    description TEXT,
+    discount_type VARCHAR(10) NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    -- 'percent' : pourcentage (1-100) ; 'fixed' : montant en unités mineures de currency
+    discount_value BIGINT NOT NULL CHECK (discount_value > 0),
+    currency VARCHAR(3), -- obligatoire pour 'fixed'
+    concert_id INTEGER REFERENCES concerts(id) ON DELETE CASCADE, -- NULL = tous les concerts
+    max_redemptions INTEGER CHECK (max_redemptions > 0), -- NULL = illimité
+    max_redemptions_per_user INTEGER CHECK (max_redemptions_per_user > 0),
+    min_quantity INTEGER NOT NULL DEFAULT 1 CHECK (min_quantity > 0),
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_promo_codes_percent CHECK (discount_type <> 'percent' OR discount_value <= 100),
+    CONSTRAINT chk_promo_codes_fixed_currency CHECK (discount_type <> 'fixed' OR currency IS NOT NULL),
+    CONSTRAINT chk_promo_codes_window CHECK (starts_at IS NULL OR ends_at IS NULL OR starts_at < ends_at)
+);
+
+-- Une réservation 'pending' ou 'paid' compte comme une utilisation du code :
+-- une réservation expirée, annulée ou remboursée le libère automatiquement.
+ALTER TABLE reservations
+ADD COLUMN IF NOT EXISTS promo_code_id INTEGER REFERENCES promo_codes(id) ON DELETE SET NULL,
+ADD COLUMN IF NOT EXISTS discount_amount BIGINT NOT NULL DEFAULT 0; -- en unités mineures
+
+CREATE INDEX IF NOT EXISTS idx_reservations_promo_code ON reservations(promo_code_id, status);
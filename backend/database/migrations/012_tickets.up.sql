@@ -0,0 +1,19 @@
+-- Migration: Billets nominatifs (un par place) émis au paiement
+CREATE TABLE IF NOT EXISTS tickets (
+    id SERIAL PRIMARY KEY,
+    reservation_id INTEGER NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    concert_id INTEGER NOT NULL REFERENCES concerts(id) ON DELETE CASCADE,
+    ticket_type VARCHAR(20) NOT NULL,
+    code VARCHAR(32) NOT NULL UNIQUE, -- identifiant aléatoire signé dans le QR code
+    status VARCHAR(20) NOT NULL DEFAULT 'valid', -- 'valid', 'used', 'void' (réservation remboursée)
+    used_at TIMESTAMP,
+    scanned_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_tickets_status CHECK (status IN ('valid', 'used', 'void'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_tickets_reservation_id ON tickets(reservation_id);
+CREATE INDEX IF NOT EXISTS idx_tickets_user_id ON tickets(user_id);
+CREATE INDEX IF NOT EXISTS idx_tickets_concert_status ON tickets(concert_id, status);
@@ -0,0 +1,26 @@
+-- Migration: Sessions serveur et refresh tokens (rotation + détection de réutilisation)
+-- Une session = une famille de refresh tokens. Révoquer la session invalide
+-- aussi les access tokens qui portent son identifiant (claim "sid").
+CREATE TABLE IF NOT EXISTS auth_sessions (
+    id VARCHAR(64) PRIMARY KEY, -- identifiant aléatoire, repris dans le claim "sid" du JWT
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP,
+    revoke_reason VARCHAR(50) -- 'logout', 'logout_all', 'password_reset', 'refresh_token_reuse'
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_user_id ON auth_sessions(user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(64) NOT NULL REFERENCES auth_sessions(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 hex : le token en clair n'est jamais stocké
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP, -- renseigné à la rotation ; une seconde présentation = réutilisation
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id);
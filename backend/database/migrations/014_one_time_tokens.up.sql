@@ -0,0 +1,22 @@
+-- Migration: Jetons à usage unique (vérification email, reset mot de passe)
+-- Les jetons ne sont plus stockés en clair : seul leur SHA-256 est conservé.
+-- Les anciennes tables contenaient des jetons devinables en clair : elles sont
+-- supprimées, les liens encore en circulation deviennent invalides.
+CREATE TABLE IF NOT EXISTS one_time_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL, -- adresse à laquelle le jeton a été envoyé (limitation par email)
+    purpose VARCHAR(30) NOT NULL, -- 'email_verification', 'password_reset'
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_one_time_tokens_purpose CHECK (purpose IN ('email_verification', 'password_reset'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_one_time_tokens_user_purpose ON one_time_tokens(user_id, purpose);
+CREATE INDEX IF NOT EXISTS idx_one_time_tokens_email_purpose ON one_time_tokens(LOWER(email), purpose, created_at);
+
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS email_verification_tokens;
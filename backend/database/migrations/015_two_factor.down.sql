@@ -0,0 +1,12 @@
+DELETE FROM one_time_tokens WHERE purpose = 'login_challenge';
+ALTER TABLE one_time_tokens DROP CONSTRAINT IF EXISTS chk_one_time_tokens_purpose;
+ALTER TABLE one_time_tokens ADD CONSTRAINT chk_one_time_tokens_purpose
+    CHECK (purpose IN ('email_verification', 'password_reset'));
+
+DROP TABLE IF EXISTS two_factor_recovery_codes;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS totp_last_step,
+DROP COLUMN IF EXISTS totp_enabled_at,
+DROP COLUMN IF EXISTS totp_enabled,
+DROP COLUMN IF EXISTS totp_secret;
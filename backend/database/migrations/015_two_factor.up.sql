@@ -0,0 +1,20 @@
+-- Migration: Double authentification TOTP (RFC 6238) et codes de secours
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS totp_secret TEXT, -- secret chiffré (AES-GCM), renseigné dès l'enrôlement
+ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE, -- vrai une fois le premier code vérifié
+ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP,
+ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0; -- dernier pas de 30 s accepté (anti-rejeu)
+
+CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL, -- SHA-256 du code normalisé
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
+
+-- Le jeton de challenge de la connexion en deux étapes réutilise one_time_tokens
+ALTER TABLE one_time_tokens DROP CONSTRAINT IF EXISTS chk_one_time_tokens_purpose;
+ALTER TABLE one_time_tokens ADD CONSTRAINT chk_one_time_tokens_purpose
+    CHECK (purpose IN ('email_verification', 'password_reset', 'login_challenge'));
@@ -0,0 +1,31 @@
+-- Migration: Identités externes (Google, GitHub, fournisseurs OIDC)
+-- Un compte est retrouvé par (fournisseur, sujet) et non plus par email :
+-- une adresse changée chez le fournisseur ne crée pas de doublon, et une
+-- adresse réutilisée ailleurs ne donne pas accès au compte.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL, -- claim "sub" OIDC ou identifiant numérique GitHub
+    email VARCHAR(255), -- dernière adresse communiquée par le fournisseur (informative)
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMP,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- Reprise des identifiants déjà connus (colonnes conservées mais plus lues)
+INSERT INTO user_identities (user_id, provider, subject, email)
+SELECT id, 'google', google_id, email FROM users WHERE google_id IS NOT NULL AND google_id <> ''
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_identities (user_id, provider, subject, email)
+SELECT id, 'github', github_id, email FROM users WHERE github_id IS NOT NULL AND github_id <> ''
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_identities (user_id, provider, subject, email)
+SELECT id, LOWER(oauth_provider), oauth_id, email FROM users
+WHERE oauth_provider IS NOT NULL AND oauth_provider <> '' AND oauth_id IS NOT NULL AND oauth_id <> ''
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_identity_link_requests_user_id;
+DROP TABLE IF EXISTS identity_link_requests;
@@ -0,0 +1,19 @@
+-- Migration: Rattachement explicite d'une identité externe à un compte connecté
+-- Une demande suit trois étapes : créée par l'utilisateur connecté (jeton de
+-- démarrage), complétée au retour du fournisseur (sujet + jeton de confirmation
+-- remis au seul navigateur qui a fait l'aller-retour), puis confirmée par ce
+-- navigateur avec la session du même utilisateur.
+CREATE TABLE IF NOT EXISTS identity_link_requests (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    start_token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 du jeton de démarrage
+    confirm_token_hash CHAR(64) UNIQUE, -- SHA-256 du jeton de confirmation
+    subject VARCHAR(255), -- renseigné au retour du fournisseur
+    email VARCHAR(255),
+    expires_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_identity_link_requests_user_id ON identity_link_requests(user_id);
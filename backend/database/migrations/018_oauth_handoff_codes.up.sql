@@ -0,0 +1,15 @@
+-- Migration: Code de passation après connexion externe
+-- Le callback OAuth ne met plus de JWT dans l'URL : il remet au front (ou à
+-- l'app Android via deep link) un code à usage unique, valable quelques
+-- secondes, que le client échange contre ses tokens sur POST /api/auth/exchange.
+CREATE TABLE IF NOT EXISTS oauth_handoff_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    code_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 du code
+    state_hash CHAR(64) NOT NULL, -- SHA-256 du state choisi par le client
+    code_challenge VARCHAR(128) NOT NULL, -- PKCE S256 du client (RFC 7636)
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_login_lockouts_key;
+DROP TABLE IF EXISTS login_lockouts;
+DROP INDEX IF EXISTS idx_login_attempts_ip;
+DROP INDEX IF EXISTS idx_login_attempts_email;
+DROP TABLE IF EXISTS login_attempts;
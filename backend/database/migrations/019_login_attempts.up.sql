@@ -0,0 +1,27 @@
+-- Migration: Protection contre la force brute sur /auth/login
+-- Chaque tentative est enregistrée par email et par IP ; au-delà d'un seuil
+-- d'échecs, le compte (ou l'IP) est verrouillé temporairement.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL, -- normalisé en minuscules, compte existant ou non
+    ip_address VARCHAR(64) NOT NULL,
+    success BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts(email, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip_address, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    id SERIAL PRIMARY KEY,
+    scope VARCHAR(10) NOT NULL CHECK (scope IN ('account', 'ip')),
+    lock_key VARCHAR(255) NOT NULL, -- email (minuscules) ou adresse IP
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL, -- compte concerné, s'il existe
+    failures INTEGER NOT NULL,
+    locked_until TIMESTAMP NOT NULL,
+    released_at TIMESTAMP, -- déverrouillage manuel par un admin
+    released_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_lockouts_key ON login_lockouts(scope, lock_key, created_at DESC);
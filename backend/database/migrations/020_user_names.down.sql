@@ -0,0 +1,3 @@
+ALTER TABLE users
+DROP COLUMN IF EXISTS last_name,
+DROP COLUMN IF EXISTS first_name;
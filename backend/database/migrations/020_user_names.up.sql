@@ -0,0 +1,14 @@
+-- Migration: Prénom et nom des utilisateurs
+-- L'inscription et la connexion externe renseignent first_name / last_name ;
+-- le schéma initial n'avait qu'une colonne name, reprise ici pour les comptes existants.
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS first_name VARCHAR(100),
+ADD COLUMN IF NOT EXISTS last_name VARCHAR(100);
+
+UPDATE users
+SET first_name = COALESCE(NULLIF(SPLIT_PART(TRIM(name), ' ', 1), ''), ''),
+    last_name = CASE
+        WHEN POSITION(' ' IN TRIM(name)) > 0 THEN TRIM(SUBSTRING(TRIM(name) FROM POSITION(' ' IN TRIM(name)) + 1))
+        ELSE ''
+    END
+WHERE first_name IS NULL AND name IS NOT NULL;
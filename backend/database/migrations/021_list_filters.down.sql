@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_reservations_created_at_id;
+DROP INDEX IF EXISTS idx_users_created_at_id;
+DROP INDEX IF EXISTS idx_concerts_city;
+DROP INDEX IF EXISTS idx_concerts_date_id;
+DROP INDEX IF EXISTS idx_artists_genre;
+
+ALTER TABLE artists DROP COLUMN IF EXISTS genre;
@@ -0,0 +1,11 @@
+-- Migration: Filtres et pagination des listes
+-- Le genre n'existait que dans le catalogue de démonstration ; les index
+-- suivent les tris par défaut (avec l'ID en départage) et les filtres courants.
+ALTER TABLE artists
+ADD COLUMN IF NOT EXISTS genre VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_artists_genre ON artists (LOWER(genre));
+CREATE INDEX IF NOT EXISTS idx_concerts_date_id ON concerts (date, id);
+CREATE INDEX IF NOT EXISTS idx_concerts_city ON concerts (LOWER(city));
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_reservations_created_at_id ON reservations (created_at, id);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_concerts_city_trgm;
+DROP INDEX IF EXISTS idx_concerts_venue_trgm;
+DROP INDEX IF EXISTS idx_artists_top_tracks_trgm;
+DROP INDEX IF EXISTS idx_artists_members_trgm;
+DROP INDEX IF EXISTS idx_artists_name_trgm;
+
+ALTER TABLE artists DROP COLUMN IF EXISTS top_tracks;
+
+DROP FUNCTION IF EXISTS search_fold(text);
+-- Les extensions pg_trgm et unaccent sont conservées : d'autres objets peuvent en dépendre
@@ -0,0 +1,20 @@
+-- Migration: Recherche tolérante aux accents et aux fautes de frappe
+-- pg_trgm fournit word_similarity et l'opérateur <% (indexable en GIN),
+-- unaccent le repli des accents. unaccent() n'étant pas IMMUTABLE, search_fold
+-- fixe son dictionnaire pour pouvoir être indexée.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE OR REPLACE FUNCTION search_fold(text) RETURNS text
+LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+AS $$ SELECT lower(public.unaccent('public.unaccent'::regdictionary, $1)) $$;
+
+-- Titres phares des artistes, jusqu'ici présents seulement dans le catalogue de démonstration
+ALTER TABLE artists
+ADD COLUMN IF NOT EXISTS top_tracks TEXT NOT NULL DEFAULT '[]';
+
+CREATE INDEX IF NOT EXISTS idx_artists_name_trgm ON artists USING GIN (search_fold(name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_artists_members_trgm ON artists USING GIN (search_fold(COALESCE(members, '')) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_artists_top_tracks_trgm ON artists USING GIN (search_fold(top_tracks) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_concerts_venue_trgm ON concerts USING GIN (search_fold(venue) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_concerts_city_trgm ON concerts USING GIN (search_fold(city) gin_trgm_ops);
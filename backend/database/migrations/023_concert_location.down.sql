@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_concerts_lat_lng;
+DROP INDEX IF EXISTS idx_concerts_earth;
+
+ALTER TABLE concerts DROP CONSTRAINT IF EXISTS concerts_location_check;
+ALTER TABLE concerts
+DROP COLUMN IF EXISTS longitude,
+DROP COLUMN IF EXISTS latitude;
+-- Les extensions cube et earthdistance sont conservées : d'autres objets peuvent en dépendre
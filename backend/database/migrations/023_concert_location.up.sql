@@ -0,0 +1,21 @@
+-- Migration: Position des concerts pour la recherche par rayon et la carte
+-- earthdistance (avec cube) calcule les distances sur la sphère terrestre ;
+-- l'index GiST sur ll_to_earth sert les recherches par rayon (earth_box),
+-- l'index B-tree les recherches par zone (bounding box).
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+ALTER TABLE concerts
+ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+ALTER TABLE concerts
+ADD CONSTRAINT concerts_location_check CHECK (
+    (latitude IS NULL AND longitude IS NULL)
+    OR (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+);
+
+CREATE INDEX IF NOT EXISTS idx_concerts_earth ON concerts USING GIST (ll_to_earth(latitude, longitude))
+WHERE latitude IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_concerts_lat_lng ON concerts (latitude, longitude)
+WHERE latitude IS NOT NULL;
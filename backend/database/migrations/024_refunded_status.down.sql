@@ -0,0 +1,3 @@
+UPDATE reservations
+SET status = 'cancelled', updated_at = NOW()
+WHERE status = 'refunded';
@@ -0,0 +1,6 @@
+-- Migration: Statut 'refunded' des réservations
+-- Une réservation remboursée passait en 'cancelled', comme un paiement abandonné
+-- ou échoué : on la distingue désormais par son propre statut.
+UPDATE reservations
+SET status = 'refunded', updated_at = NOW()
+WHERE status = 'cancelled' AND payment_status = 'refunded';
@@ -0,0 +1,2 @@
+DELETE FROM refunds WHERE stripe_refund_id IS NULL;
+ALTER TABLE refunds ALTER COLUMN stripe_refund_id SET NOT NULL;
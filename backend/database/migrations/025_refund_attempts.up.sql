@@ -0,0 +1,5 @@
+-- Migration: Tentatives de remboursement
+-- RefundReservation enregistre chaque tentative avant d'appeler Stripe : l'id de
+-- la ligne sert de clé d'idempotence, et stripe_refund_id reste vide tant que
+-- Stripe n'a pas répondu.
+ALTER TABLE refunds ALTER COLUMN stripe_refund_id DROP NOT NULL;
@@ -0,0 +1,59 @@
+package database_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"groupie-backend/database"
+	"groupie-backend/database/dbtest"
+)
+
+// TestUserNamesBackfill rejoue le découpage de name en first_name / last_name
+// de la migration 020 sur des comptes d'avant la migration.
+func TestUserNamesBackfill(t *testing.T) {
+	db := dbtest.Open(t)
+
+	migrations, err := database.LoadMigrations(os.DirFS("."), "migrations")
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	var script string
+	for _, m := range migrations {
+		if m.Version == 20 {
+			script = m.Up
+		}
+	}
+	if script == "" {
+		t.Fatal("migration 020 not found")
+	}
+
+	tests := []struct {
+		name, first, last string
+	}{
+		{"Ada Lovelace", "Ada", "Lovelace"},
+		{"Cher", "Cher", ""},
+		{"  Jean  de La Fontaine ", "Jean", "de La Fontaine"},
+		{"", "", ""},
+	}
+	for i, tt := range tests {
+		_, err := db.Exec(`INSERT INTO users (email, name) VALUES ($1, $2)`, fmt.Sprintf("user%d@example.com", i), tt.name)
+		if err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+	}
+
+	if _, err := db.Exec(script); err != nil {
+		t.Fatalf("apply migration 020: %v", err)
+	}
+
+	for i, tt := range tests {
+		var first, last string
+		if err := db.QueryRow(`SELECT first_name, last_name FROM users WHERE email = $1`, fmt.Sprintf("user%d@example.com", i)).Scan(&first, &last); err != nil {
+			t.Fatalf("read user: %v", err)
+		}
+		if first != tt.first || last != tt.last {
+			t.Errorf("%q split into (%q, %q), want (%q, %q)", tt.name, first, last, tt.first, tt.last)
+		}
+	}
+}
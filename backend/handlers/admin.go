@@ -2,16 +2,78 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
-	"groupie-backend/database"
 	"groupie-backend/middleware"
 	"groupie-backend/models"
+	"groupie-backend/services"
 	"groupie-backend/storage"
 )
 
+// decodeAdminBody lit le corps JSON d'un formulaire de l'admin ; un champ du
+// mauvais type est signalé comme une erreur de validation de ce champ
+func decodeAdminBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		writeFieldErrors(w, models.FieldErrors{typeErr.Field: "must be a " + typeErr.Type.String()})
+	case errors.As(err, &timeErr):
+		writeFieldErrors(w, models.FieldErrors{"date": "must be an RFC 3339 date (2026-06-21T20:00:00Z)"})
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+	}
+	return false
+}
+
+// writeFieldErrors répond 400 avec le message d'erreur de chaque champ invalide
+func writeFieldErrors(w http.ResponseWriter, fields models.FieldErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Validation failed",
+		"fields": fields,
+	})
+}
+
+// writeAdminError traduit les erreurs des services d'administration
+func writeAdminError(w http.ResponseWriter, err error, action string) {
+	var fields models.FieldErrors
+	if errors.As(err, &fields) {
+		writeFieldErrors(w, fields)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case errors.Is(err, services.ErrArtistNotFound), errors.Is(err, services.ErrConcertNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrArtistHasBookings), errors.Is(err, services.ErrConcertHasBookings):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	default:
+		log.Printf("❌ Error (%s): %v", action, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to " + action})
+	}
+}
+
+// ========= ARTISTES =========
+
 func AdminGetArtists(w http.ResponseWriter, r *http.Request) {
 	claims, ok := middleware.GetUserFromContext(r)
 	if !ok || claims.Role != "admin" {
@@ -21,38 +83,24 @@ func AdminGetArtists(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := database.DB.Query(`
-		SELECT id, name, image, members, creation_date, first_album, locations, concert_dates, relations
-		FROM artists
-		ORDER BY name
-	`)
+	q, err := services.ArtistListSpec.Parse(r.URL.Query())
+	var fields models.FieldErrors
+	if errors.As(err, &fields) {
+		writeFieldErrors(w, fields)
+		return
+	}
+
+	page, err := services.ListAdminArtists(q)
 	if err != nil {
+		log.Printf("❌ Error listing artists: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
-	defer rows.Close()
-
-	artists := []models.Artist{}
-	for rows.Next() {
-		var artist models.Artist
-		var membersJSON, locationsJSON, datesJSON, relationsJSON string
-		err := rows.Scan(&artist.ID, &artist.Name, &artist.Image, &membersJSON,
-			&artist.CreationDate, &artist.FirstAlbum, &locationsJSON,
-			&datesJSON, &relationsJSON)
-		if err != nil {
-			continue
-		}
-		json.Unmarshal([]byte(membersJSON), &artist.Members)
-		json.Unmarshal([]byte(locationsJSON), &artist.Locations)
-		json.Unmarshal([]byte(datesJSON), &artist.ConcertDates)
-		json.Unmarshal([]byte(relationsJSON), &artist.Relations)
-		artists = append(artists, artist)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(artists)
+	json.NewEncoder(w).Encode(page)
 }
 
 func AdminCreateArtist(w http.ResponseWriter, r *http.Request) {
@@ -65,35 +113,21 @@ func AdminCreateArtist(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var artist models.Artist
-	if err := json.NewDecoder(r.Body).Decode(&artist); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+	if !decodeAdminBody(w, r, &artist) {
 		return
 	}
 
-	membersJSON, _ := json.Marshal(artist.Members)
-	locationsJSON, _ := json.Marshal(artist.Locations)
-	datesJSON, _ := json.Marshal(artist.ConcertDates)
-	relationsJSON, _ := json.Marshal(artist.Relations)
-
-	err := database.DB.QueryRow(`
-		INSERT INTO artists (name, image, members, creation_date, first_album, locations, concert_dates, relations)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id
-	`, artist.Name, artist.Image, string(membersJSON), artist.CreationDate,
-		artist.FirstAlbum, string(locationsJSON), string(datesJSON), string(relationsJSON)).Scan(&artist.ID)
-
+	created, err := services.CreateArtist(artist)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create artist"})
+		writeAdminError(w, err, "create artist")
 		return
 	}
 
+	LogActivity(int(claims.UserID), "create_artist", fmt.Sprintf("Artist #%d %s created", created.ID, created.Name), r.RemoteAddr)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(artist)
+	json.NewEncoder(w).Encode(created)
 }
 
 func AdminUpdateArtist(w http.ResponseWriter, r *http.Request) {
@@ -105,8 +139,7 @@ func AdminUpdateArtist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -115,36 +148,20 @@ func AdminUpdateArtist(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var artist models.Artist
-	if err := json.NewDecoder(r.Body).Decode(&artist); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+	if !decodeAdminBody(w, r, &artist) {
 		return
 	}
 
-	membersJSON, _ := json.Marshal(artist.Members)
-	locationsJSON, _ := json.Marshal(artist.Locations)
-	datesJSON, _ := json.Marshal(artist.ConcertDates)
-	relationsJSON, _ := json.Marshal(artist.Relations)
-
-	_, err = database.DB.Exec(`
-		UPDATE artists 
-		SET name=$1, image=$2, members=$3, creation_date=$4, first_album=$5, 
-		    locations=$6, concert_dates=$7, relations=$8
-		WHERE id=$9
-	`, artist.Name, artist.Image, string(membersJSON), artist.CreationDate,
-		artist.FirstAlbum, string(locationsJSON), string(datesJSON), string(relationsJSON), id)
-
+	updated, err := services.UpdateArtist(id, artist)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update artist"})
+		writeAdminError(w, err, "update artist")
 		return
 	}
 
-	artist.ID = id
+	LogActivity(int(claims.UserID), "update_artist", fmt.Sprintf("Artist #%d %s updated", id, updated.Name), r.RemoteAddr)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(artist)
+	json.NewEncoder(w).Encode(updated)
 }
 
 func AdminDeleteArtist(w http.ResponseWriter, r *http.Request) {
@@ -156,8 +173,7 @@ func AdminDeleteArtist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -165,18 +181,19 @@ func AdminDeleteArtist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = database.DB.Exec("DELETE FROM artists WHERE id=$1", id)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete artist"})
+	if err := services.DeleteArtist(id); err != nil {
+		writeAdminError(w, err, "delete artist")
 		return
 	}
 
+	LogActivity(int(claims.UserID), "delete_artist", fmt.Sprintf("Artist #%d deleted", id), r.RemoteAddr)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Artist deleted successfully"})
 }
 
+// ========= CONCERTS =========
+
 func AdminGetConcerts(w http.ResponseWriter, r *http.Request) {
 	claims, ok := middleware.GetUserFromContext(r)
 	if !ok || claims.Role != "admin" {
@@ -186,37 +203,24 @@ func AdminGetConcerts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := database.DB.Query(`
-		SELECT c.id, c.artist_id, c.location, c.date, c.available_tickets, c.price,
-		       a.name as artist_name, a.image as artist_image
-		FROM concerts c
-		LEFT JOIN artists a ON c.artist_id = a.id
-		ORDER BY c.date DESC
-	`)
+	q, err := services.ConcertListSpec.Parse(r.URL.Query())
+	var fields models.FieldErrors
+	if errors.As(err, &fields) {
+		writeFieldErrors(w, fields)
+		return
+	}
+
+	page, err := services.ListAdminConcerts(q)
 	if err != nil {
+		log.Printf("❌ Error listing concerts: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
-	defer rows.Close()
-
-	concerts := []models.Concert{}
-	for rows.Next() {
-		var concert models.Concert
-		var artistName, artistImage string
-		err := rows.Scan(&concert.ID, &concert.ArtistID, &concert.Location, &concert.Date,
-			&concert.AvailableTickets, &concert.Price, &artistName, &artistImage)
-		if err != nil {
-			continue
-		}
-		concert.ArtistName = artistName
-		concert.ArtistImage = artistImage
-		concerts = append(concerts, concert)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(concerts)
+	json.NewEncoder(w).Encode(page)
 }
 
 func AdminCreateConcert(w http.ResponseWriter, r *http.Request) {
@@ -229,29 +233,21 @@ func AdminCreateConcert(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var concert models.Concert
-	if err := json.NewDecoder(r.Body).Decode(&concert); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+	if !decodeAdminBody(w, r, &concert) {
 		return
 	}
 
-	err := database.DB.QueryRow(`
-		INSERT INTO concerts (artist_id, location, date, available_tickets, price)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-	`, concert.ArtistID, concert.Location, concert.Date, concert.AvailableTickets, concert.Price).Scan(&concert.ID)
-
+	created, err := services.CreateConcert(concert)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create concert"})
+		writeAdminError(w, err, "create concert")
 		return
 	}
 
+	LogActivity(int(claims.UserID), "create_concert", fmt.Sprintf("Concert #%d %s created", created.ID, created.Name), r.RemoteAddr)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(concert)
+	json.NewEncoder(w).Encode(created)
 }
 
 func AdminUpdateConcert(w http.ResponseWriter, r *http.Request) {
@@ -263,8 +259,7 @@ func AdminUpdateConcert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -273,32 +268,111 @@ func AdminUpdateConcert(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var concert models.Concert
-	if err := json.NewDecoder(r.Body).Decode(&concert); err != nil {
+	if !decodeAdminBody(w, r, &concert) {
+		return
+	}
+
+	updated, err := services.UpdateConcert(id, concert)
+	if err != nil {
+		writeAdminError(w, err, "update concert")
+		return
+	}
+
+	LogActivity(int(claims.UserID), "update_concert", fmt.Sprintf("Concert #%d %s updated", id, updated.Name), r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func AdminDeleteConcert(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok || claims.Role != "admin" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Admin access required"})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid concert ID"})
+		return
+	}
+
+	if err := services.DeleteConcert(id); err != nil {
+		writeAdminError(w, err, "delete concert")
+		return
+	}
+
+	LogActivity(int(claims.UserID), "delete_concert", fmt.Sprintf("Concert #%d deleted", id), r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Concert deleted successfully"})
+}
+
+// ========= PAIEMENTS ET UTILISATEURS =========
+
+func AdminGetPayments(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok || claims.Role != "admin" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Admin access required"})
 		return
 	}
 
-	_, err = database.DB.Exec(`
-		UPDATE concerts 
-		SET artist_id=$1, location=$2, date=$3, available_tickets=$4, price=$5
-		WHERE id=$6
-	`, concert.ArtistID, concert.Location, concert.Date, concert.AvailableTickets, concert.Price, id)
+	q, err := services.AdminPaymentListSpec.Parse(r.URL.Query())
+	var fields models.FieldErrors
+	if errors.As(err, &fields) {
+		writeFieldErrors(w, fields)
+		return
+	}
 
+	page, err := services.ListAdminPayments(q)
 	if err != nil {
+		log.Printf("❌ Error listing payments: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update concert"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
 
-	concert.ID = id
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(concert)
+	json.NewEncoder(w).Encode(page)
 }
 
-func AdminDeleteConcert(w http.ResponseWriter, r *http.Request) {
+func AdminGetUsers(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok || claims.Role != "admin" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Admin access required"})
+		return
+	}
+
+	q, err := services.AdminUserListSpec.Parse(r.URL.Query())
+	var fields models.FieldErrors
+	if errors.As(err, &fields) {
+		writeFieldErrors(w, fields)
+		return
+	}
+
+	page, err := services.ListAdminUsers(q)
+	if err != nil {
+		log.Printf("❌ Error listing users: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func AdminRefundReservation(w http.ResponseWriter, r *http.Request) {
 	claims, ok := middleware.GetUserFromContext(r)
 	if !ok || claims.Role != "admin" {
 		w.Header().Set("Content-Type", "application/json")
@@ -312,23 +386,45 @@ func AdminDeleteConcert(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid concert ID"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid reservation ID"})
 		return
 	}
 
-	_, err = database.DB.Exec("DELETE FROM concerts WHERE id=$1", id)
+	// Corps optionnel : sans montant, on rembourse la totalité
+	var req models.RefundRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	refund, err := services.RefundReservation(id, req.Amount, req.Reason)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete concert"})
+		switch {
+		case errors.Is(err, services.ErrReservationNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, services.ErrRefundInProgress):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 
+	LogActivity(int(claims.UserID), "refund_reservation",
+		fmt.Sprintf("Reservation #%d refunded (%s, %s)", id, models.FormatAmount(refund.Amount, refund.Currency), refund.StripeRefundID), r.RemoteAddr)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Concert deleted successfully"})
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(refund)
 }
 
-func AdminGetPayments(w http.ResponseWriter, r *http.Request) {
+func AdminGetWebhookEvents(w http.ResponseWriter, r *http.Request) {
 	claims, ok := middleware.GetUserFromContext(r)
 	if !ok || claims.Role != "admin" {
 		w.Header().Set("Content-Type", "application/json")
@@ -337,62 +433,85 @@ func AdminGetPayments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := database.DB.Query(`
-		SELECT r.id, r.user_id, r.concert_id, r.quantity as tickets, r.total_price, 
-		       r.payment_status, r.payment_intent, r.created_at,
-		       u.name as user_name, u.email as user_email,
-		       c.location as concert_location, c.date as concert_date,
-		       a.name as artist_name
-		FROM reservations r
-		LEFT JOIN users u ON r.user_id = u.id
-		LEFT JOIN concerts c ON r.concert_id = c.id
-		LEFT JOIN artists a ON c.artist_id = a.id
-		ORDER BY r.created_at DESC
-	`)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	events, err := services.ListStripeEvents(r.URL.Query().Get("status"), limit)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
-	defer rows.Close()
 
-	payments := []map[string]interface{}{}
-	for rows.Next() {
-		var id, userID, concertID, tickets int
-		var totalPrice float64
-		var paymentStatus, paymentIntent, userName, userEmail, concertLocation, concertDate, artistName string
-		var createdAt string
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// AdminReplayWebhookEvents rejoue un événement précis ({"event_id": "evt_..."})
+// ou, sans corps, tous les événements en échec. Un événement déjà traité n'est
+// rejoué qu'avec "force": true
+func AdminReplayWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok || claims.Role != "admin" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Admin access required"})
+		return
+	}
+
+	var req struct {
+		EventID string `json:"event_id"`
+		Force   bool   `json:"force"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+	}
 
-		err := rows.Scan(&id, &userID, &concertID, &tickets, &totalPrice, &paymentStatus,
-			&paymentIntent, &createdAt, &userName, &userEmail, &concertLocation, &concertDate, &artistName)
+	if req.EventID == "" {
+		count, err := services.RetryFailedStripeEvents()
 		if err != nil {
-			continue
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+			return
 		}
 
-		payment := map[string]interface{}{
-			"id":               id,
-			"user_id":          userID,
-			"user_name":        userName,
-			"user_email":       userEmail,
-			"concert_id":       concertID,
-			"concert_location": concertLocation,
-			"concert_date":     concertDate,
-			"artist_name":      artistName,
-			"tickets":          tickets,
-			"total_price":      totalPrice,
-			"payment_status":   paymentStatus,
-			"payment_intent":   paymentIntent,
-			"created_at":       createdAt,
+		LogActivity(int(claims.UserID), "replay_webhooks", fmt.Sprintf("%d failed webhook(s) replayed", count), r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"replayed": count})
+		return
+	}
+
+	err := services.ReplayStripeEvent(req.EventID, req.Force)
+	details := "Webhook " + req.EventID + " replayed"
+	if req.Force {
+		details += " (forced)"
+	}
+	LogActivity(int(claims.UserID), "replay_webhook", details, r.RemoteAddr)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case errors.Is(err, services.ErrStripeEventNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, services.ErrStripeEventAlreadyProcessed):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
 		}
-		payments = append(payments, payment)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(payments)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Event replayed successfully"})
 }
 
-func AdminGetUsers(w http.ResponseWriter, r *http.Request) {
+// AdminGetLockouts liste les verrouillages de connexion en cours (comptes et IP)
+func AdminGetLockouts(w http.ResponseWriter, r *http.Request) {
 	claims, ok := middleware.GetUserFromContext(r)
 	if !ok || claims.Role != "admin" {
 		w.Header().Set("Content-Type", "application/json")
@@ -401,47 +520,55 @@ func AdminGetUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := database.DB.Query(`
-		SELECT id, name, email, role, email_verified, created_at,
-		       (SELECT COUNT(*) FROM reservations WHERE user_id = users.id) as total_bookings
-		FROM users
-		ORDER BY created_at DESC
-	`)
+	lockouts, err := services.ListActiveLockouts()
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
-	defer rows.Close()
 
-	users := []map[string]interface{}{}
-	for rows.Next() {
-		var id int
-		var name, email, role, createdAt string
-		var emailVerified bool
-		var totalBookings int
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lockouts)
+}
 
-		err := rows.Scan(&id, &name, &email, &role, &emailVerified, &createdAt, &totalBookings)
-		if err != nil {
-			continue
-		}
+// AdminReleaseLockout lève un verrouillage avant son expiration
+func AdminReleaseLockout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok || claims.Role != "admin" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Admin access required"})
+		return
+	}
 
-		user := map[string]interface{}{
-			"id":             id,
-			"name":           name,
-			"email":          email,
-			"is_admin":       role == "admin", // Construct is_admin from role
-			"email_verified": emailVerified,
-			"created_at":     createdAt,
-			"total_bookings": totalBookings,
+	lockoutID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid lockout ID"})
+		return
+	}
+
+	lockout, err := services.ReleaseLockout(lockoutID, int(claims.UserID))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, services.ErrLockoutNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
 		}
-		users = append(users, user)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
 	}
 
+	LogActivity(int(claims.UserID), "release_lockout", fmt.Sprintf("Released %s lockout on %s", lockout.Scope, lockout.Key), r.RemoteAddr)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(lockout)
 }
+
 func AdminUploadImage(w http.ResponseWriter, r *http.Request) {
 	r.ParseMultipartForm(10 << 20)
 
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"groupie-backend/database/dbtest"
+	"groupie-backend/internal/auth"
+	"groupie-backend/internal/listquery"
+	"groupie-backend/middleware"
+	"groupie-backend/models"
+	"groupie-backend/services"
+)
+
+// adminServer monte les routes d'administration derrière les mêmes
+// middlewares que main.go.
+func adminServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	t.Setenv("APP_ENV", "development") // clé JWT éphémère
+	t.Setenv("JWT_PRIVATE_KEY_FILE", "")
+	t.Setenv("JWT_PRIVATE_KEY", "")
+	t.Setenv("JWT_VERIFICATION_KEY_FILES", "")
+	t.Setenv("REQUIRE_ADMIN_2FA", "true")
+	if err := auth.InitJWT(); err != nil {
+		t.Fatalf("InitJWT: %v", err)
+	}
+
+	r := mux.NewRouter()
+	protected := r.PathPrefix("/api").Subrouter()
+	protected.Use(middleware.JWTAuth)
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.AdminOnly)
+	admin.HandleFunc("/artists", AdminGetArtists).Methods("GET")
+	admin.HandleFunc("/artists", AdminCreateArtist).Methods("POST")
+	admin.HandleFunc("/artists/{id:[0-9]+}", AdminUpdateArtist).Methods("PUT")
+	admin.HandleFunc("/artists/{id:[0-9]+}", AdminDeleteArtist).Methods("DELETE")
+	admin.HandleFunc("/concerts", AdminGetConcerts).Methods("GET")
+	admin.HandleFunc("/concerts", AdminCreateConcert).Methods("POST")
+	admin.HandleFunc("/concerts/{id:[0-9]+}", AdminUpdateConcert).Methods("PUT")
+	admin.HandleFunc("/concerts/{id:[0-9]+}", AdminDeleteConcert).Methods("DELETE")
+	admin.HandleFunc("/users", AdminGetUsers).Methods("GET")
+	admin.HandleFunc("/payments", AdminGetPayments).Methods("GET")
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// insertAdminUser crée un compte avec ce rôle et retourne un access token de session.
+func insertAdminUser(t *testing.T, db *sql.DB, email, role string, twoFactor bool) (int, string) {
+	t.Helper()
+
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO users (email, first_name, last_name, role, email_verified, totp_enabled)
+		VALUES ($1, 'Test', 'Admin', $2, TRUE, $3)
+		RETURNING id
+	`, email, role, twoFactor).Scan(&id)
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	tokens, err := services.CreateSession(id, role, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return id, tokens.Token
+}
+
+// adminRequest envoie body en JSON et décode la réponse dans out (si non nil).
+func adminRequest(t *testing.T, srv *httptest.Server, token, method, path string, body, out interface{}) int {
+	t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAdminRoutesRequireAnEnrolledAdmin(t *testing.T) {
+	db := dbtest.Open(t)
+	srv := adminServer(t)
+
+	_, userToken := insertAdminUser(t, db, "user@example.com", "user", true)
+	_, unenrolledToken := insertAdminUser(t, db, "new-admin@example.com", "admin", false)
+	_, adminToken := insertAdminUser(t, db, "admin@example.com", "admin", true)
+
+	tests := []struct {
+		name, token string
+		want        int
+		code        string
+	}{
+		{"anonymous", "", http.StatusUnauthorized, ""},
+		{"user", userToken, http.StatusForbidden, ""},
+		{"admin without 2FA", unenrolledToken, http.StatusForbidden, "2fa_enrollment_required"},
+		{"admin", adminToken, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		var body map[string]interface{}
+		if got := adminRequest(t, srv, tt.token, "GET", "/api/admin/users", nil, &body); got != tt.want {
+			t.Errorf("%s: status = %d, want %d (%v)", tt.name, got, tt.want, body)
+		}
+		if tt.code != "" && body["code"] != tt.code {
+			t.Errorf("%s: code = %v, want %s", tt.name, body["code"], tt.code)
+		}
+	}
+}
+
+func TestAdminArtistAndConcertCRUD(t *testing.T) {
+	db := dbtest.Open(t)
+	srv := adminServer(t)
+	adminID, token := insertAdminUser(t, db, "admin@example.com", "admin", true)
+
+	// Création : erreurs par champ, puis succès
+	var invalid struct {
+		Error  string             `json:"error"`
+		Fields models.FieldErrors `json:"fields"`
+	}
+	if got := adminRequest(t, srv, token, "POST", "/api/admin/artists", `{"name": "", "creationDate": "1999"}`, &invalid); got != http.StatusBadRequest {
+		t.Fatalf("create invalid artist: status = %d", got)
+	}
+	if invalid.Fields["creationDate"] != "must be a int" {
+		t.Errorf("create invalid artist: fields = %v", invalid.Fields)
+	}
+
+	var artist models.Artist
+	if got := adminRequest(t, srv, token, "POST", "/api/admin/artists", models.Artist{Name: "Phoenix", Genre: "Indie"}, &artist); got != http.StatusCreated {
+		t.Fatalf("create artist: status = %d", got)
+	}
+
+	var concert models.Concert
+	newConcert := map[string]interface{}{
+		"artist_id": artist.ID, "venue": "Olympia", "city": "Paris",
+		"date":           time.Now().Add(60 * 24 * time.Hour).UTC().Format(time.RFC3339),
+		"standard_price": 4500, "vip_price": 9000, "currency": "EUR",
+		"available_standard": 200, "available_vip": 20,
+	}
+	if got := adminRequest(t, srv, token, "POST", "/api/admin/concerts", newConcert, &concert); got != http.StatusCreated {
+		t.Fatalf("create concert: status = %d", got)
+	}
+	if concert.ArtistName != "Phoenix" || concert.Currency != "eur" {
+		t.Errorf("created concert = %+v", concert)
+	}
+
+	newConcert["date"] = "next friday"
+	if got := adminRequest(t, srv, token, "PUT", fmt.Sprintf("/api/admin/concerts/%d", concert.ID), newConcert, &invalid); got != http.StatusBadRequest || invalid.Fields["date"] == "" {
+		t.Errorf("update with invalid date: status = %d, fields = %v", got, invalid.Fields)
+	}
+
+	// Listes filtrées
+	var concerts listquery.Page[models.Concert]
+	if got := adminRequest(t, srv, token, "GET", fmt.Sprintf("/api/admin/concerts?artist_id=%d", artist.ID), nil, &concerts); got != http.StatusOK {
+		t.Fatalf("list concerts: status = %d", got)
+	}
+	if concerts.Total != 1 || concerts.Items[0].ID != concert.ID {
+		t.Errorf("concerts = %+v", concerts)
+	}
+	if got := adminRequest(t, srv, token, "GET", "/api/admin/artists?sort=popularity", nil, &invalid); got != http.StatusBadRequest || invalid.Fields["sort"] == "" {
+		t.Errorf("list artists with unknown sort: status = %d, fields = %v", got, invalid.Fields)
+	}
+
+	// Un concert vendu ne peut plus être supprimé, ni son artiste
+	buyerID, _ := insertAdminUser(t, db, "buyer@example.com", "user", false)
+	_, err := db.Exec(`
+		INSERT INTO reservations
+			(user_id, concert_id, ticket_type, quantity, total_price, currency, status, payment_status, expires_at)
+		VALUES ($1, $2, 'standard', 1, 4500, 'eur', 'paid', 'succeeded', NOW())
+	`, buyerID, concert.ID)
+	if err != nil {
+		t.Fatalf("insert reservation: %v", err)
+	}
+	if got := adminRequest(t, srv, token, "DELETE", fmt.Sprintf("/api/admin/concerts/%d", concert.ID), nil, nil); got != http.StatusConflict {
+		t.Errorf("delete sold concert: status = %d, want 409", got)
+	}
+	if got := adminRequest(t, srv, token, "DELETE", fmt.Sprintf("/api/admin/artists/%d", artist.ID), nil, nil); got != http.StatusConflict {
+		t.Errorf("delete artist with sold concert: status = %d, want 409", got)
+	}
+	if got := adminRequest(t, srv, token, "DELETE", fmt.Sprintf("/api/admin/artists/%d", artist.ID+1000), nil, nil); got != http.StatusNotFound {
+		t.Errorf("delete unknown artist: status = %d, want 404", got)
+	}
+
+	var payments listquery.Page[models.AdminPayment]
+	if got := adminRequest(t, srv, token, "GET", "/api/admin/payments?status=paid", nil, &payments); got != http.StatusOK {
+		t.Fatalf("list payments: status = %d", got)
+	}
+	if payments.Total != 1 || payments.Items[0].ArtistName != "Phoenix" || payments.Items[0].UserEmail != "buyer@example.com" {
+		t.Errorf("payments = %+v", payments)
+	}
+
+	// Chaque écriture réussie est journalisée
+	var logged int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM activity_logs WHERE user_id = $1 AND action IN ('create_artist', 'create_concert')`, adminID).Scan(&logged); err != nil {
+		t.Fatalf("count activity logs: %v", err)
+	}
+	if logged != 2 {
+		t.Errorf("activity logs = %d, want 2", logged)
+	}
+}
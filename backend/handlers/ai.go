@@ -8,6 +8,7 @@ import (
 	"os"
 
 	"groupie-backend/database"
+	"groupie-backend/internal/search"
 	"groupie-backend/models"
 
 	"github.com/sashabaranov/go-openai"
@@ -279,35 +280,26 @@ Return up to 10 most relevant results.`, contextData)
 	})
 }
 
-// simpleSearch - Fallback search without AI
+// simpleSearch - Fallback search without AI: artists whose name, members or
+// tracks match the query, best match first
 func simpleSearch(w http.ResponseWriter, r *http.Request, query string) {
-	rows, err := database.DB.Query(`
-		SELECT id, name, image, members, creation_date, first_album, locations, concert_dates, relations
-		FROM artists
-		WHERE LOWER(name) LIKE LOWER($1) OR LOWER(members) LIKE LOWER($1)
-		LIMIT 10
-	`, "%"+query+"%")
-
+	hits, err := artistRepo.Search(query, []string{search.TypeArtist, search.TypeMember, search.TypeTrack}, maxSearchLimit)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
 	artists := []models.Artist{}
-	for rows.Next() {
-		var artist models.Artist
-		var membersJSON, locationsJSON, datesJSON, relationsJSON string
-		err := rows.Scan(&artist.ID, &artist.Name, &artist.Image, &membersJSON,
-			&artist.CreationDate, &artist.FirstAlbum, &locationsJSON,
-			&datesJSON, &relationsJSON)
+	seen := map[int]bool{}
+	for _, hit := range hits {
+		if seen[hit.ArtistID] || len(artists) == 10 {
+			continue
+		}
+		seen[hit.ArtistID] = true
+		artist, err := artistRepo.GetByID(hit.ArtistID)
 		if err != nil {
 			continue
 		}
-		json.Unmarshal([]byte(membersJSON), &artist.Members)
-		json.Unmarshal([]byte(locationsJSON), &artist.Locations)
-		json.Unmarshal([]byte(datesJSON), &artist.ConcertDates)
-		json.Unmarshal([]byte(relationsJSON), &artist.Relations)
 		artists = append(artists, artist)
 	}
 
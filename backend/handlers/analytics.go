@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 
 	"groupie-backend/database"
@@ -10,27 +12,32 @@ import (
 )
 
 type DashboardStats struct {
-	TotalArtists     int             `json:"total_artists"`
-	TotalConcerts    int             `json:"total_concerts"`
-	TotalUsers       int             `json:"total_users"`
-	TotalRevenue     float64         `json:"total_revenue"`
-	RecentBookings   int             `json:"recent_bookings"`
-	PopularArtists   []PopularArtist `json:"popular_artists"`
-	RevenueByMonth   []RevenueData   `json:"revenue_by_month"`
-	BookingsByStatus map[string]int  `json:"bookings_by_status"`
-	UpcomingConcerts int             `json:"upcoming_concerts"`
+	TotalArtists     int               `json:"total_artists"`
+	TotalConcerts    int               `json:"total_concerts"`
+	TotalUsers       int               `json:"total_users"`
+	TotalRevenue     RevenueByCurrency `json:"total_revenue"`
+	RecentBookings   int               `json:"recent_bookings"`
+	PopularArtists   []PopularArtist   `json:"popular_artists"`
+	RevenueByMonth   []RevenueData     `json:"revenue_by_month"`
+	BookingsByStatus map[string]int    `json:"bookings_by_status"`
+	UpcomingConcerts int               `json:"upcoming_concerts"`
 }
 
+// RevenueByCurrency associe chaque devise à un montant en unités mineures
+// (ex. {"eur": 125000, "usd": 89900}) : deux devises ne sont jamais additionnées
+type RevenueByCurrency map[string]int64
+
 type PopularArtist struct {
-	ArtistName    string  `json:"artist_name"`
-	ArtistImage   string  `json:"artist_image"`
-	TotalBookings int     `json:"total_bookings"`
-	TotalRevenue  float64 `json:"total_revenue"`
+	ArtistName    string            `json:"artist_name"`
+	ArtistImage   string            `json:"artist_image"`
+	TotalBookings int               `json:"total_bookings"`
+	TotalRevenue  RevenueByCurrency `json:"total_revenue"`
 }
 
 type RevenueData struct {
-	Month   string  `json:"month"`
-	Revenue float64 `json:"revenue"`
+	Month    string `json:"month"`
+	Currency string `json:"currency"`
+	Revenue  int64  `json:"revenue"`
 }
 
 type ActivityLog struct {
@@ -64,8 +71,18 @@ func AdminGetDashboard(w http.ResponseWriter, r *http.Request) {
 	// Total Users
 	database.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.TotalUsers)
 
-	// Total Revenue
-	database.DB.QueryRow("SELECT COALESCE(SUM(total_price), 0) FROM reservations WHERE payment_status = 'succeeded'").Scan(&stats.TotalRevenue)
+	// Total Revenue (par devise)
+	stats.TotalRevenue = RevenueByCurrency{}
+	totalRows, err := database.DB.Query(`
+		SELECT currency, SUM(total_price)
+		FROM reservations
+		WHERE payment_status = 'succeeded'
+		GROUP BY currency
+	`)
+	if err == nil {
+		defer totalRows.Close()
+		scanRevenueByCurrency(totalRows, stats.TotalRevenue)
+	}
 
 	// Recent Bookings (last 7 days)
 	database.DB.QueryRow(`
@@ -81,7 +98,17 @@ func AdminGetDashboard(w http.ResponseWriter, r *http.Request) {
 
 	// Popular Artists (Top 5 by bookings)
 	rows, err := database.DB.Query(`
-		SELECT a.name, a.image, COUNT(r.id) as bookings, COALESCE(SUM(r.total_price), 0) as revenue
+		SELECT a.name, a.image, COUNT(r.id) as bookings,
+		       COALESCE((
+		           SELECT jsonb_object_agg(t.currency, t.revenue)
+		           FROM (
+		               SELECT r2.currency, SUM(r2.total_price) AS revenue
+		               FROM reservations r2
+		               JOIN concerts c2 ON c2.id = r2.concert_id
+		               WHERE c2.artist_id = a.id AND r2.payment_status = 'succeeded'
+		               GROUP BY r2.currency
+		           ) t
+		       ), '{}'::jsonb) as revenue
 		FROM artists a
 		LEFT JOIN concerts c ON a.id = c.artist_id
 		LEFT JOIN reservations r ON c.id = r.concert_id AND r.payment_status = 'succeeded'
@@ -93,7 +120,10 @@ func AdminGetDashboard(w http.ResponseWriter, r *http.Request) {
 		defer rows.Close()
 		for rows.Next() {
 			var artist PopularArtist
-			rows.Scan(&artist.ArtistName, &artist.ArtistImage, &artist.TotalBookings, &artist.TotalRevenue)
+			var revenueJSON []byte
+			rows.Scan(&artist.ArtistName, &artist.ArtistImage, &artist.TotalBookings, &revenueJSON)
+			artist.TotalRevenue = RevenueByCurrency{}
+			json.Unmarshal(revenueJSON, &artist.TotalRevenue)
 			stats.PopularArtists = append(stats.PopularArtists, artist)
 		}
 	}
@@ -102,18 +132,19 @@ func AdminGetDashboard(w http.ResponseWriter, r *http.Request) {
 	revenueRows, err := database.DB.Query(`
 		SELECT 
 			TO_CHAR(created_at, 'Mon YYYY') as month,
+			currency,
 			COALESCE(SUM(total_price), 0) as revenue
 		FROM reservations
 		WHERE payment_status = 'succeeded'
 		AND created_at >= NOW() - INTERVAL '6 months'
-		GROUP BY TO_CHAR(created_at, 'YYYY-MM'), TO_CHAR(created_at, 'Mon YYYY')
-		ORDER BY TO_CHAR(created_at, 'YYYY-MM')
+		GROUP BY TO_CHAR(created_at, 'YYYY-MM'), TO_CHAR(created_at, 'Mon YYYY'), currency
+		ORDER BY TO_CHAR(created_at, 'YYYY-MM'), currency
 	`)
 	if err == nil {
 		defer revenueRows.Close()
 		for revenueRows.Next() {
 			var data RevenueData
-			revenueRows.Scan(&data.Month, &data.Revenue)
+			revenueRows.Scan(&data.Month, &data.Currency, &data.Revenue)
 			stats.RevenueByMonth = append(stats.RevenueByMonth, data)
 		}
 	}
@@ -139,6 +170,17 @@ func AdminGetDashboard(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
+// scanRevenueByCurrency remplit totals à partir de lignes (currency, montant)
+func scanRevenueByCurrency(rows *sql.Rows, totals RevenueByCurrency) {
+	for rows.Next() {
+		var currency string
+		var amount int64
+		if err := rows.Scan(&currency, &amount); err == nil {
+			totals[currency] = amount
+		}
+	}
+}
+
 // AdminGetActivityLogs - Retourne les logs d'activité
 func AdminGetActivityLogs(w http.ResponseWriter, r *http.Request) {
 	claims, ok := middleware.GetUserFromContext(r)
@@ -149,13 +191,16 @@ func AdminGetActivityLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	limit := r.URL.Query().Get("limit")
-	if limit == "" {
-		limit = "50"
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
 	}
 
+	// user_id est NULL pour les événements sans compte (échec de connexion sur un email inconnu, IP verrouillée)
 	rows, err := database.DB.Query(`
-		SELECT l.id, l.user_id, u.name, l.action, l.details, l.ip_address, l.created_at
+		SELECT l.id, COALESCE(l.user_id, 0),
+		       COALESCE(NULLIF(TRIM(CONCAT_WS(' ', u.first_name, u.last_name)), ''), u.name, ''),
+		       l.action, COALESCE(l.details, ''), COALESCE(l.ip_address, ''), l.created_at
 		FROM activity_logs l
 		LEFT JOIN users u ON l.user_id = u.id
 		ORDER BY l.created_at DESC
@@ -173,7 +218,9 @@ func AdminGetActivityLogs(w http.ResponseWriter, r *http.Request) {
 	logs := []ActivityLog{}
 	for rows.Next() {
 		var log ActivityLog
-		rows.Scan(&log.ID, &log.UserID, &log.UserName, &log.Action, &log.Details, &log.IPAddress, &log.CreatedAt)
+		if err := rows.Scan(&log.ID, &log.UserID, &log.UserName, &log.Action, &log.Details, &log.IPAddress, &log.CreatedAt); err != nil {
+			continue
+		}
 		logs = append(logs, log)
 	}
 
@@ -181,11 +228,11 @@ func AdminGetActivityLogs(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(logs)
 }
 
-// LogActivity - Fonction utilitaire pour logger une activité
+// LogActivity - Fonction utilitaire pour logger une activité (userID 0 : pas de compte associé)
 func LogActivity(userID int, action, details, ipAddress string) error {
 	_, err := database.DB.Exec(`
 		INSERT INTO activity_logs (user_id, action, details, ip_address, created_at)
-		VALUES ($1, $2, $3, $4, NOW())
+		VALUES (NULLIF($1, 0), $2, $3, $4, NOW())
 	`, userID, action, details, ipAddress)
 	return err
 }
@@ -2,20 +2,45 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 
+	"groupie-backend/models"
 	"groupie-backend/services"
 
 	"github.com/gorilla/mux"
 )
 
-var artistService = services.NewArtistService()
+// artistRepo est la source du catalogue public (mock en mémoire tant que
+// InitArtistRepository n'a pas été appelé)
+var artistRepo services.ArtistRepository = services.NewArtistService()
 
+// InitArtistRepository branche les routes publiques sur le repository du catalogue
+func InitArtistRepository(repo services.ArtistRepository) {
+	artistRepo = repo
+}
+
+// GetArtists retourne une page d'artistes (voir services.ArtistListSpec pour
+// les filtres et tris acceptés)
 func GetArtists(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	artists := artistService.GetAll()
-	json.NewEncoder(w).Encode(artists)
+	q, err := services.ArtistListSpec.Parse(r.URL.Query())
+	var fields models.FieldErrors
+	if errors.As(err, &fields) {
+		writeFieldErrors(w, fields)
+		return
+	}
+
+	page, err := artistRepo.ListArtists(q)
+	if err != nil {
+		log.Printf("❌ Error fetching artists: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	json.NewEncoder(w).Encode(page)
 }
 
 func GetArtist(w http.ResponseWriter, r *http.Request) {
@@ -27,24 +52,44 @@ func GetArtist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	artist, found := artistService.GetByID(id)
-	if !found {
+	artist, err := artistRepo.GetByID(id)
+	if errors.Is(err, services.ErrArtistNotFound) {
 		http.Error(w, "Artist not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		log.Printf("❌ Error fetching artist %d: %v", id, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
 
 	json.NewEncoder(w).Encode(artist)
 }
 
+// GetConcerts retourne une page de concerts, par date par défaut (voir
+// services.ConcertListSpec pour les filtres et tris acceptés)
 func GetConcerts(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	concerts := artistService.GetAllConcerts()
-	json.NewEncoder(w).Encode(concerts)
+	q, err := services.ConcertListSpec.Parse(r.URL.Query())
+	var fields models.FieldErrors
+	if errors.As(err, &fields) {
+		writeFieldErrors(w, fields)
+		return
+	}
+
+	page, err := artistRepo.ListConcerts(q)
+	if err != nil {
+		log.Printf("❌ Error fetching concerts: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	json.NewEncoder(w).Encode(page)
 }
 
+// SearchConcerts est conservé pour les anciens clients : la recherche plein
+// texte est le filtre q de GetConcerts
 func SearchConcerts(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	query := r.URL.Query().Get("q")
-	concerts := artistService.SearchConcerts(query)
-	json.NewEncoder(w).Encode(concerts)
+	GetConcerts(w, r)
 }
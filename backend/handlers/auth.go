@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"groupie-backend/database"
+	"groupie-backend/internal/auth"
 	
 	"groupie-backend/middleware"
 	"groupie-backend/models"
@@ -46,7 +49,7 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Login vérifie les identifiants et renvoie un token JWT
+// Login vérifie les identifiants et renvoie un access token JWT et un refresh token
 func Login(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -56,12 +59,27 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, token, err := services.LoginUser(req)
+	ip := middleware.ClientIP(r)
+
+	// Anti force brute : compte ou IP verrouillé, ou délai depuis le dernier échec
+	if err := services.CheckLoginAllowed(req.Email, ip); err != nil {
+		writeLoginThrottled(w, err)
+		return
+	}
+
+	login, challenge, err := services.LoginUser(req, r.UserAgent(), ip)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			recordLoginFailure(req.Email, ip)
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		if err.Error() == "veuillez vérifier votre email avant de vous connecter" {
 			w.WriteHeader(http.StatusForbidden) // 403 Forbidden
 			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		} else if errors.Is(err, services.ErrTwoFactorLocked) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		} else {
 			w.WriteHeader(http.StatusUnauthorized) // 401 Unauthorized
 			json.NewEncoder(w).Encode(map[string]string{"error": "Email ou mot de passe incorrect"})
@@ -69,95 +87,237 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Mot de passe correct : le compteur d'échecs du compte repart de zéro
+	if err := services.RecordLoginSuccess(req.Email, ip); err != nil {
+		log.Printf("⚠️  Login success not recorded: %v", err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.LoginResponse{
-		Token: token,
-		User:  *user,
-	})
+	if challenge != nil {
+		// 2FA active : le client doit poster un code sur /auth/2fa/login
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+	LogActivity(login.User.ID, "login_success", "Password login", ip)
+	json.NewEncoder(w).Encode(login)
 }
 
-// ForgotPassword génère un token de reset et l'envoie par mail
-func ForgotPassword(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Email string `json:"email"`
+// recordLoginFailure enregistre l'échec et journalise les verrouillages déclenchés
+func recordLoginFailure(email, ip string) {
+	failure, err := services.RecordLoginFailure(email, ip)
+	if err != nil {
+		log.Printf("❌ Error recording login failure: %v", err)
+		return
 	}
+
+	LogActivity(failure.UserID, "login_failed", "Failed login for "+email, ip)
+	if lock := failure.AccountLockout; lock != nil {
+		log.Printf("🔒 Account %s locked until %s (%d failures)", lock.Key, lock.LockedUntil.Format(time.RFC3339), lock.Failures)
+		LogActivity(failure.UserID, "account_locked", fmt.Sprintf("Account %s locked after %d failed logins", lock.Key, lock.Failures), ip)
+	}
+	if lock := failure.IPLockout; lock != nil {
+		log.Printf("🔒 IP %s locked until %s (%d failures)", lock.Key, lock.LockedUntil.Format(time.RFC3339), lock.Failures)
+		LogActivity(0, "ip_locked", fmt.Sprintf("IP %s locked after %d failed logins", lock.Key, lock.Failures), ip)
+	}
+}
+
+// writeLoginThrottled répond 429 avec Retry-After
+func writeLoginThrottled(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var throttled *services.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		log.Printf("❌ Error checking login attempts: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication failed"})
+		return
+	}
+
+	retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       throttled.Error(),
+		"retry_after": retryAfter,
+	})
+}
+
+// ExchangeOAuthCode échange le code remis après une connexion externe contre
+// les tokens (même réponse que /auth/login, challenge 2FA compris)
+func ExchangeOAuthCode(w http.ResponseWriter, r *http.Request) {
+	var req models.OAuthExchangeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
 		return
 	}
 
-	var userID int
-	err := database.DB.QueryRow("SELECT id FROM users WHERE email = $1", req.Email).Scan(&userID)
+	login, challenge, err := services.ExchangeOAuthHandoff(req, r.UserAgent(), middleware.ClientIP(r))
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK) // Still return OK to avoid email enumeration
-		json.NewEncoder(w).Encode(map[string]string{"message": "Si l'adresse e-mail existe, un lien de réinitialisation de mot de passe a été envoyé."})
+		switch {
+		case errors.Is(err, services.ErrOAuthHandoffInvalid):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		case errors.Is(err, services.ErrTwoFactorLocked):
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		default:
+			log.Printf("❌ Error exchanging OAuth code: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Authentication failed"})
+		}
 		return
 	}
 
-	database.DB.Exec("DELETE FROM password_reset_tokens WHERE user_id = $1", userID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if challenge != nil {
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+	json.NewEncoder(w).Encode(login)
+}
 
-	token := fmt.Sprintf("%x", time.Now().UnixNano())
-	_, err = database.DB.Exec("INSERT INTO password_reset_tokens (user_id, token, expires_at) VALUES ($1, $2, $3)",
-		userID, token, time.Now().Add(time.Hour))
+// RefreshToken échange un refresh token contre une nouvelle paire de tokens (rotation)
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "refresh_token is required"})
+		return
+	}
 
+	tokens, err := services.RefreshSession(req.RefreshToken)
 	if err != nil {
-		log.Printf("Erreur insertion token: %v", err)
-		http.Error(w, "Erreur serveur", http.StatusInternalServerError)
+		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("❌ Error refreshing session: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
 
-	services.SendPasswordResetEmail(req.Email, token)
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
 }
 
-// ResetPassword change le mot de passe via le token reçu par mail
-func ResetPassword(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Token       string `json:"token"`
-		NewPassword string `json:"new_password"`
+// Logout ferme la session du refresh token fourni. Toujours 200 : un token
+// inconnu ou déjà révoqué ne change rien pour le client.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "refresh_token is required"})
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := services.RevokeSessionByRefreshToken(req.RefreshToken); err != nil {
+		log.Printf("❌ Error during logout: %v", err)
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Données invalides"})
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
 
-	var userID int
-	err := database.DB.QueryRow(
-		"SELECT user_id FROM password_reset_tokens WHERE token = $1 AND expires_at > $2",
-		req.Token, time.Now(),
-	).Scan(&userID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Déconnecté"})
+}
 
-	if err != nil {
+// LogoutAll ferme toutes les sessions de l'utilisateur connecté (tous les appareils)
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Lien invalide ou expiré"})
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Unauthorized - JWT token required",
+		})
 		return
 	}
 
-	hashedPassword, _ := services.HashPassword(req.NewPassword)
-	
-	tx, err := database.DB.Begin()
+	count, err := services.RevokeUserSessions(int(claims.UserID), "logout_all")
 	if err != nil {
+		log.Printf("❌ Error during logout-all: %v", err)
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
-	
-	_, err = tx.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", hashedPassword, userID)
-	if err != nil {
-		tx.Rollback()
-		log.Printf("❌ Erreur SQL lors de l'UPDATE: %v", err)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":          "Déconnecté de tous les appareils",
+		"revoked_sessions": count,
+	})
+}
+
+// GetJWKS publie les clés publiques de vérification des access tokens
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(auth.PublicJWKS())
+}
+
+// ForgotPassword génère un jeton de reset et l'envoie par mail.
+// La réponse est identique que le compte existe ou non (pas d'énumération).
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.RequestPasswordReset(req.Email); err != nil {
+		log.Printf("❌ Erreur demande de reset: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Si l'adresse e-mail existe, un lien de réinitialisation de mot de passe a été envoyé."})
+}
+
+// ResetPassword change le mot de passe via le token reçu par mail
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Erreur lors de la mise à jour"})
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Données invalides"})
 		return
 	}
 
-	tx.Exec("DELETE FROM password_reset_tokens WHERE token = $1", req.Token)
-	tx.Commit()
+	err := services.ResetPassword(req.Token, req.NewPassword)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case errors.Is(err, services.ErrOneTimeTokenInvalid), errors.Is(err, services.ErrOneTimeTokenExpired):
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Lien invalide ou expiré"})
+		case errors.Is(err, services.ErrWeakPassword):
+			// Mot de passe refusé : le lien reste utilisable
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		default:
+			log.Printf("❌ Erreur lors du reset de mot de passe: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Erreur lors de la mise à jour"})
+		}
+		return
+	}
 
 	log.Println("✅ Mot de passe mis à jour avec succès !")
 	w.Header().Set("Content-Type", "application/json")
@@ -173,26 +333,17 @@ func VerifyEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var userID int
-	var expiresAt time.Time
-	err := database.DB.QueryRow(`SELECT user_id, expires_at FROM email_verification_tokens WHERE token = $1`, token).Scan(&userID, &expiresAt)
-
+	err := services.VerifyEmail(token)
+	if errors.Is(err, services.ErrOneTimeTokenExpired) {
+		http.Redirect(w, r, "http://localhost:5173/verify-error?reason=expired", http.StatusSeeOther)
+		return
+	}
 	if err != nil {
 		log.Printf("❌ Token invalide : %v", err)
 		http.Redirect(w, r, "http://localhost:5173/verify-error", http.StatusSeeOther)
 		return
 	}
 
-	if time.Now().After(expiresAt) {
-		http.Redirect(w, r, "http://localhost:5173/verify-error?reason=expired", http.StatusSeeOther)
-		return
-	}
-
-	tx, _ := database.DB.Begin()
-	tx.Exec("UPDATE users SET email_verified = true WHERE id = $1", userID)
-	tx.Exec("DELETE FROM email_verification_tokens WHERE token = $1", token)
-	tx.Commit()
-
 	http.Redirect(w, r, "http://localhost:5173/verify-success", http.StatusSeeOther)
 }
 
@@ -227,18 +378,9 @@ func ResendVerification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var userID int
-	err := database.DB.QueryRow("SELECT id FROM users WHERE email = $1 AND email_verified = false", req.Email).Scan(&userID)
-	if err != nil {
-		// On renvoie OK même si l'user n'existe pas (sécurité)
-		w.WriteHeader(http.StatusOK)
-		return
+	// On renvoie OK même si l'user n'existe pas ou a trop demandé (sécurité)
+	if err := services.ResendVerificationEmail(req.Email); err != nil {
+		log.Printf("❌ Erreur renvoi de vérification: %v", err)
 	}
-
-	token := fmt.Sprintf("%x", time.Now().UnixNano())
-	database.DB.Exec("INSERT INTO email_verification_tokens (user_id, token, expires_at) VALUES ($1, $2, $3)",
-		userID, token, time.Now().Add(24*time.Hour))
-
-	services.SendVerificationEmail(req.Email, token)
 	w.WriteHeader(http.StatusOK)
-}
\ No newline at end of file
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"groupie-backend/internal/oauth"
+	"groupie-backend/middleware"
+	"groupie-backend/models"
+	"groupie-backend/services"
+
+	"github.com/gorilla/mux"
+)
+
+// ========= IDENTITÉS LIÉES (GOOGLE, GITHUB, OIDC) =========
+
+// GetIdentities liste les moyens de connexion du compte
+func GetIdentities(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized - JWT token required"})
+		return
+	}
+
+	methods, err := services.ListLoginMethods(int(claims.UserID))
+	if err != nil {
+		log.Printf("❌ Error listing identities: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to list identities"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(methods)
+}
+
+// StartIdentityLink ouvre une demande de rattachement : le front redirige
+// ensuite vers /api/auth/{provider}?link=<link_token>
+func StartIdentityLink(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized - JWT token required"})
+		return
+	}
+
+	provider, err := oauth.Lookup(mux.Vars(r)["provider"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown login provider"})
+		return
+	}
+
+	start, err := services.StartIdentityLink(int(claims.UserID), provider.Name())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, services.ErrIdentityAlreadyLinked) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("❌ Error starting identity link: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to start identity link"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(start)
+}
+
+// ConfirmIdentityLink termine le rattachement au retour du fournisseur
+func ConfirmIdentityLink(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized - JWT token required"})
+		return
+	}
+
+	var req models.IdentityLinkConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ConfirmToken == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "confirm_token is required"})
+		return
+	}
+
+	provider, err := services.ConfirmIdentityLink(int(claims.UserID), req.ConfirmToken)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case errors.Is(err, services.ErrIdentityLinkInvalid):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		case errors.Is(err, services.ErrIdentityInUse), errors.Is(err, services.ErrIdentityAlreadyLinked):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		default:
+			log.Printf("❌ Error confirming identity link: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to link identity"})
+		}
+		return
+	}
+
+	LogActivity(int(claims.UserID), "link_identity", "Linked "+provider+" identity", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Identity linked", "provider": provider})
+}
+
+// UnlinkIdentity retire une identité liée (refusé s'il s'agit du dernier moyen de connexion)
+func UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized - JWT token required"})
+		return
+	}
+
+	identityID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid identity ID"})
+		return
+	}
+
+	provider, err := services.UnlinkIdentity(int(claims.UserID), identityID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case errors.Is(err, services.ErrIdentityNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		case errors.Is(err, services.ErrLastLoginMethod):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		default:
+			log.Printf("❌ Error unlinking identity: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to unlink identity"})
+		}
+		return
+	}
+
+	LogActivity(int(claims.UserID), "unlink_identity", "Unlinked "+provider+" identity", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Identity unlinked"})
+}
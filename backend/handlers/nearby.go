@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"groupie-backend/internal/geo"
+	"groupie-backend/models"
+)
+
+const (
+	defaultNearbyRadiusKm = 50.0
+	defaultNearbyLimit    = 50
+	maxNearbyLimit        = 500
+)
+
+// GetNearbyConcerts retourne les concerts autour d'un point, les plus proches
+// d'abord, avec leur distance en kilomètres.
+// GET /api/concerts/nearby?lat=48.85&lng=2.35&radius_km=50&limit=50
+func GetNearbyConcerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	values := r.URL.Query()
+	errs := models.FieldErrors{}
+
+	center := geo.Point{
+		Lat: floatParam(values, "lat", -90, 90, nil, errs),
+		Lng: floatParam(values, "lng", -180, 180, nil, errs),
+	}
+	defaultRadius := defaultNearbyRadiusKm
+	radius := floatParam(values, "radius_km", 0, geo.MaxDistanceKm, &defaultRadius, errs)
+	if errs["radius_km"] == "" && radius == 0 {
+		errs["radius_km"] = "must be greater than 0"
+	}
+	limit := nearbyLimit(values, errs)
+
+	if len(errs) > 0 {
+		writeFieldErrors(w, errs)
+		return
+	}
+
+	concerts, err := artistRepo.NearbyConcerts(center, radius, limit)
+	if err != nil {
+		log.Printf("❌ Error searching nearby concerts: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":     concerts,
+		"count":     len(concerts),
+		"radius_km": radius,
+	})
+}
+
+// GetConcertsInBox retourne les concerts visibles dans une zone de la carte,
+// les plus proches de lat/lng d'abord (du centre de la zone par défaut).
+// min_lng > max_lng désigne une zone à cheval sur l'antiméridien.
+// GET /api/concerts/within?min_lat=41&min_lng=-5&max_lat=51&max_lng=10&limit=200
+func GetConcertsInBox(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	values := r.URL.Query()
+	errs := models.FieldErrors{}
+
+	box := geo.BBox{
+		MinLat: floatParam(values, "min_lat", -90, 90, nil, errs),
+		MinLng: floatParam(values, "min_lng", -180, 180, nil, errs),
+		MaxLat: floatParam(values, "max_lat", -90, 90, nil, errs),
+		MaxLng: floatParam(values, "max_lng", -180, 180, nil, errs),
+	}
+	if errs["min_lat"] == "" && errs["max_lat"] == "" && box.MinLat > box.MaxLat {
+		errs["max_lat"] = "must be greater than or equal to min_lat"
+	}
+
+	from := box.Center()
+	if values.Get("lat") != "" || values.Get("lng") != "" {
+		from = geo.Point{
+			Lat: floatParam(values, "lat", -90, 90, nil, errs),
+			Lng: floatParam(values, "lng", -180, 180, nil, errs),
+		}
+	}
+	limit := nearbyLimit(values, errs)
+
+	if len(errs) > 0 {
+		writeFieldErrors(w, errs)
+		return
+	}
+
+	concerts, err := artistRepo.ConcertsInBox(box, from, limit)
+	if err != nil {
+		log.Printf("❌ Error searching concerts in box: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items": concerts,
+		"count": len(concerts),
+	})
+}
+
+// floatParam lit un nombre entre min et max ; obligatoire si def est nil
+func floatParam(values url.Values, name string, min, max float64, def *float64, errs models.FieldErrors) float64 {
+	raw := values.Get(name)
+	if raw == "" {
+		if def == nil {
+			errs[name] = "required"
+			return 0
+		}
+		return *def
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(v) || v < min || v > max {
+		errs[name] = fmt.Sprintf("must be a number between %g and %g", min, max)
+		return 0
+	}
+	return v
+}
+
+func nearbyLimit(values url.Values, errs models.FieldErrors) int {
+	raw := values.Get("limit")
+	if raw == "" {
+		return defaultNearbyLimit
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > maxNearbyLimit {
+		errs["limit"] = "must be between 1 and " + strconv.Itoa(maxNearbyLimit)
+		return 0
+	}
+	return n
+}
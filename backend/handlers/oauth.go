@@ -1,36 +1,68 @@
 package handlers
 
 import (
-	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
-	"time"
+	"regexp"
 	"strings"
-	"groupie-backend/database"
-	"groupie-backend/internal/auth" 
-	"groupie-backend/models"
-	"net/url"
+	"time"
+
+	"groupie-backend/internal/oauth"
+	"groupie-backend/services"
+
+	"github.com/gorilla/mux"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
-var googleOauthConfig *oauth2.Config
+// Cookies de la tentative de connexion, le temps de l'aller-retour chez le fournisseur
+const (
+	oauthStateCookie    = "oauth_state"
+	oauthNonceCookie    = "oauth_nonce"
+	oauthVerifierCookie = "oauth_verifier"
+	oauthLinkCookie     = "oauth_link"   // jeton de rattachement (compte déjà connecté)
+	oauthClientCookie   = "oauth_client" // plateforme, PKCE et state du client (passation)
+	oauthFlowTTL        = 10 * time.Minute
+)
+
+// clientParamPattern : state et code_challenge du client (base64url, RFC 7636)
+var clientParamPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
 
-func InitOAuth() {
-	googleOauthConfig = &oauth2.Config{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URI"),
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-		Endpoint: google.Endpoint,
+// oauthClient décrit le client qui recevra le code de passation
+type oauthClient struct {
+	Platform      string // "web" ou "android"
+	CodeChallenge string
+	State         string
+}
+
+func (c oauthClient) cookieValue() string {
+	return c.Platform + "." + c.CodeChallenge + "." + c.State
+}
+
+func parseOAuthClient(value string) (oauthClient, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || (parts[0] != "web" && parts[0] != "android") ||
+		!clientParamPattern.MatchString(parts[1]) || !clientParamPattern.MatchString(parts[2]) {
+		return oauthClient{}, false
+	}
+	return oauthClient{Platform: parts[0], CodeChallenge: parts[1], State: parts[2]}, true
+}
+
+// redirectTarget est l'adresse de retour du client : la page de connexion du
+// front, ou le deep link de l'app Android. Jamais une URL fournie par la requête.
+func (c oauthClient) redirectTarget() string {
+	if c.Platform == "android" {
+		if uri := os.Getenv("MOBILE_AUTH_REDIRECT_URI"); uri != "" {
+			return uri
+		}
+		return "com.groupie.app://auth/callback"
 	}
+	return frontendURL() + "/login"
 }
 
 func generateStateToken() (string, error) {
@@ -39,118 +71,199 @@ func generateStateToken() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return base64.URLEncoding.EncodeToString(b), nil
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func GoogleLogin(w http.ResponseWriter, r *http.Request) {
-	state, err := generateStateToken()
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate state"})
-		return
+func frontendURL() string {
+	fURL := os.Getenv("FRONTEND_URL")
+	if fURL == "" {
+		fURL = "http://localhost:5173"
 	}
+	return fURL
+}
 
+func setOAuthCookie(w http.ResponseWriter, r *http.Request, name, value string, maxAge int) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "oauth_state",
-		Value:    state,
-		Expires:  time.Now().Add(10 * time.Minute),
+		Name:     name,
+		Value:    value,
+		MaxAge:   maxAge,
 		HttpOnly: true,
-		Secure:   false, 
-		Path:     "/",     
-		SameSite: http.SameSiteLaxMode, 
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		Path:     "/api/auth",
+		SameSite: http.SameSiteLaxMode,
 	})
+}
 
-	authURL := googleOauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
-	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+// GetOAuthProviders liste les fournisseurs de connexion configurés (boutons du front)
+func GetOAuthProviders(w http.ResponseWriter, r *http.Request) {
+	list := []map[string]string{}
+	for _, p := range oauth.Providers() {
+		list = append(list, map[string]string{"name": p.Name(), "display_name": p.DisplayName()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
 }
 
-func GoogleCallback(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("🚀 Callback Google reçu...")
+// OAuthLogin redirige vers le fournisseur (state anti-CSRF, nonce OIDC et PKCE).
+// Le client fournit client_state et code_challenge (S256), auxquels sera lié le
+// code de passation, et platform=android pour un retour par deep link.
+// Avec ?link=<jeton>, l'aller-retour sert à rattacher l'identité au compte connecté.
+func OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	provider, err := oauth.Lookup(mux.Vars(r)["provider"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown login provider"})
+		return
+	}
 
-	// 1. Échange du Code contre le Token
-	code := r.URL.Query().Get("code")
-	if code == "" {
-		fmt.Println("❌ Pas de code reçu de Google")
-		http.Redirect(w, r, os.Getenv("FRONTEND_URL")+"?error=no_code", http.StatusTemporaryRedirect)
+	query := r.URL.Query()
+	link := query.Get("link")
+	client := oauthClient{Platform: "web", CodeChallenge: query.Get("code_challenge"), State: query.Get("client_state")}
+	if query.Get("platform") == "android" {
+		client.Platform = "android"
+	}
+	if link == "" && (!clientParamPattern.MatchString(client.CodeChallenge) || !clientParamPattern.MatchString(client.State)) {
+		http.Redirect(w, r, client.redirectTarget()+"?error=invalid_request", http.StatusTemporaryRedirect)
 		return
 	}
 
-	token, err := googleOauthConfig.Exchange(context.Background(), code)
+	if err := startOAuthFlow(w, r, provider, client, link); err != nil {
+		fmt.Printf("❌ Erreur démarrage connexion %s: %v\n", provider.Name(), err)
+		http.Redirect(w, r, frontendURL()+"/login?error=provider_unavailable", http.StatusTemporaryRedirect)
+	}
+}
+
+func startOAuthFlow(w http.ResponseWriter, r *http.Request, provider oauth.Provider, client oauthClient, link string) error {
+	state, err := generateStateToken()
 	if err != nil {
-		fmt.Printf("❌ ERREUR ÉCHANGE TOKEN: %v\n", err)
-		http.Redirect(w, r, os.Getenv("FRONTEND_URL")+"?error=token_exchange_failed", http.StatusTemporaryRedirect)
-		return
+		return err
+	}
+	nonce, err := generateStateToken()
+	if err != nil {
+		return err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		return err
+	}
+
+	maxAge := int(oauthFlowTTL.Seconds())
+	setOAuthCookie(w, r, oauthStateCookie, provider.Name()+":"+state, maxAge)
+	setOAuthCookie(w, r, oauthNonceCookie, nonce, maxAge)
+	setOAuthCookie(w, r, oauthVerifierCookie, verifier, maxAge)
+	if link != "" {
+		setOAuthCookie(w, r, oauthLinkCookie, link, maxAge)
+		setOAuthCookie(w, r, oauthClientCookie, "", -1)
+	} else {
+		setOAuthCookie(w, r, oauthLinkCookie, "", -1)
+		setOAuthCookie(w, r, oauthClientCookie, client.cookieValue(), maxAge)
+	}
+	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+	return nil
+}
+
+// OAuthCallback termine la connexion : vérifie le state, échange le code,
+// retrouve le compte par (fournisseur, sujet) et remet au client un code de
+// passation à échanger sur POST /api/auth/exchange (aucun token dans l'URL)
+func OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	fURL := frontendURL()
+
+	var client oauthClient
+	clientCookie, errClient := r.Cookie(oauthClientCookie)
+	if errClient == nil {
+		client, _ = parseOAuthClient(clientCookie.Value)
+	}
+	target := client.redirectTarget()
+	redirectError := func(code string) {
+		http.Redirect(w, r, target+"?error="+code, http.StatusTemporaryRedirect)
 	}
 
-	// 2. Récupération des infos utilisateur chez Google
-	client := googleOauthConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	provider, err := oauth.Lookup(mux.Vars(r)["provider"])
 	if err != nil {
-		http.Redirect(w, r, os.Getenv("FRONTEND_URL")+"?error=failed_to_get_user_info", http.StatusTemporaryRedirect)
+		redirectError("unknown_provider")
 		return
 	}
-	defer resp.Body.Close()
+	fmt.Printf("🚀 Callback %s reçu...\n", provider.Name())
 
-	var googleUser struct {
-		Email   string `json:"email"`
-		Name    string `json:"name"`
-		Picture string `json:"picture"`
+	// 1. Le state doit correspondre au cookie posé par OAuthLogin (et au même fournisseur)
+	stateCookie, errState := r.Cookie(oauthStateCookie)
+	nonceCookie, errNonce := r.Cookie(oauthNonceCookie)
+	verifierCookie, errVerifier := r.Cookie(oauthVerifierCookie)
+	linkCookie, _ := r.Cookie(oauthLinkCookie)
+	for _, name := range []string{oauthStateCookie, oauthNonceCookie, oauthVerifierCookie, oauthLinkCookie, oauthClientCookie} {
+		setOAuthCookie(w, r, name, "", -1)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&googleUser); err != nil {
-		http.Redirect(w, r, os.Getenv("FRONTEND_URL")+"?error=failed_to_decode_user", http.StatusTemporaryRedirect)
+	// Hors rattachement, le client doit s'être identifié (state + PKCE) au départ
+	isLink := linkCookie != nil && linkCookie.Value != ""
+	state := r.URL.Query().Get("state")
+	if errState != nil || errNonce != nil || errVerifier != nil || state == "" ||
+		stateCookie.Value != provider.Name()+":"+state || (!isLink && client.State == "") {
+		fmt.Printf("❌ State OAuth invalide pour %s\n", provider.Name())
+		redirectError("invalid_state")
 		return
 	}
 
-	// 3. Parsing du nom
-	firstName := googleUser.Name
-	lastName := ""
-	if parts := strings.SplitN(googleUser.Name, " ", 2); len(parts) > 1 {
-		firstName = parts[0]
-		lastName = parts[1]
+	if providerErr := r.URL.Query().Get("error"); providerErr != "" {
+		fmt.Printf("⚠️  Connexion %s refusée: %s\n", provider.Name(), providerErr)
+		redirectError("access_denied")
+		return
 	}
 
-	// 4. Vérification/Création de l'utilisateur
-	var user models.User
-	err = database.DB.QueryRow(
-		"SELECT id, first_name, last_name, email, role, email_verified, created_at FROM users WHERE email=$1",
-		googleUser.Email,
-	).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.EmailVerified, &user.CreatedAt)
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		redirectError("no_code")
+		return
+	}
 
+	// 2. Échange du code et vérification de l'identité
+	identity, err := provider.Exchange(r.Context(), code, nonceCookie.Value, verifierCookie.Value)
 	if err != nil {
-		fmt.Println("🆕 Nouvel utilisateur Google, création...")
-		// ✅ L'utilisateur n'existe pas, on le crée avec email_verified = true
-		err = database.DB.QueryRow(`
-			INSERT INTO users (first_name, last_name, email, password_hash, role, email_verified)
-			VALUES ($1, $2, $3, $4, $5, $6)
-			RETURNING id, first_name, last_name, email, role, email_verified, created_at
-		`, firstName, lastName, googleUser.Email, "", "user", true).
-			Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.EmailVerified, &user.CreatedAt)
+		fmt.Printf("❌ ERREUR ÉCHANGE %s: %v\n", provider.Name(), err)
+		redirectError("token_exchange_failed")
+		return
+	}
 
+	// Rattachement : la confirmation se fait depuis le profil, avec la session de l'utilisateur
+	if isLink {
+		confirmToken, err := services.AttachIdentityToLink(linkCookie.Value, identity)
 		if err != nil {
-			fmt.Println("❌ Erreur SQL:", err)
-			http.Redirect(w, r, os.Getenv("FRONTEND_URL")+"?error=failed_to_create_user", http.StatusTemporaryRedirect)
+			fmt.Printf("❌ Rattachement %s impossible: %v\n", provider.Name(), err)
+			http.Redirect(w, r, fURL+"/profile?link_error=link_expired", http.StatusTemporaryRedirect)
 			return
 		}
+		http.Redirect(w, r, fURL+"/profile?link_confirm="+url.QueryEscape(confirmToken), http.StatusTemporaryRedirect)
+		return
 	}
 
-	// 5. Génération JWT et redirection
-	jwtToken, err := auth.GenerateToken(uint(user.ID), user.Role)
+	// 3. Compte lié à l'identité (création ou rattachement à la première connexion)
+	user, err := services.LoginWithIdentity(identity, provider.TrustsEmail())
 	if err != nil {
-		http.Redirect(w, r, os.Getenv("FRONTEND_URL")+"?error=failed_to_generate_token", http.StatusTemporaryRedirect)
+		switch {
+		case errors.Is(err, services.ErrIdentityEmailRequired):
+			redirectError("email_not_verified")
+		case errors.Is(err, services.ErrIdentityAccountExists):
+			redirectError("account_exists")
+		default:
+			fmt.Printf("❌ Erreur compte %s: %v\n", provider.Name(), err)
+			redirectError("failed_to_create_user")
+		}
 		return
 	}
 
-	userJSON, _ := json.Marshal(user)
-	encodedUser := url.QueryEscape(string(userJSON))
-
-	fURL := os.Getenv("FRONTEND_URL")
-	if fURL == "" { 
-		fURL = "http://localhost:5173" 
+	// 4. Passation : le client échange ce code (lié à son state et à son PKCE) contre ses tokens
+	handoffCode, err := services.IssueOAuthHandoff(user.ID, provider.Name(), client.State, client.CodeChallenge)
+	if err != nil {
+		fmt.Printf("❌ Erreur code de passation %s: %v\n", provider.Name(), err)
+		redirectError("failed_to_generate_token")
+		return
 	}
 
-	redirectURL := fmt.Sprintf("%s/login?token=%s&user=%s", fURL, jwtToken, encodedUser)
-	fmt.Println("✅ Connexion Google réussie, redirection vers le front !")
+	redirectURL := fmt.Sprintf("%s?code=%s&state=%s", target, url.QueryEscape(handoffCode), url.QueryEscape(client.State))
+	fmt.Printf("✅ Connexion %s réussie, redirection vers le client (%s) !\n", provider.Name(), client.Platform)
 	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 }
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"groupie-backend/internal/search"
+	"groupie-backend/models"
+	"groupie-backend/services"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// Search cherche dans tout le catalogue (artistes, membres, titres, salles,
+// villes) sans tenir compte des accents et en tolérant les fautes de frappe.
+// GET /api/search?q=angele&type=artist,track&limit=20
+func Search(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	values := r.URL.Query()
+	errs := models.FieldErrors{}
+
+	query := strings.TrimSpace(values.Get("q"))
+	if query == "" {
+		errs["q"] = "required"
+	} else if utf8.RuneCountInString(query) > search.MaxQueryLength {
+		errs["q"] = "must be at most " + strconv.Itoa(search.MaxQueryLength) + " characters"
+	}
+
+	limit := defaultSearchLimit
+	if raw := values.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			errs["limit"] = "must be between 1 and " + strconv.Itoa(maxSearchLimit)
+		} else {
+			limit = n
+		}
+	}
+
+	types := search.Types
+	if raw := values.Get("type"); raw != "" {
+		types = nil
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.TrimSpace(t)
+			if !isSearchType(t) {
+				errs["type"] = "must be a comma-separated list of " + strings.Join(search.Types, ", ")
+				break
+			}
+			types = append(types, t)
+		}
+	}
+
+	if len(errs) > 0 {
+		writeFieldErrors(w, errs)
+		return
+	}
+
+	hits, err := artistRepo.Search(query, types, limit)
+	if err != nil {
+		log.Printf("❌ Error searching catalog: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query": query,
+		"hits":  hits,
+		"count": len(hits),
+	})
+}
+
+func isSearchType(t string) bool {
+	for _, known := range search.Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	defaultSuggestLimit = 8
+	maxSuggestLimit     = 20
+)
+
+// Suggest complète la saisie de la barre de recherche à partir de l'index en
+// mémoire (artistes, titres, salles, villes). Une saisie vide ne suggère rien.
+// GET /api/search/suggest?q=ang&limit=8
+func Suggest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	values := r.URL.Query()
+	errs := models.FieldErrors{}
+
+	query := strings.TrimSpace(values.Get("q"))
+	if utf8.RuneCountInString(query) > search.MaxQueryLength {
+		errs["q"] = "must be at most " + strconv.Itoa(search.MaxQueryLength) + " characters"
+	}
+
+	limit := defaultSuggestLimit
+	if raw := values.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxSuggestLimit {
+			errs["limit"] = "must be between 1 and " + strconv.Itoa(maxSuggestLimit)
+		} else {
+			limit = n
+		}
+	}
+
+	if len(errs) > 0 {
+		writeFieldErrors(w, errs)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":       query,
+		"suggestions": services.Suggest(query, limit),
+	})
+}
@@ -12,7 +12,6 @@ import (
 	"groupie-backend/models"
 	"groupie-backend/services"
 
-	"github.com/stripe/stripe-go/v76"
 	"github.com/stripe/stripe-go/v76/webhook"
 )
 
@@ -71,7 +70,7 @@ func CreatePaymentIntent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 4. Créer le Payment Intent via le service
-	clientSecret, amount, err := services.CreatePaymentIntent(int(claims.UserID), req)
+	resp, err := services.CreatePaymentIntent(int(claims.UserID), req)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -82,13 +81,62 @@ func CreatePaymentIntent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 5. Retourner le client_secret pour le frontend
-	log.Printf("✅ Payment Intent created for user %d: Amount=%.2f€", claims.UserID, amount)
+	log.Printf("✅ Payment Intent created for user %d: Amount=%s", claims.UserID, models.FormatAmount(resp.Amount, resp.Currency))
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.CreatePaymentIntentResponse{
-		ClientSecret: clientSecret,
-		Amount:       amount,
-	})
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ========= STRIPE CHECKOUT =========
+
+// CreateCheckoutSession crée une session Stripe Checkout hébergée (pas besoin de Stripe Elements)
+func CreateCheckoutSession(w http.ResponseWriter, r *http.Request) {
+	// 1. Récupérer l'utilisateur authentifié
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Unauthorized - JWT token required",
+		})
+		return
+	}
+
+	// 2. Parser la requête
+	var req models.CreateCheckoutSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if req.ConcertID <= 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid concert_id",
+		})
+		return
+	}
+
+	// 3. Créer la session (validation des tarifs et quantités dans le service)
+	resp, err := services.CreateCheckoutSession(int(claims.UserID), req)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	log.Printf("✅ Checkout Session created for user %d: Amount=%s", claims.UserID, models.FormatAmount(resp.Amount, resp.Currency))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 // ========= CONFIRMATION MANUELLE (Optionnel) =========
@@ -206,120 +254,24 @@ func StripeWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("🪝 Webhook received: %s", event.Type)
-
-	switch event.Type {
-	case "payment_intent.succeeded":
-		handlePaymentSucceeded(w, event)
-
-	case "payment_intent.payment_failed":
-		handlePaymentFailed(w, event)
-
-	case "payment_intent.canceled":
-		handlePaymentCanceled(w, event)
-
-	default:
-		// Événements non traités (mais on retourne 200 quand même)
-		log.Printf("ℹ️  Webhook event ignored: %s", event.Type)
-	}
-
-	// 5. Toujours retourner 200 pour éviter les retry de Stripe
-	w.WriteHeader(http.StatusOK)
-}
-
-// ========= HELPERS WEBHOOK =========
-
-// handlePaymentSucceeded traite un paiement réussi
-func handlePaymentSucceeded(w http.ResponseWriter, event stripe.Event) {
-	var paymentIntent stripe.PaymentIntent
-	err := json.Unmarshal(event.Data.Raw, &paymentIntent)
-	if err != nil {
-		log.Printf("❌ Error parsing payment_intent.succeeded: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "Error parsing webhook JSON: %v", err)
-		return
-	}
-
-	// Récupérer l'ID de réservation depuis les metadata
-	reservationID, ok := paymentIntent.Metadata["reservation_id"]
-	if !ok {
-		log.Printf("⚠️  Payment succeeded but no reservation_id in metadata: %s", paymentIntent.ID)
-		return
-	}
-
-	// Marquer la réservation comme payée
-	err = services.MarkReservationAsPaid(reservationID, paymentIntent.ID)
-	if err != nil {
-		log.Printf("❌ Failed to mark reservation as paid (ID: %s): %v", reservationID, err)
-		// On ne retourne pas d'erreur HTTP car le paiement Stripe a réussi
-		// L'admin devra gérer manuellement
-		return
-	}
-
-	log.Printf("✅ PAYMENT SUCCEEDED - Reservation #%s marked as PAID (Payment Intent: %s)",
-		reservationID, paymentIntent.ID)
-}
-
-// handlePaymentFailed traite un échec de paiement
-// handlePaymentFailed traite un échec de paiement
-func handlePaymentFailed(w http.ResponseWriter, event stripe.Event) {
-	var paymentIntent stripe.PaymentIntent
-	err := json.Unmarshal(event.Data.Raw, &paymentIntent)
-	if err != nil {
-		log.Printf("❌ Error parsing payment_intent.payment_failed: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "Error parsing webhook JSON: %v", err)
-		return
-	}
-
-	reservationID, ok := paymentIntent.Metadata["reservation_id"]
-	if !ok {
-		log.Printf("⚠️  Payment failed but no reservation_id in metadata: %s", paymentIntent.ID)
-		return
-	}
-
-	failureReason := "Payment failed"
-
-	// --- CHANGE STARTS HERE ---
-	if paymentIntent.LastPaymentError != nil {
-		// Use .Error() instead of .Message to ensure compatibility
-		failureReason = paymentIntent.LastPaymentError.Error()
-	}
-	// --- CHANGE ENDS HERE ---
-
-	err = services.MarkReservationAsFailed(reservationID, failureReason)
-	if err != nil {
-		log.Printf("❌ Failed to mark reservation as failed (ID: %s): %v", reservationID, err)
-		return
-	}
-
-	log.Printf("❌ PAYMENT FAILED - Reservation #%s marked as FAILED: %s", reservationID, failureReason)
-}
+	log.Printf("🪝 Webhook received: %s (%s)", event.Type, event.ID)
 
-// handlePaymentCanceled traite une annulation de paiement
-func handlePaymentCanceled(w http.ResponseWriter, event stripe.Event) {
-	var paymentIntent stripe.PaymentIntent
-	err := json.Unmarshal(event.Data.Raw, &paymentIntent)
+	// Enregistrer puis traiter l'événement ; les doublons sont sans effet
+	duplicate, err := services.HandleStripeEvent(event, payload)
 	if err != nil {
-		log.Printf("❌ Error parsing payment_intent.canceled: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "Error parsing webhook JSON: %v", err)
-		return
-	}
-
-	reservationID, ok := paymentIntent.Metadata["reservation_id"]
-	if !ok {
-		log.Printf("⚠️  Payment canceled but no reservation_id in metadata: %s", paymentIntent.ID)
+		// L'échec est journalisé dans stripe_events et sera rejoué par le retrier ;
+		// le 500 permet aussi à Stripe de relivrer l'événement
+		log.Printf("❌ Webhook %s (%s) processing failed: %v", event.ID, event.Type, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Webhook processing failed")
 		return
 	}
 
-	err = services.MarkReservationAsFailed(reservationID, "Payment canceled by user")
-	if err != nil {
-		log.Printf("❌ Failed to mark reservation as canceled (ID: %s): %v", reservationID, err)
-		return
+	if duplicate {
+		log.Printf("ℹ️  Duplicate webhook delivery ignored: %s", event.ID)
 	}
 
-	log.Printf("🚫 PAYMENT CANCELED - Reservation #%s marked as CANCELED", reservationID)
+	w.WriteHeader(http.StatusOK)
 }
 
 // ========= LEGACY / DEPRECATED =========
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"groupie-backend/middleware"
+	"groupie-backend/models"
+	"groupie-backend/services"
+)
+
+// ========= BILLETS =========
+
+// GetTickets liste les billets de l'utilisateur connecté
+func GetTickets(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Unauthorized - JWT token required",
+		})
+		return
+	}
+
+	tickets, err := services.GetUserTickets(int(claims.UserID))
+	if err != nil {
+		log.Printf("❌ Error fetching tickets: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tickets)
+}
+
+// GetTicketQR retourne le QR code PNG d'un billet (propriétaire ou admin)
+func GetTicketQR(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Unauthorized - JWT token required",
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ticket ID"})
+		return
+	}
+
+	ticket, err := services.GetTicket(id)
+	// Un billet d'un autre utilisateur répond 404 pour ne pas révéler son existence
+	if errors.Is(err, services.ErrTicketNotFound) || (err == nil && ticket.UserID != int(claims.UserID) && claims.Role != "admin") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Ticket not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	if size < 128 || size > 1024 {
+		size = 512
+	}
+
+	png, err := services.TicketQRCode(ticket, size)
+	if err != nil {
+		log.Printf("❌ Error generating QR code for ticket #%d: %v", id, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate QR code"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(png)
+}
+
+// GetReservationTicketsPDF retélécharge les billets PDF d'une réservation payée
+func GetReservationTicketsPDF(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Unauthorized - JWT token required",
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid reservation ID"})
+		return
+	}
+
+	pdf, err := services.GetReservationTicketsPDF(id, int(claims.UserID), claims.Role == "admin")
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, services.ErrReservationNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Reservation not found"})
+			return
+		}
+		log.Printf("❌ Error rendering tickets PDF for reservation #%d: %v", id, err)
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="billets-reservation-%d.pdf"`, id))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(pdf)
+}
+
+// ========= CONTRÔLE À L'ENTRÉE (Staff) =========
+
+// ScanTicket valide un QR code à l'entrée. Un billet refusé répond 409 avec
+// la raison (already_used, refunded, wrong_concert...) pour l'afficher au staff.
+func ScanTicket(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Unauthorized - JWT token required",
+		})
+		return
+	}
+
+	var req models.ScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Payload == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "payload is required"})
+		return
+	}
+
+	result, err := services.ScanTicket(req, int(claims.UserID))
+	if err != nil {
+		log.Printf("❌ Error scanning ticket: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case result.Valid:
+		w.WriteHeader(http.StatusOK)
+	case result.Reason == "invalid_signature":
+		w.WriteHeader(http.StatusBadRequest)
+	case result.Reason == "not_found":
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusConflict)
+	}
+	json.NewEncoder(w).Encode(result)
+}
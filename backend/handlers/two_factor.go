@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"groupie-backend/middleware"
+	"groupie-backend/models"
+	"groupie-backend/services"
+)
+
+// ========= DOUBLE AUTHENTIFICATION (2FA) =========
+
+// TwoFactorSetup démarre l'enrôlement TOTP : secret, URI otpauth:// et QR code
+func TwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Unauthorized - JWT token required",
+		})
+		return
+	}
+
+	setup, err := services.SetupTwoFactor(int(claims.UserID))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, services.ErrTwoFactorAlreadyEnabled) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("❌ Error starting 2FA setup: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(setup)
+}
+
+// TwoFactorVerify active la 2FA avec un premier code et retourne les codes de secours
+func TwoFactorVerify(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Unauthorized - JWT token required",
+		})
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "code is required"})
+		return
+	}
+
+	codes, err := services.EnableTwoFactor(int(claims.UserID), req.Code)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case errors.Is(err, services.ErrInvalidTwoFactorCode), errors.Is(err, services.ErrTwoFactorNotSetUp):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		case errors.Is(err, services.ErrTwoFactorAlreadyEnabled):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		default:
+			log.Printf("❌ Error enabling 2FA: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to enable two-factor"})
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// TwoFactorDisable désactive la 2FA (code TOTP ou code de secours requis)
+func TwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Unauthorized - JWT token required",
+		})
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "code is required"})
+		return
+	}
+
+	if err := services.DisableTwoFactor(int(claims.UserID), req.Code); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case errors.Is(err, services.ErrInvalidTwoFactorCode), errors.Is(err, services.ErrTwoFactorNotEnabled):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		default:
+			log.Printf("❌ Error disabling 2FA: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to disable two-factor"})
+		}
+		return
+	}
+
+	LogActivity(int(claims.UserID), "disable_2fa", "Two-factor authentication disabled", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// TwoFactorLogin termine une connexion 2FA : challenge + code contre les tokens
+func TwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ChallengeToken == "" || req.Code == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "challenge_token and code are required"})
+		return
+	}
+
+	login, err := services.CompleteTwoFactorLogin(req.ChallengeToken, req.Code, r.UserAgent(), middleware.ClientIP(r))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case errors.Is(err, services.ErrInvalidTwoFactorCode):
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Code invalide"})
+		case errors.Is(err, services.ErrOneTimeTokenInvalid), errors.Is(err, services.ErrOneTimeTokenExpired):
+			// Challenge expiré ou trop d'essais : il faut se reconnecter
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Session de connexion expirée, veuillez vous reconnecter"})
+		default:
+			log.Printf("❌ Error completing 2FA login: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Authentication failed"})
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(login)
+}
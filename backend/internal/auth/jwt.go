@@ -1,44 +1,88 @@
 package auth
 
 import (
-	"errors" // Added import
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret []byte
+var (
+	// signingKey signs new tokens; verificationKeys holds it plus the keys
+	// being rotated out, indexed by kid.
+	signingKey       *SigningKey
+	verificationKeys map[string]*SigningKey
+	verificationList []*SigningKey
+)
+
+// AccessTokenTTL is the lifetime of an access token. Sessions are kept alive
+// with refresh tokens, so access tokens stay short-lived.
+const AccessTokenTTL = 15 * time.Minute
+
+// InitJWT loads the signing key and the verification keys. Outside
+// APP_ENV=development a missing key is a startup error: there is no default key.
+func InitJWT() error {
+	key, err := loadSigningKey()
+	if err != nil {
+		return err
+	}
+
+	if key == nil {
+		if os.Getenv("APP_ENV") != "development" {
+			return errors.New("no JWT signing key configured: set JWT_PRIVATE_KEY_FILE (or JWT_PRIVATE_KEY)")
+		}
+		log.Println("⚠️  No JWT signing key configured. Using an ephemeral Ed25519 key (development only, tokens will not survive a restart).")
+		if key, err = generateDevelopmentKey(); err != nil {
+			return err
+		}
+	}
 
-func InitJWT() {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		// Fallback to a default secret in dev if not set, but warn
-		fmt.Println("⚠️  JWT_SECRET environment variable not set. Using a default secret (NOT SECURE FOR PRODUCTION!).")
-		jwtSecret = []byte("super-secret-jwt-key-not-for-prod")
-	} else {
-		jwtSecret = []byte(secret)
+	previous, err := loadVerificationKeys()
+	if err != nil {
+		return err
 	}
+
+	keys := map[string]*SigningKey{key.ID: key}
+	list := []*SigningKey{key}
+	for _, k := range previous {
+		if _, exists := keys[k.ID]; exists {
+			continue
+		}
+		keys[k.ID] = k
+		list = append(list, k)
+	}
+
+	signingKey = key
+	verificationKeys = keys
+	verificationList = list
+
+	log.Printf("🔑 JWT signing key %s (%s), %d verification key(s)", key.ID, key.Method.Alg(), len(list))
+	return nil
 }
 
 // Claims defines the JWT claims structure
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken creates a new JWT token for the given user ID and role, valid for 24 hours.
-func GenerateToken(userID uint, role string) (string, error) {
-	if jwtSecret == nil {
-		return "", fmt.Errorf("JWT secret not initialized. Call InitJWT() first")
+// GenerateToken creates a new JWT access token for the given user ID, role and
+// server-side session, valid for AccessTokenTTL.
+func GenerateToken(userID uint, role string, sessionID string) (string, error) {
+	if signingKey == nil {
+		return "", fmt.Errorf("JWT signing key not initialized. Call InitJWT() first")
 	}
 
-	expirationTime := time.Now().Add(24 * time.Hour)
+	expirationTime := time.Now().Add(AccessTokenTTL)
 	claims := &Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -48,8 +92,9 @@ func GenerateToken(userID uint, role string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.ID
+	tokenString, err := token.SignedString(signingKey.PrivateKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -58,15 +103,24 @@ func GenerateToken(userID uint, role string) (string, error) {
 }
 
 // ValidateToken validates the JWT token string and returns the claims if valid.
+// The key is selected by the kid header and must match the token's algorithm.
 func ValidateToken(tokenString string) (*Claims, error) {
-	if jwtSecret == nil {
-		return nil, fmt.Errorf("JWT secret not initialized. Call InitJWT() first")
+	if verificationKeys == nil {
+		return nil, fmt.Errorf("JWT signing key not initialized. Call InitJWT() first")
 	}
 
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := verificationKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -78,3 +132,17 @@ func ValidateToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// PublicJWKS returns the public verification keys as a JWK Set, so other
+// services can verify our access tokens.
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range verificationList {
+		jwk := publicJWK(key.PublicKey)
+		jwk.Kid = key.ID
+		jwk.Use = "sig"
+		jwk.Alg = key.Method.Alg()
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is an asymmetric key used to sign or verify access tokens.
+// Only the current signing key holds a private key; keys kept for rotation
+// are verification-only.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// JWK is the public representation of a verification key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// readPEM returns the first PEM block found in data.
+func readPEM(data []byte, source string) (*pem.Block, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", source)
+	}
+	return block, nil
+}
+
+// parsePrivateKey accepts PKCS#8 (RSA or Ed25519) and PKCS#1 RSA private keys.
+func parsePrivateKey(data []byte, source string) (crypto.Signer, error) {
+	block, err := readPEM(data, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", source, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", source)
+	}
+}
+
+// parsePublicKey accepts a public key (PKIX or PKCS#1) or a private key, in
+// which case only its public half is kept.
+func parsePublicKey(data []byte, source string) (crypto.PublicKey, error) {
+	block, err := readPEM(data, source)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		switch key.(type) {
+		case *rsa.PublicKey, ed25519.PublicKey:
+			return key, nil
+		}
+		return nil, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", source)
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		return key, nil
+	default:
+		signer, err := parsePrivateKey(data, source)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Public(), nil
+	}
+}
+
+// signingMethodFor picks the JWT algorithm matching the key type.
+func signingMethodFor(pub crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+}
+
+// publicJWK builds the JWK of a public key, without kid/alg.
+func publicJWK(pub crypto.PublicKey) JWK {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(k)}
+	}
+	return JWK{}
+}
+
+// keyThumbprint computes the RFC 7638 thumbprint used as default kid, so the
+// same key always gets the same ID on every instance.
+func keyThumbprint(pub crypto.PublicKey) string {
+	jwk := publicJWK(pub)
+
+	// Required members only, in lexicographic order
+	var canonical []byte
+	if jwk.Kty == "RSA" {
+		canonical, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N})
+	} else {
+		canonical, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X})
+	}
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func newSigningKey(id string, signer crypto.Signer, pub crypto.PublicKey) (*SigningKey, error) {
+	method, err := signingMethodFor(pub)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		id = keyThumbprint(pub)
+	}
+	return &SigningKey{ID: id, Method: method, PrivateKey: signer, PublicKey: pub}, nil
+}
+
+// loadSigningKey loads the current private key from JWT_PRIVATE_KEY_FILE, or
+// from JWT_PRIVATE_KEY (inline PEM) for platforms that only inject env vars.
+// It returns nil when neither is set.
+func loadSigningKey() (*SigningKey, error) {
+	var data []byte
+	source := "JWT_PRIVATE_KEY"
+
+	if path := os.Getenv("JWT_PRIVATE_KEY_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT private key: %w", err)
+		}
+		data, source = content, path
+	} else if inline := os.Getenv("JWT_PRIVATE_KEY"); inline != "" {
+		// Secrets stores often flatten newlines
+		data = []byte(strings.ReplaceAll(inline, `\n`, "\n"))
+	} else {
+		return nil, nil
+	}
+
+	signer, err := parsePrivateKey(data, source)
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(os.Getenv("JWT_KEY_ID"), signer, signer.Public())
+}
+
+// loadVerificationKeys loads the public keys listed in JWT_VERIFICATION_KEY_FILES
+// (comma-separated). These are previous signing keys kept during a rotation so
+// tokens they signed stay valid until they expire. An entry is either a path,
+// for a key that signed under its thumbprint, or kid=path for a key that
+// signed under a custom JWT_KEY_ID: tokens are looked up by the kid they carry.
+func loadVerificationKeys() ([]*SigningKey, error) {
+	var keys []*SigningKey
+	for _, entry := range strings.Split(os.Getenv("JWT_VERIFICATION_KEY_FILES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, path := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			kid, path = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+			if kid == "" || path == "" {
+				return nil, fmt.Errorf("invalid JWT verification key entry %q: expected kid=path", entry)
+			}
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT verification key: %w", err)
+		}
+		pub, err := parsePublicKey(data, path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := newSigningKey(kid, nil, pub)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// generateDevelopmentKey creates an ephemeral Ed25519 key. Tokens it signs do
+// not survive a restart, which is fine for local development only.
+func generateDevelopmentKey() (*SigningKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate development key: %w", err)
+	}
+	return newSigningKey("", priv, pub)
+}
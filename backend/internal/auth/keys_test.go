@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKeyPair writes an Ed25519 key pair as PKCS#8 / PKIX PEM files and
+// returns their paths.
+func writeKeyPair(t *testing.T, name string) (privatePath, publicPath string) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	privatePath = filepath.Join(dir, name+".pem")
+	publicPath = filepath.Join(dir, name+".pub.pem")
+	if err := os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+// signWith initializes the package with the given environment and issues a token.
+func signWith(t *testing.T, env map[string]string) string {
+	t.Helper()
+
+	for _, name := range []string{"JWT_PRIVATE_KEY_FILE", "JWT_PRIVATE_KEY", "JWT_KEY_ID", "JWT_VERIFICATION_KEY_FILES"} {
+		t.Setenv(name, env[name])
+	}
+	if err := InitJWT(); err != nil {
+		t.Fatalf("InitJWT: %v", err)
+	}
+	token, err := GenerateToken(42, "user", "session")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return token
+}
+
+func TestRotationKeepsTokensSignedUnderCustomKid(t *testing.T) {
+	oldPrivate, oldPublic := writeKeyPair(t, "old")
+	newPrivate, _ := writeKeyPair(t, "new")
+
+	oldToken := signWith(t, map[string]string{"JWT_PRIVATE_KEY_FILE": oldPrivate, "JWT_KEY_ID": "groupie-2025"})
+
+	// Rotation: the new key signs under its thumbprint, the old one is kept with its kid
+	signWith(t, map[string]string{
+		"JWT_PRIVATE_KEY_FILE":       newPrivate,
+		"JWT_VERIFICATION_KEY_FILES": "groupie-2025=" + oldPublic,
+	})
+
+	claims, err := ValidateToken(oldToken)
+	if err != nil {
+		t.Fatalf("token signed before the rotation rejected: %v", err)
+	}
+	if claims.UserID != 42 {
+		t.Errorf("claims.UserID = %d, want 42", claims.UserID)
+	}
+
+	found := false
+	for _, jwk := range PublicJWKS().Keys {
+		found = found || jwk.Kid == "groupie-2025"
+	}
+	if !found {
+		t.Error("rotated key not published under its kid in the JWKS")
+	}
+}
+
+func TestRotationWithThumbprintKid(t *testing.T) {
+	oldPrivate, oldPublic := writeKeyPair(t, "old")
+	newPrivate, _ := writeKeyPair(t, "new")
+
+	oldToken := signWith(t, map[string]string{"JWT_PRIVATE_KEY_FILE": oldPrivate})
+	signWith(t, map[string]string{"JWT_PRIVATE_KEY_FILE": newPrivate, "JWT_VERIFICATION_KEY_FILES": oldPublic})
+
+	if _, err := ValidateToken(oldToken); err != nil {
+		t.Fatalf("token signed before the rotation rejected: %v", err)
+	}
+}
+
+func TestRotationWithWrongKidRejectsOldTokens(t *testing.T) {
+	oldPrivate, oldPublic := writeKeyPair(t, "old")
+	newPrivate, _ := writeKeyPair(t, "new")
+
+	oldToken := signWith(t, map[string]string{"JWT_PRIVATE_KEY_FILE": oldPrivate, "JWT_KEY_ID": "groupie-2025"})
+	signWith(t, map[string]string{"JWT_PRIVATE_KEY_FILE": newPrivate, "JWT_VERIFICATION_KEY_FILES": oldPublic})
+
+	_, err := ValidateToken(oldToken)
+	if err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("ValidateToken = %v, want an unknown signing key error", err)
+	}
+}
+
+func TestInvalidVerificationKeyEntry(t *testing.T) {
+	t.Setenv("JWT_VERIFICATION_KEY_FILES", "=./missing.pem")
+	if _, err := loadVerificationKeys(); err == nil {
+		t.Fatal("loadVerificationKeys accepted an entry without kid")
+	}
+}
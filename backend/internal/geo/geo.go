@@ -0,0 +1,214 @@
+// Package geo provides great-circle distances, bounding boxes and an
+// in-memory grid index for "near me" and map-viewport queries.
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// EarthRadiusKm is the mean Earth radius used by Haversine.
+const EarthRadiusKm = 6371.0
+
+// MaxDistanceKm is half the Earth's circumference: no two points are further apart.
+const MaxDistanceKm = math.Pi * EarthRadiusKm
+
+// Point is a WGS 84 position in decimal degrees.
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// Valid reports whether p is a real position.
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// HaversineKm is the great-circle distance between a and b.
+func HaversineKm(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLng := radians(b.Lng - a.Lng)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// BBox is a latitude/longitude rectangle. MinLng > MaxLng means the box
+// crosses the antimeridian (e.g. 170 to -170).
+type BBox struct {
+	MinLat, MinLng, MaxLat, MaxLng float64
+}
+
+// CrossesAntimeridian reports whether the box wraps around longitude ±180.
+func (b BBox) CrossesAntimeridian() bool {
+	return b.MinLng > b.MaxLng
+}
+
+// Contains reports whether p lies in the box, edges included.
+func (b BBox) Contains(p Point) bool {
+	if p.Lat < b.MinLat || p.Lat > b.MaxLat {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return p.Lng >= b.MinLng || p.Lng <= b.MaxLng
+	}
+	return p.Lng >= b.MinLng && p.Lng <= b.MaxLng
+}
+
+// Center is the middle of the box, across the antimeridian if it wraps.
+func (b BBox) Center() Point {
+	maxLng := b.MaxLng
+	if b.CrossesAntimeridian() {
+		maxLng += 360
+	}
+	lng := (b.MinLng + maxLng) / 2
+	if lng > 180 {
+		lng -= 360
+	}
+	return Point{Lat: (b.MinLat + b.MaxLat) / 2, Lng: lng}
+}
+
+// Around returns a box containing every point within radiusKm of center.
+// Near the poles, or for large radii, it spans all longitudes.
+func Around(center Point, radiusKm float64) BBox {
+	dLat := radiusKm / EarthRadiusKm * 180 / math.Pi
+	box := BBox{MinLat: math.Max(-90, center.Lat-dLat), MaxLat: math.Min(90, center.Lat+dLat), MinLng: -180, MaxLng: 180}
+	if box.MinLat == -90 || box.MaxLat == 90 {
+		return box
+	}
+
+	// Widest longitude span of the circle, reached at the tangent latitude
+	ratio := math.Sin(radiusKm/EarthRadiusKm) / math.Cos(radians(center.Lat))
+	if ratio >= 1 {
+		return box
+	}
+	dLng := math.Asin(ratio) * 180 / math.Pi
+	box.MinLng, box.MaxLng = wrapLng(center.Lng-dLng), wrapLng(center.Lng+dLng)
+	return box
+}
+
+func wrapLng(lng float64) float64 {
+	switch {
+	case lng < -180:
+		return lng + 360
+	case lng > 180:
+		return lng - 360
+	}
+	return lng
+}
+
+// Match is an indexed item found by a query, with its distance to the
+// query's reference point.
+type Match struct {
+	Item       int // position of the point given to NewIndex
+	DistanceKm float64
+}
+
+// cellDegrees is the size of a grid cell. One degree keeps a city-scale
+// query to a handful of cells.
+const cellDegrees = 1.0
+
+type cell struct{ lat, lng int }
+
+// Index is an immutable grid of points, queried by radius or box.
+type Index struct {
+	points []Point
+	cells  map[cell][]int
+}
+
+// NewIndex indexes points; results refer to them by position.
+func NewIndex(points []Point) *Index {
+	ix := &Index{points: points, cells: map[cell][]int{}}
+	for i, p := range points {
+		c := cellOf(p)
+		ix.cells[c] = append(ix.cells[c], i)
+	}
+	return ix
+}
+
+func cellOf(p Point) cell {
+	lat, lng := int(math.Floor(p.Lat/cellDegrees)), int(math.Floor(p.Lng/cellDegrees))
+	// The ±90 / 180 edges belong to the last cell
+	if p.Lat == 90 {
+		lat--
+	}
+	if p.Lng == 180 {
+		lng--
+	}
+	return cell{lat, lng}
+}
+
+// Nearby returns the points within radiusKm of center, nearest first, at
+// most limit of them.
+func (ix *Index) Nearby(center Point, radiusKm float64, limit int) []Match {
+	var matches []Match
+	ix.scan(Around(center, radiusKm), func(i int) {
+		if d := HaversineKm(center, ix.points[i]); d <= radiusKm {
+			matches = append(matches, Match{Item: i, DistanceKm: d})
+		}
+	})
+	return sortMatches(matches, limit)
+}
+
+// Within returns the points inside box, nearest to from first, at most limit
+// of them.
+func (ix *Index) Within(box BBox, from Point, limit int) []Match {
+	var matches []Match
+	ix.scan(box, func(i int) {
+		if box.Contains(ix.points[i]) {
+			matches = append(matches, Match{Item: i, DistanceKm: HaversineKm(from, ix.points[i])})
+		}
+	})
+	return sortMatches(matches, limit)
+}
+
+// scan calls fn for every point in the cells overlapping box.
+func (ix *Index) scan(box BBox, fn func(int)) {
+	minCell, maxCell := cellOf(Point{box.MinLat, box.MinLng}), cellOf(Point{box.MaxLat, box.MaxLng})
+	lngRanges := [][2]int{{minCell.lng, maxCell.lng}}
+	if box.CrossesAntimeridian() {
+		last := cellOf(Point{0, 180}).lng
+		first := cellOf(Point{0, -180}).lng
+		lngRanges = [][2]int{{minCell.lng, last}, {first, maxCell.lng}}
+	}
+
+	// A box wider than the populated cells is cheaper to check point by point
+	cellCount := 0
+	for _, r := range lngRanges {
+		cellCount += (maxCell.lat - minCell.lat + 1) * (r[1] - r[0] + 1)
+	}
+	if cellCount > len(ix.cells) {
+		for i := range ix.points {
+			fn(i)
+		}
+		return
+	}
+
+	for lat := minCell.lat; lat <= maxCell.lat; lat++ {
+		for _, r := range lngRanges {
+			for lng := r[0]; lng <= r[1]; lng++ {
+				for _, i := range ix.cells[cell{lat, lng}] {
+					fn(i)
+				}
+			}
+		}
+	}
+}
+
+func sortMatches(matches []Match, limit int) []Match {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].DistanceKm != matches[j].DistanceKm {
+			return matches[i].DistanceKm < matches[j].DistanceKm
+		}
+		return matches[i].Item < matches[j].Item
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
@@ -0,0 +1,269 @@
+// Package listquery parses the query string of list endpoints (limit, cursor,
+// sort, filters) against a declared Spec, and applies it either as SQL for the
+// Postgres repositories or to a slice for the in-memory ones. Both paths share
+// the same semantics, so an endpoint behaves the same whatever its backend.
+//
+// Pagination is keyset based: the cursor carries the sort value and the ID of
+// the last item returned, and the next page starts strictly after them.
+package listquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"groupie-backend/models"
+)
+
+// Kind is the type of a field, used to parse request values and compare items.
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Bool
+	Time
+)
+
+// Op is the comparison a filter applies.
+type Op int
+
+const (
+	Eq       Op = iota // case-insensitive for strings
+	Contains           // case-insensitive substring, OR across the filter's fields
+	Gte
+	Lte
+)
+
+// Field is a column that can be filtered or sorted on. Column is a trusted SQL
+// expression (never built from the request); Value reads the same data from an
+// in-memory item and returns a string, int, int64, bool or time.Time.
+type Field[T any] struct {
+	Column string
+	Kind   Kind
+	Value  func(T) any
+}
+
+// Filter maps a query parameter to fields. With several fields (Contains
+// only), an item matches if any of them does.
+type Filter struct {
+	Param  string
+	Fields []string
+	Op     Op
+}
+
+// Spec declares what a list endpoint accepts.
+type Spec[T any] struct {
+	Fields       map[string]Field[T]
+	Filters      []Filter
+	Sorts        []string // sortable field names; "-name" in the request sorts descending
+	DefaultSort  string   // e.g. "date" or "-created_at"
+	ID           Field[T] // unique tie-breaker for keyset pagination, must be an Int
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Condition is a parsed filter.
+type Condition struct {
+	Fields []string
+	Op     Op
+	Value  any
+}
+
+// Query is a parsed list request.
+type Query struct {
+	Limit      int
+	Sort       string
+	Desc       bool
+	Conditions []Condition
+	after      *cursor
+}
+
+// Page is the envelope returned by every list endpoint.
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+	Total      int     `json:"total"`
+	Limit      int     `json:"limit"`
+}
+
+type cursor struct {
+	Sort  string `json:"s"` // sort the cursor was issued for, "-" prefix when descending
+	Value any    `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// Parse reads limit, cursor, sort and the declared filters. Invalid
+// parameters are reported per parameter as models.FieldErrors.
+func (s *Spec[T]) Parse(values url.Values) (Query, error) {
+	errs := models.FieldErrors{}
+	q := Query{Limit: s.DefaultLimit}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > s.MaxLimit {
+			errs["limit"] = fmt.Sprintf("must be between 1 and %d", s.MaxLimit)
+		} else {
+			q.Limit = limit
+		}
+	}
+
+	sort := values.Get("sort")
+	if sort == "" {
+		sort = s.DefaultSort
+	}
+	q.Sort, q.Desc = strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+	if !s.sortable(q.Sort) {
+		errs["sort"] = "must be one of " + strings.Join(s.Sorts, ", ") + " (prefix with - to sort descending)"
+	}
+
+	for _, f := range s.Filters {
+		raw := strings.TrimSpace(values.Get(f.Param))
+		if raw == "" {
+			continue
+		}
+		value, err := parseValue(s.Fields[f.Fields[0]].Kind, raw, f.Op)
+		if err != nil {
+			errs[f.Param] = err.Error()
+			continue
+		}
+		q.Conditions = append(q.Conditions, Condition{Fields: f.Fields, Op: f.Op, Value: value})
+	}
+
+	if raw := values.Get("cursor"); raw != "" && errs["sort"] == "" {
+		c, err := s.decodeCursor(raw, sort)
+		if err != nil {
+			errs["cursor"] = err.Error()
+		} else {
+			q.after = c
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		return Query{}, err
+	}
+	return q, nil
+}
+
+func (s *Spec[T]) sortable(name string) bool {
+	for _, field := range s.Sorts {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+func parseValue(kind Kind, raw string, op Op) (any, error) {
+	switch kind {
+	case Int:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return v, nil
+	case Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return v, nil
+	case Time:
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		day, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be a date (2006-01-02) or an RFC 3339 timestamp")
+		}
+		if op == Lte {
+			// A bare date as upper bound includes the whole day
+			return day.Add(24*time.Hour - time.Nanosecond), nil
+		}
+		return day, nil
+	}
+	if len(raw) > 200 {
+		return nil, fmt.Errorf("must be at most 200 characters")
+	}
+	return raw, nil
+}
+
+func (s *Spec[T]) decodeCursor(raw, sort string) (*cursor, error) {
+	invalid := fmt.Errorf("invalid cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, invalid
+	}
+	if c.Sort != sort {
+		return nil, fmt.Errorf("cursor was issued for another sort order")
+	}
+
+	// JSON numbers and timestamps come back as float64 and string
+	switch s.Fields[strings.TrimPrefix(sort, "-")].Kind {
+	case Int:
+		n, ok := c.Value.(float64)
+		if !ok {
+			return nil, invalid
+		}
+		c.Value = int64(n)
+	case Bool:
+		if _, ok := c.Value.(bool); !ok {
+			return nil, invalid
+		}
+	case Time:
+		str, ok := c.Value.(string)
+		if !ok {
+			return nil, invalid
+		}
+		t, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return nil, invalid
+		}
+		c.Value = t
+	default:
+		if _, ok := c.Value.(string); !ok {
+			return nil, invalid
+		}
+	}
+	return &c, nil
+}
+
+// cursorAfter builds the cursor pointing after item.
+func (s *Spec[T]) cursorAfter(q Query, item T) *string {
+	sort := q.Sort
+	if q.Desc {
+		sort = "-" + sort
+	}
+	value := normalize(s.Fields[q.Sort].Value(item))
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC().Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(cursor{Sort: sort, Value: value, ID: toInt64(s.ID.Value(item))})
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return &encoded
+}
+
+// normalize widens ints so that values compare and serialize consistently.
+func normalize(v any) any {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	}
+	return v
+}
+
+func toInt64(v any) int64 {
+	n, _ := normalize(v).(int64)
+	return n
+}
@@ -0,0 +1,116 @@
+package listquery
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Apply runs q over an in-memory slice, with the same semantics as Fetch.
+// items is not modified.
+func (s *Spec[T]) Apply(q Query, items []T) Page[T] {
+	page := Page[T]{Items: []T{}, Limit: q.Limit}
+
+	var matched []T
+	for _, item := range items {
+		if s.matches(q, item) {
+			matched = append(matched, item)
+		}
+	}
+	page.Total = len(matched)
+
+	sortField := s.Fields[q.Sort]
+	less := func(a, b T) bool {
+		if c := compare(sortField.Value(a), sortField.Value(b)); c != 0 {
+			return (c < 0) != q.Desc
+		}
+		return (toInt64(s.ID.Value(a)) < toInt64(s.ID.Value(b))) != q.Desc
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+
+	start := 0
+	if q.after != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			item := matched[i]
+			c := compare(sortField.Value(item), q.after.Value)
+			if c == 0 {
+				c = compare(s.ID.Value(item), q.after.ID)
+			}
+			if q.Desc {
+				return c < 0
+			}
+			return c > 0
+		})
+	}
+
+	end := start + q.Limit
+	if end < len(matched) {
+		page.NextCursor = s.cursorAfter(q, matched[end-1])
+	} else {
+		end = len(matched)
+	}
+	page.Items = append(page.Items, matched[start:end]...)
+	return page
+}
+
+func (s *Spec[T]) matches(q Query, item T) bool {
+	for _, c := range q.Conditions {
+		ok := false
+		for _, name := range c.Fields {
+			value := s.Fields[name].Value(item)
+			switch c.Op {
+			case Contains:
+				str, _ := value.(string)
+				ok = strings.Contains(strings.ToLower(str), strings.ToLower(c.Value.(string)))
+			case Gte:
+				ok = compare(value, c.Value) >= 0
+			case Lte:
+				ok = compare(value, c.Value) <= 0
+			default:
+				if str, isString := value.(string); isString {
+					ok = strings.EqualFold(str, c.Value.(string))
+				} else {
+					ok = compare(value, c.Value) == 0
+				}
+			}
+			if ok {
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compare orders two values of the same Kind.
+func compare(a, b any) int {
+	a, b = normalize(a), normalize(b)
+	switch x := a.(type) {
+	case int64:
+		y, _ := b.(int64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	case string:
+		y, _ := b.(string)
+		return strings.Compare(x, y)
+	case bool:
+		y, _ := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case time.Time:
+		y, _ := b.(time.Time)
+		return x.Compare(y)
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package listquery
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Fetch runs q against Postgres: one COUNT(*) over the filtered rows for the
+// total, and one page query ordered on the sort field then the ID. from is the
+// FROM clause (with its joins) the Spec's columns refer to, and columns the
+// SELECT list scan reads. Sort columns must never be NULL, as keyset
+// comparisons would skip those rows.
+func Fetch[T any](db *sql.DB, s *Spec[T], q Query, columns, from string, scan func(*sql.Rows) (T, error)) (Page[T], error) {
+	page := Page[T]{Items: []T{}, Limit: q.Limit}
+
+	conditions, args := s.where(q)
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) `+from+where, args...).Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("error counting rows: %w", err)
+	}
+
+	sortColumn, idColumn := s.Fields[q.Sort].Column, s.ID.Column
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if q.after != nil {
+		args = append(args, q.after.Value, q.after.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, %s) %s ($%d, $%d)", sortColumn, idColumn, cmp, len(args)-1, len(args)))
+	}
+	where = ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query := fmt.Sprintf(`SELECT %s %s%s ORDER BY %s %s, %s %s LIMIT %d`,
+		columns, from, where, sortColumn, dir, idColumn, dir, q.Limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return page, fmt.Errorf("error fetching rows: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return page, fmt.Errorf("error scanning row: %w", err)
+		}
+		page.Items = append(page.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return page, fmt.Errorf("error fetching rows: %w", err)
+	}
+
+	// The extra row only tells whether another page exists
+	if len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		page.NextCursor = s.cursorAfter(q, page.Items[q.Limit-1])
+	}
+	return page, nil
+}
+
+// where renders the filters as SQL conditions with numbered placeholders.
+func (s *Spec[T]) where(q Query) ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	for _, c := range q.Conditions {
+		args = append(args, sqlValue(c))
+		placeholder := fmt.Sprintf("$%d", len(args))
+
+		var alternatives []string
+		for _, name := range c.Fields {
+			field := s.Fields[name]
+			switch {
+			case c.Op == Contains:
+				alternatives = append(alternatives, field.Column+` ILIKE `+placeholder+` ESCAPE '\'`)
+			case c.Op == Gte:
+				alternatives = append(alternatives, field.Column+` >= `+placeholder)
+			case c.Op == Lte:
+				alternatives = append(alternatives, field.Column+` <= `+placeholder)
+			case field.Kind == String:
+				alternatives = append(alternatives, `LOWER(`+field.Column+`) = LOWER(`+placeholder+`)`)
+			default:
+				alternatives = append(alternatives, field.Column+` = `+placeholder)
+			}
+		}
+		conditions = append(conditions, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	return conditions, args
+}
+
+// sqlValue escapes LIKE wildcards so that a search matches them literally.
+func sqlValue(c Condition) any {
+	if c.Op != Contains {
+		return c.Value
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(c.Value.(string))
+	return "%" + escaped + "%"
+}
@@ -0,0 +1,149 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+// GitHubConfig configures the GitHub provider. The endpoints default to
+// github.com and only need overriding for GitHub Enterprise or tests.
+type GitHubConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Endpoint     oauth2.Endpoint
+	APIURL       string
+	HTTPClient   *http.Client
+}
+
+// GitHubProvider logs users in with a GitHub OAuth app. GitHub is not an
+// OIDC provider: the subject is the numeric user ID (stable across renames)
+// and the email is the primary address from /user/emails, if verified.
+type GitHubProvider struct {
+	config *oauth2.Config
+	apiURL string
+	client *http.Client
+}
+
+// NewGitHubProvider validates cfg and builds the provider.
+func NewGitHubProvider(cfg GitHubConfig) (*GitHubProvider, error) {
+	if cfg.ClientID == "" || cfg.ClientSecret == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("client ID, client secret and redirect URL are required")
+	}
+	if cfg.Endpoint.AuthURL == "" {
+		cfg.Endpoint = github.Endpoint
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = "https://api.github.com"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = defaultHTTPClient
+	}
+
+	return &GitHubProvider{
+		config: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       []string{"read:user", "user:email"},
+			Endpoint:     cfg.Endpoint,
+		},
+		apiURL: strings.TrimRight(cfg.APIURL, "/"),
+		client: cfg.HTTPClient,
+	}, nil
+}
+
+func (p *GitHubProvider) Name() string        { return "github" }
+func (p *GitHubProvider) DisplayName() string { return "GitHub" }
+
+// TrustsEmail is true: GitHub only reports an address as verified after the
+// user confirmed it.
+func (p *GitHubProvider) TrustsEmail() bool { return true }
+
+func (p *GitHubProvider) AuthCodeURL(_ context.Context, state, _, verifier string) (string, error) {
+	return p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, code, _, verifier string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	client := p.config.Client(ctx, token)
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getJSON(client, "/user", &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("github user has no id")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(client, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{Provider: p.Name(), Subject: strconv.FormatInt(user.ID, 10)}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+			break
+		}
+	}
+
+	name := user.Name
+	if name == "" {
+		name = user.Login
+	}
+	identity.FirstName, identity.LastName = splitName(name)
+	return identity, nil
+}
+
+func (p *GitHubProvider) getJSON(client *http.Client, path string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github %s: unexpected status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("github %s: %w", path, err)
+	}
+	return nil
+}
+
+// splitName splits a display name into first and last name on the first space.
+func splitName(name string) (string, string) {
+	name = strings.TrimSpace(name)
+	if first, last, ok := strings.Cut(name, " "); ok {
+		return first, strings.TrimSpace(last)
+	}
+	return name, ""
+}
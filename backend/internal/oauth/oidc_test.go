@@ -0,0 +1,396 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "groupie-client"
+	testNonce    = "nonce-123"
+)
+
+// fakeIdP is a minimal OpenID Connect provider: discovery, JWKS, token and
+// userinfo endpoints. The token endpoint returns whatever ID token the test
+// prepared.
+type fakeIdP struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	keys       map[string]*ecdsa.PrivateKey // published keys, by kid
+	idToken    string
+	userinfo   map[string]any
+	jwksHits   int
+	tokenForms []map[string]string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+
+	idp := &fakeIdP{keys: map[string]*ecdsa.PrivateKey{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"userinfo_endpoint":      idp.URL + "/userinfo",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		idp.jwksHits++
+
+		keys := []map[string]string{}
+		for kid, key := range idp.keys {
+			keys = append(keys, map[string]string{
+				"kty": "EC", "kid": kid, "use": "sig", "crv": "P-256",
+				"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+				"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+		writeJSON(w, map[string]any{"keys": keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		form := map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		idp.tokenForms = append(idp.tokenForms, form)
+		writeJSON(w, map[string]any{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idp.idToken,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		writeJSON(w, idp.userinfo)
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// addKey publishes a new signing key under kid.
+func (idp *fakeIdP) addKey(t *testing.T, kid string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys[kid] = key
+}
+
+// validClaims are the claims of an ID token the provider must accept.
+func (idp *fakeIdP) validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.URL,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          testNonce,
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"given_name":     "Ada",
+		"family_name":    "Lovelace",
+	}
+}
+
+// issue signs claims with the key published under kid (or an unpublished key
+// when kid is unknown) and makes it the next token response.
+func (idp *fakeIdP) issue(t *testing.T, kid string, claims jwt.MapClaims) {
+	t.Helper()
+
+	idp.mu.Lock()
+	key := idp.keys[kid]
+	idp.mu.Unlock()
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.idToken = signed
+}
+
+func (idp *fakeIdP) jwksFetches() int {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.jwksHits
+}
+
+func newTestProvider(t *testing.T, idp *fakeIdP) *OIDCProvider {
+	t.Helper()
+
+	p, err := NewOIDCProvider(OIDCConfig{
+		Name:         "test",
+		Issuer:       idp.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/api/auth/test/callback",
+		HTTPClient:   idp.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOIDCExchangeValidToken(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.addKey(t, "key-1")
+	idp.issue(t, "key-1", idp.validClaims())
+	p := newTestProvider(t, idp)
+
+	identity, err := p.Exchange(context.Background(), "auth-code", testNonce, "pkce-verifier")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := Identity{Provider: "test", Subject: "user-1", Email: "ada@example.com", EmailVerified: true, FirstName: "Ada", LastName: "Lovelace"}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+
+	form := idp.tokenForms[0]
+	if form["code"] != "auth-code" || form["code_verifier"] != "pkce-verifier" {
+		t.Errorf("token request = %v, want the code and the PKCE verifier", form)
+	}
+}
+
+func TestOIDCExchangeRejectsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(claims jwt.MapClaims)
+		want   string
+	}{
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, "issuer"},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "other-client" }, "audience"},
+		{"wrong nonce", func(c jwt.MapClaims) { c["nonce"] = "replayed-nonce" }, "nonce"},
+		{"missing nonce", func(c jwt.MapClaims) { delete(c, "nonce") }, "nonce"},
+		{"expired", func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-2 * idTokenLeeway).Unix()
+			c["iat"] = time.Now().Add(-time.Hour).Unix()
+		}, "expired"},
+		{"missing expiry", func(c jwt.MapClaims) { delete(c, "exp") }, "exp"},
+		{"issued in the future", func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() }, "used before issued"},
+		{"missing subject", func(c jwt.MapClaims) { delete(c, "sub") }, "sub"},
+		{"several audiences without azp", func(c jwt.MapClaims) { c["aud"] = []string{testClientID, "other-client"} }, "azp"},
+		{"azp of another client", func(c jwt.MapClaims) { c["azp"] = "other-client" }, "azp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newFakeIdP(t)
+			idp.addKey(t, "key-1")
+			claims := idp.validClaims()
+			tt.mutate(claims)
+			idp.issue(t, "key-1", claims)
+
+			_, err := newTestProvider(t, idp).Exchange(context.Background(), "auth-code", testNonce, "pkce-verifier")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Exchange = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeAcceptsMatchingAzp(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.addKey(t, "key-1")
+	claims := idp.validClaims()
+	claims["aud"] = []string{testClientID, "other-client"}
+	claims["azp"] = testClientID
+	idp.issue(t, "key-1", claims)
+
+	if _, err := newTestProvider(t, idp).Exchange(context.Background(), "auth-code", testNonce, "pkce-verifier"); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+}
+
+func TestOIDCRefetchesJWKSForUnknownKid(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.addKey(t, "key-1")
+	p := newTestProvider(t, idp)
+	ctx := context.Background()
+
+	idp.issue(t, "key-1", idp.validClaims())
+	if _, err := p.Exchange(ctx, "auth-code", testNonce, "pkce-verifier"); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+	if n := idp.jwksFetches(); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", n)
+	}
+
+	// A known kid is served from the cache
+	if _, err := p.Exchange(ctx, "auth-code", testNonce, "pkce-verifier"); err != nil {
+		t.Fatalf("second Exchange: %v", err)
+	}
+	if n := idp.jwksFetches(); n != 1 {
+		t.Fatalf("JWKS fetched %d times for a cached kid, want 1", n)
+	}
+
+	// Within the refresh interval an unknown kid does not hit the IdP again
+	idp.addKey(t, "key-2")
+	idp.issue(t, "key-2", idp.validClaims())
+	if _, err := p.Exchange(ctx, "auth-code", testNonce, "pkce-verifier"); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("Exchange = %v, want an unknown signing key error", err)
+	}
+	if n := idp.jwksFetches(); n != 1 {
+		t.Fatalf("JWKS fetched %d times within the refresh interval, want 1", n)
+	}
+
+	// Once it has elapsed, the rotated key is fetched
+	p.mu.Lock()
+	p.keysFetchedAt = time.Now().Add(-2 * jwksRefreshInterval)
+	p.mu.Unlock()
+	if _, err := p.Exchange(ctx, "auth-code", testNonce, "pkce-verifier"); err != nil {
+		t.Fatalf("Exchange after rotation: %v", err)
+	}
+	if n := idp.jwksFetches(); n != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", n)
+	}
+
+	// A kid the IdP never published is still rejected after the refetch
+	p.mu.Lock()
+	p.keysFetchedAt = time.Now().Add(-2 * jwksRefreshInterval)
+	p.mu.Unlock()
+	idp.issue(t, "forged", idp.validClaims())
+	if _, err := p.Exchange(ctx, "auth-code", testNonce, "pkce-verifier"); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("Exchange = %v, want an unknown signing key error", err)
+	}
+}
+
+func TestOIDCRejectsTokenSignedByAnotherKey(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.addKey(t, "key-1")
+	p := newTestProvider(t, idp)
+
+	// Signed with a key of our own under the published kid
+	forged := jwt.NewWithClaims(jwt.SigningMethodES256, idp.validClaims())
+	forged.Header["kid"] = "key-1"
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signed, err := forged.SignedString(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.idToken = signed
+	idp.mu.Unlock()
+
+	if _, err := p.Exchange(context.Background(), "auth-code", testNonce, "pkce-verifier"); err == nil {
+		t.Fatal("Exchange accepted a token with an invalid signature")
+	}
+}
+
+func TestOIDCUserinfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		userinfo  map[string]any
+		wantErr   string
+		wantEmail string
+	}{
+		{
+			name:      "fills the missing email",
+			userinfo:  map[string]any{"sub": "user-1", "email": "ada@example.com", "email_verified": "true"},
+			wantEmail: "ada@example.com",
+		},
+		{
+			name:     "sub mismatch",
+			userinfo: map[string]any{"sub": "someone-else", "email": "victim@example.com", "email_verified": true},
+			wantErr:  "userinfo sub does not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newFakeIdP(t)
+			idp.addKey(t, "key-1")
+			claims := idp.validClaims()
+			delete(claims, "email")
+			delete(claims, "email_verified")
+			idp.issue(t, "key-1", claims)
+			idp.userinfo = tt.userinfo
+
+			identity, err := newTestProvider(t, idp).Exchange(context.Background(), "auth-code", testNonce, "pkce-verifier")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Exchange = %v, want an error mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if identity.Email != tt.wantEmail || !identity.EmailVerified {
+				t.Errorf("identity email = %q (verified %v), want %q (verified)", identity.Email, identity.EmailVerified, tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newFakeIdP(t)
+	p, err := NewOIDCProvider(OIDCConfig{
+		Name:         "test",
+		Issuer:       idp.URL + "/",
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/api/auth/test/callback",
+		HTTPClient:   idp.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.AuthCodeURL(context.Background(), "state", testNonce, "pkce-verifier"); err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Fatalf("AuthCodeURL = %v, want an issuer mismatch error", err)
+	}
+}
@@ -0,0 +1,199 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Identity is the account asserted by an external provider once the
+// authorization code has been exchanged. Accounts are keyed by
+// (Provider, Subject); the email is only used to create or link an account.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// Provider is an external login provider served under /api/auth/{name}.
+type Provider interface {
+	Name() string
+	DisplayName() string
+	// TrustsEmail reports whether a verified email asserted by this provider
+	// may be used to link the identity to an existing local account.
+	TrustsEmail() bool
+	// AuthCodeURL returns the authorization URL for a login attempt. nonce
+	// is bound to the ID token and verifier is the PKCE code verifier.
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange trades the authorization code for the user's identity.
+	Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error)
+}
+
+// ErrUnknownProvider is returned for a provider name that is not configured.
+var ErrUnknownProvider = errors.New("unknown login provider")
+
+var (
+	registryMu sync.RWMutex
+	providers  = map[string]Provider{}
+	order      []string
+)
+
+// providerNamePattern keeps provider names usable as a URL path segment.
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// reservedNames collide with the other routes of /api/auth.
+var reservedNames = map[string]bool{
+	"providers": true, "register": true, "login": true, "logout": true, "logout-all": true,
+	"refresh": true, "2fa": true, "verify-email": true, "send-verification": true,
+	"request-password-reset": true, "reset-password": true,
+}
+
+// Register adds a provider to the registry. Registering the same name twice
+// replaces the previous provider.
+func Register(p Provider) error {
+	name := p.Name()
+	if !providerNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid provider name %q", name)
+	}
+	if reservedNames[name] {
+		return fmt.Errorf("provider name %q is reserved", name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := providers[name]; !exists {
+		order = append(order, name)
+	}
+	providers[name] = p
+	return nil
+}
+
+// Lookup returns the provider registered under name.
+func Lookup(name string) (Provider, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Providers returns the registered providers in registration order.
+func Providers() []Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	list := make([]Provider, 0, len(order))
+	for _, name := range order {
+		list = append(list, providers[name])
+	}
+	return list
+}
+
+// InitProviders registers the providers configured in the environment:
+//
+//   - Google when GOOGLE_CLIENT_ID is set (OIDC, issuer accounts.google.com)
+//   - GitHub when GITHUB_CLIENT_ID is set
+//   - every name listed in OIDC_PROVIDERS, configured by OIDC_<NAME>_ISSUER,
+//     OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL
+//     and optionally OIDC_<NAME>_DISPLAY_NAME, OIDC_<NAME>_SCOPES and
+//     OIDC_<NAME>_TRUST_EMAIL.
+//
+// A provider that is partially configured is a startup error. OIDC discovery
+// happens on first use, so an unreachable IdP does not prevent startup.
+func InitProviders() error {
+	if clientID := os.Getenv("GOOGLE_CLIENT_ID"); clientID != "" {
+		p, err := NewOIDCProvider(OIDCConfig{
+			Name:         "google",
+			DisplayName:  "Google",
+			Issuer:       "https://accounts.google.com",
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+			RedirectURL:  firstEnv("GOOGLE_REDIRECT_URL", "GOOGLE_REDIRECT_URI"),
+			TrustEmail:   true,
+		})
+		if err != nil {
+			return fmt.Errorf("google: %w", err)
+		}
+		if err := Register(p); err != nil {
+			return err
+		}
+	}
+
+	if clientID := os.Getenv("GITHUB_CLIENT_ID"); clientID != "" {
+		p, err := NewGitHubProvider(GitHubConfig{
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("GITHUB_REDIRECT_URL"),
+		})
+		if err != nil {
+			return fmt.Errorf("github: %w", err)
+		}
+		if err := Register(p); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		var scopes []string
+		if raw := os.Getenv(prefix + "SCOPES"); raw != "" {
+			scopes = strings.Fields(strings.ReplaceAll(raw, ",", " "))
+		}
+		displayName := os.Getenv(prefix + "DISPLAY_NAME")
+		if displayName == "" {
+			displayName = name
+		}
+
+		p, err := NewOIDCProvider(OIDCConfig{
+			Name:         name,
+			DisplayName:  displayName,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       scopes,
+			TrustEmail:   os.Getenv(prefix+"TRUST_EMAIL") == "true",
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := Register(p); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(order))
+	for _, p := range Providers() {
+		names = append(names, p.Name())
+	}
+	if len(names) == 0 {
+		log.Println("⚠️  No external login provider configured")
+	} else {
+		log.Printf("🔐 Login providers: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// firstEnv returns the first non-empty variable among names.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
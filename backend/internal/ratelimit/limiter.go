@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Policy allows Limit requests per Window and per client. Policies are
+// counted separately: a client throttled on login can still browse.
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// KeyFunc identifies the client of a request (user ID, IP address...).
+type KeyFunc func(r *http.Request) string
+
+// Limiter applies a policy to each request, chosen from the route that
+// matched, or the default policy.
+type Limiter struct {
+	store    Store
+	key      KeyFunc
+	fallback Policy
+	routes   map[string]Policy // "METHOD path template"
+}
+
+// New returns a limiter counting in store, with fallback for routes that
+// have no policy of their own.
+func New(store Store, key KeyFunc, fallback Policy) *Limiter {
+	return &Limiter{store: store, key: key, fallback: fallback, routes: map[string]Policy{}}
+}
+
+// Route sets the policy of a route, identified by its path template as
+// registered on the router (e.g. "/api/artists/{id}"). Without methods, the
+// policy applies to every method.
+func (l *Limiter) Route(path string, policy Policy, methods ...string) {
+	if len(methods) == 0 {
+		methods = []string{"*"}
+	}
+	for _, method := range methods {
+		l.routes[method+" "+path] = policy
+	}
+}
+
+func (l *Limiter) policyFor(r *http.Request) Policy {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return l.fallback
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return l.fallback
+	}
+	if p, ok := l.routes[r.Method+" "+path]; ok {
+		return p
+	}
+	if p, ok := l.routes["* "+path]; ok {
+		return p
+	}
+	return l.fallback
+}
+
+// Middleware counts the request and answers 429 once the client is over the
+// limit. It sets the RateLimit-* headers of the IETF draft and Retry-After.
+// If the store fails, the request goes through: an outage of the counters
+// must not take the API down.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		policy := l.policyFor(r)
+		ctx, cancel := context.WithTimeout(r.Context(), 500*time.Millisecond)
+		res, err := l.store.Take(ctx, policy.Name+":"+l.key(r), policy.Limit, policy.Window)
+		cancel()
+		if err != nil {
+			log.Printf("⚠️  Rate limit store error (%s): %v", policy.Name, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reset := strconv.Itoa(int(math.Ceil(res.Reset.Seconds())))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Window.Seconds())))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", reset)
+
+		if !res.Allowed {
+			w.Header().Set("Retry-After", reset)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Too many requests"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
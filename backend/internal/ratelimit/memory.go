@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps counters in process. Each replica counts on its own:
+// use RedisStore when the API runs on more than one instance.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+}
+
+type memoryCounter struct {
+	count   int
+	resetAt time.Time
+}
+
+// NewMemoryStore returns an empty store and starts purging expired windows.
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{counters: map[string]*memoryCounter{}}
+	go s.purge(time.Minute)
+	return s
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit int, window time.Duration) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.resetAt) {
+		c = &memoryCounter{resetAt: now.Add(window)}
+		s.counters[key] = c
+	}
+	c.count++
+
+	return newResult(c.count, limit, c.resetAt.Sub(now)), nil
+}
+
+func (s *MemoryStore) purge(every time.Duration) {
+	for range time.Tick(every) {
+		now := time.Now()
+		s.mu.Lock()
+		for key, c := range s.counters {
+			if !now.Before(c.resetAt) {
+				delete(s.counters, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
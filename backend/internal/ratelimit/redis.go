@@ -0,0 +1,270 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// takeScript increments the counter of the current window and starts its
+// expiry on the first hit, atomically. It returns the count and the time left
+// in the window, in milliseconds.
+const takeScript = `
+local count = redis.call('INCR', KEYS[1])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+  ttl = tonumber(ARGV[1])
+end
+return {count, ttl}
+`
+
+var takeScriptSHA = func() string {
+	sum := sha1.Sum([]byte(takeScript))
+	return hex.EncodeToString(sum[:])
+}()
+
+const (
+	redisKeyPrefix   = "ratelimit:"
+	redisMaxIdle     = 16
+	redisDialTimeout = 2 * time.Second
+	redisIOTimeout   = time.Second
+)
+
+// RedisStore keeps counters in Redis, shared by every replica. It speaks the
+// Redis protocol (RESP) directly and only needs EVAL, so any compatible server
+// works.
+type RedisStore struct {
+	addr     string
+	tls      *tls.Config
+	username string
+	password string
+	db       int
+	idle     chan *redisConn
+}
+
+// NewRedisStore connects to redis://[user:password@]host[:port][/db], or
+// rediss:// for TLS, and checks that the server answers.
+func NewRedisStore(rawURL string) (*RedisStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit: invalid redis URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("ratelimit: unsupported redis URL scheme %q", u.Scheme)
+	}
+
+	s := &RedisStore{addr: u.Host, idle: make(chan *redisConn, redisMaxIdle)}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.Scheme == "rediss" {
+		s.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	}
+	if u.User != nil {
+		s.password, _ = u.User.Password()
+		if s.password == "" {
+			// redis://:password@host and redis://password@host are both common
+			s.password = u.User.Username()
+		} else {
+			s.username = u.User.Username()
+		}
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("ratelimit: invalid redis database %q", db)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisDialTimeout)
+	defer cancel()
+	if _, err := s.do(ctx, "PING"); err != nil {
+		return nil, fmt.Errorf("ratelimit: redis unreachable: %w", err)
+	}
+	return s, nil
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	ms := strconv.FormatInt(window.Milliseconds(), 10)
+	reply, err := s.do(ctx, "EVALSHA", takeScriptSHA, "1", redisKeyPrefix+key, ms)
+	var rerr redisError
+	if errors.As(err, &rerr) && strings.HasPrefix(string(rerr), "NOSCRIPT") {
+		reply, err = s.do(ctx, "EVAL", takeScript, "1", redisKeyPrefix+key, ms)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected redis reply %v", reply)
+	}
+	count, ok1 := values[0].(int64)
+	ttl, ok2 := values[1].(int64)
+	if !ok1 || !ok2 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected redis reply %v", reply)
+	}
+	return newResult(int(count), limit, time.Duration(ttl)*time.Millisecond), nil
+}
+
+// do sends one command and reads its reply. A connection is returned to the
+// pool unless the exchange failed at the network level. A pooled connection
+// the server has closed in the meantime (idle timeout, restart) is replaced by
+// a fresh one, once.
+func (s *RedisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	for retried := false; ; retried = true {
+		c, pooled, err := s.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			deadline = time.Now().Add(redisIOTimeout)
+		}
+		c.conn.SetDeadline(deadline)
+
+		reply, err := c.roundTrip(args)
+		var rerr redisError
+		if err != nil && !errors.As(err, &rerr) {
+			c.conn.Close()
+			if pooled && !retried && isClosedByPeer(err) {
+				continue
+			}
+			return nil, err
+		}
+		s.put(c)
+		return reply, err
+	}
+}
+
+// isClosedByPeer reports whether err means the server closed the connection.
+func isClosedByPeer(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// get returns an idle connection, or dials a new one. pooled tells which.
+func (s *RedisStore) get(ctx context.Context) (c *redisConn, pooled bool, err error) {
+	select {
+	case c := <-s.idle:
+		return c, true, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: redisDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.tls != nil {
+		conn = tls.Client(conn, s.tls)
+	}
+	conn.SetDeadline(time.Now().Add(redisDialTimeout))
+
+	c = &redisConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	if s.password != "" {
+		args := []string{"AUTH", s.password}
+		if s.username != "" {
+			args = []string{"AUTH", s.username, s.password}
+		}
+		if _, err := c.roundTrip(args); err != nil {
+			conn.Close()
+			return nil, false, err
+		}
+	}
+	if s.db != 0 {
+		if _, err := c.roundTrip([]string{"SELECT", strconv.Itoa(s.db)}); err != nil {
+			conn.Close()
+			return nil, false, err
+		}
+	}
+	return c, false, nil
+}
+
+func (s *RedisStore) put(c *redisConn) {
+	select {
+	case s.idle <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+// redisError is an error reply from the server; the connection stays usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func (c *redisConn) roundTrip(args []string) (interface{}, error) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			values[i], err = c.readReply()
+			var rerr redisError
+			if errors.As(err, &rerr) {
+				values[i] = rerr // keep reading the rest of the array
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
@@ -0,0 +1,384 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a RESP server whose replies are decided by the test. It
+// records every command with the connection it arrived on.
+type fakeRedis struct {
+	ln      net.Listener
+	handler func(conn int, args []string) string // raw RESP reply, "" closes the connection
+
+	mu       sync.Mutex
+	conns    int
+	commands []fakeCommand
+}
+
+type fakeCommand struct {
+	conn int
+	args []string
+}
+
+func newFakeRedis(t *testing.T, handler func(conn int, args []string) string) *fakeRedis {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, handler: handler}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns++
+			id := f.conns
+			f.mu.Unlock()
+			go f.serve(id, conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(id int, conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, fakeCommand{conn: id, args: args})
+		f.mu.Unlock()
+
+		// Every connection answers the handshake the same way
+		reply := "+OK\r\n"
+		if args[0] != "AUTH" && args[0] != "SELECT" {
+			reply = f.handler(id, args)
+		}
+		if reply == "" {
+			return
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		// Lua scripts span several lines: read by length
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) url() string {
+	return "redis://" + f.ln.Addr().String()
+}
+
+func (f *fakeRedis) connections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conns
+}
+
+// names returns the command names received, in order.
+func (f *fakeRedis) names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for _, c := range f.commands {
+		names = append(names, c.args[0])
+	}
+	return names
+}
+
+// scriptServer answers PING and runs takeScript against in-memory counters,
+// loading scripts on EVAL like Redis does.
+func scriptServer() func(conn int, args []string) string {
+	var mu sync.Mutex
+	counters := map[string]int{}
+	scripts := map[string]bool{}
+
+	return func(conn int, args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch args[0] {
+		case "PING":
+			return "+PONG\r\n"
+		case "EVAL":
+			sum := sha1.Sum([]byte(args[1]))
+			scripts[hex.EncodeToString(sum[:])] = true
+		case "EVALSHA":
+			if !scripts[args[1]] {
+				return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+			}
+		default:
+			return "-ERR unknown command\r\n"
+		}
+		counters[args[3]]++
+		return fmt.Sprintf("*2\r\n:%d\r\n:%s\r\n", counters[args[3]], args[4])
+	}
+}
+
+func TestRedisStoreFallsBackToEvalOnNoscript(t *testing.T) {
+	f := newFakeRedis(t, scriptServer())
+	store, err := NewRedisStore(f.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for want := 1; want <= 3; want++ {
+		result, err := store.Take(ctx, "ip:1.2.3.4", 2, time.Minute)
+		if err != nil {
+			t.Fatalf("Take %d: %v", want, err)
+		}
+		if result.Allowed != (want <= 2) || result.Remaining != max(2-want, 0) || result.Reset != time.Minute {
+			t.Errorf("Take %d = %+v", want, result)
+		}
+	}
+
+	// The script is sent once; then EVALSHA is enough
+	want := []string{"PING", "EVALSHA", "EVAL", "EVALSHA", "EVALSHA"}
+	if got := f.names(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+	if n := f.connections(); n != 1 {
+		t.Errorf("connections = %d, want 1 (pooled)", n)
+	}
+
+	f.mu.Lock()
+	key := f.commands[1].args[3]
+	f.mu.Unlock()
+	if key != redisKeyPrefix+"ip:1.2.3.4" {
+		t.Errorf("key = %q, want it prefixed", key)
+	}
+}
+
+func TestRedisStoreErrorReplyKeepsConnection(t *testing.T) {
+	calls := 0
+	f := newFakeRedis(t, func(conn int, args []string) string {
+		if args[0] == "PING" {
+			return "+PONG\r\n"
+		}
+		calls++
+		if calls == 1 {
+			return "-OOM command not allowed when used memory > 'maxmemory'\r\n"
+		}
+		return "*2\r\n:1\r\n:1000\r\n"
+	})
+	store, err := NewRedisStore(f.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Take(context.Background(), "k", 10, time.Second)
+	var rerr redisError
+	if !errors.As(err, &rerr) || !strings.HasPrefix(string(rerr), "OOM") {
+		t.Fatalf("Take = %v, want the OOM error reply", err)
+	}
+
+	// An error reply is a complete answer: the connection stays in the pool
+	if _, err := store.Take(context.Background(), "k", 10, time.Second); err != nil {
+		t.Fatalf("Take after error reply: %v", err)
+	}
+	if n := f.connections(); n != 1 {
+		t.Errorf("connections = %d, want 1", n)
+	}
+}
+
+func TestRedisStoreDropsConnectionAfterTimeout(t *testing.T) {
+	release := make(chan struct{})
+	f := newFakeRedis(t, func(conn int, args []string) string {
+		if args[0] == "PING" {
+			return "+PONG\r\n"
+		}
+		if conn == 1 {
+			// Answer only after the client gave up: this late reply must not
+			// be read as the answer to a later command
+			<-release
+			return "*2\r\n:99\r\n:1000\r\n"
+		}
+		return "*2\r\n:1\r\n:1000\r\n"
+	})
+	defer close(release)
+	store, err := NewRedisStore(f.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = store.Take(ctx, "k", 10, time.Second)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("Take = %v, want a timeout", err)
+	}
+
+	result, err := store.Take(context.Background(), "k", 10, time.Second)
+	if err != nil {
+		t.Fatalf("Take after timeout: %v", err)
+	}
+	if result.Remaining != 9 {
+		t.Errorf("Take after timeout = %+v, want the answer of a fresh connection", result)
+	}
+	if n := f.connections(); n != 2 {
+		t.Errorf("connections = %d, want 2 (the timed-out one is not reused)", n)
+	}
+}
+
+func TestRedisStoreRedialsConnectionClosedByServer(t *testing.T) {
+	f := newFakeRedis(t, func(conn int, args []string) string {
+		if args[0] == "PING" {
+			return "+PONG\r\n"
+		}
+		if conn == 1 {
+			return "" // idle connection closed by the server
+		}
+		return "*2\r\n:1\r\n:1000\r\n"
+	})
+	store, err := NewRedisStore(f.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Take(context.Background(), "k", 10, time.Second); err != nil {
+		t.Fatalf("Take on a connection closed by the server: %v", err)
+	}
+	if n := f.connections(); n != 2 {
+		t.Errorf("connections = %d, want 2", n)
+	}
+}
+
+func TestRedisStoreNilReply(t *testing.T) {
+	f := newFakeRedis(t, func(conn int, args []string) string {
+		if args[0] == "PING" {
+			return "+PONG\r\n"
+		}
+		return "$-1\r\n"
+	})
+	store, err := NewRedisStore(f.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := store.Take(context.Background(), "k", 10, time.Second); err == nil || !strings.Contains(err.Error(), "unexpected redis reply") {
+			t.Fatalf("Take = %v, want an unexpected reply error", err)
+		}
+	}
+	if n := f.connections(); n != 1 {
+		t.Errorf("connections = %d, want 1 (a nil reply is a complete answer)", n)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"+OK\r\n", "OK"},
+		{":42\r\n", "42"},
+		{"$5\r\nhello\r\n", "hello"},
+		{"$0\r\n\r\n", ""},
+		{"$-1\r\n", "<nil>"},
+		{"*-1\r\n", "<nil>"},
+		{"*0\r\n", "[]"},
+		{"*3\r\n:1\r\n$-1\r\n-ERR inner\r\n", "[1 <nil> redis: ERR inner]"},
+		{"*2\r\n*1\r\n:1\r\n$3\r\nabc\r\n", "[[1] abc]"},
+	}
+	for _, tt := range tests {
+		c := &redisConn{r: bufio.NewReader(strings.NewReader(tt.raw))}
+		reply, err := c.readReply()
+		if err != nil {
+			t.Errorf("readReply(%q): %v", tt.raw, err)
+			continue
+		}
+		if got := fmt.Sprint(reply); got != tt.want {
+			t.Errorf("readReply(%q) = %s, want %s", tt.raw, got, tt.want)
+		}
+	}
+
+	for _, raw := range []string{"-ERR wrong\r\n", "?\r\n", "+OK\n", "$5\r\nhi\r\n", ":x\r\n"} {
+		c := &redisConn{r: bufio.NewReader(strings.NewReader(raw))}
+		if _, err := c.readReply(); err == nil {
+			t.Errorf("readReply(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestRedisStoreAuthAndSelect(t *testing.T) {
+	f := newFakeRedis(t, func(conn int, args []string) string { return "+PONG\r\n" })
+	addr := f.ln.Addr().String()
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"redis://:secret@" + addr, []string{"AUTH secret", "PING"}},
+		{"redis://app:secret@" + addr + "/2", []string{"AUTH app secret", "SELECT 2", "PING"}},
+		{"redis://" + addr + "/0", []string{"PING"}},
+	}
+	for _, tt := range tests {
+		f.mu.Lock()
+		f.commands = nil
+		f.mu.Unlock()
+
+		if _, err := NewRedisStore(tt.url); err != nil {
+			t.Fatalf("NewRedisStore(%s): %v", tt.url, err)
+		}
+
+		f.mu.Lock()
+		var got []string
+		for _, c := range f.commands {
+			got = append(got, strings.Join(c.args, " "))
+		}
+		f.mu.Unlock()
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("%s: commands = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Package ratelimit counts requests per client and per route policy. Counters
+// live in a Store: in process for a single instance, or in Redis (or any
+// server speaking its protocol: Valkey, KeyDB, Dragonfly) so that several
+// replicas share them.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Result is the state of a counter after a request has been counted.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration // until the current window ends
+}
+
+// Store counts hits in fixed windows. Take adds one hit to key and reports
+// whether it is within limit for the window that contains now.
+type Store interface {
+	Take(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+}
+
+func newResult(count, limit int, reset time.Duration) Result {
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	if reset < 0 {
+		reset = 0
+	}
+	return Result{Allowed: count <= limit, Limit: limit, Remaining: remaining, Reset: reset}
+}
@@ -0,0 +1,122 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Hit types, one per kind of searchable field.
+const (
+	TypeArtist = "artist"
+	TypeMember = "member"
+	TypeTrack  = "track"
+	TypeVenue  = "venue"
+	TypeCity   = "city"
+)
+
+// Types lists the hit types in ranking order.
+var Types = []string{TypeArtist, TypeMember, TypeTrack, TypeVenue, TypeCity}
+
+// weights rank equally similar hits by type: an artist name before one of
+// its members, a venue before a city.
+var weights = map[string]float64{
+	TypeArtist: 1,
+	TypeMember: 0.9,
+	TypeTrack:  0.8,
+	TypeVenue:  0.7,
+	TypeCity:   0.6,
+}
+
+// Threshold is the minimum word similarity of a hit. The Postgres search sets
+// pg_trgm.word_similarity_threshold to the same value.
+const Threshold = 0.4
+
+// MaxQueryLength bounds the query, in characters.
+const MaxQueryLength = 100
+
+// Doc is a searchable entry. Text is matched against the query and becomes
+// the hit's title.
+type Doc struct {
+	Type     string
+	Text     string
+	Subtitle string
+	ArtistID int
+	Concerts int
+}
+
+// Hit is a search result, scored between 0 and 1.
+type Hit struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Subtitle string    `json:"subtitle,omitempty"`
+	ArtistID int       `json:"artist_id,omitempty"` // artist, member and track hits
+	Concerts int       `json:"concerts,omitempty"`  // venue and city hits: upcoming concerts there
+	Score    float64   `json:"score"`
+	Snippet  []Segment `json:"snippet"`
+}
+
+// NewHit scores doc from its word similarity to query.
+func NewHit(query string, doc Doc, similarity float64) Hit {
+	return Hit{
+		Type:     doc.Type,
+		Title:    doc.Text,
+		Subtitle: doc.Subtitle,
+		ArtistID: doc.ArtistID,
+		Concerts: doc.Concerts,
+		Score:    math.Round(similarity*weights[doc.Type]*1000) / 1000,
+		Snippet:  Highlight(query, doc.Text),
+	}
+}
+
+// Rank sorts hits by score, then title, and keeps the first limit.
+func Rank(hits []Hit, limit int) []Hit {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return strings.ToLower(hits[i].Title) < strings.ToLower(hits[j].Title)
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Index is the in-memory search, used with the demo catalog.
+type Index struct {
+	docs   []Doc
+	folded []string
+}
+
+func NewIndex(docs []Doc) *Index {
+	ix := &Index{docs: docs, folded: make([]string, len(docs))}
+	for i, doc := range docs {
+		ix.folded[i] = Fold(doc.Text)
+	}
+	return ix
+}
+
+// Search returns the best hits for query, restricted to types when given.
+func (ix *Index) Search(query string, types []string, limit int) []Hit {
+	folded := Fold(query)
+	hits := []Hit{}
+	for i, doc := range ix.docs {
+		if len(types) > 0 && !contains(types, doc.Type) {
+			continue
+		}
+		if sim := WordSimilarity(folded, ix.folded[i]); sim >= Threshold {
+			hits = append(hits, NewHit(query, doc, sim))
+		}
+	}
+	return Rank(hits, limit)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
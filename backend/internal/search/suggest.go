@@ -0,0 +1,132 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// suggestTypes are the kinds of Doc offered as autocomplete suggestions.
+var suggestTypes = map[string]bool{TypeArtist: true, TypeTrack: true, TypeVenue: true, TypeCity: true}
+
+// Suggestion is an autocomplete entry.
+type Suggestion struct {
+	Type     string `json:"type"`
+	Label    string `json:"label"`
+	Subtitle string `json:"subtitle,omitempty"`
+	ArtistID int    `json:"artist_id,omitempty"` // artists, and the artist of a track
+	Concerts int    `json:"concerts,omitempty"`  // venues and cities: concerts there
+}
+
+// SuggestIndex answers prefix queries from a sorted list of keys: every
+// suffix of a folded label that starts a word, so that "laeken" and "los an"
+// both find their entry. It is immutable once built; rebuild it to follow
+// catalog changes.
+type SuggestIndex struct {
+	keys    []suggestKey
+	entries []Suggestion
+}
+
+type suggestKey struct {
+	key   string
+	entry int
+	start bool // the key is the whole label, not a later word
+}
+
+// NewSuggestIndex indexes the suggestible docs.
+func NewSuggestIndex(docs []Doc) *SuggestIndex {
+	ix := &SuggestIndex{}
+	seen := map[string]bool{}
+
+	for _, doc := range docs {
+		if !suggestTypes[doc.Type] {
+			continue
+		}
+		ws := words(Fold(doc.Text))
+		if len(ws) == 0 {
+			continue
+		}
+		normalized := strings.Join(ws, " ")
+		id := doc.Type + "\x00" + normalized + "\x00" + Fold(doc.Subtitle)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		entry := len(ix.entries)
+		ix.entries = append(ix.entries, Suggestion{
+			Type:     doc.Type,
+			Label:    doc.Text,
+			Subtitle: doc.Subtitle,
+			ArtistID: doc.ArtistID,
+			Concerts: doc.Concerts,
+		})
+		for i := range ws {
+			ix.keys = append(ix.keys, suggestKey{key: strings.Join(ws[i:], " "), entry: entry, start: i == 0})
+		}
+	}
+
+	sort.Slice(ix.keys, func(i, j int) bool { return ix.keys[i].key < ix.keys[j].key })
+	return ix
+}
+
+// Len is the number of suggestions in the index.
+func (ix *SuggestIndex) Len() int {
+	return len(ix.entries)
+}
+
+// Suggest returns up to limit entries with a word starting with query.
+// Whole-label matches rank before later-word matches, then by type (artists
+// first), then shorter labels first.
+func (ix *SuggestIndex) Suggest(query string, limit int) []Suggestion {
+	q := strings.Join(words(Fold(query)), " ")
+	results := []Suggestion{}
+	if q == "" {
+		return results
+	}
+
+	// Best match per entry: 2 exact label, 1 label prefix, 0 later word
+	matches := map[int]int{}
+	for i := sort.Search(len(ix.keys), func(i int) bool { return ix.keys[i].key >= q }); i < len(ix.keys); i++ {
+		k := ix.keys[i]
+		if !strings.HasPrefix(k.key, q) {
+			break
+		}
+		rank := 0
+		if k.start {
+			rank = 1
+			if k.key == q {
+				rank = 2
+			}
+		}
+		if best, ok := matches[k.entry]; !ok || rank > best {
+			matches[k.entry] = rank
+		}
+	}
+
+	ordered := make([]int, 0, len(matches))
+	for entry := range matches {
+		ordered = append(ordered, entry)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if matches[a] != matches[b] {
+			return matches[a] > matches[b]
+		}
+		ea, eb := ix.entries[a], ix.entries[b]
+		if weights[ea.Type] != weights[eb.Type] {
+			return weights[ea.Type] > weights[eb.Type]
+		}
+		if len(ea.Label) != len(eb.Label) {
+			return len(ea.Label) < len(eb.Label)
+		}
+		return a < b
+	})
+
+	if len(ordered) > limit {
+		ordered = ordered[:limit]
+	}
+	for _, entry := range ordered {
+		results = append(results, ix.entries[entry])
+	}
+	return results
+}
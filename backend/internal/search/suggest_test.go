@@ -0,0 +1,87 @@
+package search_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"groupie-backend/internal/search"
+	"groupie-backend/services"
+)
+
+// benchmarkQueries covers a one-letter prefix (many matches to rank), a word
+// prefix, a later-word match, an exact label and a miss.
+var benchmarkQueries = []string{"a", "que", "los an", "paris", "zzz"}
+
+// demoDocs is the demo catalog, as indexed by the in-memory repository.
+func demoDocs(b *testing.B) []search.Doc {
+	b.Helper()
+
+	docs, err := services.NewArtistService().CatalogDocs()
+	if err != nil {
+		b.Fatal(err)
+	}
+	return docs
+}
+
+// scaledDocs repeats docs n times, each copy after the first with a random
+// extra word so that labels stay distinct, like a catalog n times bigger.
+func scaledDocs(docs []search.Doc, n int) []search.Doc {
+	rng := rand.New(rand.NewPCG(1, 2))
+	word := func() string {
+		w := make([]byte, 3+rng.IntN(6))
+		for i := range w {
+			w[i] = byte('a' + rng.IntN(26))
+		}
+		return string(w)
+	}
+
+	scaled := make([]search.Doc, 0, len(docs)*n)
+	for i := 0; i < n; i++ {
+		for _, doc := range docs {
+			if i > 0 {
+				doc.Text += " " + word()
+			}
+			scaled = append(scaled, doc)
+		}
+	}
+	return scaled
+}
+
+func BenchmarkSuggest(b *testing.B) {
+	demo := demoDocs(b)
+	catalogs := []struct {
+		name string
+		docs []search.Doc
+	}{
+		{"demo", demo},
+		{"x10", scaledDocs(demo, 10)},
+		{"x100", scaledDocs(demo, 100)},
+		{"x1000", scaledDocs(demo, 1000)},
+	}
+
+	for _, catalog := range catalogs {
+		ix := search.NewSuggestIndex(catalog.docs)
+		for _, q := range benchmarkQueries {
+			b.Run(fmt.Sprintf("%s/%d/%s", catalog.name, ix.Len(), q), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					ix.Suggest(q, 8)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkNewSuggestIndex(b *testing.B) {
+	demo := demoDocs(b)
+	for _, n := range []int{1, 10, 100, 1000} {
+		docs := scaledDocs(demo, n)
+		b.Run(fmt.Sprintf("x%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				search.NewSuggestIndex(docs)
+			}
+		})
+	}
+}
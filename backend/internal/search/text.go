@@ -0,0 +1,166 @@
+// Package search implements the catalog search: accent folding and trigram
+// word similarity modelled on Postgres unaccent and pg_trgm, so that the
+// in-memory index ranks like the database, plus highlighting of the matched
+// words in a hit.
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ligatures are not decomposed by NFD but are folded by unaccent.
+var ligatures = strings.NewReplacer("œ", "oe", "æ", "ae", "ß", "ss", "ø", "o", "ł", "l", "đ", "d")
+
+// Fold lowercases s and strips its diacritics: "Angèle" and "ANGELE" both
+// fold to "angele".
+func Fold(s string) string {
+	if isASCII(s) {
+		return strings.ToLower(s)
+	}
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, strings.ToLower(s))
+	if err != nil {
+		folded = strings.ToLower(s)
+	}
+	return ligatures.Replace(folded)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// words splits a folded text on anything that is not a letter or a digit,
+// like pg_trgm does.
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// trigrams returns the trigrams of a folded word, padded with two spaces in
+// front and one behind as pg_trgm does.
+func trigrams(word string) []string {
+	padded := []rune("  " + word + " ")
+	grams := make([]string, 0, len(padded)-2)
+	for i := 0; i+3 <= len(padded); i++ {
+		grams = append(grams, string(padded[i:i+3]))
+	}
+	return grams
+}
+
+func trigramSet(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range words(text) {
+		for _, g := range trigrams(w) {
+			set[g] = true
+		}
+	}
+	return set
+}
+
+// maxTextRunes bounds the work spent on a single field.
+const maxTextRunes = 256
+
+// WordSimilarity is pg_trgm's word_similarity(query, text) on folded inputs:
+// the best similarity between the query's trigrams and any run of
+// consecutive trigrams of text. A query matching the start of a word scores
+// high, so partial input and typos still match.
+func WordSimilarity(query, text string) float64 {
+	q := trigramSet(query)
+	if len(q) == 0 {
+		return 0
+	}
+	if r := []rune(text); len(r) > maxTextRunes {
+		text = string(r[:maxTextRunes])
+	}
+
+	var sequence []string
+	for _, w := range words(text) {
+		sequence = append(sequence, trigrams(w)...)
+	}
+
+	best := 0.0
+	for start := range sequence {
+		extent := map[string]bool{}
+		common := 0
+		for _, g := range sequence[start:] {
+			if !extent[g] {
+				extent[g] = true
+				if q[g] {
+					common++
+				}
+			}
+			// The union only grows once all the query trigrams are found
+			sim := float64(common) / float64(len(q)+len(extent)-common)
+			if sim > best {
+				best = sim
+			}
+			if common == len(q) {
+				break
+			}
+		}
+	}
+	return best
+}
+
+// Segment is a piece of a highlighted snippet.
+type Segment struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// wordMatchThreshold is the similarity above which a single word of a field
+// is highlighted as matching a query word.
+const wordMatchThreshold = 0.4
+
+// Highlight splits text into segments, marking the words that match a word
+// of the (raw) query. Text is returned as is, not escaped: clients render
+// segments as text.
+func Highlight(query, text string) []Segment {
+	queryWords := words(Fold(query))
+
+	var segments []Segment
+	add := func(s string, match bool) {
+		if s == "" {
+			return
+		}
+		if n := len(segments); n > 0 && segments[n-1].Match == match {
+			segments[n-1].Text += s
+			return
+		}
+		segments = append(segments, Segment{Text: s, Match: match})
+	}
+
+	isWordRune := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	chars := []rune(text)
+	for i := 0; i < len(chars); {
+		j := i
+		for j < len(chars) && isWordRune(chars[j]) == isWordRune(chars[i]) {
+			j++
+		}
+		chunk := string(chars[i:j])
+		add(chunk, isWordRune(chars[i]) && matchesAny(queryWords, Fold(chunk)))
+		i = j
+	}
+	return segments
+}
+
+func matchesAny(queryWords []string, word string) bool {
+	for _, q := range queryWords {
+		if strings.HasPrefix(word, q) || WordSimilarity(q, word) >= wordMatchThreshold {
+			return true
+		}
+	}
+	return false
+}
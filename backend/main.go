@@ -10,6 +10,7 @@ import (
 	"groupie-backend/database"
 	"groupie-backend/handlers"
 	"groupie-backend/internal/auth"
+	"groupie-backend/internal/oauth"
 	"groupie-backend/middleware"
 	"groupie-backend/storage"
 
@@ -19,12 +20,9 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 	"github.com/stripe/stripe-go/v76"
-	"golang.org/x/time/rate"
 	"groupie-backend/services"
 )
 
-var limiter = rate.NewLimiter(rate.Limit(5), 10)
-
 func main() {
 	err := godotenv.Load()
 	if err != nil {
@@ -35,6 +33,10 @@ func main() {
 		log.Fatal("❌ ERREUR CRITIQUE : La variable DATABASE_URL est vide !")
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(os.Args[2:]))
+	}
+
 	// --- Initialisations ---
 	sentryDSN := os.Getenv("SENTRY_DSN")
 	if sentryDSN != "" {
@@ -54,15 +56,37 @@ func main() {
 		log.Println("💳 Stripe configuré")
 	}
 
-	auth.InitJWT()
-	handlers.InitOAuth() 
+	if err := auth.InitJWT(); err != nil {
+		log.Fatalf("❌ Failed to initialize JWT keys: %v", err)
+	}
+	if err := services.InitTicketSigner(); err != nil {
+		log.Fatalf("❌ Failed to initialize ticket signing: %v", err)
+	}
+	if err := services.InitTwoFactor(); err != nil {
+		log.Fatalf("❌ Failed to initialize two-factor authentication: %v", err)
+	}
+	if err := oauth.InitProviders(); err != nil {
+		log.Fatalf("❌ Failed to initialize login providers: %v", err)
+	}
 
 	if err := database.InitDB(); err != nil {
 		log.Fatalf("❌ Failed to initialize database: %v", err)
 	}
 	defer database.CloseDB()
 
+	var artistRepo services.ArtistRepository
+	if os.Getenv("ARTIST_REPOSITORY") == "memory" {
+		log.Println("🎭 Catalogue artistes : données mock en mémoire")
+		artistRepo = services.NewArtistService()
+	} else {
+		artistRepo = services.NewPostgresArtistRepository(database.DB)
+	}
+	handlers.InitArtistRepository(artistRepo)
+	services.StartSuggestIndex(artistRepo)
+
 	services.StartUnverifiedUserCleanup(database.DB)
+	StartCleanupScheduler()
+	services.StartWebhookRetrier()
 	storage.InitMinIO()
 
 	// --- Routeur Principal ---
@@ -70,9 +94,12 @@ func main() {
 
 	r.HandleFunc("/api/health", handlers.HealthCheck).Methods("GET")
 
+	// Clés publiques de vérification des JWT (rotation sans déconnexion, autres services)
+	r.HandleFunc("/.well-known/jwks.json", handlers.GetJWKS).Methods("GET")
+
 	// --- Routeur API ---
 	api := r.PathPrefix("/api").Subrouter()
-	api.Use(rateLimitMiddleware)
+	api.Use(newRateLimiter().Middleware)
 	api.Use(securityHeadersMiddleware)
 
 	// Routes Publiques
@@ -80,6 +107,10 @@ func main() {
 	api.HandleFunc("/artists/{id}", handlers.GetArtist).Methods("GET")
 	api.HandleFunc("/concerts", handlers.GetConcerts).Methods("GET")
 	api.HandleFunc("/concerts/search", handlers.SearchConcerts).Methods("GET")
+	api.HandleFunc("/concerts/nearby", handlers.GetNearbyConcerts).Methods("GET")
+	api.HandleFunc("/concerts/within", handlers.GetConcertsInBox).Methods("GET")
+	api.HandleFunc("/search", handlers.Search).Methods("GET")
+	api.HandleFunc("/search/suggest", handlers.Suggest).Methods("GET")
 
 	deezerHandler := handlers.NewDeezerHandler()
 	api.HandleFunc("/deezer/widget", deezerHandler.GetArtistDeezerWidget).Methods("GET")
@@ -94,23 +125,50 @@ func main() {
 	authRouter.HandleFunc("/reset-password", handlers.ResetPassword).Methods("POST")
 	authRouter.HandleFunc("/send-verification", handlers.ResendVerification).Methods("POST")
 	authRouter.HandleFunc("/verify-email", handlers.VerifyEmail).Methods("GET")
+
+	// Sessions : rotation du refresh token et déconnexion côté serveur
+	authRouter.HandleFunc("/refresh", handlers.RefreshToken).Methods("POST")
+	authRouter.HandleFunc("/exchange", handlers.ExchangeOAuthCode).Methods("POST")
+	authRouter.HandleFunc("/logout", handlers.Logout).Methods("POST")
+	authRouter.Handle("/logout-all", middleware.JWTAuth(http.HandlerFunc(handlers.LogoutAll))).Methods("POST")
+
+	// Double authentification (TOTP)
+	authRouter.HandleFunc("/2fa/login", handlers.TwoFactorLogin).Methods("POST")
+	authRouter.Handle("/2fa/setup", middleware.JWTAuth(http.HandlerFunc(handlers.TwoFactorSetup))).Methods("POST")
+	authRouter.Handle("/2fa/verify", middleware.JWTAuth(http.HandlerFunc(handlers.TwoFactorVerify))).Methods("POST")
+	authRouter.Handle("/2fa/disable", middleware.JWTAuth(http.HandlerFunc(handlers.TwoFactorDisable))).Methods("POST")
 	
-	// Routes OAuth Google
-	authRouter.HandleFunc("/google", handlers.GoogleLogin).Methods("GET", "OPTIONS")
-	authRouter.HandleFunc("/google/callback", handlers.GoogleCallback).Methods("GET", "OPTIONS")
+	// Connexion via fournisseur externe (Google, GitHub, OIDC) — à déclarer en dernier,
+	// {provider} capterait sinon les autres routes GET de /auth
+	authRouter.HandleFunc("/providers", handlers.GetOAuthProviders).Methods("GET")
+	authRouter.HandleFunc("/{provider}", handlers.OAuthLogin).Methods("GET", "OPTIONS")
+	authRouter.HandleFunc("/{provider}/callback", handlers.OAuthCallback).Methods("GET", "OPTIONS")
 
 	// --- ROUTES PROTÉGÉES ---
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(middleware.JWTAuth)
 	protected.HandleFunc("/profile", handlers.GetProfile).Methods("GET")
+	protected.HandleFunc("/profile/identities", handlers.GetIdentities).Methods("GET")
+	protected.HandleFunc("/profile/identities/confirm", handlers.ConfirmIdentityLink).Methods("POST")
+	protected.HandleFunc("/profile/identities/{id:[0-9]+}", handlers.UnlinkIdentity).Methods("DELETE")
+	protected.HandleFunc("/profile/identities/{provider}/link", handlers.StartIdentityLink).Methods("POST")
 	protected.HandleFunc("/bookings", handlers.CreateBooking).Methods("POST")
 
 	// Paiement
 	payment := protected.PathPrefix("/payment").Subrouter()
 	payment.HandleFunc("/create-intent", handlers.CreatePaymentIntent).Methods("POST")
+	payment.HandleFunc("/checkout-session", handlers.CreateCheckoutSession).Methods("POST")
 	payment.HandleFunc("/confirm", handlers.ConfirmPayment).Methods("POST")
 	payment.HandleFunc("/reservations", handlers.GetReservations).Methods("GET")
 
+	// Billets
+	protected.HandleFunc("/tickets", handlers.GetTickets).Methods("GET")
+	protected.HandleFunc("/tickets/{id}/qr", handlers.GetTicketQR).Methods("GET")
+	protected.HandleFunc("/reservations/{id}/tickets.pdf", handlers.GetReservationTicketsPDF).Methods("GET")
+
+	// Contrôle à l'entrée (staff ou admin)
+	protected.Handle("/scan", middleware.StaffOnly(http.HandlerFunc(handlers.ScanTicket))).Methods("POST")
+
 	// Admin
 	admin := protected.PathPrefix("/admin").Subrouter()
 	admin.Use(middleware.AdminOnly)
@@ -118,6 +176,25 @@ func main() {
 	admin.HandleFunc("/dashboard", handlers.AdminGetDashboard).Methods("GET")
 	admin.HandleFunc("/artists", handlers.AdminGetArtists).Methods("GET")
 	admin.HandleFunc("/artists", handlers.AdminCreateArtist).Methods("POST")
+	admin.HandleFunc("/artists/{id:[0-9]+}", handlers.AdminUpdateArtist).Methods("PUT")
+	admin.HandleFunc("/artists/{id:[0-9]+}", handlers.AdminDeleteArtist).Methods("DELETE")
+	admin.HandleFunc("/concerts", handlers.AdminGetConcerts).Methods("GET")
+	admin.HandleFunc("/concerts", handlers.AdminCreateConcert).Methods("POST")
+	admin.HandleFunc("/concerts/{id:[0-9]+}", handlers.AdminUpdateConcert).Methods("PUT")
+	admin.HandleFunc("/concerts/{id:[0-9]+}", handlers.AdminDeleteConcert).Methods("DELETE")
+	admin.HandleFunc("/users", handlers.AdminGetUsers).Methods("GET")
+	admin.HandleFunc("/payments", handlers.AdminGetPayments).Methods("GET")
+	admin.HandleFunc("/activity-logs", handlers.AdminGetActivityLogs).Methods("GET")
+	admin.HandleFunc("/reservations/{id}/refund", handlers.AdminRefundReservation).Methods("POST")
+	admin.HandleFunc("/promo-codes", handlers.AdminGetPromoCodes).Methods("GET")
+	admin.HandleFunc("/promo-codes", handlers.AdminCreatePromoCode).Methods("POST")
+	admin.HandleFunc("/promo-codes/{id}", handlers.AdminUpdatePromoCode).Methods("PUT")
+	admin.HandleFunc("/promo-codes/{id}", handlers.AdminDeletePromoCode).Methods("DELETE")
+	admin.HandleFunc("/promo-codes/{id}/stats", handlers.AdminGetPromoCodeStats).Methods("GET")
+	admin.HandleFunc("/webhooks/events", handlers.AdminGetWebhookEvents).Methods("GET")
+	admin.HandleFunc("/webhooks/events", handlers.AdminReplayWebhookEvents).Methods("POST")
+	admin.HandleFunc("/lockouts", handlers.AdminGetLockouts).Methods("GET")
+	admin.HandleFunc("/lockouts/{id:[0-9]+}/release", handlers.AdminReleaseLockout).Methods("POST")
 
 	// Webhook Stripe (Public)
 	api.HandleFunc("/stripe/webhook", handlers.StripeWebhook).Methods("POST")
@@ -146,18 +223,6 @@ func main() {
 
 // --- Middlewares ---
 
-func rateLimitMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !limiter.Allow() {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusTooManyRequests)
-			w.Write([]byte(`{"error":"Too many requests"}`))
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
 func securityHeadersMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	trustedProxiesOnce sync.Once
+	trustedProxies     []*net.IPNet
+)
+
+// loadTrustedProxies parses TRUSTED_PROXIES, a comma-separated list of proxy
+// addresses or CIDR ranges (e.g. "10.0.0.0/8,192.168.1.10").
+func loadTrustedProxies() {
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("⚠️  TRUSTED_PROXIES: ignoring invalid entry %q", entry)
+			continue
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	trustedProxiesOnce.Do(loadTrustedProxies)
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func forwardedFor(r *http.Request) []string {
+	var entries []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, entry := range strings.Split(header, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
+
+// ClientIP returns the IP address of the client, without port. Behind a
+// reverse proxy (Render, Azure, a load balancer), RemoteAddr is the proxy and
+// the address is read from X-Forwarded-For, counting from the right so that
+// entries forged by the client are ignored:
+//   - TRUSTED_PROXIES lists the proxies' addresses or ranges: the client is
+//     the rightmost entry that is not one of them;
+//   - TRUSTED_PROXY_HOPS gives the number of proxies, when their addresses
+//     are not known in advance.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if remote := net.ParseIP(host); remote != nil && isTrustedProxy(remote) {
+		entries := forwardedFor(r)
+		for i := len(entries) - 1; i >= 0; i-- {
+			ip := net.ParseIP(entries[i])
+			if ip == nil {
+				break
+			}
+			if !isTrustedProxy(ip) {
+				return ip.String()
+			}
+		}
+		return host
+	}
+
+	if hops, err := strconv.Atoi(os.Getenv("TRUSTED_PROXY_HOPS")); err == nil && hops > 0 {
+		entries := forwardedFor(r)
+		if len(entries) >= hops {
+			if ip := net.ParseIP(entries[len(entries)-hops]); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+
+	return host
+}
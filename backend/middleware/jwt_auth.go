@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"groupie-backend/internal/auth" // Import the JWT logic
+	"groupie-backend/services"
 )
 
 // UserClaimsKey is a custom type for context key to avoid collisions
@@ -15,8 +16,9 @@ type UserClaimsKey string
 
 const userClaimsKey UserClaimsKey = "userClaims"
 
-// JWTAuth middleware verifies the JWT token from the Authorization header.
-// If valid, it saves the user claims in the request context.
+// JWTAuth middleware verifies the JWT token from the Authorization header and
+// checks that its server-side session has not been revoked (logout, password
+// reset, refresh token reuse). If valid, it saves the user claims in the request context.
 func JWTAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -47,6 +49,31 @@ func JWTAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		// Tokens issued before server-side sessions carry no sid and cannot be revoked
+		if claims.SessionID == "" {
+			log.Println("❌ JWTAuth: Token has no session")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired token"})
+			return
+		}
+
+		active, err := services.IsSessionActive(claims.SessionID)
+		if err != nil {
+			log.Printf("❌ JWTAuth: Session check failed: %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+			return
+		}
+		if !active {
+			log.Printf("❌ JWTAuth: Session revoked for user #%d", claims.UserID)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Session revoked"})
+			return
+		}
+
 		// Store claims in context
 		ctx := context.WithValue(r.Context(), userClaimsKey, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -59,7 +86,8 @@ func GetUserFromContext(r *http.Request) (*auth.Claims, bool) {
 	return claims, ok
 }
 
-// AdminOnly middleware checks if the user in context has an 'admin' role.
+// AdminOnly middleware checks if the user in context has an 'admin' role and,
+// when REQUIRE_ADMIN_2FA is on (default), that two-factor authentication is enabled.
 func AdminOnly(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		claims, ok := GetUserFromContext(r)
@@ -70,6 +98,42 @@ func AdminOnly(next http.Handler) http.Handler {
 			json.NewEncoder(w).Encode(map[string]string{"error": "Admin access required"})
 			return
 		}
+
+		if services.RequireAdminTwoFactor() {
+			enabled, err := services.IsTwoFactorEnabled(int(claims.UserID))
+			if err != nil {
+				log.Printf("❌ AdminOnly: 2FA check failed: %v", err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+				return
+			}
+			if !enabled {
+				log.Printf("❌ AdminOnly: Admin #%d has not enrolled in 2FA", claims.UserID)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Two-factor authentication required for admin accounts",
+					"code":  "2fa_enrollment_required",
+				})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StaffOnly middleware allows venue staff (role 'staff') and admins.
+func StaffOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := GetUserFromContext(r)
+		if !ok || (claims.Role != "staff" && claims.Role != "admin") {
+			log.Println("❌ StaffOnly: Staff access required")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Staff access required"})
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"groupie-backend/internal/auth"
+)
+
+// RateLimitKey identifies the client for rate limiting: the user of a valid
+// access token, so that users behind a shared address (NAT, campus) keep
+// their own quota, or the client IP otherwise. Only the token signature is
+// checked here; revocation is JWTAuth's job.
+func RateLimitKey(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if claims, err := auth.ValidateToken(token); err == nil {
+			return "user:" + strconv.FormatUint(uint64(claims.UserID), 10)
+		}
+	}
+	return "ip:" + ClientIP(r)
+}
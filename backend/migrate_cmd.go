@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"groupie-backend/database"
+)
+
+// runMigrateCommand gère la sous-commande `migrate up|down|status [--dry-run] [--steps N]`
+func runMigrateCommand(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "affiche le SQL sans l'exécuter")
+	steps := fs.Int("steps", 1, "nombre de migrations à annuler (down)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: groupie-backend migrate <up|down|status> [--dry-run] [--steps N]")
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return 2
+	}
+	action := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if err := database.Connect(); err != nil {
+		log.Printf("❌ %v", err)
+		return 1
+	}
+	defer database.CloseDB()
+
+	migrator, err := database.NewMigrator(database.DB)
+	if err != nil {
+		log.Printf("❌ Error loading migrations: %v", err)
+		return 1
+	}
+
+	ctx := context.Background()
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx, *dryRun)
+		if err != nil {
+			log.Printf("❌ %v", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			log.Println("✅ Database already up to date")
+		}
+
+	case "down":
+		reverted, err := migrator.Down(ctx, *steps, *dryRun)
+		if err != nil {
+			log.Printf("❌ %v", err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			log.Println("ℹ️  No migration to revert")
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Printf("❌ %v", err)
+			return 1
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state += " (MODIFIED)"
+			}
+			fmt.Printf("%03d  %-30s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	return 0
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ========= DEVISES =========
+//
+// Tous les montants (prix, totaux, remboursements) sont stockés en unités
+// mineures de la devise, comme chez Stripe : centimes pour EUR/USD, mais
+// unités entières pour les devises sans décimale (JPY, KRW...).
+
+const DefaultCurrency = "eur"
+
+// zeroDecimalCurrencies liste les devises Stripe sans sous-unité
+var zeroDecimalCurrencies = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true,
+	"krw": true, "mga": true, "pyg": true, "rwf": true, "ugx": true, "vnd": true,
+	"vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
+// NormalizeCurrency met le code ISO 4217 au format Stripe (minuscules), eur par défaut
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToLower(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency
+	}
+	return currency
+}
+
+// IsValidCurrency vérifie qu'un code ressemble à un code ISO 4217
+func IsValidCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, c := range currency {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsZeroDecimalCurrency indique si la devise n'a pas de sous-unité
+func IsZeroDecimalCurrency(currency string) bool {
+	return zeroDecimalCurrencies[NormalizeCurrency(currency)]
+}
+
+// FormatAmount affiche un montant en unités mineures, ex. 7999 eur → "79.99 EUR"
+func FormatAmount(amount int64, currency string) string {
+	currency = NormalizeCurrency(currency)
+	code := strings.ToUpper(currency)
+	if IsZeroDecimalCurrency(currency) {
+		return fmt.Sprintf("%d %s", amount, code)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, amount/100, amount%100, code)
+}
@@ -43,6 +43,7 @@ type User struct {
 	LastName      string    `json:"last_name"`
 	Role          string    `json:"role"`
 	EmailVerified bool      `json:"email_verified"` // ✅ Ajouté pour la cohérence
+	TwoFactor     bool      `json:"two_factor_enabled"`
 	CreatedAt     time.Time `json:"created_at"`
 }
 
@@ -53,19 +54,46 @@ type Concert struct {
 	ArtistImage       string    `json:"artist_image,omitempty"`
 	ArtistID          int       `json:"artist_id"`
 	Venue             string    `json:"venue,omitempty"`
-	Location          string    `json:"location"`
 	City              string    `json:"city,omitempty"`
 	Date              time.Time `json:"date"`
 	ImageURL          string    `json:"image_url,omitempty"`
-	Price             float64   `json:"price"`
-	StandardPrice     float64   `json:"standard_price,omitempty"`
-	VIPPrice          float64   `json:"vip_price,omitempty"`
-	AvailableTickets  int       `json:"available_tickets"`
-	AvailableStandard int       `json:"available_standard,omitempty"`
-	AvailableVIP      int       `json:"available_vip,omitempty"`
+	StandardPrice     int64     `json:"standard_price"` // en unités mineures de Currency
+	VIPPrice          int64     `json:"vip_price"`      // en unités mineures de Currency
+	Currency          string    `json:"currency"`
+	AvailableStandard int       `json:"available_standard"`
+	AvailableVIP      int       `json:"available_vip"`
+	Lat               *float64  `json:"lat,omitempty"` // position de la salle, absente si inconnue
+	Lng               *float64  `json:"lng,omitempty"`
 	CreatedAt         time.Time `json:"created_at,omitempty"`
 }
 
+// NearbyConcert est un concert trouvé par position, avec sa distance au point de recherche
+type NearbyConcert struct {
+	Concert
+	DistanceKm float64 `json:"distance_km"`
+}
+
+const (
+	TicketTypeStandard = "standard"
+	TicketTypeVIP      = "vip"
+)
+
+// PriceFor retourne le prix unitaire du tarif demandé, en unités mineures
+func (c *Concert) PriceFor(ticketType string) int64 {
+	if ticketType == TicketTypeVIP {
+		return c.VIPPrice
+	}
+	return c.StandardPrice
+}
+
+// AvailableFor retourne le stock restant du tarif demandé
+func (c *Concert) AvailableFor(ticketType string) int {
+	if ticketType == TicketTypeVIP {
+		return c.AvailableVIP
+	}
+	return c.AvailableStandard
+}
+
 type Reservation struct {
 	ID                    int       `json:"id"`
 	UserID                int       `json:"user_id"`
@@ -74,7 +102,10 @@ type Reservation struct {
 	TicketType            string    `json:"ticket_type"`
 	Quantity              int       `json:"quantity"`
 	Tickets               int       `json:"tickets,omitempty"`
-	TotalPrice            float64   `json:"total_price"`
+	TotalPrice            int64     `json:"total_price"` // en unités mineures de Currency
+	Currency              string    `json:"currency"`
+	DiscountAmount        int64     `json:"discount_amount,omitempty"`
+	PromoCode             string    `json:"promo_code,omitempty"`
 	Status                string    `json:"status"`
 	PaymentStatus         string    `json:"payment_status,omitempty"`
 	PaymentIntent         string    `json:"payment_intent,omitempty"`
@@ -97,19 +128,112 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // durée de vie de l'access token, en secondes
+	User         User   `json:"user"`
+}
+
+// AuthTokens est la paire émise à la connexion et à chaque rotation
+type AuthTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// OAuthExchangeRequest échange le code de passation remis après une connexion externe
+type OAuthExchangeRequest struct {
+	Code         string `json:"code"`
+	State        string `json:"state"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// TwoFactorChallenge remplace LoginResponse quand la 2FA est active
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"` // code TOTP ou code de secours
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"` // base32, pour saisie manuelle
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCode     string `json:"qr_code"` // data URI PNG
+}
+
+// UserIdentity est une identité externe (Google, GitHub, OIDC) liée au compte
+type UserIdentity struct {
+	ID          int        `json:"id"`
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// LoginMethods liste les moyens de connexion d'un compte
+type LoginMethods struct {
+	HasPassword bool           `json:"has_password"`
+	Identities  []UserIdentity `json:"identities"`
+}
+
+type IdentityLinkStart struct {
+	LinkToken string `json:"link_token"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+type IdentityLinkConfirmRequest struct {
+	ConfirmToken string `json:"confirm_token"`
 }
 
 type CreatePaymentIntentRequest struct {
 	ConcertID  int    `json:"concert_id"`
 	TicketType string `json:"ticket_type"`
 	Quantity   int    `json:"quantity"`
+	PromoCode  string `json:"promo_code,omitempty"`
 }
 
 type CreatePaymentIntentResponse struct {
-	ClientSecret string  `json:"client_secret"`
-	Amount       float64 `json:"amount"`
+	ClientSecret   string `json:"client_secret"`
+	Amount         int64  `json:"amount"` // en unités mineures de Currency, réduction déduite
+	Currency       string `json:"currency"`
+	DiscountAmount int64  `json:"discount_amount,omitempty"`
+	PromoCode      string `json:"promo_code,omitempty"`
+}
+
+// CheckoutItem est une ligne de la session Checkout (un tarif)
+type CheckoutItem struct {
+	TicketType string `json:"ticket_type"`
+	Quantity   int    `json:"quantity"`
+}
+
+// CreateCheckoutSessionRequest accepte plusieurs tarifs via items, ou un seul via ticket_type/quantity
+type CreateCheckoutSessionRequest struct {
+	ConcertID  int            `json:"concert_id"`
+	Items      []CheckoutItem `json:"items,omitempty"`
+	TicketType string         `json:"ticket_type,omitempty"`
+	Quantity   int            `json:"quantity,omitempty"`
+}
+
+type CreateCheckoutSessionResponse struct {
+	SessionID      string    `json:"session_id"`
+	URL            string    `json:"url"`
+	Amount         int64     `json:"amount"` // en unités mineures de Currency
+	Currency       string    `json:"currency"`
+	ReservationIDs []int     `json:"reservation_ids"`
+	ExpiresAt      time.Time `json:"expires_at"`
 }
 
 type ConfirmPaymentRequest struct {
@@ -118,3 +242,149 @@ type ConfirmPaymentRequest struct {
 	TicketType      string `json:"ticket_type"`
 	Quantity        int    `json:"quantity"`
 }
+
+type Refund struct {
+	ID             int       `json:"id"`
+	ReservationID  int       `json:"reservation_id"`
+	StripeRefundID string    `json:"stripe_refund_id"`
+	Amount         int64     `json:"amount"` // en unités mineures de Currency
+	Currency       string    `json:"currency"`
+	Status         string    `json:"status"`
+	Reason         string    `json:"reason,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at,omitempty"`
+}
+
+type RefundRequest struct {
+	Amount int64  `json:"amount,omitempty"` // en unités mineures, 0 = remboursement total
+	Reason string `json:"reason,omitempty"` // duplicate, fraudulent ou requested_by_customer
+}
+
+type StripeEvent struct {
+	ID            string     `json:"id"`
+	Type          string     `json:"type"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	ReceivedAt    time.Time  `json:"received_at"`
+	ProcessedAt   *time.Time `json:"processed_at,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+const (
+	DiscountTypePercent = "percent"
+	DiscountTypeFixed   = "fixed"
+)
+
+// PromoCode est un code de réduction, global ou limité à un concert
+type PromoCode struct {
+	ID                    int        `json:"id"`
+	Code                  string     `json:"code"`
+	Description           string     `json:"description,omitempty"`
+	DiscountType          string     `json:"discount_type"`  // percent ou fixed
+	DiscountValue         int64      `json:"discount_value"` // pourcentage, ou unités mineures de Currency
+	Currency              string     `json:"currency,omitempty"`
+	ConcertID             *int       `json:"concert_id,omitempty"`
+	MaxRedemptions        *int       `json:"max_redemptions,omitempty"`
+	MaxRedemptionsPerUser *int       `json:"max_redemptions_per_user,omitempty"`
+	MinQuantity           int        `json:"min_quantity"`
+	StartsAt              *time.Time `json:"starts_at,omitempty"`
+	EndsAt                *time.Time `json:"ends_at,omitempty"`
+	Active                bool       `json:"active"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// PromoCodeStats résume les utilisations d'un code
+type PromoCodeStats struct {
+	PromoCodeID          int              `json:"promo_code_id"`
+	Code                 string           `json:"code"`
+	Redemptions          int              `json:"redemptions"`         // réservations payées
+	PendingRedemptions   int              `json:"pending_redemptions"` // checkouts en cours
+	UniqueUsers          int              `json:"unique_users"`
+	TicketsSold          int              `json:"tickets_sold"`
+	DiscountByCurrency   map[string]int64 `json:"discount_by_currency"`
+	RevenueByCurrency    map[string]int64 `json:"revenue_by_currency"`
+	RemainingRedemptions *int             `json:"remaining_redemptions,omitempty"`
+}
+
+const (
+	TicketStatusValid = "valid"
+	TicketStatusUsed  = "used"
+	TicketStatusVoid  = "void"
+)
+
+// Ticket est un billet individuel (une place) émis quand la réservation est payée
+type Ticket struct {
+	ID            int        `json:"id"`
+	ReservationID int        `json:"reservation_id"`
+	UserID        int        `json:"user_id"`
+	ConcertID     int        `json:"concert_id"`
+	ConcertName   string     `json:"concert_name,omitempty"`
+	ConcertDate   *time.Time `json:"concert_date,omitempty"`
+	TicketType    string     `json:"ticket_type"`
+	Code          string     `json:"code"`
+	Status        string     `json:"status"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// ScanRequest est envoyé par le scanner à l'entrée de la salle
+type ScanRequest struct {
+	Payload   string `json:"payload"`              // contenu du QR code
+	ConcertID int    `json:"concert_id,omitempty"` // concert contrôlé, vérifié s'il est fourni
+}
+
+// ScanResult indique si la personne peut entrer ; Reason explique un refus
+type ScanResult struct {
+	Valid  bool    `json:"valid"`
+	Reason string  `json:"reason,omitempty"` // invalid_signature, not_found, wrong_concert, already_used, refunded
+	Detail string  `json:"detail,omitempty"`
+	Ticket *Ticket `json:"ticket,omitempty"`
+}
+
+// LoginLockout est un verrouillage temporaire de connexion (compte ou IP)
+type LoginLockout struct {
+	ID          int        `json:"id"`
+	Scope       string     `json:"scope"` // "account" ou "ip"
+	Key         string     `json:"key"`   // email ou adresse IP
+	UserID      *int       `json:"user_id,omitempty"`
+	Failures    int        `json:"failures"`
+	LockedUntil time.Time  `json:"locked_until"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReleasedAt  *time.Time `json:"released_at,omitempty"`
+}
+
+// AdminUser est une ligne de la liste des utilisateurs de l'admin
+type AdminUser struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	IsAdmin       bool      `json:"is_admin"`
+	EmailVerified bool      `json:"email_verified"`
+	TwoFactor     bool      `json:"two_factor_enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	TotalBookings int       `json:"total_bookings"`
+}
+
+// AdminPayment est une réservation vue par l'admin, avec son acheteur et son concert
+type AdminPayment struct {
+	ID              int       `json:"id"`
+	UserID          int       `json:"user_id"`
+	UserName        string    `json:"user_name"`
+	UserEmail       string    `json:"user_email"`
+	ConcertID       int       `json:"concert_id"`
+	ConcertName     string    `json:"concert_name"`
+	ConcertLocation string    `json:"concert_location"` // salle, ville
+	ConcertDate     time.Time `json:"concert_date"`
+	ArtistName      string    `json:"artist_name"`
+	TicketType      string    `json:"ticket_type"`
+	Tickets         int       `json:"tickets"`
+	TotalPrice      int64     `json:"total_price"` // en unités mineures de Currency
+	Currency        string    `json:"currency"`
+	Status          string    `json:"status"`
+	PaymentStatus   string    `json:"payment_status"`
+	PaymentIntent   string    `json:"payment_intent"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// FieldErrors associe un champ JSON invalide à son message ; l'API la renvoie
+// telle quelle pour que le formulaire affiche l'erreur sous le bon champ
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field + ": " + e[field]
+	}
+	return "invalid fields: " + strings.Join(parts, ", ")
+}
+
+// Err retourne nil s'il n'y a aucune erreur, pour finir une validation par `return errs.Err()`
+func (e FieldErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"groupie-backend/internal/ratelimit"
+	"groupie-backend/middleware"
+)
+
+// Politiques de limitation, par client (utilisateur connecté ou IP) et par route.
+// Chaque politique a son propre compteur.
+var (
+	defaultRateLimit  = ratelimit.Policy{Name: "api", Limit: 300, Window: time.Minute}
+	catalogRateLimit  = ratelimit.Policy{Name: "catalog", Limit: 600, Window: time.Minute}
+	loginRateLimit    = ratelimit.Policy{Name: "login", Limit: 10, Window: time.Minute}
+	registerRateLimit = ratelimit.Policy{Name: "register", Limit: 10, Window: time.Hour}
+	emailRateLimit    = ratelimit.Policy{Name: "email", Limit: 5, Window: 15 * time.Minute}
+	tokenRateLimit    = ratelimit.Policy{Name: "token", Limit: 30, Window: time.Minute}
+	paymentRateLimit  = ratelimit.Policy{Name: "payment", Limit: 10, Window: time.Minute}
+	webhookRateLimit  = ratelimit.Policy{Name: "webhook", Limit: 1000, Window: time.Minute}
+)
+
+// newRateLimiter choisit le stockage des compteurs : Redis (partagé entre les
+// instances) si RATE_LIMIT_REDIS_URL ou REDIS_URL est défini, mémoire sinon
+func newRateLimiter() *ratelimit.Limiter {
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	redisURL := os.Getenv("RATE_LIMIT_REDIS_URL")
+	if redisURL == "" {
+		redisURL = os.Getenv("REDIS_URL")
+	}
+	if redisURL != "" {
+		redisStore, err := ratelimit.NewRedisStore(redisURL)
+		if err != nil {
+			log.Fatalf("❌ Rate limiting: %v", err)
+		}
+		store = redisStore
+		log.Println("🚦 Rate limiting: shared counters in Redis")
+	} else {
+		log.Println("🚦 Rate limiting: in-memory counters (single instance)")
+	}
+
+	limits := ratelimit.New(store, middleware.RateLimitKey, defaultRateLimit)
+
+	// Catalogue public : consulté en rafales par le front
+	limits.Route("/api/artists", catalogRateLimit, "GET")
+	limits.Route("/api/artists/{id}", catalogRateLimit, "GET")
+	limits.Route("/api/concerts", catalogRateLimit, "GET")
+	limits.Route("/api/concerts/search", catalogRateLimit, "GET")
+	limits.Route("/api/concerts/nearby", catalogRateLimit, "GET")
+	limits.Route("/api/concerts/within", catalogRateLimit, "GET")
+	limits.Route("/api/search", catalogRateLimit, "GET")
+	limits.Route("/api/search/suggest", catalogRateLimit, "GET")
+	limits.Route("/api/deezer/widget", catalogRateLimit, "GET")
+
+	// Authentification (la protection anti force brute par compte s'y ajoute)
+	limits.Route("/api/auth/login", loginRateLimit, "POST")
+	limits.Route("/api/auth/2fa/login", loginRateLimit, "POST")
+	limits.Route("/api/auth/register", registerRateLimit, "POST")
+	limits.Route("/api/auth/request-password-reset", emailRateLimit, "POST")
+	limits.Route("/api/auth/send-verification", emailRateLimit, "POST")
+	limits.Route("/api/auth/refresh", tokenRateLimit, "POST")
+	limits.Route("/api/auth/exchange", tokenRateLimit, "POST")
+
+	// Paiement
+	limits.Route("/api/payment/create-intent", paymentRateLimit, "POST")
+	limits.Route("/api/payment/checkout-session", paymentRateLimit, "POST")
+
+	// Stripe envoie ses webhooks depuis quelques IP partagées par tous ses clients
+	limits.Route("/api/stripe/webhook", webhookRateLimit, "POST")
+
+	return limits
+}
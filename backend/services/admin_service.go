@@ -0,0 +1,492 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/internal/listquery"
+	"groupie-backend/models"
+)
+
+// ========= ADMINISTRATION DU CATALOGUE, DES UTILISATEURS ET DES PAIEMENTS =========
+
+var (
+	ErrConcertNotFound    = errors.New("concert not found")
+	ErrConcertHasBookings = errors.New("ce concert a des réservations payées ou remboursées, il ne peut pas être supprimé")
+	ErrArtistHasBookings  = errors.New("cet artiste a des concerts avec des réservations payées ou remboursées, il ne peut pas être supprimé")
+)
+
+// billedReservation repère une réservation qui a donné lieu à un paiement :
+// sa suppression (ON DELETE CASCADE) effacerait l'historique comptable
+const billedReservation = `(r.status = 'paid' OR r.payment_status IN ('succeeded', 'refunded', 'partially_refunded'))`
+
+// ----- Artistes -----
+
+// validateArtist normalise et vérifie un artiste avant création ou mise à jour
+func validateArtist(a *models.Artist) error {
+	errs := models.FieldErrors{}
+
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" {
+		errs["name"] = "required"
+	} else if len(a.Name) > 255 {
+		errs["name"] = "must be at most 255 characters"
+	}
+
+	a.Image = strings.TrimSpace(a.Image)
+	if a.Image != "" && !isHTTPURL(a.Image) {
+		errs["image"] = "must be an http(s) URL"
+	}
+
+	members := make([]string, 0, len(a.Members))
+	for _, m := range a.Members {
+		if m = strings.TrimSpace(m); m != "" {
+			members = append(members, m)
+		}
+	}
+	a.Members = members
+
+	if a.TopTracks != nil {
+		tracks := make([]models.Track, 0, len(a.TopTracks))
+		for _, t := range a.TopTracks {
+			if t.Title = strings.TrimSpace(t.Title); t.Title != "" {
+				tracks = append(tracks, t)
+			}
+		}
+		a.TopTracks = tracks
+	}
+
+	if a.CreationDate != 0 && (a.CreationDate < 1900 || a.CreationDate > time.Now().Year()) {
+		errs["creationDate"] = fmt.Sprintf("must be between 1900 and %d", time.Now().Year())
+	}
+	a.FirstAlbum = strings.TrimSpace(a.FirstAlbum)
+	a.Genre = strings.TrimSpace(a.Genre)
+	if len(a.Genre) > 100 {
+		errs["genre"] = "must be at most 100 characters"
+	}
+	a.Bio = strings.TrimSpace(a.Bio)
+
+	return errs.Err()
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// GetAdminArtist retourne un artiste du catalogue par son ID
+func GetAdminArtist(id int) (*models.Artist, error) {
+	artist, err := scanArtist(database.DB.QueryRow(`SELECT `+artistColumns+` FROM artists WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrArtistNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching artist: %w", err)
+	}
+	return &artist, nil
+}
+
+// ListAdminArtists retourne une page d'artistes
+func ListAdminArtists(q listquery.Query) (listquery.Page[models.Artist], error) {
+	page, err := listquery.Fetch(database.DB, ArtistListSpec, q, artistColumns, `FROM artists`,
+		func(rows *sql.Rows) (models.Artist, error) { return scanArtist(rows) })
+	if err != nil {
+		return page, fmt.Errorf("error listing artists: %w", err)
+	}
+	return page, nil
+}
+
+// artistJSONColumns sérialise les champs stockés en JSON dans la table artists
+func artistJSONColumns(a models.Artist) (members, locations, dates, relations string) {
+	if a.Members == nil {
+		a.Members = []string{}
+	}
+	if a.Locations == nil {
+		a.Locations = []string{}
+	}
+	if a.ConcertDates == nil {
+		a.ConcertDates = []string{}
+	}
+	if a.Relations == nil {
+		a.Relations = map[string][]string{}
+	}
+	m, _ := json.Marshal(a.Members)
+	l, _ := json.Marshal(a.Locations)
+	d, _ := json.Marshal(a.ConcertDates)
+	r, _ := json.Marshal(a.Relations)
+	return string(m), string(l), string(d), string(r)
+}
+
+// topTracksJSON sérialise les titres phares ; NULL si le client ne les envoie
+// pas, pour qu'une mise à jour depuis l'admin ne les efface pas
+func topTracksJSON(a models.Artist) sql.NullString {
+	if a.TopTracks == nil {
+		return sql.NullString{}
+	}
+	t, _ := json.Marshal(a.TopTracks)
+	return sql.NullString{String: string(t), Valid: true}
+}
+
+// CreateArtist ajoute un artiste au catalogue
+func CreateArtist(a models.Artist) (*models.Artist, error) {
+	if err := validateArtist(&a); err != nil {
+		return nil, err
+	}
+
+	members, locations, dates, relations := artistJSONColumns(a)
+	created, err := scanArtist(database.DB.QueryRow(`
+		INSERT INTO artists (name, image, bio, members, creation_date, first_album, locations, concert_dates, relations, genre, top_tracks)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, NULLIF($5, 0), NULLIF($6, ''), $7, $8, $9, $10, COALESCE($11, '[]'))
+		RETURNING `+artistColumns,
+		a.Name, a.Image, a.Bio, members, a.CreationDate, a.FirstAlbum, locations, dates, relations, a.Genre, topTracksJSON(a)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating artist: %w", err)
+	}
+	catalogChanged()
+	return &created, nil
+}
+
+// UpdateArtist remplace la fiche d'un artiste ; le nom dénormalisé dans ses
+// concerts suit le nouveau nom
+func UpdateArtist(id int, a models.Artist) (*models.Artist, error) {
+	if err := validateArtist(&a); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	members, locations, dates, relations := artistJSONColumns(a)
+	updated, err := scanArtist(tx.QueryRow(`
+		UPDATE artists
+		SET name = $1, image = NULLIF($2, ''), bio = NULLIF($3, ''), members = $4,
+		    creation_date = NULLIF($5, 0), first_album = NULLIF($6, ''),
+		    locations = $7, concert_dates = $8, relations = $9, genre = $10,
+		    top_tracks = COALESCE($11, top_tracks)
+		WHERE id = $12
+		RETURNING `+artistColumns,
+		a.Name, a.Image, a.Bio, members, a.CreationDate, a.FirstAlbum, locations, dates, relations, a.Genre, topTracksJSON(a), id))
+	if err == sql.ErrNoRows {
+		return nil, ErrArtistNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error updating artist: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE concerts SET artist_name = $1 WHERE artist_id = $2`, updated.Name, id); err != nil {
+		return nil, fmt.Errorf("error updating artist concerts: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing artist: %w", err)
+	}
+	catalogChanged()
+	return &updated, nil
+}
+
+// DeleteArtist supprime un artiste et ses concerts (ON DELETE CASCADE), sauf
+// si l'un d'eux a des réservations payées
+func DeleteArtist(id int) error {
+	var paid bool
+	if err := database.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM reservations r
+			JOIN concerts c ON c.id = r.concert_id
+			WHERE c.artist_id = $1 AND `+billedReservation+`
+		)
+	`, id).Scan(&paid); err != nil {
+		return fmt.Errorf("error checking artist bookings: %w", err)
+	}
+	if paid {
+		return ErrArtistHasBookings
+	}
+
+	result, err := database.DB.Exec(`DELETE FROM artists WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting artist: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrArtistNotFound
+	}
+	catalogChanged()
+	return nil
+}
+
+// ----- Concerts -----
+
+const adminConcertColumns = `
+	c.id, COALESCE(c.artist_id, 0), c.name, c.artist_name, COALESCE(a.image, ''), c.venue, c.city, c.date,
+	COALESCE(c.image_url, ''), c.standard_price, c.vip_price, c.currency,
+	c.available_standard, c.available_vip, c.created_at, c.latitude, c.longitude
+`
+
+func scanAdminConcert(row rowScanner) (models.Concert, error) {
+	var concert models.Concert
+	err := row.Scan(
+		&concert.ID,
+		&concert.ArtistID,
+		&concert.Name,
+		&concert.ArtistName,
+		&concert.ArtistImage,
+		&concert.Venue,
+		&concert.City,
+		&concert.Date,
+		&concert.ImageURL,
+		&concert.StandardPrice,
+		&concert.VIPPrice,
+		&concert.Currency,
+		&concert.AvailableStandard,
+		&concert.AvailableVIP,
+		&concert.CreatedAt,
+		&concert.Lat,
+		&concert.Lng,
+	)
+	return concert, err
+}
+
+// validateConcert normalise et vérifie un concert ; le nom de l'artiste est
+// repris de la fiche artiste
+func validateConcert(c *models.Concert) error {
+	errs := models.FieldErrors{}
+
+	if c.ArtistID <= 0 {
+		errs["artist_id"] = "required"
+	} else {
+		artist, err := GetAdminArtist(c.ArtistID)
+		if errors.Is(err, ErrArtistNotFound) {
+			errs["artist_id"] = "unknown artist"
+		} else if err != nil {
+			return err
+		} else {
+			c.ArtistName = artist.Name
+		}
+	}
+
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		c.Name = c.ArtistName
+	}
+	if len(c.Name) > 255 {
+		errs["name"] = "must be at most 255 characters"
+	}
+
+	c.Venue = strings.TrimSpace(c.Venue)
+	if c.Venue == "" {
+		errs["venue"] = "required"
+	} else if len(c.Venue) > 255 {
+		errs["venue"] = "must be at most 255 characters"
+	}
+
+	c.City = strings.TrimSpace(c.City)
+	if c.City == "" {
+		errs["city"] = "required"
+	} else if len(c.City) > 100 {
+		errs["city"] = "must be at most 100 characters"
+	}
+
+	if c.Date.IsZero() {
+		errs["date"] = "required"
+	}
+
+	c.ImageURL = strings.TrimSpace(c.ImageURL)
+	if c.ImageURL != "" && !isHTTPURL(c.ImageURL) {
+		errs["image_url"] = "must be an http(s) URL"
+	}
+
+	if c.StandardPrice < 0 {
+		errs["standard_price"] = "must be greater than or equal to 0"
+	}
+	if c.VIPPrice < 0 {
+		errs["vip_price"] = "must be greater than or equal to 0"
+	}
+	c.Currency = models.NormalizeCurrency(c.Currency)
+	if !models.IsValidCurrency(c.Currency) {
+		errs["currency"] = "must be an ISO 4217 code"
+	}
+	if c.AvailableStandard < 0 {
+		errs["available_standard"] = "must be greater than or equal to 0"
+	}
+	if c.AvailableVIP < 0 {
+		errs["available_vip"] = "must be greater than or equal to 0"
+	}
+
+	// Position facultative, mais complète : elle place le concert sur la carte
+	switch {
+	case (c.Lat == nil) != (c.Lng == nil):
+		errs["lat"] = "lat and lng must be set together"
+	case c.Lat != nil && (*c.Lat < -90 || *c.Lat > 90):
+		errs["lat"] = "must be between -90 and 90"
+	case c.Lng != nil && (*c.Lng < -180 || *c.Lng > 180):
+		errs["lng"] = "must be between -180 and 180"
+	}
+
+	return errs.Err()
+}
+
+// ListAdminConcerts retourne une page de concerts, avec l'image de l'artiste
+func ListAdminConcerts(q listquery.Query) (listquery.Page[models.Concert], error) {
+	page, err := listquery.Fetch(database.DB, ConcertListSpec, q, adminConcertColumns,
+		`FROM concerts c LEFT JOIN artists a ON a.id = c.artist_id`,
+		func(rows *sql.Rows) (models.Concert, error) { return scanAdminConcert(rows) })
+	if err != nil {
+		return page, fmt.Errorf("error listing concerts: %w", err)
+	}
+	return page, nil
+}
+
+func getAdminConcert(id int) (*models.Concert, error) {
+	concert, err := scanAdminConcert(database.DB.QueryRow(`
+		SELECT `+adminConcertColumns+`
+		FROM concerts c
+		LEFT JOIN artists a ON a.id = c.artist_id
+		WHERE c.id = $1
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrConcertNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching concert: %w", err)
+	}
+	return &concert, nil
+}
+
+// CreateConcert ajoute un concert au catalogue
+func CreateConcert(c models.Concert) (*models.Concert, error) {
+	if err := validateConcert(&c); err != nil {
+		return nil, err
+	}
+
+	var id int
+	err := database.DB.QueryRow(`
+		INSERT INTO concerts (artist_id, name, artist_name, venue, city, date, image_url,
+		                      standard_price, vip_price, currency, available_standard, available_vip,
+		                      latitude, longitude)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`, c.ArtistID, c.Name, c.ArtistName, c.Venue, c.City, c.Date, c.ImageURL,
+		c.StandardPrice, c.VIPPrice, c.Currency, c.AvailableStandard, c.AvailableVIP, c.Lat, c.Lng).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("error creating concert: %w", err)
+	}
+	catalogChanged()
+	return getAdminConcert(id)
+}
+
+// UpdateConcert remplace la fiche d'un concert. Les stocks saisis sont les
+// places encore en vente : celles bloquées par un checkout en cours restent à part.
+// Les réservations existantes gardent leur prix et leur devise.
+func UpdateConcert(id int, c models.Concert) (*models.Concert, error) {
+	if err := validateConcert(&c); err != nil {
+		return nil, err
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE concerts
+		SET artist_id = $1, name = $2, artist_name = $3, venue = $4, city = $5, date = $6,
+		    image_url = NULLIF($7, ''), standard_price = $8, vip_price = $9, currency = $10,
+		    available_standard = $11, available_vip = $12, latitude = $13, longitude = $14
+		WHERE id = $15
+	`, c.ArtistID, c.Name, c.ArtistName, c.Venue, c.City, c.Date, c.ImageURL,
+		c.StandardPrice, c.VIPPrice, c.Currency, c.AvailableStandard, c.AvailableVIP, c.Lat, c.Lng, id)
+	if err != nil {
+		return nil, fmt.Errorf("error updating concert: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrConcertNotFound
+	}
+	catalogChanged()
+	return getAdminConcert(id)
+}
+
+// DeleteConcert supprime un concert sans réservation payée (ses réservations
+// en attente ou expirées partent avec lui)
+func DeleteConcert(id int) error {
+	var paid bool
+	if err := database.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM reservations r WHERE r.concert_id = $1 AND `+billedReservation+`)
+	`, id).Scan(&paid); err != nil {
+		return fmt.Errorf("error checking concert bookings: %w", err)
+	}
+	if paid {
+		return ErrConcertHasBookings
+	}
+
+	result, err := database.DB.Exec(`DELETE FROM concerts WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting concert: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrConcertNotFound
+	}
+	catalogChanged()
+	return nil
+}
+
+// ----- Utilisateurs et paiements -----
+
+// adminUserName est le nom affiché d'un compte (alias u), aussi imprimé sur les billets
+const adminUserName = `COALESCE(NULLIF(TRIM(CONCAT_WS(' ', u.first_name, u.last_name)), ''), u.name, '')`
+
+const adminUserColumns = `
+	u.id, ` + adminUserName + `,
+	u.email, COALESCE(u.role, 'user'), COALESCE(u.email_verified, FALSE), u.totp_enabled,
+	u.created_at,
+	(SELECT COUNT(*) FROM reservations r WHERE r.user_id = u.id) AS total_bookings
+`
+
+func scanAdminUser(rows *sql.Rows) (models.AdminUser, error) {
+	var u models.AdminUser
+	err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.EmailVerified, &u.TwoFactor,
+		&u.CreatedAt, &u.TotalBookings)
+	u.IsAdmin = u.Role == "admin"
+	return u, err
+}
+
+// ListAdminUsers retourne une page de comptes
+func ListAdminUsers(q listquery.Query) (listquery.Page[models.AdminUser], error) {
+	page, err := listquery.Fetch(database.DB, AdminUserListSpec, q, adminUserColumns, `FROM users u`, scanAdminUser)
+	if err != nil {
+		return page, fmt.Errorf("error listing users: %w", err)
+	}
+	return page, nil
+}
+
+const adminPaymentColumns = `
+	r.id, COALESCE(r.user_id, 0), ` + adminUserName + `, COALESCE(u.email, ''),
+	COALESCE(r.concert_id, 0), COALESCE(c.name, ''),
+	COALESCE(c.venue || ', ' || c.city, ''), COALESCE(c.date, r.created_at),
+	COALESCE(c.artist_name, ''),
+	r.ticket_type, COALESCE(r.quantity, 1), r.total_price, r.currency,
+	COALESCE(r.status, ''), COALESCE(r.payment_status, ''),
+	COALESCE(r.stripe_payment_intent_id, ''), r.created_at
+`
+
+func scanAdminPayment(rows *sql.Rows) (models.AdminPayment, error) {
+	var p models.AdminPayment
+	err := rows.Scan(&p.ID, &p.UserID, &p.UserName, &p.UserEmail,
+		&p.ConcertID, &p.ConcertName, &p.ConcertLocation, &p.ConcertDate, &p.ArtistName,
+		&p.TicketType, &p.Tickets, &p.TotalPrice, &p.Currency,
+		&p.Status, &p.PaymentStatus, &p.PaymentIntent, &p.CreatedAt)
+	return p, err
+}
+
+// ListAdminPayments retourne une page de réservations avec leur acheteur et leur concert
+func ListAdminPayments(q listquery.Query) (listquery.Page[models.AdminPayment], error) {
+	page, err := listquery.Fetch(database.DB, AdminPaymentListSpec, q, adminPaymentColumns, `
+		FROM reservations r
+		LEFT JOIN users u ON u.id = r.user_id
+		LEFT JOIN concerts c ON c.id = r.concert_id`, scanAdminPayment)
+	if err != nil {
+		return page, fmt.Errorf("error listing payments: %w", err)
+	}
+	return page, nil
+}
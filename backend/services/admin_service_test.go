@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"groupie-backend/database/dbtest"
+	"groupie-backend/internal/listquery"
+	"groupie-backend/models"
+)
+
+func TestAdminArtistValidation(t *testing.T) {
+	dbtest.Open(t)
+
+	_, err := CreateArtist(models.Artist{Name: "  ", Image: "javascript:alert(1)", CreationDate: 1800})
+	var fields models.FieldErrors
+	if !errors.As(err, &fields) {
+		t.Fatalf("CreateArtist = %v, want field errors", err)
+	}
+	for _, field := range []string{"name", "image", "creationDate"} {
+		if fields[field] == "" {
+			t.Errorf("no error for %s: %v", field, fields)
+		}
+	}
+}
+
+func TestUpdateArtistRenamesItsConcerts(t *testing.T) {
+	db := dbtest.Open(t)
+
+	artist, err := CreateArtist(models.Artist{Name: " Daft Punk ", Members: []string{"Thomas", " ", "Guy-Manuel"}})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	if artist.Name != "Daft Punk" || len(artist.Members) != 2 {
+		t.Errorf("created artist = %+v, want trimmed name and members", artist)
+	}
+	concert := createTestAdminConcert(t, artist.ID)
+	if concert.ArtistName != "Daft Punk" || concert.Name != "Daft Punk" {
+		t.Errorf("created concert = %+v, want the artist name", concert)
+	}
+
+	if _, err := UpdateArtist(artist.ID, models.Artist{Name: "Daft Punk (live)"}); err != nil {
+		t.Fatalf("UpdateArtist: %v", err)
+	}
+	var artistName string
+	if err := db.QueryRow(`SELECT artist_name FROM concerts WHERE id = $1`, concert.ID).Scan(&artistName); err != nil {
+		t.Fatalf("read concert: %v", err)
+	}
+	if artistName != "Daft Punk (live)" {
+		t.Errorf("concert artist_name = %q after rename", artistName)
+	}
+
+	if _, err := UpdateArtist(artist.ID+1000, models.Artist{Name: "Nobody"}); !errors.Is(err, ErrArtistNotFound) {
+		t.Errorf("UpdateArtist(unknown) = %v, want ErrArtistNotFound", err)
+	}
+}
+
+func TestAdminConcertValidation(t *testing.T) {
+	dbtest.Open(t)
+
+	lat := 48.85
+	_, err := CreateConcert(models.Concert{ArtistID: 4242, Currency: "xx", StandardPrice: -1, Lat: &lat})
+	var fields models.FieldErrors
+	if !errors.As(err, &fields) {
+		t.Fatalf("CreateConcert = %v, want field errors", err)
+	}
+	want := map[string]string{
+		"artist_id":      "unknown artist",
+		"venue":          "required",
+		"city":           "required",
+		"date":           "required",
+		"currency":       "must be an ISO 4217 code",
+		"standard_price": "must be greater than or equal to 0",
+		"lat":            "lat and lng must be set together",
+	}
+	for field, msg := range want {
+		if fields[field] != msg {
+			t.Errorf("%s = %q, want %q", field, fields[field], msg)
+		}
+	}
+}
+
+func TestUpdateConcert(t *testing.T) {
+	db := dbtest.Open(t)
+
+	artist, err := CreateArtist(models.Artist{Name: "Air"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	concert := createTestAdminConcert(t, artist.ID)
+
+	lat, lng := 45.76, 4.83
+	update := *concert
+	update.City, update.Venue = "Lyon", "Halle Tony Garnier"
+	update.Currency = "EUR"
+	update.AvailableStandard = 80
+	update.Lat, update.Lng = &lat, &lng
+	updated, err := UpdateConcert(concert.ID, update)
+	if err != nil {
+		t.Fatalf("UpdateConcert: %v", err)
+	}
+	if updated.City != "Lyon" || updated.Currency != "eur" || updated.AvailableStandard != 80 ||
+		updated.Lat == nil || *updated.Lat != lat || updated.ArtistImage != "" {
+		t.Errorf("updated concert = %+v", updated)
+	}
+
+	var held int
+	if err := db.QueryRow(`SELECT held_standard FROM concerts WHERE id = $1`, concert.ID).Scan(&held); err != nil {
+		t.Fatalf("read concert: %v", err)
+	}
+	if held != 0 {
+		t.Errorf("held_standard = %d, want 0", held)
+	}
+
+	if _, err := UpdateConcert(concert.ID+1000, update); !errors.Is(err, ErrConcertNotFound) {
+		t.Errorf("UpdateConcert(unknown) = %v, want ErrConcertNotFound", err)
+	}
+}
+
+func TestDeleteRefusedWhenBookingsWereBilled(t *testing.T) {
+	db := dbtest.Open(t)
+
+	artist, err := CreateArtist(models.Artist{Name: "Justice"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	billed := createTestAdminConcert(t, artist.ID)
+	unbilled := createTestAdminConcert(t, artist.ID)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	reservationID := insertPaidReservation(t, db, userID, billed.ID, 1, 5000, "pi_test_1")
+	if _, err := db.Exec(`UPDATE reservations SET status = 'refunded', payment_status = 'refunded' WHERE id = $1`, reservationID); err != nil {
+		t.Fatalf("refund reservation: %v", err)
+	}
+	var pendingID int
+	err = db.QueryRow(`
+		INSERT INTO reservations
+			(user_id, concert_id, ticket_type, quantity, total_price, currency, status, payment_status, expires_at)
+		VALUES ($1, $2, 'standard', 1, 5000, 'eur', 'expired', 'pending', NOW())
+		RETURNING id
+	`, userID, unbilled.ID).Scan(&pendingID)
+	if err != nil {
+		t.Fatalf("insert reservation: %v", err)
+	}
+
+	// Un remboursement reste dans l'historique comptable
+	if err := DeleteConcert(billed.ID); !errors.Is(err, ErrConcertHasBookings) {
+		t.Errorf("DeleteConcert(refunded booking) = %v, want ErrConcertHasBookings", err)
+	}
+	if err := DeleteArtist(artist.ID); !errors.Is(err, ErrArtistHasBookings) {
+		t.Errorf("DeleteArtist = %v, want ErrArtistHasBookings", err)
+	}
+
+	if err := DeleteConcert(unbilled.ID); err != nil {
+		t.Fatalf("DeleteConcert(expired booking): %v", err)
+	}
+	var remaining int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM reservations WHERE id = $1`, pendingID).Scan(&remaining); err != nil {
+		t.Fatalf("count reservations: %v", err)
+	}
+	if remaining != 0 {
+		t.Error("expired reservation kept after its concert was deleted")
+	}
+	if err := DeleteConcert(unbilled.ID); !errors.Is(err, ErrConcertNotFound) {
+		t.Errorf("DeleteConcert twice = %v, want ErrConcertNotFound", err)
+	}
+}
+
+func TestListAdminUsersAndPayments(t *testing.T) {
+	db := dbtest.Open(t)
+
+	buyerID := insertTestUser(t, db, "ada@example.com")
+	if _, err := db.Exec(`UPDATE users SET first_name = 'Ada', last_name = 'Lovelace' WHERE id = $1`, buyerID); err != nil {
+		t.Fatalf("name user: %v", err)
+	}
+	insertTestUser(t, db, "legacy@example.com") // name seul, d'avant first_name / last_name
+	concertID := insertTestConcert(t, db, 10, 0)
+	paidID := insertPaidReservation(t, db, buyerID, concertID, 2, 10000, "pi_test_1")
+	insertPaidReservation(t, db, buyerID, concertID, 1, 5000, "pi_test_2")
+	if _, err := db.Exec(`UPDATE reservations SET status = 'refunded', payment_status = 'refunded' WHERE stripe_payment_intent_id = 'pi_test_2'`); err != nil {
+		t.Fatalf("refund reservation: %v", err)
+	}
+
+	users, err := ListAdminUsers(parseListQuery(t, AdminUserListSpec.Parse, "sort=email"))
+	if err != nil {
+		t.Fatalf("ListAdminUsers: %v", err)
+	}
+	if users.Total != 2 || len(users.Items) != 2 {
+		t.Fatalf("users = %+v, want 2", users)
+	}
+	if u := users.Items[0]; u.Name != "Ada Lovelace" || u.TotalBookings != 2 || u.IsAdmin {
+		t.Errorf("first user = %+v", u)
+	}
+	if u := users.Items[1]; u.Name != "Test User" || u.TotalBookings != 0 {
+		t.Errorf("legacy user = %+v", u)
+	}
+
+	payments, err := ListAdminPayments(parseListQuery(t, AdminPaymentListSpec.Parse, "status=paid&q=ada"))
+	if err != nil {
+		t.Fatalf("ListAdminPayments: %v", err)
+	}
+	if payments.Total != 1 || len(payments.Items) != 1 {
+		t.Fatalf("payments = %+v, want the paid one", payments)
+	}
+	p := payments.Items[0]
+	if p.ID != paidID || p.UserName != "Ada Lovelace" || p.ConcertLocation != "Test Venue, Paris" ||
+		p.Tickets != 2 || p.TotalPrice != 10000 || p.PaymentIntent != "pi_test_1" {
+		t.Errorf("payment = %+v", p)
+	}
+}
+
+// createTestAdminConcert crée par le service d'admin un concert à venir de l'artiste.
+func createTestAdminConcert(t *testing.T, artistID int) *models.Concert {
+	t.Helper()
+
+	concert, err := CreateConcert(models.Concert{
+		ArtistID:          artistID,
+		Venue:             "Accor Arena",
+		City:              "Paris",
+		Date:              time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second),
+		StandardPrice:     5000,
+		VIPPrice:          12000,
+		Currency:          "eur",
+		AvailableStandard: 100,
+		AvailableVIP:      10,
+	})
+	if err != nil {
+		t.Fatalf("CreateConcert: %v", err)
+	}
+	return concert
+}
+
+func parseListQuery(t *testing.T, parse func(url.Values) (listquery.Query, error), raw string) listquery.Query {
+	t.Helper()
+
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := parse(values)
+	if err != nil {
+		t.Fatalf("parse %q: %v", raw, err)
+	}
+	return q
+}
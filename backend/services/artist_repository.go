@@ -0,0 +1,358 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"groupie-backend/internal/geo"
+	"groupie-backend/internal/listquery"
+	"groupie-backend/internal/search"
+	"groupie-backend/models"
+)
+
+var ErrArtistNotFound = errors.New("artist not found")
+
+// ArtistRepository est la source unique du catalogue (artistes et concerts),
+// partagée par les routes publiques et l'admin
+type ArtistRepository interface {
+	ListArtists(q listquery.Query) (listquery.Page[models.Artist], error)
+	GetByID(id int) (models.Artist, error)
+	ListConcerts(q listquery.Query) (listquery.Page[models.Concert], error)
+	Search(query string, types []string, limit int) ([]search.Hit, error)
+	CatalogDocs() ([]search.Doc, error)
+	NearbyConcerts(center geo.Point, radiusKm float64, limit int) ([]models.NearbyConcert, error)
+	ConcertsInBox(box geo.BBox, from geo.Point, limit int) ([]models.NearbyConcert, error)
+}
+
+// PostgresArtistRepository lit le catalogue dans les tables artists et concerts
+type PostgresArtistRepository struct {
+	db *sql.DB
+}
+
+var _ ArtistRepository = (*PostgresArtistRepository)(nil)
+
+func NewPostgresArtistRepository(db *sql.DB) *PostgresArtistRepository {
+	return &PostgresArtistRepository{db: db}
+}
+
+const artistColumns = `
+	id, name, COALESCE(image, ''), genre, COALESCE(bio, ''), COALESCE(members, '[]'),
+	COALESCE(creation_date, 0), COALESCE(first_album, ''), COALESCE(locations, '[]'),
+	COALESCE(concert_dates, '[]'), COALESCE(relations, '{}'), top_tracks
+`
+
+const concertColumns = `
+	id, COALESCE(artist_id, 0), name, artist_name, venue, city, date,
+	COALESCE(image_url, ''), standard_price, vip_price, currency,
+	COALESCE(available_standard, 0), COALESCE(available_vip, 0), created_at,
+	latitude, longitude
+`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanArtist(row rowScanner) (models.Artist, error) {
+	var artist models.Artist
+	var membersJSON, locationsJSON, datesJSON, relationsJSON, tracksJSON string
+
+	err := row.Scan(&artist.ID, &artist.Name, &artist.Image, &artist.Genre, &artist.Bio, &membersJSON,
+		&artist.CreationDate, &artist.FirstAlbum, &locationsJSON, &datesJSON, &relationsJSON, &tracksJSON)
+	if err != nil {
+		return artist, err
+	}
+
+	json.Unmarshal([]byte(membersJSON), &artist.Members)
+	json.Unmarshal([]byte(locationsJSON), &artist.Locations)
+	json.Unmarshal([]byte(datesJSON), &artist.ConcertDates)
+	json.Unmarshal([]byte(relationsJSON), &artist.Relations)
+	json.Unmarshal([]byte(tracksJSON), &artist.TopTracks)
+
+	return artist, nil
+}
+
+func scanConcert(row rowScanner) (models.Concert, error) {
+	var concert models.Concert
+	err := row.Scan(
+		&concert.ID,
+		&concert.ArtistID,
+		&concert.Name,
+		&concert.ArtistName,
+		&concert.Venue,
+		&concert.City,
+		&concert.Date,
+		&concert.ImageURL,
+		&concert.StandardPrice,
+		&concert.VIPPrice,
+		&concert.Currency,
+		&concert.AvailableStandard,
+		&concert.AvailableVIP,
+		&concert.CreatedAt,
+		&concert.Lat,
+		&concert.Lng,
+	)
+	return concert, err
+}
+
+func (r *PostgresArtistRepository) ListArtists(q listquery.Query) (listquery.Page[models.Artist], error) {
+	page, err := listquery.Fetch(r.db, ArtistListSpec, q, artistColumns, `FROM artists`,
+		func(rows *sql.Rows) (models.Artist, error) { return scanArtist(rows) })
+	if err != nil {
+		return page, fmt.Errorf("error listing artists: %w", err)
+	}
+	if len(page.Items) == 0 {
+		return page, nil
+	}
+
+	// Rattacher les prochaines dates des artistes de la page
+	ids := make([]int64, len(page.Items))
+	for i, artist := range page.Items {
+		ids[i] = int64(artist.ID)
+	}
+	upcoming, err := r.upcomingDates(`WHERE date >= NOW() AND artist_id = ANY($1)`, ids)
+	if err != nil {
+		return page, err
+	}
+	for i := range page.Items {
+		page.Items[i].UpcomingDates = upcoming[page.Items[i].ID]
+	}
+
+	return page, nil
+}
+
+func (r *PostgresArtistRepository) GetByID(id int) (models.Artist, error) {
+	artist, err := scanArtist(r.db.QueryRow(`SELECT `+artistColumns+` FROM artists WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Artist{}, ErrArtistNotFound
+		}
+		return models.Artist{}, fmt.Errorf("error fetching artist: %w", err)
+	}
+
+	upcoming, err := r.upcomingDates(`WHERE date >= NOW() AND artist_id = $1`, id)
+	if err != nil {
+		return models.Artist{}, err
+	}
+	artist.UpcomingDates = upcoming[artist.ID]
+
+	return artist, nil
+}
+
+func (r *PostgresArtistRepository) ListConcerts(q listquery.Query) (listquery.Page[models.Concert], error) {
+	page, err := listquery.Fetch(r.db, ConcertListSpec, q, concertColumns, `FROM concerts c`,
+		func(rows *sql.Rows) (models.Concert, error) { return scanConcert(rows) })
+	if err != nil {
+		return page, fmt.Errorf("error listing concerts: %w", err)
+	}
+	return page, nil
+}
+
+// searchCandidates rassemble les correspondances de chaque type avec leur
+// word_similarity ; l'opérateur <% s'appuie sur les index trigrammes (migration 022)
+const searchCandidates = `
+	WITH q AS (SELECT search_fold($1) AS q)
+	SELECT type, text, subtitle, artist_id, concerts, similarity FROM (
+		SELECT 'artist' AS type, a.name AS text, a.genre AS subtitle, a.id AS artist_id, 0 AS concerts,
+		       word_similarity(q.q, search_fold(a.name)) AS similarity
+		FROM artists a, q
+		WHERE q.q <% search_fold(a.name)
+
+		UNION ALL
+		SELECT 'member', m.member, a.name, a.id, 0, word_similarity(q.q, search_fold(m.member))
+		FROM artists a, q, jsonb_array_elements_text(COALESCE(NULLIF(a.members, ''), '[]')::jsonb) AS m(member)
+		WHERE q.q <% search_fold(COALESCE(a.members, '')) AND q.q <% search_fold(m.member)
+		  AND search_fold(m.member) <> search_fold(a.name)
+
+		UNION ALL
+		SELECT 'track', t.title, a.name, a.id, 0, word_similarity(q.q, search_fold(t.title))
+		FROM artists a, q, jsonb_array_elements(a.top_tracks::jsonb) AS tr(track),
+		     LATERAL (SELECT COALESCE(tr.track->>'title', '') AS title) t
+		WHERE q.q <% search_fold(a.top_tracks) AND q.q <% search_fold(t.title)
+
+		UNION ALL
+		SELECT 'venue', c.venue, c.city, 0, COUNT(*), word_similarity(q.q, search_fold(c.venue))
+		FROM concerts c, q
+		WHERE q.q <% search_fold(c.venue) AND c.date >= NOW()
+		GROUP BY c.venue, c.city, q.q
+
+		UNION ALL
+		SELECT 'city', c.city, '', 0, COUNT(*), word_similarity(q.q, search_fold(c.city))
+		FROM concerts c, q
+		WHERE q.q <% search_fold(c.city) AND c.date >= NOW()
+		GROUP BY c.city, q.q
+	) hits
+	WHERE type = ANY($2)
+	ORDER BY similarity DESC
+	LIMIT 200
+`
+
+func (r *PostgresArtistRepository) Search(query string, types []string, limit int) ([]search.Hit, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting search: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Seuil de l'opérateur <%, le même que celui de l'index en mémoire
+	threshold := strconv.FormatFloat(search.Threshold, 'f', -1, 64)
+	if _, err := tx.Exec(`SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`, threshold); err != nil {
+		return nil, fmt.Errorf("error configuring search: %w", err)
+	}
+
+	rows, err := tx.Query(searchCandidates, query, types)
+	if err != nil {
+		return nil, fmt.Errorf("error searching catalog: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []search.Hit{}
+	for rows.Next() {
+		var doc search.Doc
+		var similarity float64
+		if err := rows.Scan(&doc.Type, &doc.Text, &doc.Subtitle, &doc.ArtistID, &doc.Concerts, &similarity); err != nil {
+			return nil, fmt.Errorf("error scanning search hit: %w", err)
+		}
+		hits = append(hits, search.NewHit(query, doc, similarity))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error searching catalog: %w", err)
+	}
+
+	return search.Rank(hits, limit), nil
+}
+
+// CatalogDocs charge tout le catalogue pour l'index d'autocomplétion
+func (r *PostgresArtistRepository) CatalogDocs() ([]search.Doc, error) {
+	rows, err := r.db.Query(`SELECT ` + artistColumns + ` FROM artists`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching artists: %w", err)
+	}
+	defer rows.Close()
+
+	artists := []models.Artist{}
+	for rows.Next() {
+		artist, err := scanArtist(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning artist: %w", err)
+		}
+		artists = append(artists, artist)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching artists: %w", err)
+	}
+
+	concerts, err := r.queryConcerts(`SELECT ` + concertColumns + ` FROM concerts ORDER BY date`)
+	if err != nil {
+		return nil, err
+	}
+
+	return catalogDocs(artists, concerts), nil
+}
+
+// NearbyConcerts retourne les concerts à venir à moins de radiusKm de center,
+// les plus proches d'abord. earth_distance travaille sur le rayon terrestre earth() :
+// le rayon demandé y est ramené pour retenir exactement les mêmes concerts
+// que la distance de Haversine renvoyée.
+func (r *PostgresArtistRepository) NearbyConcerts(center geo.Point, radiusKm float64, limit int) ([]models.NearbyConcert, error) {
+	concerts, err := r.queryConcerts(`
+		SELECT `+concertColumns+`
+		FROM concerts
+		WHERE latitude IS NOT NULL AND date >= NOW()
+		  AND earth_box(ll_to_earth($1, $2), $3::float8 / $4::float8 * earth()) @> ll_to_earth(latitude, longitude)
+		  AND earth_distance(ll_to_earth($1, $2), ll_to_earth(latitude, longitude)) <= $3::float8 / $4::float8 * earth()
+		ORDER BY earth_distance(ll_to_earth($1, $2), ll_to_earth(latitude, longitude)), id
+		LIMIT $5
+	`, center.Lat, center.Lng, radiusKm, geo.EarthRadiusKm, limit)
+	if err != nil {
+		return nil, err
+	}
+	return withDistances(concerts, center), nil
+}
+
+// ConcertsInBox retourne les concerts à venir situés dans box (vue de la
+// carte), les plus proches de from d'abord
+func (r *PostgresArtistRepository) ConcertsInBox(box geo.BBox, from geo.Point, limit int) ([]models.NearbyConcert, error) {
+	lngCondition := `longitude BETWEEN $2 AND $4`
+	if box.CrossesAntimeridian() {
+		lngCondition = `(longitude >= $2 OR longitude <= $4)`
+	}
+
+	concerts, err := r.queryConcerts(`
+		SELECT `+concertColumns+`
+		FROM concerts
+		WHERE latitude IS NOT NULL AND date >= NOW()
+		  AND latitude BETWEEN $1 AND $3 AND `+lngCondition+`
+		ORDER BY earth_distance(ll_to_earth($5, $6), ll_to_earth(latitude, longitude)), id
+		LIMIT $7
+	`, box.MinLat, box.MinLng, box.MaxLat, box.MaxLng, from.Lat, from.Lng, limit)
+	if err != nil {
+		return nil, err
+	}
+	return withDistances(concerts, from), nil
+}
+
+// withDistances ajoute à chaque concert sa distance à from, au mètre près
+func withDistances(concerts []models.Concert, from geo.Point) []models.NearbyConcert {
+	nearby := make([]models.NearbyConcert, 0, len(concerts))
+	for _, c := range concerts {
+		if c.Lat == nil || c.Lng == nil {
+			continue
+		}
+		d := geo.HaversineKm(from, geo.Point{Lat: *c.Lat, Lng: *c.Lng})
+		nearby = append(nearby, models.NearbyConcert{Concert: c, DistanceKm: math.Round(d*1000) / 1000})
+	}
+	return nearby
+}
+
+func (r *PostgresArtistRepository) queryConcerts(query string, args ...interface{}) ([]models.Concert, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching concerts: %w", err)
+	}
+	defer rows.Close()
+
+	concerts := []models.Concert{}
+	for rows.Next() {
+		concert, err := scanConcert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning concert: %w", err)
+		}
+		concerts = append(concerts, concert)
+	}
+
+	return concerts, rows.Err()
+}
+
+// upcomingDates regroupe les concerts à venir par artiste, au format attendu par le front
+func (r *PostgresArtistRepository) upcomingDates(where string, args ...interface{}) (map[int][]models.ConcertDate, error) {
+	concerts, err := r.queryConcerts(`SELECT `+concertColumns+` FROM concerts `+where+` ORDER BY date`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make(map[int][]models.ConcertDate)
+	for _, c := range concerts {
+		dates[c.ArtistID] = append(dates[c.ArtistID], models.ConcertDate{
+			ID:         strconv.Itoa(c.ID),
+			Venue:      c.Venue,
+			City:       c.City,
+			Date:       c.Date.Format("2006-01-02"),
+			TicketsURL: "/tickets",
+			Lat:        derefFloat(c.Lat),
+			Lng:        derefFloat(c.Lng),
+		})
+	}
+
+	return dates, nil
+}
+
+func derefFloat(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"groupie-backend/database/dbtest"
+	"groupie-backend/internal/geo"
+	"groupie-backend/internal/search"
+	"groupie-backend/models"
+)
+
+var paris = geo.Point{Lat: 48.8566, Lng: 2.3522}
+
+// geoTestConcerts : un concert passé au centre de Paris, deux à venir plus loin.
+func geoTestConcerts() []models.Concert {
+	located := func(name string, lat, lng float64, date time.Time) models.Concert {
+		return models.Concert{Name: name, ArtistName: name, Venue: name, City: "Paris", Date: date,
+			Currency: "eur", Lat: &lat, Lng: &lng}
+	}
+	now := time.Now()
+	return []models.Concert{
+		located("Past", paris.Lat, paris.Lng, now.Add(-24*time.Hour)),
+		located("Near", 48.8938, 2.3935, now.Add(10*24*time.Hour)), // La Villette, ~5 km
+		located("Far", 48.9244, 2.3601, now.Add(20*24*time.Hour)),  // Stade de France, ~7.5 km
+	}
+}
+
+// testGeoQueries vérifie qu'une implémentation d'ArtistRepository ne retourne
+// que les concerts à venir, limit appliquée après ce filtre.
+func testGeoQueries(t *testing.T, repo ArtistRepository) {
+	t.Helper()
+
+	names := func(concerts []models.NearbyConcert, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		list := []string{}
+		for _, c := range concerts {
+			list = append(list, c.Name)
+		}
+		return list
+	}
+	box := geo.BBox{MinLat: 48, MinLng: 2, MaxLat: 49, MaxLng: 3}
+
+	tests := []struct {
+		query string
+		got   []string
+		want  []string
+	}{
+		{"nearby", names(repo.NearbyConcerts(paris, 50, 10)), []string{"Near", "Far"}},
+		{"nearby limit 1", names(repo.NearbyConcerts(paris, 50, 1)), []string{"Near"}},
+		{"nearby 1 km", names(repo.NearbyConcerts(paris, 1, 10)), []string{}},
+		{"box", names(repo.ConcertsInBox(box, paris, 10)), []string{"Near", "Far"}},
+		{"box limit 1", names(repo.ConcertsInBox(box, paris, 1)), []string{"Near"}},
+	}
+	for _, tt := range tests {
+		if len(tt.got) != len(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.query, tt.got, tt.want)
+			continue
+		}
+		for i := range tt.got {
+			if tt.got[i] != tt.want[i] {
+				t.Errorf("%s = %v, want %v", tt.query, tt.got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestMemoryGeoQueriesSkipPastConcerts(t *testing.T) {
+	s := &ArtistService{concerts: geoTestConcerts()}
+	s.indexLocations()
+	testGeoQueries(t, s)
+}
+
+func TestPostgresGeoQueriesSkipPastConcerts(t *testing.T) {
+	db := dbtest.Open(t)
+	insertGeoTestConcerts(t, db)
+	testGeoQueries(t, NewPostgresArtistRepository(db))
+}
+
+// testSearchCountsUpcomingConcerts vérifie que salles et villes ne comptent
+// que leurs concerts à venir : une salle sans date à venir n'est plus proposée.
+func testSearchCountsUpcomingConcerts(t *testing.T, repo ArtistRepository) {
+	t.Helper()
+
+	hits, err := repo.Search("past", []string{search.TypeVenue}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 0 {
+		t.Errorf("venue search for a past concert = %+v, want no hit", hits)
+	}
+
+	hits, err = repo.Search("paris", []string{search.TypeCity}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Concerts != 2 {
+		t.Errorf("city search = %+v, want Paris with 2 concerts", hits)
+	}
+}
+
+func TestMemorySearchCountsUpcomingConcerts(t *testing.T) {
+	s := &ArtistService{concerts: geoTestConcerts()}
+	s.index = search.NewIndex(catalogDocs(nil, s.concerts))
+	testSearchCountsUpcomingConcerts(t, s)
+}
+
+func TestPostgresSearchCountsUpcomingConcerts(t *testing.T) {
+	db := dbtest.Open(t)
+	insertGeoTestConcerts(t, db)
+	testSearchCountsUpcomingConcerts(t, NewPostgresArtistRepository(db))
+}
+
+func insertGeoTestConcerts(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	for _, c := range geoTestConcerts() {
+		_, err := db.Exec(`
+			INSERT INTO concerts
+				(name, artist_name, venue, city, date, standard_price, vip_price,
+				 available_standard, available_vip, currency, latitude, longitude)
+			VALUES ($1, $2, $3, $4, $5, 5000, 12000, 10, 0, $6, $7, $8)
+		`, c.Name, c.ArtistName, c.Venue, c.City, c.Date, c.Currency, *c.Lat, *c.Lng)
+		if err != nil {
+			t.Fatalf("insert concert: %v", err)
+		}
+	}
+}
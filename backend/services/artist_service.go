@@ -1,57 +1,164 @@
 package services
 
 import (
-	"strings"
 	"time"
 
+	"groupie-backend/internal/geo"
+	"groupie-backend/internal/listquery"
+	"groupie-backend/internal/search"
 	"groupie-backend/models"
 )
 
+// ArtistService est l'implémentation en mémoire de ArtistRepository,
+// alimentée par le catalogue de démonstration (dev et tests)
 type ArtistService struct {
 	artists  []models.Artist
 	concerts []models.Concert
+	index    *search.Index
+	geo      *geo.Index
+	located  []models.Concert // concerts avec une position, dans l'ordre de l'index geo
 }
 
+var _ ArtistRepository = (*ArtistService)(nil)
+
 func NewArtistService() *ArtistService {
 	s := &ArtistService{}
 	s.initMockData()
+	s.locateConcerts()
+	s.index = search.NewIndex(catalogDocs(s.artists, s.concerts))
+	s.indexLocations()
 	return s
 }
 
-func (s *ArtistService) GetAll() []models.Artist {
-	return s.artists
+// indexLocations construit l'index geo des concerts qui ont une position
+func (s *ArtistService) indexLocations() {
+	var points []geo.Point
+	s.located = nil
+	for _, c := range s.concerts {
+		if c.Lat != nil && c.Lng != nil {
+			s.located = append(s.located, c)
+			points = append(points, geo.Point{Lat: *c.Lat, Lng: *c.Lng})
+		}
+	}
+	s.geo = geo.NewIndex(points)
+}
+
+func (s *ArtistService) ListArtists(q listquery.Query) (listquery.Page[models.Artist], error) {
+	return ArtistListSpec.Apply(q, s.artists), nil
 }
 
-func (s *ArtistService) GetByID(id int) (models.Artist, bool) {
+func (s *ArtistService) GetByID(id int) (models.Artist, error) {
 	for _, artist := range s.artists {
 		if artist.ID == id {
-			return artist, true
+			return artist, nil
 		}
 	}
-	return models.Artist{}, false
+	return models.Artist{}, ErrArtistNotFound
+}
+
+func (s *ArtistService) ListConcerts(q listquery.Query) (listquery.Page[models.Concert], error) {
+	return ConcertListSpec.Apply(q, s.concerts), nil
+}
+
+func (s *ArtistService) Search(query string, types []string, limit int) ([]search.Hit, error) {
+	return s.index.Search(query, types, limit), nil
+}
+
+func (s *ArtistService) CatalogDocs() ([]search.Doc, error) {
+	return catalogDocs(s.artists, s.concerts), nil
+}
+
+// NearbyConcerts et ConcertsInBox interrogent l'index geo sans limite : les
+// concerts passés, que l'index ne connaît pas, sont écartés avant d'appliquer limit
+func (s *ArtistService) NearbyConcerts(center geo.Point, radiusKm float64, limit int) ([]models.NearbyConcert, error) {
+	return s.matchedConcerts(s.geo.Nearby(center, radiusKm, len(s.located)), center, limit), nil
+}
+
+func (s *ArtistService) ConcertsInBox(box geo.BBox, from geo.Point, limit int) ([]models.NearbyConcert, error) {
+	return s.matchedConcerts(s.geo.Within(box, from, len(s.located)), from, limit), nil
 }
 
-func (s *ArtistService) GetAllConcerts() []models.Concert {
-	return s.concerts
+// matchedConcerts retourne les concerts à venir parmi matches, comme le filtre
+// date >= NOW() côté Postgres
+func (s *ArtistService) matchedConcerts(matches []geo.Match, from geo.Point, limit int) []models.NearbyConcert {
+	now := time.Now()
+	var concerts []models.Concert
+	for _, m := range matches {
+		if len(concerts) == limit {
+			break
+		}
+		if c := s.located[m.Item]; !c.Date.Before(now) {
+			concerts = append(concerts, c)
+		}
+	}
+	return withDistances(concerts, from)
 }
 
-func (s *ArtistService) SearchConcerts(query string) []models.Concert {
-	if query == "" {
-		return s.concerts
+// locateConcerts reprend la position des salles depuis les dates de tournée
+// des artistes, qui la portent dans le catalogue de démonstration
+func (s *ArtistService) locateConcerts() {
+	type venue struct{ name, city string }
+	positions := map[venue]models.ConcertDate{}
+	for _, a := range s.artists {
+		for _, d := range a.UpcomingDates {
+			if d.Lat != 0 || d.Lng != 0 {
+				positions[venue{d.Venue, d.City}] = d
+			}
+		}
 	}
 
-	var results []models.Concert
-	lowerQuery := strings.ToLower(query)
+	for i := range s.concerts {
+		if d, ok := positions[venue{s.concerts[i].Venue, s.concerts[i].City}]; ok {
+			lat, lng := d.Lat, d.Lng
+			s.concerts[i].Lat, s.concerts[i].Lng = &lat, &lng
+		}
+	}
+}
 
-	for _, concert := range s.concerts {
-		if strings.Contains(strings.ToLower(concert.Venue), lowerQuery) ||
-			strings.Contains(strings.ToLower(concert.City), lowerQuery) ||
-			strings.Contains(strings.ToLower(concert.ArtistName), lowerQuery) {
-			results = append(results, concert)
+// catalogDocs liste les entrées recherchables du catalogue, comme la requête
+// searchCandidates côté Postgres : salles et villes ne comptent que les concerts à venir
+func catalogDocs(artists []models.Artist, concerts []models.Concert) []search.Doc {
+	var docs []search.Doc
+	for _, a := range artists {
+		docs = append(docs, search.Doc{Type: search.TypeArtist, Text: a.Name, Subtitle: a.Genre, ArtistID: a.ID})
+		for _, m := range a.Members {
+			if search.Fold(m) != search.Fold(a.Name) {
+				docs = append(docs, search.Doc{Type: search.TypeMember, Text: m, Subtitle: a.Name, ArtistID: a.ID})
+			}
+		}
+		for _, t := range a.TopTracks {
+			docs = append(docs, search.Doc{Type: search.TypeTrack, Text: t.Title, Subtitle: a.Name, ArtistID: a.ID})
 		}
 	}
 
-	return results
+	type venue struct{ name, city string }
+	venues := map[venue]int{}
+	cities := map[string]int{}
+	var venueOrder []venue
+	var cityOrder []string
+	now := time.Now()
+	for _, c := range concerts {
+		if c.Date.Before(now) {
+			continue
+		}
+		v := venue{c.Venue, c.City}
+		if venues[v] == 0 {
+			venueOrder = append(venueOrder, v)
+		}
+		venues[v]++
+		if cities[c.City] == 0 {
+			cityOrder = append(cityOrder, c.City)
+		}
+		cities[c.City]++
+	}
+	for _, v := range venueOrder {
+		docs = append(docs, search.Doc{Type: search.TypeVenue, Text: v.name, Subtitle: v.city, Concerts: venues[v]})
+	}
+	for _, city := range cityOrder {
+		docs = append(docs, search.Doc{Type: search.TypeCity, Text: city, Concerts: cities[city]})
+	}
+
+	return docs
 }
 
 func (s *ArtistService) initMockData() {
@@ -622,8 +729,9 @@ func (s *ArtistService) initMockData() {
 			City:              "Paris",
 			Date:              time.Date(2026, 5, 12, 20, 0, 0, 0, time.UTC),
 			ImageURL:          "https://groupie-tracker-assets.s3.eu-north-1.amazonaws.com/artists/1/ninho.jpg.jpg",
-			StandardPrice:     79.99,
-			VIPPrice:          189.99,
+			StandardPrice:     7999,
+			VIPPrice:          18999,
+			Currency:          "eur",
 			AvailableStandard: 250,
 			AvailableVIP:      100,
 			CreatedAt:         time.Now(),
@@ -637,8 +745,9 @@ func (s *ArtistService) initMockData() {
 			City:              "Marseille",
 			Date:              time.Date(2026, 5, 18, 20, 0, 0, 0, time.UTC),
 			ImageURL:          "https://groupie-tracker-assets.s3.eu-north-1.amazonaws.com/artists/1/ninho.jpg.jpg",
-			StandardPrice:     79.99,
-			VIPPrice:          189.99,
+			StandardPrice:     7999,
+			VIPPrice:          18999,
+			Currency:          "eur",
 			AvailableStandard: 200,
 			AvailableVIP:      80,
 			CreatedAt:         time.Now(),
@@ -652,8 +761,9 @@ func (s *ArtistService) initMockData() {
 			City:              "Bruxelles",
 			Date:              time.Date(2026, 6, 10, 20, 0, 0, 0, time.UTC),
 			ImageURL:          "https://groupie-tracker-assets.s3.eu-north-1.amazonaws.com/artists/2/angele.jpg.webp",
-			StandardPrice:     69.99,
-			VIPPrice:          159.99,
+			StandardPrice:     6999,
+			VIPPrice:          15999,
+			Currency:          "eur",
 			AvailableStandard: 180,
 			AvailableVIP:      70,
 			CreatedAt:         time.Now(),
@@ -667,8 +777,9 @@ func (s *ArtistService) initMockData() {
 			City:              "Clisson",
 			Date:              time.Date(2026, 6, 20, 18, 0, 0, 0, time.UTC),
 			ImageURL:          "https://groupie-tracker-assets.s3.eu-north-1.amazonaws.com/artists/3/gojira.jpg",
-			StandardPrice:     89.99,
-			VIPPrice:          199.99,
+			StandardPrice:     8999,
+			VIPPrice:          19999,
+			Currency:          "eur",
 			AvailableStandard: 300,
 			AvailableVIP:      120,
 			CreatedAt:         time.Now(),
@@ -682,8 +793,9 @@ func (s *ArtistService) initMockData() {
 			City:              "Paris",
 			Date:              time.Date(2026, 11, 15, 20, 0, 0, 0, time.UTC),
 			ImageURL:          "https://groupie-tracker-assets.s3.eu-north-1.amazonaws.com/artists/4/orelsan.jpg",
-			StandardPrice:     75.00,
-			VIPPrice:          175.00,
+			StandardPrice:     7500,
+			VIPPrice:          17500,
+			Currency:          "eur",
 			AvailableStandard: 220,
 			AvailableVIP:      90,
 			CreatedAt:         time.Now(),
@@ -697,8 +809,9 @@ func (s *ArtistService) initMockData() {
 			City:              "Paris",
 			Date:              time.Date(2026, 6, 21, 21, 0, 0, 0, time.UTC),
 			ImageURL:          "https://groupie-tracker-assets.s3.eu-north-1.amazonaws.com/artists/6/pnl.jpg",
-			StandardPrice:     0.00,
-			VIPPrice:          0.00,
+			StandardPrice:     0,
+			VIPPrice:          0,
+			Currency:          "eur",
 			AvailableStandard: 0,
 			AvailableVIP:      0,
 			CreatedAt:         time.Now(),
@@ -712,8 +825,9 @@ func (s *ArtistService) initMockData() {
 			City:              "Los Angeles",
 			Date:              time.Date(2026, 9, 10, 20, 0, 0, 0, time.UTC),
 			ImageURL:          "https://groupie-tracker-assets.s3.eu-north-1.amazonaws.com/artists/12/theweeknd.jpg",
-			StandardPrice:     129.99,
-			VIPPrice:          299.99,
+			StandardPrice:     12999,
+			VIPPrice:          29999,
+			Currency:          "usd",
 			AvailableStandard: 350,
 			AvailableVIP:      150,
 			CreatedAt:         time.Now(),
@@ -727,8 +841,9 @@ func (s *ArtistService) initMockData() {
 			City:              "Barcelone",
 			Date:              time.Date(2026, 7, 10, 21, 0, 0, 0, time.UTC),
 			ImageURL:          "https://groupie-tracker-assets.s3.eu-north-1.amazonaws.com/artists/13/dualipa.jpg",
-			StandardPrice:     99.99,
-			VIPPrice:          229.99,
+			StandardPrice:     9999,
+			VIPPrice:          22999,
+			Currency:          "eur",
 			AvailableStandard: 180,
 			AvailableVIP:      80,
 			CreatedAt:         time.Now(),
@@ -4,15 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
-	"time"
 	"unicode"
 	"groupie-backend/database"
-	"groupie-backend/internal/auth"
 	"groupie-backend/models"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrWeakPassword enveloppe le refus d'un mot de passe qui ne respecte pas la politique
+var ErrWeakPassword = errors.New("mot de passe trop faible")
+
+// ErrInvalidCredentials : email inconnu ou mot de passe erroné (sans distinguer les deux)
+var ErrInvalidCredentials = errors.New("email ou mot de passe incorrect")
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
@@ -108,13 +112,10 @@ func RegisterUser(req models.RegisterRequest) (*models.User, error) {
 	}
 
 	// 4. Token de vérification email
-	token := fmt.Sprintf("%x", time.Now().UnixNano())
-	_, err = database.DB.Exec(
-		"INSERT INTO email_verification_tokens (user_id, token, expires_at) VALUES ($1, $2, $3)",
-		userID, token, time.Now().Add(24*time.Hour),
-	)
+	token, err := IssueOneTimeToken(userID, req.Email, TokenPurposeEmailVerification, EmailVerificationTTL)
 	if err != nil {
-		fmt.Println("❌ Erreur insertion token:", err)
+		fmt.Println("❌ Erreur création du jeton de vérification:", err)
+		return nil, errors.New("échec de l'envoi de l'email de vérification")
 	}
 
 	// 5. Envoi de l'email
@@ -134,45 +135,170 @@ func RegisterUser(req models.RegisterRequest) (*models.User, error) {
 	}, nil
 }
 
-func LoginUser(req models.LoginRequest) (*models.User, string, error) {
+// LoginUser vérifie les identifiants et ouvre une session (access token + refresh token).
+// Si la 2FA est active, aucune session n'est ouverte : un challenge est retourné à la place.
+func LoginUser(req models.LoginRequest, userAgent, ipAddress string) (*models.LoginResponse, *models.TwoFactorChallenge, error) {
 	var user models.User
 	err := database.DB.QueryRow(
-		`SELECT id, email, password_hash, first_name, last_name, role, email_verified, created_at 
+		`SELECT id, email, password_hash, first_name, last_name, role, email_verified, totp_enabled, created_at 
          FROM users WHERE email = $1`,
 		req.Email,
-	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Role, &user.EmailVerified, &user.CreatedAt)
+	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Role, &user.EmailVerified, &user.TwoFactor, &user.CreatedAt)
 
 	if err != nil {
-		return nil, "", errors.New("email ou mot de passe incorrect")
+		return nil, nil, ErrInvalidCredentials
 	}
 	
 	if !CheckPasswordHash(req.Password, user.PasswordHash) {
-		return nil, "", errors.New("email ou mot de passe incorrect")
+		return nil, nil, ErrInvalidCredentials
 	}
 
 	// ✅ Vérification email avant connexion
 	if !user.EmailVerified {
-		return nil, "", errors.New("veuillez vérifier votre email avant de vous connecter")
+		return nil, nil, errors.New("veuillez vérifier votre email avant de vous connecter")
+	}
+
+	// 2FA : le mot de passe ne suffit pas
+	if user.TwoFactor {
+		challenge, err := IssueLoginChallenge(user.ID, user.Email)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, challenge, nil
 	}
 
-	// Génération du token JWT
-	token, err := auth.GenerateToken(uint(user.ID), user.Role)
+	// Ouverture de la session et génération des tokens
+	tokens, err := CreateSession(user.ID, user.Role, userAgent, ipAddress)
 	if err != nil {
-		return nil, "", errors.New("authentication failed")
+		fmt.Printf("❌ Erreur création de session pour l'utilisateur #%d: %v\n", user.ID, err)
+		return nil, nil, errors.New("authentication failed")
 	}
 
-	return &user, token, nil
+	return &models.LoginResponse{
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
+	}, nil, nil
 }
 
 func GetUserByID(userID int) (*models.User, error) {
 	var user models.User
 	err := database.DB.QueryRow(
-		`SELECT id, email, first_name, last_name, role, email_verified, created_at FROM users WHERE id = $1`,
+		`SELECT id, email, first_name, last_name, role, email_verified, totp_enabled, created_at FROM users WHERE id = $1`,
 		userID,
-	).Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Role, &user.EmailVerified, &user.CreatedAt)
+	).Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Role, &user.EmailVerified, &user.TwoFactor, &user.CreatedAt)
 
 	if err != nil {
 		return nil, errors.New("utilisateur non trouvé")
 	}
 	return &user, nil
 }
+
+// RequestPasswordReset envoie un lien de réinitialisation si l'adresse correspond
+// à un compte. Ne retourne jamais d'erreur révélant l'existence du compte.
+func RequestPasswordReset(email string) error {
+	var userID int
+	err := database.DB.QueryRow("SELECT id FROM users WHERE email = $1", email).Scan(&userID)
+	if err != nil {
+		return nil
+	}
+
+	token, err := IssueOneTimeToken(userID, email, TokenPurposePasswordReset, PasswordResetTTL)
+	if errors.Is(err, ErrOneTimeTokenThrottled) {
+		fmt.Printf("⚠️  Demande de reset limitée pour l'utilisateur #%d\n", userID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return SendPasswordResetEmail(email, token)
+}
+
+// ResetPassword change le mot de passe avec un jeton de réinitialisation.
+// Un mot de passe refusé ne consomme pas le jeton, mais chaque essai est compté.
+func ResetPassword(token, newPassword string) error {
+	if _, err := VerifyOneTimeToken(token, TokenPurposePasswordReset); err != nil {
+		return err
+	}
+
+	if err := isStrongPassword(newPassword); err != nil {
+		return fmt.Errorf("%w : %s", ErrWeakPassword, err)
+	}
+
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return errors.New("error processing password")
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID, err := ConsumeOneTimeToken(tx, token, TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", hashedPassword, userID); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit password reset: %w", err)
+	}
+
+	// Un nouveau mot de passe déconnecte toutes les sessions ouvertes
+	if _, err := RevokeUserSessions(userID, "password_reset"); err != nil {
+		fmt.Printf("⚠️  Sessions non révoquées après reset pour l'utilisateur #%d: %v\n", userID, err)
+	}
+	return nil
+}
+
+// VerifyEmail valide l'adresse email du compte associé au jeton
+func VerifyEmail(token string) error {
+	if _, err := VerifyOneTimeToken(token, TokenPurposeEmailVerification); err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID, err := ConsumeOneTimeToken(tx, token, TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET email_verified = true WHERE id = $1", userID); err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ResendVerificationEmail renvoie un lien de vérification à un compte non vérifié.
+// Comme pour le reset, une adresse inconnue ne produit pas d'erreur.
+func ResendVerificationEmail(email string) error {
+	var userID int
+	err := database.DB.QueryRow("SELECT id FROM users WHERE email = $1 AND email_verified = false", email).Scan(&userID)
+	if err != nil {
+		return nil
+	}
+
+	token, err := IssueOneTimeToken(userID, email, TokenPurposeEmailVerification, EmailVerificationTTL)
+	if errors.Is(err, ErrOneTimeTokenThrottled) {
+		fmt.Printf("⚠️  Renvoi de vérification limité pour l'utilisateur #%d\n", userID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return SendVerificationEmail(email, token)
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/models"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/checkout/session"
+)
+
+// ========= STRIPE CHECKOUT (page de paiement hébergée) =========
+//
+// Alternative à CreatePaymentIntent pour les clients qui n'embarquent pas
+// Stripe Elements (app Android) : une réservation 'pending' est créée par
+// tarif, et la session Checkout porte une ligne par tarif.
+
+// CheckoutSessionExpiryMinutes est le minimum imposé par Stripe pour expires_at
+const CheckoutSessionExpiryMinutes = 30
+
+// checkoutURLs retourne les URLs de retour Checkout, configurables par
+// CHECKOUT_SUCCESS_URL / CHECKOUT_CANCEL_URL, sinon dérivées de FRONTEND_URL
+func checkoutURLs() (successURL, cancelURL string) {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+	frontendURL = strings.TrimRight(frontendURL, "/")
+
+	successURL = os.Getenv("CHECKOUT_SUCCESS_URL")
+	if successURL == "" {
+		successURL = frontendURL + "/checkout/success?session_id={CHECKOUT_SESSION_ID}"
+	}
+	cancelURL = os.Getenv("CHECKOUT_CANCEL_URL")
+	if cancelURL == "" {
+		cancelURL = frontendURL + "/checkout/cancel"
+	}
+	return successURL, cancelURL
+}
+
+// normalizeCheckoutItems accepte soit une liste d'items, soit le couple ticket_type/quantity
+func normalizeCheckoutItems(req models.CreateCheckoutSessionRequest) ([]models.CheckoutItem, error) {
+	items := req.Items
+	if len(items) == 0 && req.TicketType != "" {
+		items = []models.CheckoutItem{{TicketType: req.TicketType, Quantity: req.Quantity}}
+	}
+	if len(items) == 0 {
+		return nil, errors.New("at least one item is required")
+	}
+
+	// Regrouper par tarif : une réservation et une ligne Stripe par tarif
+	quantities := make(map[string]int)
+	var order []string
+	for _, item := range items {
+		if item.TicketType != models.TicketTypeStandard && item.TicketType != models.TicketTypeVIP {
+			return nil, errors.New("ticket_type must be 'standard' or 'vip'")
+		}
+		if item.Quantity <= 0 {
+			return nil, errors.New("quantity must be greater than 0")
+		}
+		if _, seen := quantities[item.TicketType]; !seen {
+			order = append(order, item.TicketType)
+		}
+		quantities[item.TicketType] += item.Quantity
+	}
+
+	normalized := make([]models.CheckoutItem, 0, len(order))
+	for _, ticketType := range order {
+		normalized = append(normalized, models.CheckoutItem{TicketType: ticketType, Quantity: quantities[ticketType]})
+	}
+	return normalized, nil
+}
+
+// CreateCheckoutSession bloque les places et crée une session Stripe Checkout hébergée
+func CreateCheckoutSession(userID int, req models.CreateCheckoutSessionRequest) (*models.CreateCheckoutSessionResponse, error) {
+	items, err := normalizeCheckoutItems(req)
+	if err != nil {
+		return nil, err
+	}
+
+	concert, err := GetConcertByID(req.ConcertID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := CleanupExpiredReservations(); err != nil {
+		log.Printf("⚠️  Warning: %v", err)
+	}
+
+	// 1. Créer une réservation 'pending' par tarif, dans une seule transaction
+	expiresAt := time.Now().Add(CheckoutSessionExpiryMinutes * time.Minute)
+	reservationIDs, err := createCheckoutReservations(userID, concert, items, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	var lineItems []*stripe.CheckoutSessionLineItemParams
+	var totalPrice int64
+	for i, item := range items {
+		unitPrice := concert.PriceFor(item.TicketType)
+		ids = append(ids, strconv.Itoa(reservationIDs[i]))
+		totalPrice += unitPrice * int64(item.Quantity)
+
+		lineItems = append(lineItems, &stripe.CheckoutSessionLineItemParams{
+			Quantity: stripe.Int64(int64(item.Quantity)),
+			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+				Currency:   stripe.String(concert.Currency),
+				UnitAmount: stripe.Int64(unitPrice),
+				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+					Name: stripe.String(fmt.Sprintf("%s - %s", concert.Name, strings.ToUpper(item.TicketType))),
+				},
+			},
+		})
+	}
+
+	// 2. Créer la session Checkout une fois les places bloquées et la
+	// transaction validée : l'appel à Stripe ne retient pas le verrou du concert
+	successURL, cancelURL := checkoutURLs()
+	metadata := map[string]string{
+		"reservation_ids": strings.Join(ids, ","),
+		"user_id":         strconv.Itoa(userID),
+		"concert_id":      strconv.Itoa(concert.ID),
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		Mode:              stripe.String(string(stripe.CheckoutSessionModePayment)),
+		LineItems:         lineItems,
+		SuccessURL:        stripe.String(successURL),
+		CancelURL:         stripe.String(cancelURL),
+		ExpiresAt:         stripe.Int64(expiresAt.Unix()),
+		ClientReferenceID: stripe.String(strconv.Itoa(userID)),
+		Metadata:          metadata,
+		PaymentIntentData: &stripe.CheckoutSessionPaymentIntentDataParams{
+			Metadata: metadata,
+		},
+	}
+
+	sess, err := session.New(params)
+	if err != nil {
+		// Annuler les réservations (et libérer les places) si Stripe échoue
+		for _, reservationID := range reservationIDs {
+			if cancelErr := cancelPendingReservation(reservationID, "cancelled"); cancelErr != nil {
+				log.Printf("⚠️  Warning: Failed to release reservation #%d: %v", reservationID, cancelErr)
+			}
+		}
+		return nil, fmt.Errorf("error creating stripe checkout session: %w", err)
+	}
+
+	// 3. Rattacher les réservations à la session (les webhooks s'appuient sur les metadata)
+	_, err = database.DB.Exec(`
+		UPDATE reservations
+		SET stripe_checkout_session_id = $1, updated_at = NOW()
+		WHERE id = ANY(string_to_array($2, ',')::int[])
+	`, sess.ID, metadata["reservation_ids"])
+	if err != nil {
+		log.Printf("⚠️  Warning: Failed to link reservations to checkout session %s: %v", sess.ID, err)
+	}
+
+	log.Printf("✅ Checkout Session créée : %s pour %s (Reservations #%s)",
+		sess.ID, models.FormatAmount(totalPrice, concert.Currency), metadata["reservation_ids"])
+
+	return &models.CreateCheckoutSessionResponse{
+		SessionID:      sess.ID,
+		URL:            sess.URL,
+		Amount:         totalPrice,
+		Currency:       concert.Currency,
+		ReservationIDs: reservationIDs,
+		ExpiresAt:      expiresAt,
+	}, nil
+}
+
+// createCheckoutReservations bloque les places de chaque tarif et crée les
+// réservations 'pending' correspondantes : tout ou rien
+func createCheckoutReservations(userID int, concert *models.Concert, items []models.CheckoutItem, expiresAt time.Time) ([]int, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	reservationIDs := make([]int, 0, len(items))
+	for _, item := range items {
+		reservationID, err := insertPendingReservation(tx, &pendingReservation{
+			UserID:     userID,
+			ConcertID:  concert.ID,
+			TicketType: item.TicketType,
+			Quantity:   item.Quantity,
+			TotalPrice: concert.PriceFor(item.TicketType) * int64(item.Quantity),
+			Currency:   concert.Currency,
+			ExpiresAt:  expiresAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		reservationIDs = append(reservationIDs, reservationID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return reservationIDs, nil
+}
+
+// ========= WEBHOOKS CHECKOUT =========
+
+// checkoutReservationIDs lit les réservations liées à une session (metadata)
+func checkoutReservationIDs(sess *stripe.CheckoutSession) []string {
+	raw := sess.Metadata["reservation_ids"]
+	if raw == "" {
+		return nil
+	}
+	return strings.Split(raw, ",")
+}
+
+// CompleteCheckoutSession règle les réservations d'une session payée
+// (checkout.session.completed ou checkout.session.async_payment_succeeded)
+func CompleteCheckoutSession(sess *stripe.CheckoutSession) error {
+	if sess.PaymentStatus != stripe.CheckoutSessionPaymentStatusPaid {
+		// Moyen de paiement différé : on attend async_payment_succeeded
+		log.Printf("⏳ Checkout Session %s completed but payment is %s", sess.ID, sess.PaymentStatus)
+		return nil
+	}
+
+	paymentIntentID := ""
+	if sess.PaymentIntent != nil {
+		paymentIntentID = sess.PaymentIntent.ID
+	}
+
+	for _, reservationID := range checkoutReservationIDs(sess) {
+		// Le Payment Intent est nécessaire pour les remboursements
+		if paymentIntentID != "" {
+			_, err := database.DB.Exec(`
+				UPDATE reservations
+				SET stripe_payment_intent_id = $1, updated_at = NOW()
+				WHERE id = $2
+			`, paymentIntentID, reservationID)
+			if err != nil {
+				return fmt.Errorf("failed to link payment intent to reservation %s: %w", reservationID, err)
+			}
+		}
+
+		if err := MarkReservationAsPaid(reservationID, paymentIntentID); err != nil {
+			return fmt.Errorf("failed to mark reservation %s as paid: %w", reservationID, err)
+		}
+	}
+
+	return nil
+}
+
+// ReleaseCheckoutSession libère les places d'une session expirée ou dont le paiement a échoué
+func ReleaseCheckoutSession(sess *stripe.CheckoutSession, reason string) error {
+	for _, reservationID := range checkoutReservationIDs(sess) {
+		if err := MarkReservationAsFailed(reservationID, reason); err != nil {
+			return fmt.Errorf("failed to release reservation %s: %w", reservationID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+
+	"groupie-backend/database/dbtest"
+	"groupie-backend/models"
+)
+
+func TestCheckoutSessionDoesNotLockConcertDuringStripeCall(t *testing.T) {
+	db := dbtest.Open(t)
+	stub := useStripeStub(t)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 10, 5)
+
+	stub.handle("POST /v1/checkout/sessions", func(params stripe.ParamsContainer, v stripe.LastResponseSetter) error {
+		// Un autre checkout doit pouvoir prendre le verrou du concert pendant l'appel
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if _, err := db.ExecContext(ctx, `UPDATE concerts SET available_vip = available_vip WHERE id = $1`, concertID); err != nil {
+			t.Errorf("concert row still locked during the Stripe call: %v", err)
+		}
+
+		*v.(*stripe.CheckoutSession) = stripe.CheckoutSession{ID: "cs_test_1", URL: "https://checkout.stripe.test/cs_test_1"}
+		return nil
+	})
+
+	resp, err := CreateCheckoutSession(userID, models.CreateCheckoutSessionRequest{
+		ConcertID: concertID,
+		Items: []models.CheckoutItem{
+			{TicketType: models.TicketTypeStandard, Quantity: 2},
+			{TicketType: models.TicketTypeVIP, Quantity: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateCheckoutSession: %v", err)
+	}
+	if resp.SessionID != "cs_test_1" || len(resp.ReservationIDs) != 2 || resp.Amount != 2*5000+12000 {
+		t.Errorf("response = %+v", resp)
+	}
+
+	assertStock(t, db, concertID, 8, 2)
+	for _, reservationID := range resp.ReservationIDs {
+		var sessionID sql.NullString
+		err := db.QueryRow(`SELECT stripe_checkout_session_id FROM reservations WHERE id = $1`, reservationID).Scan(&sessionID)
+		if err != nil {
+			t.Fatalf("read reservation: %v", err)
+		}
+		if sessionID.String != "cs_test_1" {
+			t.Errorf("reservation #%d linked to %q, want cs_test_1", reservationID, sessionID.String)
+		}
+	}
+}
+
+func TestCheckoutSessionStripeFailureReleasesHolds(t *testing.T) {
+	db := dbtest.Open(t)
+	stub := useStripeStub(t)
+	stub.handle("POST /v1/checkout/sessions", func(stripe.ParamsContainer, stripe.LastResponseSetter) error {
+		return &stripe.Error{Type: stripe.ErrorTypeAPI, Msg: "stripe unavailable"}
+	})
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 10, 5)
+
+	_, err := CreateCheckoutSession(userID, models.CreateCheckoutSessionRequest{
+		ConcertID: concertID, TicketType: models.TicketTypeStandard, Quantity: 3,
+	})
+	var stripeErr *stripe.Error
+	if !errors.As(err, &stripeErr) {
+		t.Fatalf("CreateCheckoutSession = %v, want the Stripe error", err)
+	}
+
+	assertStock(t, db, concertID, 10, 0)
+	assertPendingQuantity(t, db, concertID, 0)
+}
@@ -1,9 +1,17 @@
 package services
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/smtp"
+	"net/textproto"
 	"os"
+	"strings"
+	"time"
 )
 func SendPasswordResetEmail(toEmail string, token string) error {
     resetLink := fmt.Sprintf("http://localhost:5173/reset-password?token=%s", token)
@@ -23,7 +31,16 @@ func SendPasswordResetEmail(toEmail string, token string) error {
     return SendHTMLEmail(toEmail, subject, htmlBody)
 }
 
-func SendHTMLEmail(to, subject, htmlBody string) error {
+// EmailAttachment est une pièce jointe (PDF de billets, etc.)
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// SendHTMLEmail envoie un email HTML ; avec des pièces jointes, le message
+// devient un multipart/mixed (partie HTML + une partie base64 par fichier)
+func SendHTMLEmail(to, subject, htmlBody string, attachments ...EmailAttachment) error {
 	from := os.Getenv("SMTP_FROM")
 	password := os.Getenv("SMTP_PASSWORD")
 	smtpHost := os.Getenv("SMTP_HOST")
@@ -33,30 +50,80 @@ func SendHTMLEmail(to, subject, htmlBody string) error {
 		return fmt.Errorf("SMTP configuration missing")
 	}
 
-	headers := map[string]string{
-		"From":         from,
-		"To":           to,
-		"Subject":      subject,
-		"MIME-Version": "1.0",
-		"Content-Type": "text/html; charset=UTF-8",
-	}
-
-	message := ""
-	for k, v := range headers {
-		message += fmt.Sprintf("%s: %s\r\n", k, v)
+	message, err := buildMIMEMessage(from, to, subject, htmlBody, attachments)
+	if err != nil {
+		return err
 	}
-	message += "\r\n" + htmlBody
 
 	auth := smtp.PlainAuth("", from, password, smtpHost)
 	addr := fmt.Sprintf("%s:%s", smtpHost, smtpPort)
 
-	err := smtp.SendMail(addr, auth, from, []string{to}, []byte(message))
+	err = smtp.SendMail(addr, auth, from, []string{to}, message)
 	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
 	return nil
 }
+
+// buildMIMEMessage construit le message brut (en-têtes + corps) envoyé en SMTP
+func buildMIMEMessage(from, to, subject, htmlBody string, attachments []EmailAttachment) ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if len(attachments) == 0 {
+		buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
+		buf.WriteString(htmlBody)
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mw.Boundary())
+
+	htmlPart, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+	qp := quotedprintable.NewWriter(htmlPart)
+	qp.Write([]byte(htmlBody))
+	qp.Close()
+
+	for _, attachment := range attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": attachment.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build email: %w", err)
+		}
+
+		// RFC 2045 : lignes base64 de 76 caractères maximum
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+	return buf.Bytes(), nil
+}
 func SendVerificationEmail(toEmail string, token string) error {
 
     verificationLink := fmt.Sprintf("http://localhost:8080/api/auth/verify-email?token=%s", token)
@@ -79,4 +146,27 @@ func SendVerificationEmail(toEmail string, token string) error {
 
 
     return SendHTMLEmail(toEmail, subject, htmlBody)
-}
\ No newline at end of file
+}
+// SendAccountLockedEmail prévient le titulaire que son compte est verrouillé
+// après trop d'échecs de connexion (et l'invite à changer de mot de passe)
+func SendAccountLockedEmail(toEmail string, duration time.Duration) error {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+	resetLink := strings.TrimRight(frontendURL, "/") + "/forgot-password"
+
+	subject := "🔒 Connexion bloquée sur ton compte YNOT"
+	htmlBody := fmt.Sprintf(`
+		<div style="font-family: Arial, sans-serif;">
+			<h2>Trop de tentatives de connexion</h2>
+			<p>Plusieurs mots de passe erronés ont été saisis pour ton compte. Par sécurité, la connexion est bloquée pendant %d minutes.</p>
+			<p>Si ce n'était pas toi, quelqu'un essaie peut-être de deviner ton mot de passe : change-le dès maintenant.</p>
+			<a href="%s" style="background-color: #007bff; color: white; padding: 10px 20px; text-decoration: none; border-radius: 5px;">
+				Changer mon mot de passe
+			</a>
+		</div>
+	`, int(duration.Minutes()), resetLink)
+
+	return SendHTMLEmail(toEmail, subject, htmlBody)
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/internal/oauth"
+	"groupie-backend/models"
+)
+
+// ========= IDENTITÉS EXTERNES (GOOGLE, GITHUB, OIDC) =========
+//
+// Un compte est retrouvé par (fournisseur, sujet). L'email ne sert qu'à la
+// première connexion : créer le compte, ou le rattacher à un compte existant
+// quand le fournisseur est de confiance et a vérifié l'adresse.
+
+// identityLinkTTL borne l'aller-retour chez le fournisseur lors d'un rattachement
+const identityLinkTTL = 10 * time.Minute
+
+var (
+	ErrIdentityEmailRequired = errors.New("le fournisseur n'a pas communiqué d'adresse email vérifiée")
+	ErrIdentityAccountExists = errors.New("un compte existe déjà avec cette adresse email")
+	ErrIdentityInUse         = errors.New("cette identité est déjà liée à un autre compte")
+	ErrIdentityAlreadyLinked = errors.New("un compte de ce fournisseur est déjà lié")
+	ErrIdentityNotFound      = errors.New("identité introuvable")
+	ErrIdentityLinkInvalid   = errors.New("demande de rattachement invalide ou expirée")
+	ErrLastLoginMethod       = errors.New("impossible de retirer le dernier moyen de connexion du compte")
+)
+
+// LoginWithIdentity retourne le compte lié à l'identité externe, en le créant
+// ou en le rattachant par email à la première connexion
+func LoginWithIdentity(identity *oauth.Identity, trustEmail bool) (*models.User, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 1. Identité déjà connue : l'email du fournisseur n'a plus d'importance
+	var userID int
+	err = tx.QueryRow(`
+		UPDATE user_identities SET last_login_at = NOW(), email = COALESCE($3, email)
+		WHERE provider = $1 AND subject = $2
+		RETURNING user_id
+	`, identity.Provider, identity.Subject, nullIfEmpty(identity.Email)).Scan(&userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error looking up identity: %w", err)
+	}
+
+	if err == sql.ErrNoRows {
+		// 2. Première connexion : il faut une adresse vérifiée
+		if identity.Email == "" || !identity.EmailVerified {
+			return nil, ErrIdentityEmailRequired
+		}
+		email := sanitizeInput(strings.TrimSpace(identity.Email), 255)
+
+		var emailVerified bool
+		err = tx.QueryRow(
+			"SELECT id, email_verified FROM users WHERE LOWER(email) = LOWER($1) FOR UPDATE",
+			email,
+		).Scan(&userID, &emailVerified)
+
+		switch {
+		case err == sql.ErrNoRows:
+			err = tx.QueryRow(`
+				INSERT INTO users (first_name, last_name, email, password_hash, role, email_verified)
+				VALUES ($1, $2, $3, '', 'user', true)
+				RETURNING id
+			`, sanitizeInput(identity.FirstName, 100), sanitizeInput(identity.LastName, 100), email).Scan(&userID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create user: %w", err)
+			}
+			fmt.Printf("🆕 Compte créé via %s pour l'utilisateur #%d\n", identity.Provider, userID)
+
+		case err != nil:
+			return nil, fmt.Errorf("error looking up user: %w", err)
+
+		case !trustEmail:
+			return nil, ErrIdentityAccountExists
+
+		default:
+			// Compte local jamais vérifié : son mot de passe a pu être choisi par
+			// quelqu'un d'autre que le propriétaire de l'adresse, on l'efface
+			if !emailVerified {
+				if _, err := tx.Exec(
+					"UPDATE users SET email_verified = true, password_hash = '' WHERE id = $1", userID,
+				); err != nil {
+					return nil, fmt.Errorf("failed to verify user: %w", err)
+				}
+			}
+			fmt.Printf("🔗 Identité %s rattachée à l'utilisateur #%d\n", identity.Provider, userID)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+			VALUES ($1, $2, $3, $4, NOW())
+		`, userID, identity.Provider, identity.Subject, email)
+		if err != nil {
+			// Déjà une autre identité de ce fournisseur sur le compte, ou course
+			// entre deux premières connexions
+			return nil, ErrIdentityAccountExists
+		}
+	}
+
+	var user models.User
+	err = tx.QueryRow(
+		`SELECT id, email, first_name, last_name, role, email_verified, totp_enabled, created_at FROM users WHERE id = $1`,
+		userID,
+	).Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Role, &user.EmailVerified, &user.TwoFactor, &user.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error loading user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit identity login: %w", err)
+	}
+	return &user, nil
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// ListLoginMethods retourne le mot de passe (présent ou non) et les identités liées
+func ListLoginMethods(userID int) (*models.LoginMethods, error) {
+	methods := &models.LoginMethods{Identities: []models.UserIdentity{}}
+	err := database.DB.QueryRow(
+		"SELECT COALESCE(password_hash, '') <> '' FROM users WHERE id = $1", userID,
+	).Scan(&methods.HasPassword)
+	if err != nil {
+		return nil, fmt.Errorf("error loading user: %w", err)
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, provider, COALESCE(email, ''), created_at, last_login_at
+		FROM user_identities WHERE user_id = $1 ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing identities: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var identity models.UserIdentity
+		if err := rows.Scan(&identity.ID, &identity.Provider, &identity.Email, &identity.CreatedAt, &identity.LastLoginAt); err != nil {
+			return nil, fmt.Errorf("error scanning identity: %w", err)
+		}
+		methods.Identities = append(methods.Identities, identity)
+	}
+	return methods, rows.Err()
+}
+
+// UnlinkIdentity retire une identité du compte, sauf si c'est le dernier moyen
+// de connexion (ni mot de passe, ni autre identité). Retourne le fournisseur retiré.
+func UnlinkIdentity(userID, identityID int) (string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Verrou sur le compte : deux retraits simultanés ne laissent pas un compte sans accès
+	var hasPassword bool
+	err = tx.QueryRow(
+		"SELECT COALESCE(password_hash, '') <> '' FROM users WHERE id = $1 FOR UPDATE", userID,
+	).Scan(&hasPassword)
+	if err != nil {
+		return "", fmt.Errorf("error loading user: %w", err)
+	}
+
+	var provider string
+	var total int
+	err = tx.QueryRow(`
+		SELECT provider, (SELECT COUNT(*) FROM user_identities WHERE user_id = $1)
+		FROM user_identities WHERE id = $2 AND user_id = $1
+	`, userID, identityID).Scan(&provider, &total)
+	if err == sql.ErrNoRows {
+		return "", ErrIdentityNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("error loading identity: %w", err)
+	}
+
+	if !hasPassword && total <= 1 {
+		return "", ErrLastLoginMethod
+	}
+
+	if _, err := tx.Exec("DELETE FROM user_identities WHERE id = $1", identityID); err != nil {
+		return "", fmt.Errorf("failed to unlink identity: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit unlink: %w", err)
+	}
+	return provider, nil
+}
+
+// StartIdentityLink ouvre une demande de rattachement pour l'utilisateur
+// connecté. Le jeton retourné accompagne la redirection vers le fournisseur.
+func StartIdentityLink(userID int, provider string) (*models.IdentityLinkStart, error) {
+	var exists bool
+	err := database.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM user_identities WHERE user_id = $1 AND provider = $2)",
+		userID, provider,
+	).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("error checking identities: %w", err)
+	}
+	if exists {
+		return nil, ErrIdentityAlreadyLinked
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	_, err = database.DB.Exec(`
+		INSERT INTO identity_link_requests (user_id, provider, start_token_hash, expires_at)
+		VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second')
+	`, userID, provider, hashToken(token), int(identityLinkTTL.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store link request: %w", err)
+	}
+
+	return &models.IdentityLinkStart{LinkToken: token, ExpiresIn: int(identityLinkTTL.Seconds())}, nil
+}
+
+// AttachIdentityToLink enregistre l'identité obtenue au retour du fournisseur
+// et retourne le jeton de confirmation, remis au seul navigateur qui a fait
+// l'aller-retour. Le jeton de démarrage ne sert qu'une fois.
+func AttachIdentityToLink(startToken string, identity *oauth.Identity) (string, error) {
+	confirmToken, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE identity_link_requests
+		SET subject = $3, email = $4, confirm_token_hash = $5
+		WHERE start_token_hash = $1 AND provider = $2
+		AND subject IS NULL AND expires_at > NOW()
+	`, hashToken(startToken), identity.Provider, identity.Subject, nullIfEmpty(identity.Email), hashToken(confirmToken))
+	if err != nil {
+		return "", fmt.Errorf("failed to attach identity: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return "", ErrIdentityLinkInvalid
+	}
+	return confirmToken, nil
+}
+
+// ConfirmIdentityLink lie l'identité au compte. La confirmation exige à la fois
+// le jeton de confirmation (ce navigateur a fait l'aller-retour) et la session
+// de l'utilisateur qui a ouvert la demande : un lien de rattachement piégé ne
+// peut pas lier l'identité d'une victime au compte d'un tiers.
+func ConfirmIdentityLink(userID int, confirmToken string) (string, error) {
+	if confirmToken == "" {
+		return "", ErrIdentityLinkInvalid
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var provider, subject string
+	var email sql.NullString
+	err = tx.QueryRow(`
+		UPDATE identity_link_requests SET completed_at = NOW()
+		WHERE confirm_token_hash = $1 AND user_id = $2
+		AND completed_at IS NULL AND expires_at > NOW()
+		RETURNING provider, subject, email
+	`, hashToken(confirmToken), userID).Scan(&provider, &subject, &email)
+	if err == sql.ErrNoRows {
+		return "", ErrIdentityLinkInvalid
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to complete link request: %w", err)
+	}
+
+	var ownerID int
+	err = tx.QueryRow(
+		"SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2", provider, subject,
+	).Scan(&ownerID)
+	switch {
+	case err == nil && ownerID != userID:
+		return "", ErrIdentityInUse
+	case err == nil:
+		return "", ErrIdentityAlreadyLinked
+	case err != sql.ErrNoRows:
+		return "", fmt.Errorf("error checking identity: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+	`, userID, provider, subject, email)
+	if err != nil {
+		// UNIQUE (user_id, provider) : un autre compte du même fournisseur est déjà lié
+		return "", ErrIdentityAlreadyLinked
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit link: %w", err)
+	}
+	return provider, nil
+}
+
+// CleanupIdentityLinkRequests supprime les demandes de rattachement expirées
+func CleanupIdentityLinkRequests() (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM identity_link_requests WHERE expires_at < NOW() - INTERVAL '1 day'`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup link requests: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ========= RÉSERVATION DE PLACES (HOLDS) =========
+//
+// Une réservation 'pending' immobilise ses places : elles passent de
+// available_<tarif> à held_<tarif> le temps du checkout (15 minutes).
+// Le paiement convertit la réservation en vente (held → vendu), l'expiration
+// ou l'échec la libère (held → available).
+
+var ErrNotEnoughTickets = errors.New("not enough tickets available")
+
+// heldColumn retourne la colonne des places bloquées du tarif
+func heldColumn(ticketType string) (string, error) {
+	column, err := stockColumn(ticketType)
+	if err != nil {
+		return "", err
+	}
+	if column == "available_vip" {
+		return "held_vip", nil
+	}
+	return "held_standard", nil
+}
+
+// holdSeats déplace atomiquement `quantity` places de available vers held.
+// L'UPDATE conditionnel garantit qu'on ne bloque jamais plus que le stock,
+// même avec des checkouts concurrents.
+func holdSeats(tx *sql.Tx, concertID int, ticketType string, quantity int) error {
+	available, err := stockColumn(ticketType)
+	if err != nil {
+		return err
+	}
+	held, _ := heldColumn(ticketType)
+
+	result, err := tx.Exec(`
+		UPDATE concerts
+		SET `+available+` = `+available+` - $1,
+		    `+held+` = `+held+` + $1
+		WHERE id = $2
+		AND `+available+` >= $1
+	`, quantity, concertID)
+	if err != nil {
+		return fmt.Errorf("failed to hold seats: %w", err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrNotEnoughTickets
+	}
+	return nil
+}
+
+// releaseSeats rend au stock des places bloquées par une réservation abandonnée
+func releaseSeats(tx *sql.Tx, concertID int, ticketType string, quantity int) error {
+	available, err := stockColumn(ticketType)
+	if err != nil {
+		return err
+	}
+	held, _ := heldColumn(ticketType)
+
+	_, err = tx.Exec(`
+		UPDATE concerts
+		SET `+available+` = `+available+` + $1,
+		    `+held+` = GREATEST(`+held+` - $1, 0)
+		WHERE id = $2
+	`, quantity, concertID)
+	if err != nil {
+		return fmt.Errorf("failed to release seats: %w", err)
+	}
+	return nil
+}
+
+// sellHeldSeats convertit des places bloquées en places vendues
+func sellHeldSeats(tx *sql.Tx, concertID int, ticketType string, quantity int) error {
+	held, err := heldColumn(ticketType)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE concerts
+		SET `+held+` = `+held+` - $1
+		WHERE id = $2
+		AND `+held+` >= $1
+	`, quantity, concertID)
+	if err != nil {
+		return fmt.Errorf("failed to convert hold: %w", err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return errors.New("no matching seat hold for reservation")
+	}
+	return nil
+}
+
+// sellAvailableSeats vend directement depuis le stock, pour un paiement arrivé
+// après l'expiration de la réservation (les places ont déjà été libérées)
+func sellAvailableSeats(tx *sql.Tx, concertID int, ticketType string, quantity int) error {
+	available, err := stockColumn(ticketType)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE concerts
+		SET `+available+` = `+available+` - $1
+		WHERE id = $2
+		AND `+available+` >= $1
+	`, quantity, concertID)
+	if err != nil {
+		return fmt.Errorf("failed to update concert tickets: %w", err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrNotEnoughTickets
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"groupie-backend/database/dbtest"
+)
+
+// checkoutAttempts dépasse volontairement le pool de connexions : les
+// checkouts s'empilent sur le verrou de la ligne du concert.
+const checkoutAttempts = 300
+
+func TestConcurrentCheckoutsNeverOversell(t *testing.T) {
+	db := dbtest.Open(t)
+	db.SetMaxOpenConns(20)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 10, 0)
+
+	results := checkoutConcurrently(t, userID, concertID, "standard", func(int) int { return 1 })
+
+	if results.succeeded != 10 {
+		t.Errorf("succeeded checkouts = %d, want 10", results.succeeded)
+	}
+	if results.soldOut != checkoutAttempts-10 {
+		t.Errorf("sold-out checkouts = %d, want %d", results.soldOut, checkoutAttempts-10)
+	}
+	assertStock(t, db, concertID, 0, 10)
+	assertPendingQuantity(t, db, concertID, 10)
+}
+
+func TestConcurrentCheckoutsWithMixedQuantities(t *testing.T) {
+	db := dbtest.Open(t)
+	db.SetMaxOpenConns(20)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 10, 0)
+
+	results := checkoutConcurrently(t, userID, concertID, "standard", func(i int) int { return 1 + i%4 })
+
+	if results.seats > 10 {
+		t.Fatalf("oversold: %d seats held for a 10-seat concert", results.seats)
+	}
+	assertStock(t, db, concertID, 10-results.seats, results.seats)
+	assertPendingQuantity(t, db, concertID, results.seats)
+}
+
+func TestExpiredHoldsReturnToStockUnderContention(t *testing.T) {
+	db := dbtest.Open(t)
+	db.SetMaxOpenConns(20)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 10, 0)
+
+	// Dix places bloquées par des réservations déjà expirées
+	for i := 0; i < 10; i++ {
+		reservation := &pendingReservation{
+			UserID: userID, ConcertID: concertID, TicketType: "standard", Quantity: 1,
+			TotalPrice: 5000, Currency: "eur", ExpiresAt: time.Now().Add(-time.Minute),
+		}
+		if _, err := createPendingReservation(reservation, ""); err != nil {
+			t.Fatalf("create expired reservation: %v", err)
+		}
+	}
+	assertStock(t, db, concertID, 0, 10)
+
+	// Le nettoyage tourne pendant que les checkouts se disputent les places libérées
+	var cleanup sync.WaitGroup
+	cleanup.Add(1)
+	go func() {
+		defer cleanup.Done()
+		if _, err := CleanupExpiredReservations(); err != nil {
+			t.Errorf("cleanup: %v", err)
+		}
+	}()
+	results := checkoutConcurrently(t, userID, concertID, "standard", func(int) int { return 1 })
+	cleanup.Wait()
+	if _, err := CleanupExpiredReservations(); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+
+	if results.seats > 10 {
+		t.Fatalf("oversold: %d seats held for a 10-seat concert", results.seats)
+	}
+	assertStock(t, db, concertID, 10-results.seats, results.seats)
+	assertPendingQuantity(t, db, concertID, results.seats)
+}
+
+type checkoutResults struct {
+	succeeded int
+	soldOut   int
+	seats     int
+}
+
+// checkoutConcurrently lance checkoutAttempts réservations en parallèle,
+// quantity(i) places chacune, et compte celles qui ont obtenu leurs places.
+func checkoutConcurrently(t *testing.T, userID, concertID int, ticketType string, quantity func(int) int) checkoutResults {
+	t.Helper()
+
+	var (
+		mu      sync.Mutex
+		results checkoutResults
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+	)
+	for i := 0; i < checkoutAttempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			reservation := &pendingReservation{
+				UserID: userID, ConcertID: concertID, TicketType: ticketType, Quantity: quantity(i),
+				TotalPrice: 5000 * int64(quantity(i)), Currency: "eur",
+				ExpiresAt: time.Now().Add(ReservationExpiryMinutes * time.Minute),
+			}
+			_, err := createPendingReservation(reservation, "")
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				results.succeeded++
+				results.seats += reservation.Quantity
+			case strings.Contains(err.Error(), "not enough"):
+				results.soldOut++
+			default:
+				t.Errorf("checkout %d: %v", i, err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return results
+}
+
+func insertTestUser(t *testing.T, db *sql.DB, email string) int {
+	t.Helper()
+
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO users (email, name, role, email_verified)
+		VALUES ($1, 'Test User', 'user', TRUE)
+		RETURNING id
+	`, email).Scan(&id)
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	return id
+}
+
+// insertTestConcert crée un concert à venir à 50 € la place standard, 120 € la VIP.
+func insertTestConcert(t *testing.T, db *sql.DB, standard, vip int) int {
+	t.Helper()
+
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO concerts
+			(name, artist_name, venue, city, date, standard_price, vip_price,
+			 available_standard, available_vip, currency)
+		VALUES ('Test Concert', 'Test Artist', 'Test Venue', 'Paris', NOW() + INTERVAL '30 days',
+			5000, 12000, $1, $2, 'eur')
+		RETURNING id
+	`, standard, vip).Scan(&id)
+	if err != nil {
+		t.Fatalf("insert concert: %v", err)
+	}
+	return id
+}
+
+func assertStock(t *testing.T, db *sql.DB, concertID, wantAvailable, wantHeld int) {
+	t.Helper()
+
+	var available, held int
+	err := db.QueryRow(`SELECT available_standard, held_standard FROM concerts WHERE id = $1`, concertID).
+		Scan(&available, &held)
+	if err != nil {
+		t.Fatalf("read stock: %v", err)
+	}
+	if available != wantAvailable || held != wantHeld {
+		t.Errorf("stock = %d available / %d held, want %d / %d", available, held, wantAvailable, wantHeld)
+	}
+}
+
+func assertPendingQuantity(t *testing.T, db *sql.DB, concertID, want int) {
+	t.Helper()
+
+	var quantity int
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(quantity), 0) FROM reservations
+		WHERE concert_id = $1 AND status = 'pending'
+	`, concertID).Scan(&quantity)
+	if err != nil {
+		t.Fatalf("read pending reservations: %v", err)
+	}
+	if quantity != want {
+		t.Errorf("pending reservations hold %d seats, want %d", quantity, want)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"groupie-backend/internal/listquery"
+	"groupie-backend/models"
+)
+
+// Paramètres acceptés par les routes de liste : chaque Spec déclare les
+// filtres et tris autorisés, avec la colonne SQL et le champ Go équivalents,
+// pour que le repository Postgres et celui en mémoire se comportent pareil.
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// ArtistListSpec : GET /api/artists et GET /api/admin/artists (table artists)
+var ArtistListSpec = &listquery.Spec[models.Artist]{
+	Fields: map[string]listquery.Field[models.Artist]{
+		"name":          {Column: "name", Kind: listquery.String, Value: func(a models.Artist) any { return a.Name }},
+		"genre":         {Column: "genre", Kind: listquery.String, Value: func(a models.Artist) any { return a.Genre }},
+		"creation_date": {Column: "COALESCE(creation_date, 0)", Kind: listquery.Int, Value: func(a models.Artist) any { return a.CreationDate }},
+		"id":            {Column: "id", Kind: listquery.Int, Value: func(a models.Artist) any { return a.ID }},
+	},
+	Filters: []listquery.Filter{
+		{Param: "q", Fields: []string{"name"}, Op: listquery.Contains},
+		{Param: "genre", Fields: []string{"genre"}, Op: listquery.Eq},
+		{Param: "created_from", Fields: []string{"creation_date"}, Op: listquery.Gte},
+		{Param: "created_to", Fields: []string{"creation_date"}, Op: listquery.Lte},
+	},
+	Sorts:        []string{"name", "creation_date", "id"},
+	DefaultSort:  "name",
+	ID:           listquery.Field[models.Artist]{Column: "id", Kind: listquery.Int, Value: func(a models.Artist) any { return a.ID }},
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// ConcertListSpec : GET /api/concerts, /api/concerts/search et
+// /api/admin/concerts (table concerts, alias c)
+var ConcertListSpec = &listquery.Spec[models.Concert]{
+	Fields: map[string]listquery.Field[models.Concert]{
+		"date":           {Column: "c.date", Kind: listquery.Time, Value: func(c models.Concert) any { return c.Date }},
+		"name":           {Column: "c.name", Kind: listquery.String, Value: func(c models.Concert) any { return c.Name }},
+		"artist_name":    {Column: "c.artist_name", Kind: listquery.String, Value: func(c models.Concert) any { return c.ArtistName }},
+		"artist_id":      {Column: "COALESCE(c.artist_id, 0)", Kind: listquery.Int, Value: func(c models.Concert) any { return c.ArtistID }},
+		"venue":          {Column: "c.venue", Kind: listquery.String, Value: func(c models.Concert) any { return c.Venue }},
+		"city":           {Column: "c.city", Kind: listquery.String, Value: func(c models.Concert) any { return c.City }},
+		"standard_price": {Column: "c.standard_price", Kind: listquery.Int, Value: func(c models.Concert) any { return c.StandardPrice }},
+		"currency":       {Column: "c.currency", Kind: listquery.String, Value: func(c models.Concert) any { return c.Currency }},
+		"id":             {Column: "c.id", Kind: listquery.Int, Value: func(c models.Concert) any { return c.ID }},
+	},
+	Filters: []listquery.Filter{
+		{Param: "q", Fields: []string{"artist_name", "name", "venue", "city"}, Op: listquery.Contains},
+		{Param: "city", Fields: []string{"city"}, Op: listquery.Eq},
+		{Param: "artist_id", Fields: []string{"artist_id"}, Op: listquery.Eq},
+		{Param: "currency", Fields: []string{"currency"}, Op: listquery.Eq},
+		{Param: "date_from", Fields: []string{"date"}, Op: listquery.Gte},
+		{Param: "date_to", Fields: []string{"date"}, Op: listquery.Lte},
+	},
+	Sorts:        []string{"date", "artist_name", "standard_price", "id"},
+	DefaultSort:  "date",
+	ID:           listquery.Field[models.Concert]{Column: "c.id", Kind: listquery.Int, Value: func(c models.Concert) any { return c.ID }},
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// AdminUserListSpec : GET /api/admin/users (table users, alias u)
+var AdminUserListSpec = &listquery.Spec[models.AdminUser]{
+	Fields: map[string]listquery.Field[models.AdminUser]{
+		"created_at":     {Column: "u.created_at", Kind: listquery.Time, Value: func(u models.AdminUser) any { return u.CreatedAt }},
+		"name":           {Column: adminUserName, Kind: listquery.String, Value: func(u models.AdminUser) any { return u.Name }},
+		"email":          {Column: "u.email", Kind: listquery.String, Value: func(u models.AdminUser) any { return u.Email }},
+		"role":           {Column: "COALESCE(u.role, 'user')", Kind: listquery.String, Value: func(u models.AdminUser) any { return u.Role }},
+		"email_verified": {Column: "COALESCE(u.email_verified, FALSE)", Kind: listquery.Bool, Value: func(u models.AdminUser) any { return u.EmailVerified }},
+		"id":             {Column: "u.id", Kind: listquery.Int, Value: func(u models.AdminUser) any { return u.ID }},
+	},
+	Filters: []listquery.Filter{
+		{Param: "q", Fields: []string{"name", "email"}, Op: listquery.Contains},
+		{Param: "role", Fields: []string{"role"}, Op: listquery.Eq},
+		{Param: "email_verified", Fields: []string{"email_verified"}, Op: listquery.Eq},
+		{Param: "date_from", Fields: []string{"created_at"}, Op: listquery.Gte},
+		{Param: "date_to", Fields: []string{"created_at"}, Op: listquery.Lte},
+	},
+	Sorts:        []string{"created_at", "name", "email", "id"},
+	DefaultSort:  "-created_at",
+	ID:           listquery.Field[models.AdminUser]{Column: "u.id", Kind: listquery.Int, Value: func(u models.AdminUser) any { return u.ID }},
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// AdminPaymentListSpec : GET /api/admin/payments (reservations r, users u, concerts c)
+var AdminPaymentListSpec = &listquery.Spec[models.AdminPayment]{
+	Fields: map[string]listquery.Field[models.AdminPayment]{
+		"created_at":     {Column: "r.created_at", Kind: listquery.Time, Value: func(p models.AdminPayment) any { return p.CreatedAt }},
+		"total_price":    {Column: "r.total_price", Kind: listquery.Int, Value: func(p models.AdminPayment) any { return p.TotalPrice }},
+		"status":         {Column: "COALESCE(r.status, '')", Kind: listquery.String, Value: func(p models.AdminPayment) any { return p.Status }},
+		"payment_status": {Column: "COALESCE(r.payment_status, '')", Kind: listquery.String, Value: func(p models.AdminPayment) any { return p.PaymentStatus }},
+		"currency":       {Column: "r.currency", Kind: listquery.String, Value: func(p models.AdminPayment) any { return p.Currency }},
+		"user_id":        {Column: "COALESCE(r.user_id, 0)", Kind: listquery.Int, Value: func(p models.AdminPayment) any { return p.UserID }},
+		"concert_id":     {Column: "COALESCE(r.concert_id, 0)", Kind: listquery.Int, Value: func(p models.AdminPayment) any { return p.ConcertID }},
+		"user_email":     {Column: "COALESCE(u.email, '')", Kind: listquery.String, Value: func(p models.AdminPayment) any { return p.UserEmail }},
+		"concert_name":   {Column: "COALESCE(c.name, '')", Kind: listquery.String, Value: func(p models.AdminPayment) any { return p.ConcertName }},
+		"artist_name":    {Column: "COALESCE(c.artist_name, '')", Kind: listquery.String, Value: func(p models.AdminPayment) any { return p.ArtistName }},
+		"id":             {Column: "r.id", Kind: listquery.Int, Value: func(p models.AdminPayment) any { return p.ID }},
+	},
+	Filters: []listquery.Filter{
+		{Param: "q", Fields: []string{"user_email", "concert_name", "artist_name"}, Op: listquery.Contains},
+		{Param: "status", Fields: []string{"status"}, Op: listquery.Eq},
+		{Param: "payment_status", Fields: []string{"payment_status"}, Op: listquery.Eq},
+		{Param: "currency", Fields: []string{"currency"}, Op: listquery.Eq},
+		{Param: "user_id", Fields: []string{"user_id"}, Op: listquery.Eq},
+		{Param: "concert_id", Fields: []string{"concert_id"}, Op: listquery.Eq},
+		{Param: "date_from", Fields: []string{"created_at"}, Op: listquery.Gte},
+		{Param: "date_to", Fields: []string{"created_at"}, Op: listquery.Lte},
+	},
+	Sorts:        []string{"created_at", "total_price", "id"},
+	DefaultSort:  "-created_at",
+	ID:           listquery.Field[models.AdminPayment]{Column: "r.id", Kind: listquery.Int, Value: func(p models.AdminPayment) any { return p.ID }},
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/models"
+)
+
+// ========= PROTECTION CONTRE LA FORCE BRUTE =========
+//
+// Les échecs de connexion sont comptés par email (compte existant ou non, pour
+// ne pas révéler lesquels existent) et par IP. Par email : ralentissement
+// progressif dès quelques échecs, puis verrouillage temporaire. Par IP : un
+// seuil plus haut, qui arrête le balayage de nombreux comptes depuis une même
+// machine. Une connexion réussie remet le compteur du compte à zéro, pas
+// celui de l'IP (sinon un compte valide suffirait à le remettre à zéro).
+
+const (
+	loginFailureWindow    = 15 * time.Minute
+	loginDelayAfter       = 3 // échecs tolérés avant ralentissement
+	maxLoginDelay         = 30 * time.Second
+	accountLockThreshold  = 10
+	ipLockThreshold       = 30
+	baseLockDuration      = 15 * time.Minute // doublée à chaque nouveau verrouillage dans les 24 h
+	maxLockDuration       = 24 * time.Hour
+	loginAttemptRetention = 30 * 24 * time.Hour
+)
+
+const (
+	LockoutScopeAccount = "account"
+	LockoutScopeIP      = "ip"
+)
+
+var ErrLockoutNotFound = errors.New("verrouillage introuvable ou déjà levé")
+
+// LoginThrottledError refuse une tentative : ralentissement (Locked false) ou
+// verrouillage. RetryAfter alimente l'en-tête Retry-After.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "trop de tentatives échouées, connexion temporairement bloquée"
+	}
+	return "trop de tentatives, patientez avant de réessayer"
+}
+
+// LoginFailure décrit les conséquences d'un échec : verrouillages déclenchés
+// par cet échec (nil sinon) et compte concerné (0 si l'email est inconnu)
+type LoginFailure struct {
+	UserID         int
+	AccountLockout *models.LoginLockout
+	IPLockout      *models.LoginLockout
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CheckLoginAllowed refuse la tentative si l'IP ou le compte est verrouillé,
+// ou si le délai imposé depuis le dernier échec n'est pas écoulé
+func CheckLoginAllowed(email, ip string) error {
+	email = normalizeLoginEmail(email)
+
+	for _, lock := range []struct{ scope, key string }{{LockoutScopeIP, ip}, {LockoutScopeAccount, email}} {
+		remaining, err := activeLockout(database.DB, lock.scope, lock.key)
+		if err != nil {
+			return err
+		}
+		if remaining > 0 {
+			return &LoginThrottledError{RetryAfter: remaining, Locked: true}
+		}
+	}
+
+	failures, sinceLast, err := recentFailures(database.DB, LockoutScopeAccount, email)
+	if err != nil {
+		return err
+	}
+	if delay := loginDelay(failures); sinceLast < delay {
+		return &LoginThrottledError{RetryAfter: delay - sinceLast}
+	}
+	return nil
+}
+
+// loginDelay : 1 s après loginDelayAfter échecs, puis doublé à chaque échec
+func loginDelay(failures int) time.Duration {
+	if failures < loginDelayAfter {
+		return 0
+	}
+	shift := failures - loginDelayAfter
+	if shift > 5 {
+		return maxLoginDelay
+	}
+	delay := time.Second << shift
+	if delay > maxLoginDelay {
+		delay = maxLoginDelay
+	}
+	return delay
+}
+
+// RecordLoginFailure enregistre un échec et verrouille le compte et/ou l'IP
+// si un seuil est atteint. Le titulaire du compte est prévenu par email.
+func RecordLoginFailure(email, ip string) (*LoginFailure, error) {
+	email = normalizeLoginEmail(email)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Verrous par email et par IP : deux échecs simultanés ne créent pas deux verrouillages
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('login:' || $1)), pg_advisory_xact_lock(hashtext('login-ip:' || $2))`, email, ip); err != nil {
+		return nil, fmt.Errorf("failed to lock login attempts: %w", err)
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO login_attempts (email, ip_address, success) VALUES ($1, $2, false)", email, ip,
+	); err != nil {
+		return nil, fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	result := &LoginFailure{}
+	err = tx.QueryRow("SELECT id FROM users WHERE LOWER(email) = $1", email).Scan(&result.UserID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error looking up user: %w", err)
+	}
+
+	if result.AccountLockout, err = lockIfNeeded(tx, LockoutScopeAccount, email, result.UserID, accountLockThreshold); err != nil {
+		return nil, err
+	}
+	if result.IPLockout, err = lockIfNeeded(tx, LockoutScopeIP, ip, 0, ipLockThreshold); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit login attempt: %w", err)
+	}
+
+	if result.AccountLockout != nil && result.UserID != 0 {
+		duration := result.AccountLockout.LockedUntil.Sub(result.AccountLockout.CreatedAt)
+		if err := SendAccountLockedEmail(email, duration); err != nil {
+			fmt.Printf("⚠️  Email de verrouillage non envoyé à l'utilisateur #%d: %v\n", result.UserID, err)
+		}
+	}
+	return result, nil
+}
+
+// RecordLoginSuccess enregistre une connexion réussie (remet à zéro le compteur du compte)
+func RecordLoginSuccess(email, ip string) error {
+	_, err := database.DB.Exec(
+		"INSERT INTO login_attempts (email, ip_address, success) VALUES ($1, $2, true)",
+		normalizeLoginEmail(email), ip,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	return nil
+}
+
+// lockIfNeeded crée un verrouillage si le seuil d'échecs est atteint et qu'aucun
+// n'est déjà actif. La durée double à chaque verrouillage des dernières 24 h.
+func lockIfNeeded(tx *sql.Tx, scope, key string, userID, threshold int) (*models.LoginLockout, error) {
+	failures, _, err := recentFailures(tx, scope, key)
+	if err != nil || failures < threshold {
+		return nil, err
+	}
+	remaining, err := activeLockout(tx, scope, key)
+	if err != nil || remaining > 0 {
+		return nil, err
+	}
+
+	var previous int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM login_lockouts
+		WHERE scope = $1 AND lock_key = $2 AND created_at > NOW() - INTERVAL '24 hours'
+	`, scope, key).Scan(&previous)
+	if err != nil {
+		return nil, fmt.Errorf("error counting lockouts: %w", err)
+	}
+	duration := maxLockDuration
+	if previous < 7 {
+		duration = baseLockDuration << previous
+	}
+	if duration > maxLockDuration {
+		duration = maxLockDuration
+	}
+
+	lockout := &models.LoginLockout{Scope: scope, Key: key, Failures: failures}
+	var owner sql.NullInt64
+	if userID != 0 {
+		owner = sql.NullInt64{Int64: int64(userID), Valid: true}
+		lockout.UserID = &userID
+	}
+	err = tx.QueryRow(`
+		INSERT INTO login_lockouts (scope, lock_key, user_id, failures, locked_until)
+		VALUES ($1, $2, $3, $4, NOW() + $5 * INTERVAL '1 second')
+		RETURNING id, locked_until, created_at
+	`, scope, key, owner, failures, int(duration.Seconds())).Scan(&lockout.ID, &lockout.LockedUntil, &lockout.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lockout: %w", err)
+	}
+	return lockout, nil
+}
+
+// activeLockout retourne le temps restant du verrouillage actif (0 sinon)
+func activeLockout(q queryRower, scope, key string) (time.Duration, error) {
+	var seconds float64
+	err := q.QueryRow(`
+		SELECT COALESCE(MAX(EXTRACT(EPOCH FROM locked_until - NOW())), 0)::float8
+		FROM login_lockouts
+		WHERE scope = $1 AND lock_key = $2 AND released_at IS NULL AND locked_until > NOW()
+	`, scope, key).Scan(&seconds)
+	if err != nil {
+		return 0, fmt.Errorf("error checking lockout: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// recentFailures compte les échecs de la fenêtre, depuis le dernier
+// verrouillage ou déverrouillage (et, pour un compte, la dernière réussite),
+// et retourne le temps écoulé depuis le dernier échec
+func recentFailures(q queryRower, scope, key string) (int, time.Duration, error) {
+	column, resetOnSuccess := "email", true
+	if scope == LockoutScopeIP {
+		column, resetOnSuccess = "ip_address", false
+	}
+
+	var count int
+	var seconds float64
+	err := q.QueryRow(`
+		SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM NOW() - MAX(a.created_at)), 0)::float8
+		FROM login_attempts a
+		WHERE a.`+column+` = $1 AND NOT a.success
+		AND a.created_at > NOW() - $2 * INTERVAL '1 second'
+		AND ($4 = false OR a.created_at > COALESCE(
+			(SELECT MAX(created_at) FROM login_attempts WHERE `+column+` = $1 AND success), '-infinity'))
+		AND a.created_at > COALESCE(
+			(SELECT MAX(GREATEST(created_at, COALESCE(released_at, created_at)))
+			 FROM login_lockouts WHERE scope = $3 AND lock_key = $1), '-infinity')
+	`, key, int(loginFailureWindow.Seconds()), scope, resetOnSuccess).Scan(&count, &seconds)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error counting login failures: %w", err)
+	}
+	return count, time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ListActiveLockouts liste les verrouillages en cours (admin)
+func ListActiveLockouts() ([]models.LoginLockout, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, scope, lock_key, user_id, failures, locked_until, created_at, released_at
+		FROM login_lockouts
+		WHERE released_at IS NULL AND locked_until > NOW()
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing lockouts: %w", err)
+	}
+	defer rows.Close()
+
+	lockouts := []models.LoginLockout{}
+	for rows.Next() {
+		lockout, err := scanLockout(rows)
+		if err != nil {
+			return nil, err
+		}
+		lockouts = append(lockouts, *lockout)
+	}
+	return lockouts, rows.Err()
+}
+
+// ReleaseLockout lève un verrouillage (admin) ; les échecs antérieurs ne
+// comptent plus
+func ReleaseLockout(lockoutID, adminID int) (*models.LoginLockout, error) {
+	row := database.DB.QueryRow(`
+		UPDATE login_lockouts SET released_at = NOW(), released_by = $2
+		WHERE id = $1 AND released_at IS NULL AND locked_until > NOW()
+		RETURNING id, scope, lock_key, user_id, failures, locked_until, created_at, released_at
+	`, lockoutID, adminID)
+	lockout, err := scanLockout(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLockoutNotFound
+	}
+	return lockout, err
+}
+
+func scanLockout(row interface{ Scan(...interface{}) error }) (*models.LoginLockout, error) {
+	var lockout models.LoginLockout
+	var userID sql.NullInt64
+	err := row.Scan(&lockout.ID, &lockout.Scope, &lockout.Key, &userID, &lockout.Failures,
+		&lockout.LockedUntil, &lockout.CreatedAt, &lockout.ReleasedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error scanning lockout: %w", err)
+	}
+	if userID.Valid {
+		id := int(userID.Int64)
+		lockout.UserID = &id
+	}
+	return &lockout, nil
+}
+
+// CleanupLoginAttempts purge l'historique des tentatives et les vieux verrouillages
+func CleanupLoginAttempts() (int64, error) {
+	seconds := int(loginAttemptRetention.Seconds())
+	result, err := database.DB.Exec(`DELETE FROM login_attempts WHERE created_at < NOW() - $1 * INTERVAL '1 second'`, seconds)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup login attempts: %w", err)
+	}
+	if _, err := database.DB.Exec(`DELETE FROM login_lockouts WHERE locked_until < NOW() - $1 * INTERVAL '1 second'`, seconds); err != nil {
+		return 0, fmt.Errorf("failed to cleanup lockouts: %w", err)
+	}
+	return result.RowsAffected()
+}
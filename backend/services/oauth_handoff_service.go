@@ -0,0 +1,109 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/models"
+)
+
+// ========= PASSATION APRÈS CONNEXION EXTERNE =========
+//
+// Le callback OAuth remet au client un code à usage unique plutôt que ses
+// tokens : rien de sensible dans l'historique, les logs des proxys ni le
+// Referer. Le code est lié au state et au PKCE choisis par le client au
+// départ : intercepté (deep link Android détourné, URL copiée), il est inutile.
+
+// OAuthHandoffTTL : le client échange le code dès son retour
+const OAuthHandoffTTL = 60 * time.Second
+
+var ErrOAuthHandoffInvalid = errors.New("code de connexion invalide ou expiré")
+
+// IssueOAuthHandoff crée le code de passation d'une connexion externe réussie
+func IssueOAuthHandoff(userID int, provider, clientState, codeChallenge string) (string, error) {
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = database.DB.Exec(`
+		INSERT INTO oauth_handoff_codes (user_id, provider, code_hash, state_hash, code_challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NOW() + $6 * INTERVAL '1 second')
+	`, userID, provider, hashToken(code), hashToken(clientState), codeChallenge, int(OAuthHandoffTTL.Seconds()))
+	if err != nil {
+		return "", fmt.Errorf("failed to store handoff code: %w", err)
+	}
+	return code, nil
+}
+
+// ExchangeOAuthHandoff consomme le code et ouvre la session, ou retourne un
+// challenge si la 2FA est active (le fournisseur remplace le mot de passe,
+// pas le second facteur). Le code est brûlé même si la vérification échoue.
+func ExchangeOAuthHandoff(req models.OAuthExchangeRequest, userAgent, ipAddress string) (*models.LoginResponse, *models.TwoFactorChallenge, error) {
+	if req.Code == "" || req.State == "" || req.CodeVerifier == "" {
+		return nil, nil, ErrOAuthHandoffInvalid
+	}
+
+	var userID int
+	var provider, stateHash, codeChallenge string
+	err := database.DB.QueryRow(`
+		UPDATE oauth_handoff_codes SET used_at = NOW()
+		WHERE code_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id, provider, state_hash, code_challenge
+	`, hashToken(req.Code)).Scan(&userID, &provider, &stateHash, &codeChallenge)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrOAuthHandoffInvalid
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to consume handoff code: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(req.CodeVerifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(hashToken(req.State)), []byte(stateHash)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(challenge), []byte(codeChallenge)) != 1 {
+		fmt.Printf("⚠️  Code de passation %s présenté avec un state/PKCE invalide (utilisateur #%d)\n", provider, userID)
+		return nil, nil, ErrOAuthHandoffInvalid
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, nil, ErrOAuthHandoffInvalid
+	}
+
+	if user.TwoFactor {
+		challenge, err := IssueLoginChallenge(user.ID, user.Email)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, challenge, nil
+	}
+
+	tokens, err := CreateSession(user.ID, user.Role, userAgent, ipAddress)
+	if err != nil {
+		fmt.Printf("❌ Erreur création de session pour l'utilisateur #%d: %v\n", user.ID, err)
+		return nil, nil, errors.New("authentication failed")
+	}
+
+	return &models.LoginResponse{
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
+	}, nil, nil
+}
+
+// CleanupOAuthHandoffCodes supprime les codes de passation expirés
+func CleanupOAuthHandoffCodes() (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM oauth_handoff_codes WHERE expires_at < NOW() - INTERVAL '1 hour'`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup handoff codes: %w", err)
+	}
+	return result.RowsAffected()
+}
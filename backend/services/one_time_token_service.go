@@ -0,0 +1,165 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"groupie-backend/database"
+)
+
+// ========= JETONS À USAGE UNIQUE =========
+//
+// Vérification d'email et réinitialisation de mot de passe partagent le même
+// mécanisme : un jeton aléatoire de 256 bits envoyé par email, dont seul le
+// SHA-256 est stocké. Un jeton ne sert qu'une fois, supporte un nombre limité
+// de présentations, et l'émission est limitée par adresse email.
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeLoginChallenge    = "login_challenge"
+
+	maxOneTimeTokenAttempts = 5
+	maxOneTimeTokensPerHour = 3
+	oneTimeTokenMinInterval = time.Minute
+	EmailVerificationTTL    = 24 * time.Hour
+	PasswordResetTTL        = time.Hour
+)
+
+var (
+	ErrOneTimeTokenInvalid   = errors.New("invalid or already used token")
+	ErrOneTimeTokenExpired   = errors.New("token expired")
+	ErrOneTimeTokenThrottled = errors.New("too many tokens requested for this email, try again later")
+)
+
+// IssueOneTimeToken génère un jeton pour l'utilisateur et invalide les
+// précédents du même usage. Retourne ErrOneTimeTokenThrottled si l'adresse
+// a déjà reçu trop de jetons récemment.
+func IssueOneTimeToken(userID int, email, purpose string, ttl time.Duration) (string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Verrou par (adresse, usage) : deux demandes simultanées ne contournent pas la limite
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext(LOWER($1) || ':' || $2))`, email, purpose); err != nil {
+		return "", fmt.Errorf("failed to lock token issuance: %w", err)
+	}
+
+	var recent int
+	var tooSoon bool
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(BOOL_OR(created_at > NOW() - $3 * INTERVAL '1 second'), false)
+		FROM one_time_tokens
+		WHERE LOWER(email) = LOWER($1) AND purpose = $2
+		AND created_at > NOW() - INTERVAL '1 hour'
+	`, email, purpose, int(oneTimeTokenMinInterval.Seconds())).Scan(&recent, &tooSoon)
+	if err != nil {
+		return "", fmt.Errorf("error checking token issuance: %w", err)
+	}
+	if recent >= maxOneTimeTokensPerHour || tooSoon {
+		return "", ErrOneTimeTokenThrottled
+	}
+
+	token, err := insertOneTimeToken(tx, userID, email, purpose, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit token: %w", err)
+	}
+	return token, nil
+}
+
+// insertOneTimeToken invalide les jetons encore valides du même usage et en
+// crée un nouveau : un seul lien (ou challenge) utilisable à la fois
+func insertOneTimeToken(tx *sql.Tx, userID int, email, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE one_time_tokens SET expires_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+	`, userID, purpose)
+	if err != nil {
+		return "", fmt.Errorf("failed to invalidate previous tokens: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO one_time_tokens (user_id, email, purpose, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW() + $5 * INTERVAL '1 second', NOW())
+	`, userID, email, purpose, hashToken(token), int(ttl.Seconds()))
+	if err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+	return token, nil
+}
+
+// VerifyOneTimeToken compte une présentation du jeton et retourne son
+// utilisateur s'il est encore utilisable. Au-delà de maxOneTimeTokenAttempts
+// présentations, le jeton est considéré comme brûlé.
+func VerifyOneTimeToken(token, purpose string) (int, error) {
+	if token == "" {
+		return 0, ErrOneTimeTokenInvalid
+	}
+
+	var userID, attempts int
+	var used, expired bool
+	err := database.DB.QueryRow(`
+		UPDATE one_time_tokens SET attempts = attempts + 1
+		WHERE token_hash = $1 AND purpose = $2
+		RETURNING user_id, used_at IS NOT NULL, expires_at <= NOW(), attempts
+	`, hashToken(token), purpose).Scan(&userID, &used, &expired, &attempts)
+	if err == sql.ErrNoRows {
+		return 0, ErrOneTimeTokenInvalid
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error verifying token: %w", err)
+	}
+
+	switch {
+	case used || attempts > maxOneTimeTokenAttempts:
+		return 0, ErrOneTimeTokenInvalid
+	case expired:
+		return 0, ErrOneTimeTokenExpired
+	}
+	return userID, nil
+}
+
+// ConsumeOneTimeToken marque le jeton comme utilisé dans la transaction de
+// l'action qu'il autorise. L'UPDATE conditionnel garantit l'usage unique même
+// si le lien est ouvert deux fois en parallèle.
+func ConsumeOneTimeToken(tx *sql.Tx, token, purpose string) (int, error) {
+	var userID int
+	err := tx.QueryRow(`
+		UPDATE one_time_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2
+		AND used_at IS NULL AND expires_at > NOW() AND attempts <= $3
+		RETURNING user_id
+	`, hashToken(token), purpose, maxOneTimeTokenAttempts).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrOneTimeTokenInvalid
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to consume token: %w", err)
+	}
+	return userID, nil
+}
+
+// CleanupOneTimeTokens supprime les jetons expirés depuis plus d'une journée
+// (ils servent encore à la limitation d'émission jusque-là)
+func CleanupOneTimeTokens() (int64, error) {
+	result, err := database.DB.Exec(`
+		DELETE FROM one_time_tokens WHERE expires_at < NOW() - INTERVAL '1 day'
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"groupie-backend/database"
@@ -18,9 +19,19 @@ import (
 // ========= CONSTANTES =========
 const (
 	ReservationExpiryMinutes = 15
-	VIPPriceMultiplier       = 1.5 // VIP = +50% du prix standard
 )
 
+// stockColumn retourne la colonne de stock du tarif (liste blanche, sûre à concaténer en SQL)
+func stockColumn(ticketType string) (string, error) {
+	switch ticketType {
+	case models.TicketTypeStandard:
+		return "available_standard", nil
+	case models.TicketTypeVIP:
+		return "available_vip", nil
+	}
+	return "", fmt.Errorf("unknown ticket type: %q", ticketType)
+}
+
 // ========= GESTION DES CONCERTS =========
 
 // GetConcertByID récupère un concert avec toutes ses informations
@@ -28,8 +39,8 @@ func GetConcertByID(concertID int) (*models.Concert, error) {
 	var concert models.Concert
 
 	query := `
-		SELECT id, name, artist_name, location, date, image_url, 
-		       price, available_tickets
+		SELECT id, name, artist_name, venue, city, date, COALESCE(image_url, ''),
+		       standard_price, vip_price, currency, available_standard, available_vip
 		FROM concerts 
 		WHERE id = $1
 	`
@@ -38,16 +49,20 @@ func GetConcertByID(concertID int) (*models.Concert, error) {
 		&concert.ID,
 		&concert.Name,
 		&concert.ArtistName,
-		&concert.Location,
+		&concert.Venue,
+		&concert.City,
 		&concert.Date,
 		&concert.ImageURL,
-		&concert.Price,
-		&concert.AvailableTickets,
+		&concert.StandardPrice,
+		&concert.VIPPrice,
+		&concert.Currency,
+		&concert.AvailableStandard,
+		&concert.AvailableVIP,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("concert not found")
+			return nil, ErrConcertNotFound
 		}
 		return nil, fmt.Errorf("error fetching concert: %w", err)
 	}
@@ -58,82 +73,67 @@ func GetConcertByID(concertID int) (*models.Concert, error) {
 // ========= CRÉATION DE PAYMENT INTENT =========
 
 // CreatePaymentIntent crée une réservation et génère un Payment Intent Stripe
-func CreatePaymentIntent(userID int, req models.CreatePaymentIntentRequest) (string, float64, error) {
+func CreatePaymentIntent(userID int, req models.CreatePaymentIntentRequest) (*models.CreatePaymentIntentResponse, error) {
 	// 1. Validation de base
 	if req.Quantity <= 0 {
-		return "", 0, errors.New("quantity must be greater than 0")
+		return nil, errors.New("quantity must be greater than 0")
 	}
 	if req.TicketType != "standard" && req.TicketType != "vip" {
-		return "", 0, errors.New("ticket_type must be 'standard' or 'vip'")
+		return nil, errors.New("ticket_type must be 'standard' or 'vip'")
 	}
 
 	// 2. Récupérer le concert
 	concert, err := GetConcertByID(req.ConcertID)
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
 
-	// 3. Vérifier le stock disponible
-	if req.Quantity > concert.AvailableTickets {
-		return "", 0, fmt.Errorf("not enough tickets available. Only %d left", concert.AvailableTickets)
+	// 3. Vérifier le stock disponible pour le tarif demandé
+	available := concert.AvailableFor(req.TicketType)
+	if req.Quantity > available {
+		return nil, fmt.Errorf("not enough %s tickets available. Only %d left", req.TicketType, available)
 	}
 
-	// 4. Calculer le prix (gérer VIP)
-	var pricePerTicket float64
-	if req.TicketType == "vip" {
-		pricePerTicket = concert.Price * VIPPriceMultiplier
-	} else {
-		pricePerTicket = concert.Price
+	// 4. Calculer le prix selon le tarif, en unités mineures comme Stripe
+	reservation := &pendingReservation{
+		UserID:     userID,
+		ConcertID:  concert.ID,
+		TicketType: req.TicketType,
+		Quantity:   req.Quantity,
+		TotalPrice: concert.PriceFor(req.TicketType) * int64(req.Quantity),
+		Currency:   concert.Currency,
+		ExpiresAt:  time.Now().Add(ReservationExpiryMinutes * time.Minute),
 	}
 
-	totalPrice := pricePerTicket * float64(req.Quantity)
-	amountInCents := int64(totalPrice * 100) // Stripe utilise les centimes
-
-	// 5. Nettoyer les réservations expirées avant d'en créer une nouvelle
-	_, _ = database.DB.Exec(`
-		UPDATE reservations 
-		SET status = 'expired', updated_at = NOW()
-		WHERE status = 'pending' 
-		AND expires_at < NOW()
-	`)
-
-	// 6. Créer la réservation en base (statut 'pending')
-	var reservationID int
-	expiresAt := time.Now().Add(ReservationExpiryMinutes * time.Minute)
-
-	query := `
-		INSERT INTO reservations 
-			(user_id, concert_id, ticket_type, quantity, total_price, status, expires_at, created_at) 
-		VALUES 
-			($1, $2, $3, $4, $5, 'pending', $6, NOW()) 
-		RETURNING id
-	`
-
-	err = database.DB.QueryRow(
-		query,
-		userID,
-		req.ConcertID,
-		req.TicketType,
-		req.Quantity,
-		totalPrice,
-		expiresAt,
-	).Scan(&reservationID)
+	// 5. Libérer les réservations expirées avant d'en créer une nouvelle
+	if _, err := CleanupExpiredReservations(); err != nil {
+		log.Printf("⚠️  Warning: %v", err)
+	}
 
+	// 6. Créer la réservation 'pending' en bloquant les places (réduction appliquée)
+	reservationID, err := createPendingReservation(reservation, req.PromoCode)
 	if err != nil {
-		return "", 0, fmt.Errorf("error creating reservation: %w", err)
+		return nil, err
 	}
+	totalPrice := reservation.TotalPrice
 
 	// 7. Créer le Payment Intent Stripe
+	metadata := map[string]string{
+		"reservation_id": strconv.Itoa(reservationID),
+		"user_id":        strconv.Itoa(userID),
+		"concert_id":     strconv.Itoa(req.ConcertID),
+		"ticket_type":    req.TicketType,
+		"quantity":       strconv.Itoa(req.Quantity),
+	}
+	if reservation.PromoCode != "" {
+		metadata["promo_code"] = reservation.PromoCode
+		metadata["discount_amount"] = strconv.FormatInt(reservation.DiscountAmount, 10)
+	}
+
 	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(amountInCents),
-		Currency: stripe.String("eur"),
-		Metadata: map[string]string{
-			"reservation_id": strconv.Itoa(reservationID),
-			"user_id":        strconv.Itoa(userID),
-			"concert_id":     strconv.Itoa(req.ConcertID),
-			"ticket_type":    req.TicketType,
-			"quantity":       strconv.Itoa(req.Quantity),
-		},
+		Amount:   stripe.Int64(totalPrice),
+		Currency: stripe.String(concert.Currency),
+		Metadata: metadata,
 		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
 			Enabled: stripe.Bool(true),
 		},
@@ -141,9 +141,11 @@ func CreatePaymentIntent(userID int, req models.CreatePaymentIntentRequest) (str
 
 	pi, err := paymentintent.New(params)
 	if err != nil {
-		// Annuler la réservation si Stripe échoue
-		_, _ = database.DB.Exec("DELETE FROM reservations WHERE id = $1", reservationID)
-		return "", 0, fmt.Errorf("error creating stripe payment intent: %w", err)
+		// Annuler la réservation (et libérer les places) si Stripe échoue
+		if cancelErr := cancelPendingReservation(reservationID, "cancelled"); cancelErr != nil {
+			log.Printf("⚠️  Warning: Failed to release reservation #%d: %v", reservationID, cancelErr)
+		}
+		return nil, fmt.Errorf("error creating stripe payment intent: %w", err)
 	}
 
 	// 8. Mettre à jour la réservation avec l'ID Stripe
@@ -159,14 +161,124 @@ func CreatePaymentIntent(userID int, req models.CreatePaymentIntentRequest) (str
 		log.Printf("⚠️  Warning: Failed to update reservation with Stripe ID: %v", err)
 	}
 
-	log.Printf("✅ Payment Intent créé : %s pour %.2f€ (Reservation #%d)", pi.ID, totalPrice, reservationID)
+	log.Printf("✅ Payment Intent créé : %s pour %s (Reservation #%d)", pi.ID, models.FormatAmount(totalPrice, concert.Currency), reservationID)
+
+	return &models.CreatePaymentIntentResponse{
+		ClientSecret:   pi.ClientSecret,
+		Amount:         totalPrice,
+		Currency:       concert.Currency,
+		DiscountAmount: reservation.DiscountAmount,
+		PromoCode:      reservation.PromoCode,
+	}, nil
+}
+
+// pendingReservation décrit une réservation 'pending' à insérer
+type pendingReservation struct {
+	UserID         int
+	ConcertID      int
+	TicketType     string
+	Quantity       int
+	TotalPrice     int64 // en unités mineures, réduction déduite
+	Currency       string
+	DiscountAmount int64
+	PromoCodeID    *int
+	PromoCode      string
+	ExpiresAt      time.Time
+}
+
+// createPendingReservation applique le code promo éventuel, bloque les places
+// et crée la réservation dans une même transaction
+func createPendingReservation(reservation *pendingReservation, promoCode string) (int, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(promoCode) != "" {
+		if err := applyPromoCode(tx, promoCode, reservation); err != nil {
+			return 0, err
+		}
+	}
+
+	reservationID, err := insertPendingReservation(tx, reservation)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return reservationID, nil
+}
+
+// insertPendingReservation bloque les places du tarif puis insère la réservation 'pending'
+func insertPendingReservation(tx *sql.Tx, reservation *pendingReservation) (int, error) {
+	if err := holdSeats(tx, reservation.ConcertID, reservation.TicketType, reservation.Quantity); err != nil {
+		if errors.Is(err, ErrNotEnoughTickets) {
+			return 0, fmt.Errorf("not enough %s tickets available", reservation.TicketType)
+		}
+		return 0, err
+	}
+
+	var reservationID int
+	err := tx.QueryRow(`
+		INSERT INTO reservations 
+			(user_id, concert_id, ticket_type, quantity, total_price, currency,
+			 discount_amount, promo_code_id, status, expires_at, created_at) 
+		VALUES 
+			($1, $2, $3, $4, $5, $6, $7, $8, 'pending', $9, NOW()) 
+		RETURNING id
+	`, reservation.UserID, reservation.ConcertID, reservation.TicketType, reservation.Quantity,
+		reservation.TotalPrice, reservation.Currency, reservation.DiscountAmount, reservation.PromoCodeID,
+		reservation.ExpiresAt).Scan(&reservationID)
+	if err != nil {
+		return 0, fmt.Errorf("error creating reservation: %w", err)
+	}
+
+	return reservationID, nil
+}
+
+// cancelPendingReservation passe une réservation 'pending' au statut donné et libère ses places.
+// Sans effet si la réservation n'est plus en attente.
+func cancelPendingReservation(reservationID int, status string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var concertID, quantity int
+	var ticketType string
+	err = tx.QueryRow(`
+		UPDATE reservations
+		SET status = $2, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING concert_id, ticket_type, quantity
+	`, reservationID, status).Scan(&concertID, &ticketType, &quantity)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to cancel reservation: %w", err)
+	}
+
+	if err := releaseSeats(tx, concertID, ticketType, quantity); err != nil {
+		return err
+	}
 
-	return pi.ClientSecret, totalPrice, nil
+	return tx.Commit()
 }
 
 // ========= CONFIRMATION DE PAIEMENT (Webhook) =========
 
-// MarkReservationAsPaid marque une réservation comme payée et décrémente le stock
+// ErrReservationNotPayable signale un paiement reçu pour une réservation qui
+// n'attend plus de paiement (annulée, remboursée) : à traiter à la main
+var ErrReservationNotPayable = errors.New("reservation cannot be marked as paid")
+
+// MarkReservationAsPaid marque une réservation comme payée et décrémente le stock.
+// Seules les réservations 'pending' ou 'expired' peuvent passer en 'paid'.
 func MarkReservationAsPaid(reservationIDStr string, stripePaymentID string) error {
 	reservationID, err := strconv.Atoi(reservationIDStr)
 	if err != nil {
@@ -181,14 +293,15 @@ func MarkReservationAsPaid(reservationIDStr string, stripePaymentID string) erro
 	defer tx.Rollback()
 
 	// 1. Récupérer les infos de la réservation
-	var quantity, concertID int
-	var currentStatus string
+	var quantity, concertID, userID int
+	var currentStatus, paymentStatus, ticketType string
 
 	err = tx.QueryRow(`
-		SELECT quantity, concert_id, status 
+		SELECT quantity, concert_id, COALESCE(user_id, 0), status, COALESCE(payment_status, ''), ticket_type
 		FROM reservations 
 		WHERE id = $1
-	`, reservationID).Scan(&quantity, &concertID, &currentStatus)
+		FOR UPDATE
+	`, reservationID).Scan(&quantity, &concertID, &userID, &currentStatus, &paymentStatus, &ticketType)
 
 	if err != nil {
 		return fmt.Errorf("reservation not found: %w", err)
@@ -200,6 +313,14 @@ func MarkReservationAsPaid(reservationIDStr string, stripePaymentID string) erro
 		return nil
 	}
 
+	// Un paiement rejoué après un remboursement ou une annulation revendrait
+	// les places et réémettrait les billets d'une commande déjà rendue
+	if (currentStatus != "pending" && currentStatus != "expired") ||
+		paymentStatus == "refunded" || paymentStatus == "partially_refunded" {
+		return fmt.Errorf("reservation #%d is %s (payment %s): %w",
+			reservationID, currentStatus, paymentStatus, ErrReservationNotPayable)
+	}
+
 	// 3. Mettre à jour le statut de la réservation
 	_, err = tx.Exec(`
 		UPDATE reservations 
@@ -214,22 +335,23 @@ func MarkReservationAsPaid(reservationIDStr string, stripePaymentID string) erro
 		return fmt.Errorf("failed to update reservation: %w", err)
 	}
 
-	// 4. Décrémenter le stock de billets
-	result, err := tx.Exec(`
-		UPDATE concerts 
-		SET available_tickets = available_tickets - $1 
-		WHERE id = $2 
-		AND available_tickets >= $1
-	`, quantity, concertID)
-
+	// 4. Convertir les places bloquées en places vendues. Si la réservation a
+	// expiré entre-temps, ses places ont été libérées : on les reprend dans le stock.
+	if currentStatus == "pending" {
+		err = sellHeldSeats(tx, concertID, ticketType, quantity)
+	} else {
+		err = sellAvailableSeats(tx, concertID, ticketType, quantity)
+	}
+	if errors.Is(err, ErrNotEnoughTickets) {
+		return errors.New("not enough tickets available to complete reservation")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update concert tickets: %w", err)
+		return err
 	}
 
-	// 5. Vérifier qu'on a bien décrémenté
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return errors.New("not enough tickets available to complete reservation")
+	// 5. Émettre un billet par place
+	if err := issueTickets(tx, reservationID, userID, concertID, ticketType, quantity); err != nil {
+		return err
 	}
 
 	// 6. Commit de la transaction
@@ -237,8 +359,10 @@ func MarkReservationAsPaid(reservationIDStr string, stripePaymentID string) erro
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	log.Printf("✅ Reservation #%d marked as PAID - %d tickets decremented for Concert #%d",
-		reservationID, quantity, concertID)
+	log.Printf("✅ Reservation #%d marked as PAID - %d %s tickets decremented for Concert #%d",
+		reservationID, quantity, ticketType, concertID)
+
+	sendOrderConfirmationAsync(reservationID)
 
 	return nil
 }
@@ -252,6 +376,11 @@ func MarkReservationAsFailed(reservationIDStr string, failureReason string) erro
 		return errors.New("invalid reservation ID format")
 	}
 
+	// Libérer les places si la réservation était encore en attente
+	if err := cancelPendingReservation(reservationID, "cancelled"); err != nil {
+		return fmt.Errorf("failed to mark reservation as failed: %w", err)
+	}
+
 	_, err = database.DB.Exec(`
 		UPDATE reservations 
 		SET status = 'cancelled', 
@@ -259,6 +388,7 @@ func MarkReservationAsFailed(reservationIDStr string, failureReason string) erro
 		    stripe_payment_status = 'failed',
 		    updated_at = NOW() 
 		WHERE id = $1
+		AND status IN ('pending', 'cancelled', 'expired')
 	`, reservationID)
 
 	if err != nil {
@@ -306,6 +436,9 @@ func GetUserReservations(userID int) ([]models.Reservation, error) {
 			r.ticket_type,
 			r.quantity, 
 			r.total_price, 
+			r.currency,
+			r.discount_amount,
+			COALESCE(p.code, ''),
 			r.status, 
 			r.payment_status,
 			r.stripe_payment_intent_id, 
@@ -316,6 +449,7 @@ func GetUserReservations(userID int) ([]models.Reservation, error) {
 			c.date as concert_date
 		FROM reservations r
 		LEFT JOIN concerts c ON r.concert_id = c.id
+		LEFT JOIN promo_codes p ON r.promo_code_id = p.id
 		WHERE r.user_id = $1 
 		ORDER BY r.created_at DESC
 	`
@@ -341,6 +475,9 @@ func GetUserReservations(userID int) ([]models.Reservation, error) {
 			&r.TicketType,
 			&r.Quantity,
 			&r.TotalPrice,
+			&r.Currency,
+			&r.DiscountAmount,
+			&r.PromoCode,
 			&r.Status,
 			&r.PaymentStatus,
 			&stripeID,
@@ -372,27 +509,50 @@ func GetUserReservations(userID int) ([]models.Reservation, error) {
 
 // ========= NETTOYAGE AUTOMATIQUE =========
 
-// CleanupExpiredReservations libère les réservations expirées
+// CleanupExpiredReservations expire les réservations en attente dépassées
+// et rend leurs places bloquées au stock, en une seule requête atomique.
 // Cette fonction doit être appelée périodiquement (via un scheduler)
 func CleanupExpiredReservations() (int, error) {
-	result, err := database.DB.Exec(`
-		UPDATE reservations 
-		SET status = 'expired', updated_at = NOW()
-		WHERE status = 'pending' 
-		AND expires_at < NOW()
-	`)
+	var expiredCount int
+
+	err := database.DB.QueryRow(`
+		WITH expired AS (
+			UPDATE reservations
+			SET status = 'expired', updated_at = NOW()
+			WHERE status = 'pending'
+			AND expires_at < NOW()
+			RETURNING concert_id, ticket_type, quantity
+		),
+		totals AS (
+			SELECT concert_id,
+			       COUNT(*) AS reservations,
+			       COALESCE(SUM(quantity) FILTER (WHERE ticket_type = 'standard'), 0) AS standard,
+			       COALESCE(SUM(quantity) FILTER (WHERE ticket_type = 'vip'), 0) AS vip
+			FROM expired
+			GROUP BY concert_id
+		),
+		released AS (
+			UPDATE concerts c
+			SET available_standard = c.available_standard + t.standard,
+			    held_standard = GREATEST(c.held_standard - t.standard, 0),
+			    available_vip = c.available_vip + t.vip,
+			    held_vip = GREATEST(c.held_vip - t.vip, 0)
+			FROM totals t
+			WHERE c.id = t.concert_id
+			RETURNING c.id
+		)
+		SELECT COALESCE(SUM(reservations), 0) FROM totals
+	`).Scan(&expiredCount)
 
 	if err != nil {
 		return 0, fmt.Errorf("failed to cleanup expired reservations: %w", err)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-
-	if rowsAffected > 0 {
-		log.Printf("🧹 Cleaned up %d expired reservations", rowsAffected)
+	if expiredCount > 0 {
+		log.Printf("🧹 Cleaned up %d expired reservations", expiredCount)
 	}
 
-	return int(rowsAffected), nil
+	return expiredCount, nil
 }
 
 // ========= STATISTIQUES ADMIN =========
@@ -403,17 +563,14 @@ func GetReservationStats() (map[string]interface{}, error) {
 
 	// Total de réservations
 	var totalReservations, paidReservations, pendingReservations int
-	var totalRevenue, avgOrderValue float64
 
 	err := database.DB.QueryRow(`
 		SELECT 
 			COUNT(*) as total,
 			COUNT(CASE WHEN status = 'paid' THEN 1 END) as paid,
-			COUNT(CASE WHEN status = 'pending' THEN 1 END) as pending,
-			COALESCE(SUM(CASE WHEN status = 'paid' THEN total_price ELSE 0 END), 0) as revenue,
-			COALESCE(AVG(CASE WHEN status = 'paid' THEN total_price END), 0) as avg_value
+			COUNT(CASE WHEN status = 'pending' THEN 1 END) as pending
 		FROM reservations
-	`).Scan(&totalReservations, &paidReservations, &pendingReservations, &totalRevenue, &avgOrderValue)
+	`).Scan(&totalReservations, &paidReservations, &pendingReservations)
 
 	if err != nil {
 		return nil, fmt.Errorf("error fetching reservation stats: %w", err)
@@ -422,6 +579,32 @@ func GetReservationStats() (map[string]interface{}, error) {
 	stats["total_reservations"] = totalReservations
 	stats["paid_reservations"] = paidReservations
 	stats["pending_reservations"] = pendingReservations
+
+	// Chiffre d'affaires par devise (en unités mineures) : on n'additionne jamais deux devises
+	totalRevenue := make(map[string]int64)
+	avgOrderValue := make(map[string]int64)
+	revenueRows, err := database.DB.Query(`
+		SELECT currency,
+		       COALESCE(SUM(total_price), 0) as revenue,
+		       COALESCE(ROUND(AVG(total_price)), 0)::BIGINT as avg_value
+		FROM reservations
+		WHERE status = 'paid'
+		GROUP BY currency
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching revenue stats: %w", err)
+	}
+	defer revenueRows.Close()
+	for revenueRows.Next() {
+		var currency string
+		var revenue, avg int64
+		if err := revenueRows.Scan(&currency, &revenue, &avg); err != nil {
+			return nil, fmt.Errorf("error scanning revenue stats: %w", err)
+		}
+		totalRevenue[currency] = revenue
+		avgOrderValue[currency] = avg
+	}
+
 	stats["total_revenue"] = totalRevenue
 	stats["average_order_value"] = avgOrderValue
 
@@ -446,82 +629,3 @@ func GetReservationStats() (map[string]interface{}, error) {
 
 	return stats, nil
 }
-
-// ========= REMBOURSEMENTS (Optionnel pour l'admin) =========
-
-// RefundReservation effectue un remboursement via Stripe
-func RefundReservation(reservationID int, reason string) error {
-	// 1. Récupérer la réservation
-	var stripePaymentIntentID string
-	var status string
-	var quantity, concertID int
-
-	err := database.DB.QueryRow(`
-		SELECT stripe_payment_intent_id, status, quantity, concert_id
-		FROM reservations
-		WHERE id = $1
-	`, reservationID).Scan(&stripePaymentIntentID, &status, &quantity, &concertID)
-
-	if err != nil {
-		return fmt.Errorf("reservation not found: %w", err)
-	}
-
-	// 2. Vérifier que la réservation est payée
-	if status != "paid" {
-		return errors.New("can only refund paid reservations")
-	}
-
-	if stripePaymentIntentID == "" {
-		return errors.New("no stripe payment intent found")
-	}
-
-	// 3. Créer le remboursement chez Stripe
-	// Note: Vous devrez importer "github.com/stripe/stripe-go/v76/refund"
-	// refundParams := &stripe.RefundParams{
-	// 	PaymentIntent: stripe.String(stripePaymentIntentID),
-	// 	Reason:        stripe.String(reason),
-	// }
-	// _, err = refund.New(refundParams)
-	// if err != nil {
-	// 	return fmt.Errorf("stripe refund failed: %w", err)
-	// }
-
-	// 4. Transaction pour mettre à jour la base
-	tx, err := database.DB.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// 5. Mettre à jour le statut
-	_, err = tx.Exec(`
-		UPDATE reservations
-		SET status = 'cancelled',
-		    payment_status = 'refunded',
-		    stripe_payment_status = 'refunded',
-		    updated_at = NOW()
-		WHERE id = $1
-	`, reservationID)
-
-	if err != nil {
-		return fmt.Errorf("failed to update reservation status: %w", err)
-	}
-
-	// 6. Remettre les billets dans le stock
-	_, err = tx.Exec(`
-		UPDATE concerts
-		SET available_tickets = available_tickets + $1
-		WHERE id = $2
-	`, quantity, concertID)
-
-	if err != nil {
-		return fmt.Errorf("failed to restore ticket stock: %w", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	log.Printf("💰 Reservation #%d refunded successfully - %d tickets restored", reservationID, quantity)
-	return nil
-}
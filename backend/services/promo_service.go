@@ -0,0 +1,369 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/models"
+)
+
+// ========= CODES PROMO =========
+//
+// Un code s'applique au moment où la réservation 'pending' est créée. La
+// réservation garde le montant de la réduction et l'ID du code : une
+// réservation 'pending' ou 'paid' compte comme une utilisation, une réservation
+// expirée, échouée ou remboursée libère automatiquement son utilisation.
+
+var ErrPromoCodeNotFound = errors.New("promo code not found")
+
+const promoCodeColumns = `
+	id, code, COALESCE(description, ''), discount_type, discount_value, COALESCE(currency, ''),
+	concert_id, max_redemptions, max_redemptions_per_user, min_quantity,
+	starts_at, ends_at, active, created_at, updated_at
+`
+
+// NormalizePromoCode met un code au format stocké (majuscules, sans espaces autour)
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func scanPromoCode(row rowScanner) (*models.PromoCode, error) {
+	var p models.PromoCode
+	var concertID, maxRedemptions, maxPerUser sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+
+	err := row.Scan(&p.ID, &p.Code, &p.Description, &p.DiscountType, &p.DiscountValue, &p.Currency,
+		&concertID, &maxRedemptions, &maxPerUser, &p.MinQuantity,
+		&startsAt, &endsAt, &p.Active, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	p.ConcertID = nullIntPtr(concertID)
+	p.MaxRedemptions = nullIntPtr(maxRedemptions)
+	p.MaxRedemptionsPerUser = nullIntPtr(maxPerUser)
+	if startsAt.Valid {
+		p.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		p.EndsAt = &endsAt.Time
+	}
+	return &p, nil
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
+
+// ========= APPLICATION AU CHECKOUT =========
+
+// applyPromoCode vérifie le code et déduit la réduction de la réservation.
+// Le code est verrouillé (FOR UPDATE) jusqu'à la fin de la transaction pour que
+// deux checkouts simultanés ne dépassent pas les limites d'utilisation.
+func applyPromoCode(tx *sql.Tx, code string, reservation *pendingReservation) error {
+	promo, err := scanPromoCode(tx.QueryRow(`
+		SELECT `+promoCodeColumns+`
+		FROM promo_codes
+		WHERE code = $1
+		FOR UPDATE
+	`, NormalizePromoCode(code)))
+	if err == sql.ErrNoRows {
+		return errors.New("invalid promo code")
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching promo code: %w", err)
+	}
+
+	// 1. Conditions d'utilisation
+	now := time.Now()
+	if !promo.Active {
+		return errors.New("invalid promo code")
+	}
+	if promo.StartsAt != nil && now.Before(*promo.StartsAt) {
+		return errors.New("promo code is not active yet")
+	}
+	if promo.EndsAt != nil && !now.Before(*promo.EndsAt) {
+		return errors.New("promo code has expired")
+	}
+	if promo.ConcertID != nil && *promo.ConcertID != reservation.ConcertID {
+		return errors.New("promo code is not valid for this concert")
+	}
+	if reservation.Quantity < promo.MinQuantity {
+		return fmt.Errorf("promo code requires at least %d tickets", promo.MinQuantity)
+	}
+	if promo.DiscountType == models.DiscountTypeFixed && promo.Currency != reservation.Currency {
+		return errors.New("promo code is not valid for this currency")
+	}
+
+	// 2. Limites d'utilisation (réservations en cours ou payées)
+	if promo.MaxRedemptions != nil || promo.MaxRedemptionsPerUser != nil {
+		var total, byUser int
+		err := tx.QueryRow(`
+			SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2)
+			FROM reservations
+			WHERE promo_code_id = $1 AND status IN ('pending', 'paid')
+		`, promo.ID, reservation.UserID).Scan(&total, &byUser)
+		if err != nil {
+			return fmt.Errorf("error counting promo code redemptions: %w", err)
+		}
+
+		if promo.MaxRedemptions != nil && total >= *promo.MaxRedemptions {
+			return errors.New("promo code has reached its redemption limit")
+		}
+		if promo.MaxRedemptionsPerUser != nil && byUser >= *promo.MaxRedemptionsPerUser {
+			return errors.New("promo code already used the maximum number of times")
+		}
+	}
+
+	// 3. Calcul de la réduction (en unités mineures, arrondi au plus proche)
+	discount := promo.DiscountValue
+	if promo.DiscountType == models.DiscountTypePercent {
+		discount = (reservation.TotalPrice*promo.DiscountValue + 50) / 100
+	}
+	if discount >= reservation.TotalPrice {
+		return errors.New("promo code cannot cover the full order amount")
+	}
+
+	reservation.TotalPrice -= discount
+	reservation.DiscountAmount = discount
+	reservation.PromoCodeID = &promo.ID
+	reservation.PromoCode = promo.Code
+
+	log.Printf("🏷️  Promo code %s applied: -%s", promo.Code, models.FormatAmount(discount, reservation.Currency))
+	return nil
+}
+
+// ========= ADMINISTRATION =========
+
+// validatePromoCode normalise et vérifie un code avant création ou mise à jour
+func validatePromoCode(p *models.PromoCode) error {
+	p.Code = NormalizePromoCode(p.Code)
+	if p.Code == "" || len(p.Code) > 50 {
+		return errors.New("code is required (max 50 characters)")
+	}
+	for _, c := range p.Code {
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+			return errors.New("code may only contain letters, digits, '-' and '_'")
+		}
+	}
+
+	switch p.DiscountType {
+	case models.DiscountTypePercent:
+		if p.DiscountValue < 1 || p.DiscountValue > 99 {
+			return errors.New("percent discount_value must be between 1 and 99")
+		}
+		p.Currency = ""
+	case models.DiscountTypeFixed:
+		if p.DiscountValue <= 0 {
+			return errors.New("fixed discount_value must be greater than 0")
+		}
+		p.Currency = models.NormalizeCurrency(p.Currency)
+		if !models.IsValidCurrency(p.Currency) {
+			return errors.New("invalid currency")
+		}
+	default:
+		return errors.New("discount_type must be 'percent' or 'fixed'")
+	}
+
+	if p.MinQuantity == 0 {
+		p.MinQuantity = 1
+	}
+	if p.MinQuantity < 0 {
+		return errors.New("min_quantity must be greater than 0")
+	}
+	if p.MaxRedemptions != nil && *p.MaxRedemptions <= 0 {
+		return errors.New("max_redemptions must be greater than 0")
+	}
+	if p.MaxRedemptionsPerUser != nil && *p.MaxRedemptionsPerUser <= 0 {
+		return errors.New("max_redemptions_per_user must be greater than 0")
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.StartsAt.Before(*p.EndsAt) {
+		return errors.New("starts_at must be before ends_at")
+	}
+	if p.ConcertID != nil {
+		if _, err := GetConcertByID(*p.ConcertID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPromoCodes retourne tous les codes, les plus récents d'abord
+func ListPromoCodes() ([]models.PromoCode, error) {
+	rows, err := database.DB.Query(`SELECT ` + promoCodeColumns + ` FROM promo_codes ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching promo codes: %w", err)
+	}
+	defer rows.Close()
+
+	codes := []models.PromoCode{}
+	for rows.Next() {
+		p, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning promo code: %w", err)
+		}
+		codes = append(codes, *p)
+	}
+	return codes, rows.Err()
+}
+
+// GetPromoCode retourne un code par son ID
+func GetPromoCode(id int) (*models.PromoCode, error) {
+	p, err := scanPromoCode(database.DB.QueryRow(`SELECT `+promoCodeColumns+` FROM promo_codes WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrPromoCodeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching promo code: %w", err)
+	}
+	return p, nil
+}
+
+// CreatePromoCode enregistre un nouveau code
+func CreatePromoCode(p models.PromoCode) (*models.PromoCode, error) {
+	if err := validatePromoCode(&p); err != nil {
+		return nil, err
+	}
+
+	created, err := scanPromoCode(database.DB.QueryRow(`
+		INSERT INTO promo_codes
+			(code, description, discount_type, discount_value, currency, concert_id,
+			 max_redemptions, max_redemptions_per_user, min_quantity, starts_at, ends_at, active)
+		VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11, $12)
+		RETURNING `+promoCodeColumns,
+		p.Code, p.Description, p.DiscountType, p.DiscountValue, p.Currency, p.ConcertID,
+		p.MaxRedemptions, p.MaxRedemptionsPerUser, p.MinQuantity, p.StartsAt, p.EndsAt, p.Active))
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("promo code %s already exists", p.Code)
+		}
+		return nil, fmt.Errorf("error creating promo code: %w", err)
+	}
+	return created, nil
+}
+
+// UpdatePromoCode remplace la configuration d'un code existant. Les réservations
+// déjà créées gardent la réduction obtenue.
+func UpdatePromoCode(id int, p models.PromoCode) (*models.PromoCode, error) {
+	if err := validatePromoCode(&p); err != nil {
+		return nil, err
+	}
+
+	updated, err := scanPromoCode(database.DB.QueryRow(`
+		UPDATE promo_codes
+		SET code = $1, description = NULLIF($2, ''), discount_type = $3, discount_value = $4,
+		    currency = NULLIF($5, ''), concert_id = $6, max_redemptions = $7,
+		    max_redemptions_per_user = $8, min_quantity = $9, starts_at = $10, ends_at = $11,
+		    active = $12, updated_at = NOW()
+		WHERE id = $13
+		RETURNING `+promoCodeColumns,
+		p.Code, p.Description, p.DiscountType, p.DiscountValue, p.Currency, p.ConcertID,
+		p.MaxRedemptions, p.MaxRedemptionsPerUser, p.MinQuantity, p.StartsAt, p.EndsAt, p.Active, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrPromoCodeNotFound
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("promo code %s already exists", p.Code)
+		}
+		return nil, fmt.Errorf("error updating promo code: %w", err)
+	}
+	return updated, nil
+}
+
+// DeletePromoCode supprime un code jamais utilisé ; un code déjà utilisé est
+// seulement désactivé pour conserver l'historique des réservations.
+func DeletePromoCode(id int) (deleted bool, err error) {
+	result, err := database.DB.Exec(`
+		DELETE FROM promo_codes
+		WHERE id = $1
+		AND NOT EXISTS (SELECT 1 FROM reservations WHERE promo_code_id = $1)
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("error deleting promo code: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+		return true, nil
+	}
+
+	result, err = database.DB.Exec(`
+		UPDATE promo_codes SET active = FALSE, updated_at = NOW() WHERE id = $1
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("error deactivating promo code: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return false, ErrPromoCodeNotFound
+	}
+	return false, nil
+}
+
+// GetPromoCodeStats résume les utilisations d'un code
+func GetPromoCodeStats(id int) (*models.PromoCodeStats, error) {
+	promo, err := GetPromoCode(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.PromoCodeStats{
+		PromoCodeID:        promo.ID,
+		Code:               promo.Code,
+		DiscountByCurrency: make(map[string]int64),
+		RevenueByCurrency:  make(map[string]int64),
+	}
+
+	err = database.DB.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE status = 'paid'),
+		       COUNT(*) FILTER (WHERE status = 'pending'),
+		       COUNT(DISTINCT user_id) FILTER (WHERE status = 'paid'),
+		       COALESCE(SUM(quantity) FILTER (WHERE status = 'paid'), 0)
+		FROM reservations
+		WHERE promo_code_id = $1
+	`, id).Scan(&stats.Redemptions, &stats.PendingRedemptions, &stats.UniqueUsers, &stats.TicketsSold)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching promo code stats: %w", err)
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT currency, SUM(discount_amount), SUM(total_price)
+		FROM reservations
+		WHERE promo_code_id = $1 AND status = 'paid'
+		GROUP BY currency
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching promo code revenue: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var currency string
+		var discount, revenue int64
+		if err := rows.Scan(&currency, &discount, &revenue); err != nil {
+			return nil, fmt.Errorf("error scanning promo code revenue: %w", err)
+		}
+		stats.DiscountByCurrency[currency] = discount
+		stats.RevenueByCurrency[currency] = revenue
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if promo.MaxRedemptions != nil {
+		remaining := *promo.MaxRedemptions - stats.Redemptions - stats.PendingRedemptions
+		if remaining < 0 {
+			remaining = 0
+		}
+		stats.RemainingRedemptions = &remaining
+	}
+
+	return stats, nil
+}
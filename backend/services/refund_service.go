@@ -0,0 +1,488 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/models"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/refund"
+)
+
+// ========= REMBOURSEMENTS =========
+
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrRefundInProgress    = errors.New("a refund of this reservation is already in progress")
+)
+
+// validRefundReasons liste les motifs acceptés par l'API Stripe
+var validRefundReasons = map[string]bool{
+	string(stripe.RefundReasonDuplicate):           true,
+	string(stripe.RefundReasonFraudulent):          true,
+	string(stripe.RefundReasonRequestedByCustomer): true,
+}
+
+// RefundReservation rembourse tout ou partie d'une réservation payée via Stripe.
+// amount est en unités mineures de la devise de la réservation ; 0 rembourse le reliquat. Une fois le
+// remboursement total abouti chez Stripe, la réservation passe en 'refunded' et ses billets retournent
+// dans le stock du bon tarif. Un remboursement encore 'pending' est suivi par webhook (SyncRefund).
+func RefundReservation(reservationID int, amount int64, reason string) (*models.Refund, error) {
+	if amount < 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	if reason != "" && !validRefundReasons[reason] {
+		return nil, errors.New("reason must be 'duplicate', 'fraudulent' or 'requested_by_customer'")
+	}
+
+	// 1. Réserver la tentative : elle bloque un double clic le temps de l'appel à Stripe
+	attempt, err := startRefundAttempt(reservationID, amount, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Créer le remboursement chez Stripe
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(attempt.paymentIntentID),
+		Amount:        stripe.Int64(attempt.amount),
+		Metadata: map[string]string{
+			"reservation_id": strconv.Itoa(reservationID),
+		},
+	}
+	if reason != "" {
+		params.Reason = stripe.String(reason)
+	}
+	// Une relance réseau de cette tentative ne rembourse pas deux fois ; une
+	// nouvelle demande après un échec obtient une nouvelle clé
+	params.SetIdempotencyKey(fmt.Sprintf("refund-%d-%d", reservationID, attempt.id))
+
+	re, err := refund.New(params)
+	if err != nil {
+		abandonRefundAttempt(attempt.id)
+		return nil, fmt.Errorf("stripe refund failed: %w", err)
+	}
+
+	// 3. Enregistrer le remboursement et mettre à jour la réservation
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	saved, err := completeRefundAttempt(tx, attempt.id, reservationID, re)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syncReservationRefundState(tx, reservationID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("💰 Reservation #%d refunded: %s (Stripe refund %s, status %s)",
+		reservationID, models.FormatAmount(attempt.amount, attempt.currency), re.ID, re.Status)
+	return saved, nil
+}
+
+// refundAttempt est une ligne refunds créée avant l'appel à Stripe
+type refundAttempt struct {
+	id              int
+	paymentIntentID string
+	amount          int64
+	currency        string
+}
+
+// refundAttemptTimeout : passé ce délai, une tentative restée sans réponse
+// (processus arrêté pendant l'appel à Stripe) ne bloque plus les suivantes
+const refundAttemptTimeout = 10 * time.Minute
+
+// startRefundAttempt vérifie la réservation et le montant remboursable, puis
+// enregistre la tentative en 'pending' sans stripe_refund_id
+func startRefundAttempt(reservationID int, amount int64, reason string) (*refundAttempt, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Le verrou sérialise les demandes concurrentes sur la réservation
+	var status string
+	var totalPrice int64
+	attempt := &refundAttempt{}
+	err = tx.QueryRow(`
+		SELECT COALESCE(stripe_payment_intent_id, ''), status, total_price, currency
+		FROM reservations
+		WHERE id = $1
+		FOR UPDATE
+	`, reservationID).Scan(&attempt.paymentIntentID, &status, &totalPrice, &attempt.currency)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reservation: %w", err)
+	}
+
+	if status != "paid" {
+		return nil, errors.New("can only refund paid reservations")
+	}
+	if attempt.paymentIntentID == "" {
+		return nil, errors.New("no stripe payment intent found")
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM refunds
+		WHERE reservation_id = $1 AND stripe_refund_id IS NULL
+		  AND created_at < NOW() - make_interval(secs => $2)
+	`, reservationID, refundAttemptTimeout.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear stale refund attempts: %w", err)
+	}
+
+	var inProgress bool
+	var alreadyRefunded int64
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM refunds WHERE reservation_id = $1 AND stripe_refund_id IS NULL),
+		       COALESCE((SELECT SUM(amount) FROM refunds
+		                 WHERE reservation_id = $1 AND status IN ('pending', 'requires_action', 'succeeded')), 0)
+	`, reservationID).Scan(&inProgress, &alreadyRefunded)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching refunds: %w", err)
+	}
+	if inProgress {
+		return nil, ErrRefundInProgress
+	}
+
+	refundable := totalPrice - alreadyRefunded
+	if refundable <= 0 {
+		return nil, errors.New("reservation already fully refunded")
+	}
+	if amount == 0 {
+		amount = refundable
+	}
+	if amount > refundable {
+		return nil, fmt.Errorf("amount exceeds refundable balance (%s left)", models.FormatAmount(refundable, attempt.currency))
+	}
+	attempt.amount = amount
+
+	err = tx.QueryRow(`
+		INSERT INTO refunds (reservation_id, amount, currency, status, reason, created_at, updated_at)
+		VALUES ($1, $2, $3, 'pending', NULLIF($4, ''), NOW(), NOW())
+		RETURNING id
+	`, reservationID, amount, attempt.currency, reason).Scan(&attempt.id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save refund attempt: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return attempt, nil
+}
+
+// abandonRefundAttempt supprime une tentative refusée par Stripe : la
+// réservation peut aussitôt être remboursée à nouveau
+func abandonRefundAttempt(attemptID int) {
+	_, err := database.DB.Exec(`DELETE FROM refunds WHERE id = $1 AND stripe_refund_id IS NULL`, attemptID)
+	if err != nil {
+		log.Printf("⚠️  Failed to clear refund attempt #%d: %v", attemptID, err)
+	}
+}
+
+// completeRefundAttempt rattache le remboursement Stripe à sa tentative
+func completeRefundAttempt(tx *sql.Tx, attemptID, reservationID int, re *stripe.Refund) (*models.Refund, error) {
+	// Le webhook a pu enregistrer le remboursement avant la réponse de Stripe
+	if _, err := tx.Exec(`DELETE FROM refunds WHERE stripe_refund_id = $1 AND id <> $2`, re.ID, attemptID); err != nil {
+		return nil, fmt.Errorf("failed to save refund: %w", err)
+	}
+
+	var saved models.Refund
+	err := tx.QueryRow(`
+		UPDATE refunds
+		SET stripe_refund_id = $1, amount = $2, status = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING id, reservation_id, stripe_refund_id, amount, currency, status,
+		          COALESCE(reason, ''), created_at, updated_at
+	`, re.ID, re.Amount, string(re.Status), attemptID).Scan(
+		&saved.ID, &saved.ReservationID, &saved.StripeRefundID, &saved.Amount, &saved.Currency,
+		&saved.Status, &saved.Reason, &saved.CreatedAt, &saved.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		// Tentative expirée entre-temps
+		return upsertRefund(tx, reservationID, re)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save refund: %w", err)
+	}
+	return &saved, nil
+}
+
+// GetReservationRefunds liste les remboursements d'une réservation
+func GetReservationRefunds(reservationID int) ([]models.Refund, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, reservation_id, COALESCE(stripe_refund_id, ''), amount, currency, status,
+		       COALESCE(reason, ''), created_at, updated_at
+		FROM refunds
+		WHERE reservation_id = $1
+		ORDER BY created_at
+	`, reservationID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching refunds: %w", err)
+	}
+	defer rows.Close()
+
+	refunds := []models.Refund{}
+	for rows.Next() {
+		var r models.Refund
+		if err := rows.Scan(&r.ID, &r.ReservationID, &r.StripeRefundID, &r.Amount, &r.Currency,
+			&r.Status, &r.Reason, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning refund: %w", err)
+		}
+		refunds = append(refunds, r)
+	}
+
+	return refunds, rows.Err()
+}
+
+// ========= SYNCHRONISATION (Webhooks) =========
+
+// SyncRefund enregistre l'état d'un remboursement notifié par Stripe
+// (charge.refund.updated), y compris ceux créés depuis le dashboard Stripe
+func SyncRefund(re *stripe.Refund) error {
+	if re.PaymentIntent == nil || re.PaymentIntent.ID == "" {
+		log.Printf("⚠️  Refund %s has no payment intent, skipping", re.ID)
+		return nil
+	}
+
+	reservationID, err := reservationIDForRefund(re, re.PaymentIntent.ID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := upsertRefund(tx, reservationID, re); err != nil {
+		return err
+	}
+
+	if err := syncReservationRefundState(tx, reservationID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SyncChargeRefunded traite charge.refunded : enregistre chaque remboursement
+// de la charge et met à jour les seules réservations qu'ils concernent
+func SyncChargeRefunded(charge *stripe.Charge) error {
+	if charge.PaymentIntent == nil || charge.PaymentIntent.ID == "" {
+		log.Printf("⚠️  Charge %s has no payment intent, skipping", charge.ID)
+		return nil
+	}
+
+	// Une session Checkout regroupe plusieurs réservations sur un même paiement
+	reservationIDs, err := reservationIDsForPaymentIntent(charge.PaymentIntent.ID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Seules les réservations visées par un remboursement changent : un
+	// remboursement partiel ne touche pas les autres réservations du paiement
+	var refunds []*stripe.Refund
+	if charge.Refunds != nil {
+		refunds = charge.Refunds.Data
+	}
+	allSucceeded := len(refunds) > 0
+	refunded := map[int]bool{}
+	for _, re := range refunds {
+		reservationID, err := reservationIDForRefund(re, charge.PaymentIntent.ID)
+		if err != nil {
+			return err
+		}
+		if _, err := upsertRefund(tx, reservationID, re); err != nil {
+			return err
+		}
+		refunded[reservationID] = true
+		allSucceeded = allSucceeded && re.Status == stripe.RefundStatusSucceeded
+	}
+
+	for _, reservationID := range reservationIDs {
+		switch {
+		case charge.Refunded && allSucceeded:
+			// La charge entière est rendue, y compris par un remboursement
+			// sans reservation_id créé depuis le dashboard Stripe
+			err = markReservationRefunded(tx, reservationID)
+		case refunded[reservationID]:
+			err = syncReservationRefundState(tx, reservationID)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// reservationIDForRefund rattache un remboursement à sa réservation : via les
+// metadata s'il a été créé par RefundReservation, sinon la première du paiement
+func reservationIDForRefund(re *stripe.Refund, paymentIntentID string) (int, error) {
+	if id, err := strconv.Atoi(re.Metadata["reservation_id"]); err == nil {
+		return id, nil
+	}
+
+	reservationIDs, err := reservationIDsForPaymentIntent(paymentIntentID)
+	if err != nil {
+		return 0, err
+	}
+	return reservationIDs[0], nil
+}
+
+func reservationIDsForPaymentIntent(paymentIntentID string) ([]int, error) {
+	rows, err := database.DB.Query(`
+		SELECT id FROM reservations WHERE stripe_payment_intent_id = $1 ORDER BY id
+	`, paymentIntentID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reservation: %w", err)
+	}
+	defer rows.Close()
+
+	var reservationIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning reservation: %w", err)
+		}
+		reservationIDs = append(reservationIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(reservationIDs) == 0 {
+		return nil, fmt.Errorf("no reservation for payment intent %s: %w", paymentIntentID, ErrReservationNotFound)
+	}
+	return reservationIDs, nil
+}
+
+// upsertRefund crée ou met à jour la ligne refunds correspondant au remboursement Stripe
+func upsertRefund(tx *sql.Tx, reservationID int, re *stripe.Refund) (*models.Refund, error) {
+	var saved models.Refund
+	err := tx.QueryRow(`
+		INSERT INTO refunds (reservation_id, stripe_refund_id, amount, currency, status, reason, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NOW(), NOW())
+		ON CONFLICT (stripe_refund_id) DO UPDATE
+		SET status = EXCLUDED.status, amount = EXCLUDED.amount, updated_at = NOW()
+		RETURNING id, reservation_id, stripe_refund_id, amount, currency, status,
+		          COALESCE(reason, ''), created_at, updated_at
+	`, reservationID, re.ID, re.Amount, string(re.Currency), string(re.Status), string(re.Reason)).Scan(
+		&saved.ID, &saved.ReservationID, &saved.StripeRefundID, &saved.Amount, &saved.Currency,
+		&saved.Status, &saved.Reason, &saved.CreatedAt, &saved.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save refund: %w", err)
+	}
+	return &saved, nil
+}
+
+// syncReservationRefundState aligne la réservation sur ses remboursements aboutis.
+// Seuls les remboursements 'succeeded' comptent : tant que Stripe annonce un
+// remboursement 'pending', la réservation et ses billets restent valides.
+func syncReservationRefundState(tx *sql.Tx, reservationID int) error {
+	var totalPrice, refunded int64
+	err := tx.QueryRow(`
+		SELECT r.total_price,
+		       COALESCE((SELECT SUM(amount) FROM refunds
+		                 WHERE reservation_id = r.id AND status = 'succeeded'), 0)
+		FROM reservations r
+		WHERE r.id = $1
+		FOR UPDATE
+	`, reservationID).Scan(&totalPrice, &refunded)
+	if err == sql.ErrNoRows {
+		return ErrReservationNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching reservation: %w", err)
+	}
+
+	switch {
+	case refunded == 0:
+		return nil
+	case refunded >= totalPrice:
+		return markReservationRefunded(tx, reservationID)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE reservations
+		SET payment_status = 'partially_refunded', updated_at = NOW()
+		WHERE id = $1 AND status = 'paid'
+	`, reservationID)
+	if err != nil {
+		return fmt.Errorf("failed to update reservation status: %w", err)
+	}
+	return nil
+}
+
+// markReservationRefunded passe une réservation payée en 'refunded' et remet
+// ses billets en stock. Sans effet si elle est déjà remboursée.
+func markReservationRefunded(tx *sql.Tx, reservationID int) error {
+	var concertID, quantity int
+	var ticketType string
+
+	err := tx.QueryRow(`
+		UPDATE reservations
+		SET status = 'refunded',
+		    payment_status = 'refunded',
+		    stripe_payment_status = 'refunded',
+		    updated_at = NOW()
+		WHERE id = $1 AND status = 'paid'
+		RETURNING concert_id, ticket_type, quantity
+	`, reservationID).Scan(&concertID, &ticketType, &quantity)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update reservation status: %w", err)
+	}
+
+	if err := voidTickets(tx, reservationID); err != nil {
+		return err
+	}
+
+	column, err := stockColumn(ticketType)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE concerts
+		SET `+column+` = `+column+` + $1
+		WHERE id = $2
+	`, quantity, concertID)
+	if err != nil {
+		return fmt.Errorf("failed to restore ticket stock: %w", err)
+	}
+
+	log.Printf("💰 Reservation #%d fully refunded - %d %s tickets restored", reservationID, quantity, ticketType)
+	return nil
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stripe/stripe-go/v76"
+
+	"groupie-backend/database/dbtest"
+)
+
+// stubRefunds répond aux créations de remboursement avec le statut donné,
+// comme Stripe : même montant, mêmes metadata, un nouvel ID à chaque appel.
+func stubRefunds(stub *stripeStub, status stripe.RefundStatus) {
+	count := 0
+	stub.handle("POST /v1/refunds", func(params stripe.ParamsContainer, v stripe.LastResponseSetter) error {
+		p := params.(*stripe.RefundParams)
+		count++
+		*v.(*stripe.Refund) = stripe.Refund{
+			ID:            fmt.Sprintf("re_test_%d", count),
+			Amount:        *p.Amount,
+			Currency:      "eur",
+			Status:        status,
+			Metadata:      p.Metadata,
+			PaymentIntent: &stripe.PaymentIntent{ID: *p.PaymentIntent},
+		}
+		return nil
+	})
+}
+
+func TestRefundReservationWaitsForSucceeded(t *testing.T) {
+	db := dbtest.Open(t)
+	stub := useStripeStub(t)
+	stubRefunds(stub, stripe.RefundStatusPending)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 8, 0)
+	reservationID := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_test_1")
+	insertTestTicket(t, db, reservationID, concertID)
+
+	saved, err := RefundReservation(reservationID, 0, "requested_by_customer")
+	if err != nil {
+		t.Fatalf("RefundReservation: %v", err)
+	}
+	if saved.Status != "pending" || saved.Amount != 10000 {
+		t.Errorf("saved refund = %s %d, want pending 10000", saved.Status, saved.Amount)
+	}
+
+	// Stripe n'a pas encore rendu l'argent : la réservation reste payée
+	assertReservation(t, db, reservationID, "paid", "succeeded")
+	assertStock(t, db, concertID, 8, 0)
+	assertTicketStatus(t, db, reservationID, "valid")
+
+	// Le remboursement en attente bloque un second remboursement
+	if _, err := RefundReservation(reservationID, 0, ""); err == nil {
+		t.Error("second refund of a pending full refund succeeded, want an error")
+	}
+	if calls := stub.callsTo("POST /v1/refunds"); len(calls) != 1 {
+		t.Errorf("stripe refunds created = %d, want 1", len(calls))
+	}
+
+	// charge.refund.updated annonce que le remboursement a abouti
+	err = SyncRefund(&stripe.Refund{
+		ID: saved.StripeRefundID, Amount: 10000, Currency: "eur", Status: stripe.RefundStatusSucceeded,
+		Metadata:      map[string]string{"reservation_id": strconv.Itoa(reservationID)},
+		PaymentIntent: &stripe.PaymentIntent{ID: "pi_test_1"},
+	})
+	if err != nil {
+		t.Fatalf("SyncRefund: %v", err)
+	}
+	assertReservation(t, db, reservationID, "refunded", "refunded")
+	assertStock(t, db, concertID, 10, 0)
+	assertTicketStatus(t, db, reservationID, "void")
+}
+
+func TestRefundReservationPartialThenFull(t *testing.T) {
+	db := dbtest.Open(t)
+	stub := useStripeStub(t)
+	stubRefunds(stub, stripe.RefundStatusSucceeded)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 8, 0)
+	reservationID := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_test_1")
+
+	partial, err := RefundReservation(reservationID, 3000, "")
+	if err != nil {
+		t.Fatalf("partial RefundReservation: %v", err)
+	}
+	assertReservation(t, db, reservationID, "paid", "partially_refunded")
+	assertStock(t, db, concertID, 8, 0)
+
+	if _, err := RefundReservation(reservationID, 8000, ""); err == nil {
+		t.Error("refund above the remaining balance succeeded, want an error")
+	}
+
+	// 0 rembourse le reliquat
+	final, err := RefundReservation(reservationID, 0, "")
+	if err != nil {
+		t.Fatalf("final RefundReservation: %v", err)
+	}
+	assertReservation(t, db, reservationID, "refunded", "refunded")
+	assertStock(t, db, concertID, 10, 0)
+
+	calls := stub.callsTo("POST /v1/refunds")
+	if len(calls) != 2 {
+		t.Fatalf("stripe refunds created = %d, want 2", len(calls))
+	}
+	// Chaque tentative a sa ligne refunds, dont l'id fait la clé
+	wantKeys := []string{
+		fmt.Sprintf("refund-%d-%d", reservationID, partial.ID),
+		fmt.Sprintf("refund-%d-%d", reservationID, final.ID),
+	}
+	for i, call := range calls {
+		p := call.Params.(*stripe.RefundParams)
+		if call.IdempotencyKey != wantKeys[i] {
+			t.Errorf("refund %d idempotency key = %q, want %q", i, call.IdempotencyKey, wantKeys[i])
+		}
+		if p.Metadata["reservation_id"] != strconv.Itoa(reservationID) || *p.PaymentIntent != "pi_test_1" {
+			t.Errorf("refund %d sent for %v / %s", i, p.Metadata, *p.PaymentIntent)
+		}
+	}
+}
+
+func TestRefundReservationStripeFailureChangesNothing(t *testing.T) {
+	db := dbtest.Open(t)
+	stub := useStripeStub(t)
+	stub.handle("POST /v1/refunds", func(stripe.ParamsContainer, stripe.LastResponseSetter) error {
+		return &stripe.Error{Type: stripe.ErrorTypeInvalidRequest, Msg: "charge already refunded"}
+	})
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 8, 0)
+	reservationID := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_test_1")
+
+	if _, err := RefundReservation(reservationID, 0, ""); err == nil {
+		t.Fatal("RefundReservation succeeded despite the Stripe error")
+	}
+	assertReservation(t, db, reservationID, "paid", "succeeded")
+	assertStock(t, db, concertID, 8, 0)
+	assertRefundRows(t, db, reservationID, 0)
+}
+
+func TestRefundRetryAfterStripeFailureUsesNewKey(t *testing.T) {
+	db := dbtest.Open(t)
+	stub := useStripeStub(t)
+	failed := false
+	stub.handle("POST /v1/refunds", func(params stripe.ParamsContainer, v stripe.LastResponseSetter) error {
+		if !failed {
+			failed = true
+			return &stripe.Error{Type: stripe.ErrorTypeAPI, Msg: "temporary failure"}
+		}
+		p := params.(*stripe.RefundParams)
+		*v.(*stripe.Refund) = stripe.Refund{ID: "re_test_1", Amount: *p.Amount, Currency: "eur", Status: stripe.RefundStatusSucceeded}
+		return nil
+	})
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 8, 0)
+	reservationID := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_test_1")
+
+	if _, err := RefundReservation(reservationID, 0, ""); err == nil {
+		t.Fatal("RefundReservation succeeded despite the Stripe error")
+	}
+	// Même montant : sans nouvelle clé, Stripe rejouerait l'échec en cache
+	if _, err := RefundReservation(reservationID, 0, ""); err != nil {
+		t.Fatalf("retried RefundReservation: %v", err)
+	}
+	assertReservation(t, db, reservationID, "refunded", "refunded")
+	assertRefundRows(t, db, reservationID, 1)
+
+	calls := stub.callsTo("POST /v1/refunds")
+	if len(calls) != 2 {
+		t.Fatalf("stripe refunds created = %d, want 2", len(calls))
+	}
+	if calls[0].IdempotencyKey == calls[1].IdempotencyKey {
+		t.Errorf("retry reused the idempotency key %q", calls[0].IdempotencyKey)
+	}
+}
+
+func TestRefundReservationRefusesConcurrentAttempt(t *testing.T) {
+	db := dbtest.Open(t)
+	stub := useStripeStub(t)
+	stubRefunds(stub, stripe.RefundStatusSucceeded)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 8, 0)
+	reservationID := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_test_1")
+
+	// Tentative en cours : Stripe n'a pas encore répondu au premier clic
+	var attemptID int
+	err := db.QueryRow(`
+		INSERT INTO refunds (reservation_id, amount, currency, status)
+		VALUES ($1, 3000, 'eur', 'pending')
+		RETURNING id
+	`, reservationID).Scan(&attemptID)
+	if err != nil {
+		t.Fatalf("insert refund attempt: %v", err)
+	}
+
+	if _, err := RefundReservation(reservationID, 3000, ""); !errors.Is(err, ErrRefundInProgress) {
+		t.Errorf("RefundReservation during an attempt = %v, want ErrRefundInProgress", err)
+	}
+	if calls := stub.callsTo("POST /v1/refunds"); len(calls) != 0 {
+		t.Errorf("stripe refunds created = %d, want 0", len(calls))
+	}
+
+	// Une tentative restée sans réponse expire
+	if _, err := db.Exec(`UPDATE refunds SET created_at = NOW() - INTERVAL '1 hour' WHERE id = $1`, attemptID); err != nil {
+		t.Fatalf("age refund attempt: %v", err)
+	}
+	if _, err := RefundReservation(reservationID, 3000, ""); err != nil {
+		t.Fatalf("RefundReservation after a stale attempt: %v", err)
+	}
+	assertReservation(t, db, reservationID, "paid", "partially_refunded")
+	assertRefundRows(t, db, reservationID, 1)
+}
+
+func TestChargeRefundedPartialOnlyTouchesRefundedReservation(t *testing.T) {
+	db := dbtest.Open(t)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 6, 0)
+	// Une session Checkout : deux réservations, un seul paiement
+	first := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_checkout")
+	second := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_checkout")
+
+	err := SyncChargeRefunded(&stripe.Charge{
+		ID: "ch_test", PaymentIntent: &stripe.PaymentIntent{ID: "pi_checkout"},
+		AmountRefunded: 4000,
+		Refunds: &stripe.RefundList{Data: []*stripe.Refund{{
+			ID: "re_partial", Amount: 4000, Currency: "eur", Status: stripe.RefundStatusSucceeded,
+			Metadata: map[string]string{"reservation_id": strconv.Itoa(second)},
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("SyncChargeRefunded: %v", err)
+	}
+
+	assertReservation(t, db, first, "paid", "succeeded")
+	assertReservation(t, db, second, "paid", "partially_refunded")
+}
+
+func TestChargeRefundedIgnoresPendingRefunds(t *testing.T) {
+	db := dbtest.Open(t)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 8, 0)
+	reservationID := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_test_1")
+
+	charge := &stripe.Charge{
+		ID: "ch_test", PaymentIntent: &stripe.PaymentIntent{ID: "pi_test_1"},
+		Refunded: true, AmountRefunded: 10000,
+		Refunds: &stripe.RefundList{Data: []*stripe.Refund{{
+			ID: "re_full", Amount: 10000, Currency: "eur", Status: stripe.RefundStatusPending,
+			Metadata: map[string]string{"reservation_id": strconv.Itoa(reservationID)},
+		}}},
+	}
+	if err := SyncChargeRefunded(charge); err != nil {
+		t.Fatalf("SyncChargeRefunded: %v", err)
+	}
+	assertReservation(t, db, reservationID, "paid", "succeeded")
+	assertStock(t, db, concertID, 8, 0)
+
+	charge.Refunds.Data[0].Status = stripe.RefundStatusSucceeded
+	if err := SyncChargeRefunded(charge); err != nil {
+		t.Fatalf("SyncChargeRefunded: %v", err)
+	}
+	assertReservation(t, db, reservationID, "refunded", "refunded")
+	assertStock(t, db, concertID, 10, 0)
+}
+
+func TestChargeFullyRefundedFromDashboardRefundsEveryReservation(t *testing.T) {
+	db := dbtest.Open(t)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 6, 0)
+	first := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_checkout")
+	second := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_checkout")
+
+	// Remboursé depuis le dashboard Stripe : pas de reservation_id en metadata
+	err := SyncChargeRefunded(&stripe.Charge{
+		ID: "ch_test", PaymentIntent: &stripe.PaymentIntent{ID: "pi_checkout"},
+		Refunded: true, AmountRefunded: 20000,
+		Refunds: &stripe.RefundList{Data: []*stripe.Refund{{
+			ID: "re_dashboard", Amount: 20000, Currency: "eur", Status: stripe.RefundStatusSucceeded,
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("SyncChargeRefunded: %v", err)
+	}
+
+	assertReservation(t, db, first, "refunded", "refunded")
+	assertReservation(t, db, second, "refunded", "refunded")
+	assertStock(t, db, concertID, 10, 0)
+}
+
+// insertPaidReservation crée une réservation déjà payée ; le stock du concert
+// est supposé déjà décompté.
+func insertPaidReservation(t *testing.T, db *sql.DB, userID, concertID, quantity int, totalPrice int64, paymentIntentID string) int {
+	t.Helper()
+
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO reservations
+			(user_id, concert_id, ticket_type, quantity, total_price, currency, status,
+			 payment_status, stripe_payment_intent_id, stripe_payment_status, expires_at)
+		VALUES ($1, $2, 'standard', $3, $4, 'eur', 'paid', 'succeeded', $5, 'succeeded', NOW())
+		RETURNING id
+	`, userID, concertID, quantity, totalPrice, paymentIntentID).Scan(&id)
+	if err != nil {
+		t.Fatalf("insert reservation: %v", err)
+	}
+	return id
+}
+
+func insertTestTicket(t *testing.T, db *sql.DB, reservationID, concertID int) {
+	t.Helper()
+
+	_, err := db.Exec(`
+		INSERT INTO tickets (reservation_id, concert_id, ticket_type, code)
+		VALUES ($1, $2, 'standard', $3)
+	`, reservationID, concertID, fmt.Sprintf("TEST%d", reservationID))
+	if err != nil {
+		t.Fatalf("insert ticket: %v", err)
+	}
+}
+
+func assertReservation(t *testing.T, db *sql.DB, reservationID int, wantStatus, wantPaymentStatus string) {
+	t.Helper()
+
+	var status, paymentStatus string
+	err := db.QueryRow(`SELECT status, COALESCE(payment_status, '') FROM reservations WHERE id = $1`, reservationID).
+		Scan(&status, &paymentStatus)
+	if err != nil {
+		t.Fatalf("read reservation: %v", err)
+	}
+	if status != wantStatus || paymentStatus != wantPaymentStatus {
+		t.Errorf("reservation #%d = %s / %s, want %s / %s",
+			reservationID, status, paymentStatus, wantStatus, wantPaymentStatus)
+	}
+}
+
+func assertRefundRows(t *testing.T, db *sql.DB, reservationID, want int) {
+	t.Helper()
+
+	var got int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM refunds WHERE reservation_id = $1`, reservationID).Scan(&got); err != nil {
+		t.Fatalf("count refunds: %v", err)
+	}
+	if got != want {
+		t.Errorf("refund rows = %d, want %d", got, want)
+	}
+}
+
+func assertTicketStatus(t *testing.T, db *sql.DB, reservationID int, want string) {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT status FROM tickets WHERE reservation_id = $1`, reservationID)
+	if err != nil {
+		t.Fatalf("read tickets: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		if err := rows.Scan(&status); err != nil {
+			t.Fatalf("scan ticket: %v", err)
+		}
+		if status != want {
+			t.Errorf("ticket of reservation #%d is %s, want %s", reservationID, status, want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/internal/auth"
+	"groupie-backend/models"
+)
+
+// ========= SESSIONS & REFRESH TOKENS =========
+//
+// Chaque connexion ouvre une session serveur (auth_sessions) dont l'ID est
+// repris dans le claim "sid" des access tokens. Le refresh token est opaque,
+// stocké haché, et change à chaque utilisation : présenter un refresh token
+// déjà consommé signifie qu'il a fuité, toute la session est alors révoquée.
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, session revoked")
+)
+
+// randomToken retourne n octets aléatoires encodés en base64 URL
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken retourne le SHA-256 hexadécimal d'un jeton : seul ce hash est stocké
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// insertRefreshToken génère un nouveau refresh token pour la session et n'en stocke que le hash
+func insertRefreshToken(tx *sql.Tx, sessionID string) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO refresh_tokens (session_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, NOW())
+	`, sessionID, hashToken(token), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return token, nil
+}
+
+func newAuthTokens(userID int, role, sessionID, refreshToken string) (*models.AuthTokens, error) {
+	accessToken, err := auth.GenerateToken(uint(userID), role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuthTokens{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// CreateSession ouvre une session pour l'utilisateur et retourne sa première paire de tokens
+func CreateSession(userID int, role, userAgent, ipAddress string) (*models.AuthTokens, error) {
+	sessionID, err := randomToken(24)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO auth_sessions (id, user_id, user_agent, ip_address, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+	`, sessionID, userID, userAgent, ipAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	refreshToken, err := insertRefreshToken(tx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit session: %w", err)
+	}
+
+	return newAuthTokens(userID, role, sessionID, refreshToken)
+}
+
+// RefreshSession consomme un refresh token et émet une nouvelle paire (rotation).
+// La ligne est verrouillée : deux rafraîchissements simultanés du même token
+// ne peuvent pas réussir tous les deux, le second est traité comme une réutilisation.
+func RefreshSession(refreshToken string) (*models.AuthTokens, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tokenID, userID int
+	var sessionID, role string
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+
+	err = tx.QueryRow(`
+		SELECT rt.id, rt.session_id, rt.expires_at, rt.used_at, s.revoked_at, s.user_id, u.role
+		FROM refresh_tokens rt
+		JOIN auth_sessions s ON s.id = rt.session_id
+		JOIN users u ON u.id = s.user_id
+		WHERE rt.token_hash = $1
+		FOR UPDATE OF rt, s
+	`, hashToken(refreshToken)).Scan(&tokenID, &sessionID, &expiresAt, &usedAt, &revokedAt, &userID, &role)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching refresh token: %w", err)
+	}
+
+	if revokedAt.Valid {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if usedAt.Valid {
+		// Token déjà consommé : quelqu'un d'autre le détient, on coupe toute la famille
+		if _, err := tx.Exec(`
+			UPDATE auth_sessions SET revoked_at = NOW(), revoke_reason = 'refresh_token_reuse'
+			WHERE id = $1
+		`, sessionID); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit session revocation: %w", err)
+		}
+		log.Printf("🚨 Refresh token reuse detected for user #%d: session revoked", userID)
+		return nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, tokenID); err != nil {
+		return nil, fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE auth_sessions SET last_used_at = NOW() WHERE id = $1`, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+
+	newRefreshToken, err := insertRefreshToken(tx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit token rotation: %w", err)
+	}
+
+	// Le rôle est relu en base : un changement de rôle prend effet au prochain rafraîchissement
+	return newAuthTokens(userID, role, sessionID, newRefreshToken)
+}
+
+// RevokeSessionByRefreshToken ferme la session à laquelle appartient le refresh token (déconnexion)
+func RevokeSessionByRefreshToken(refreshToken string) error {
+	_, err := database.DB.Exec(`
+		UPDATE auth_sessions SET revoked_at = NOW(), revoke_reason = 'logout'
+		WHERE revoked_at IS NULL
+		AND id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $1)
+	`, hashToken(refreshToken))
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeSession ferme une session par son ID
+func RevokeSession(sessionID, reason string) error {
+	_, err := database.DB.Exec(`
+		UPDATE auth_sessions SET revoked_at = NOW(), revoke_reason = $2
+		WHERE id = $1 AND revoked_at IS NULL
+	`, sessionID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSessions ferme toutes les sessions actives d'un utilisateur
+func RevokeUserSessions(userID int, reason string) (int64, error) {
+	result, err := database.DB.Exec(`
+		UPDATE auth_sessions SET revoked_at = NOW(), revoke_reason = $2
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID, reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	count, _ := result.RowsAffected()
+	log.Printf("🔒 %d session(s) revoked for user #%d (%s)", count, userID, reason)
+	return count, nil
+}
+
+// IsSessionActive indique si la session d'un access token n'a pas été révoquée
+func IsSessionActive(sessionID string) (bool, error) {
+	var active bool
+	err := database.DB.QueryRow(`
+		SELECT revoked_at IS NULL FROM auth_sessions WHERE id = $1
+	`, sessionID).Scan(&active)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking session: %w", err)
+	}
+	return active, nil
+}
+
+// CleanupExpiredSessions supprime les sessions révoquées ou inutilisées depuis
+// plus longtemps que la durée de vie d'un refresh token (leurs tokens suivent en cascade)
+func CleanupExpiredSessions() (int64, error) {
+	result, err := database.DB.Exec(`
+		DELETE FROM auth_sessions
+		WHERE revoked_at < NOW() - INTERVAL '7 days'
+		OR last_used_at < $1
+	`, time.Now().Add(-refreshTokenTTL))
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/form"
+)
+
+// stripeStub remplace l'API Stripe le temps d'un test : chaque appel est
+// enregistré puis confié au handler de sa route ("POST /v1/refunds").
+type stripeStub struct {
+	mu       sync.Mutex
+	calls    []stripeCall
+	handlers map[string]func(params stripe.ParamsContainer, v stripe.LastResponseSetter) error
+}
+
+type stripeCall struct {
+	Route          string
+	Params         stripe.ParamsContainer
+	IdempotencyKey string
+}
+
+// useStripeStub installe un stub vide comme backend API de stripe-go et
+// restaure le backend précédent à la fin du test.
+func useStripeStub(t *testing.T) *stripeStub {
+	t.Helper()
+
+	stub := &stripeStub{handlers: map[string]func(stripe.ParamsContainer, stripe.LastResponseSetter) error{}}
+	previous := stripe.GetBackend(stripe.APIBackend)
+	stripe.SetBackend(stripe.APIBackend, stub)
+	t.Cleanup(func() { stripe.SetBackend(stripe.APIBackend, previous) })
+	return stub
+}
+
+func (s *stripeStub) handle(route string, fn func(params stripe.ParamsContainer, v stripe.LastResponseSetter) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[route] = fn
+}
+
+// callsTo retourne les appels reçus sur une route, dans l'ordre.
+func (s *stripeStub) callsTo(route string) []stripeCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []stripeCall
+	for _, c := range s.calls {
+		if c.Route == route {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (s *stripeStub) Call(method, path, key string, params stripe.ParamsContainer, v stripe.LastResponseSetter) error {
+	route := method + " " + path
+
+	s.mu.Lock()
+	call := stripeCall{Route: route, Params: params}
+	if params != nil {
+		if p := params.GetParams(); p != nil && p.IdempotencyKey != nil {
+			call.IdempotencyKey = *p.IdempotencyKey
+		}
+	}
+	s.calls = append(s.calls, call)
+	fn := s.handlers[route]
+	s.mu.Unlock()
+
+	if fn == nil {
+		return fmt.Errorf("stripe stub: unexpected call %s", route)
+	}
+	return fn(params, v)
+}
+
+func (s *stripeStub) CallStreaming(method, path, key string, params stripe.ParamsContainer, v stripe.StreamingLastResponseSetter) error {
+	return errors.New("stripe stub: streaming not supported")
+}
+
+func (s *stripeStub) CallRaw(method, path, key string, body *form.Values, params *stripe.Params, v stripe.LastResponseSetter) error {
+	return errors.New("stripe stub: raw calls not supported")
+}
+
+func (s *stripeStub) CallMultipart(method, path, key, boundary string, body *bytes.Buffer, params *stripe.Params, v stripe.LastResponseSetter) error {
+	return errors.New("stripe stub: multipart calls not supported")
+}
+
+func (s *stripeStub) SetMaxNetworkRetries(maxNetworkRetries int64) {}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"groupie-backend/internal/search"
+)
+
+// ========= AUTOCOMPLÉTION =========
+
+// L'index d'autocomplétion est construit en mémoire à partir du catalogue :
+// reconstruit après chaque modification faite par l'admin de cette instance,
+// et périodiquement pour suivre celles faites sur les autres instances.
+
+const suggestRefreshInterval = 5 * time.Minute
+
+var (
+	suggestIndex   atomic.Pointer[search.SuggestIndex]
+	suggestRepo    ArtistRepository
+	suggestRebuild = make(chan struct{}, 1)
+)
+
+// StartSuggestIndex construit l'index à partir de repo puis le tient à jour
+func StartSuggestIndex(repo ArtistRepository) {
+	suggestRepo = repo
+	if err := rebuildSuggestIndex(); err != nil {
+		log.Printf("⚠️ Suggest index not built, retrying in background: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(suggestRefreshInterval)
+		for {
+			select {
+			case <-ticker.C:
+			case <-suggestRebuild:
+			}
+			if err := rebuildSuggestIndex(); err != nil {
+				log.Printf("❌ Error rebuilding suggest index: %v", err)
+			}
+		}
+	}()
+
+	log.Printf("🔎 Suggest index ready (%d entries, refreshed every %s)", SuggestIndexSize(), suggestRefreshInterval)
+}
+
+func rebuildSuggestIndex() error {
+	docs, err := suggestRepo.CatalogDocs()
+	if err != nil {
+		return err
+	}
+	suggestIndex.Store(search.NewSuggestIndex(docs))
+	return nil
+}
+
+// catalogChanged demande une reconstruction de l'index ; plusieurs
+// modifications rapprochées n'en déclenchent qu'une
+func catalogChanged() {
+	select {
+	case suggestRebuild <- struct{}{}:
+	default:
+	}
+}
+
+// Suggest retourne les suggestions dont un mot commence par query
+func Suggest(query string, limit int) []search.Suggestion {
+	ix := suggestIndex.Load()
+	if ix == nil {
+		return []search.Suggestion{}
+	}
+	return ix.Suggest(query, limit)
+}
+
+// SuggestIndexSize retourne le nombre d'entrées de l'index
+func SuggestIndexSize() int {
+	if ix := suggestIndex.Load(); ix != nil {
+		return ix.Len()
+	}
+	return 0
+}
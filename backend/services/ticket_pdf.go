@@ -0,0 +1,229 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ========= BILLETS PDF =========
+
+// reservationTickets regroupe ce qu'il faut pour imprimer ou envoyer les billets d'une réservation
+type reservationTickets struct {
+	ReservationID int
+	UserID        int
+	UserEmail     string
+	UserName      string
+	ConcertName   string
+	ArtistName    string
+	Venue         string
+	City          string
+	Date          time.Time
+	TotalPrice    int64
+	Currency      string
+	Tickets       []models.Ticket
+}
+
+// loadReservationTickets charge la réservation payée, son concert et ses billets
+func loadReservationTickets(reservationID int) (*reservationTickets, error) {
+	var rt reservationTickets
+	var status string
+
+	err := database.DB.QueryRow(`
+		SELECT r.id, COALESCE(r.user_id, 0), COALESCE(u.email, ''), `+adminUserName+`,
+		       c.name, c.artist_name, c.venue, c.city, c.date, r.total_price, r.currency, r.status
+		FROM reservations r
+		JOIN concerts c ON c.id = r.concert_id
+		LEFT JOIN users u ON u.id = r.user_id
+		WHERE r.id = $1
+	`, reservationID).Scan(&rt.ReservationID, &rt.UserID, &rt.UserEmail, &rt.UserName,
+		&rt.ConcertName, &rt.ArtistName, &rt.Venue, &rt.City, &rt.Date, &rt.TotalPrice, &rt.Currency, &status)
+	if err == sql.ErrNoRows {
+		return nil, ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reservation: %w", err)
+	}
+	if status != "paid" {
+		return nil, fmt.Errorf("reservation #%d is not paid", reservationID)
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT `+ticketColumns+`
+		FROM tickets t
+		LEFT JOIN concerts c ON c.id = t.concert_id
+		WHERE t.reservation_id = $1
+		ORDER BY t.id
+	`, reservationID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tickets: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTicket(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning ticket: %w", err)
+		}
+		rt.Tickets = append(rt.Tickets, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(rt.Tickets) == 0 {
+		return nil, fmt.Errorf("no tickets issued for reservation #%d", reservationID)
+	}
+
+	return &rt, nil
+}
+
+// GetReservationTicketsPDF retourne le PDF des billets d'une réservation.
+// Seul le propriétaire (ou un admin) peut le télécharger.
+func GetReservationTicketsPDF(reservationID, userID int, isAdmin bool) ([]byte, error) {
+	rt, err := loadReservationTickets(reservationID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin && rt.UserID != userID {
+		return nil, ErrReservationNotFound
+	}
+	return renderTicketsPDF(rt)
+}
+
+// renderTicketsPDF dessine une page A4 par billet : concert, lieu, date, tarif, code et QR
+func renderTicketsPDF(rt *reservationTickets) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(fmt.Sprintf("Billets - %s", rt.ConcertName), true)
+	pdf.SetAuthor("YNOT", true)
+
+	// Les polices standard sont en cp1252 : conversion des accents UTF-8
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	for i, ticket := range rt.Tickets {
+		pdf.AddPage()
+
+		// Bandeau
+		pdf.SetFillColor(255, 71, 87)
+		pdf.Rect(0, 0, 210, 30, "F")
+		pdf.SetTextColor(255, 255, 255)
+		pdf.SetFont("Helvetica", "B", 22)
+		pdf.SetXY(15, 9)
+		pdf.CellFormat(120, 12, "YNOT", "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.SetXY(120, 9)
+		pdf.CellFormat(75, 12, tr(fmt.Sprintf("Billet %d / %d", i+1, len(rt.Tickets))), "", 0, "R", false, 0, "")
+
+		// Concert
+		pdf.SetTextColor(20, 20, 20)
+		pdf.SetXY(15, 42)
+		pdf.SetFont("Helvetica", "B", 20)
+		pdf.MultiCell(180, 9, tr(rt.ConcertName), "", "L", false)
+		pdf.SetX(15)
+		pdf.SetFont("Helvetica", "", 13)
+		pdf.MultiCell(180, 7, tr(rt.ArtistName), "", "L", false)
+		pdf.Ln(4)
+
+		rows := [][2]string{
+			{"Lieu", fmt.Sprintf("%s, %s", rt.Venue, rt.City)},
+			{"Date", rt.Date.Format("02/01/2006 à 15h04")},
+			{"Tarif", strings.ToUpper(ticket.TicketType)},
+			{"Code billet", ticket.Code},
+			{"Réservation", fmt.Sprintf("#%d", rt.ReservationID)},
+		}
+		if rt.UserName != "" {
+			rows = append(rows, [2]string{"Titulaire", rt.UserName})
+		}
+		for _, row := range rows {
+			pdf.SetX(15)
+			pdf.SetFont("Helvetica", "B", 11)
+			pdf.CellFormat(40, 8, tr(row[0]), "", 0, "L", false, 0, "")
+			pdf.SetFont("Helvetica", "", 11)
+			pdf.CellFormat(140, 8, tr(row[1]), "", 1, "L", false, 0, "")
+		}
+
+		// QR code
+		png, err := TicketQRCode(&ticket, 512)
+		if err != nil {
+			return nil, err
+		}
+		imageName := fmt.Sprintf("qr-%d", ticket.ID)
+		pdf.RegisterImageOptionsReader(imageName, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+		pdf.ImageOptions(imageName, 55, 120, 100, 100, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+		pdf.SetXY(15, 225)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.SetTextColor(110, 110, 110)
+		pdf.MultiCell(180, 5, tr("Présentez ce QR code à l'entrée. Chaque billet n'est valable que pour une seule entrée : "+
+			"ne le partagez pas. Un billet remboursé n'est plus valable."), "", "C", false)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to render tickets PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ========= CONFIRMATION DE COMMANDE =========
+
+// SendOrderConfirmation envoie l'email de confirmation avec les billets PDF en pièce jointe
+func SendOrderConfirmation(reservationID int) error {
+	rt, err := loadReservationTickets(reservationID)
+	if err != nil {
+		return err
+	}
+	if rt.UserEmail == "" {
+		return fmt.Errorf("reservation #%d has no email address", reservationID)
+	}
+
+	pdf, err := renderTicketsPDF(rt)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("🎫 Tes billets pour %s", rt.ConcertName)
+	htmlBody := fmt.Sprintf(`
+        <div style="font-family: Arial, sans-serif;">
+            <h1 style="color: #ff4757;">YNOT</h1>
+            <p>Merci pour ta commande ! Ton paiement est confirmé.</p>
+            <h2>%s</h2>
+            <p>%s — %s<br>%s</p>
+            <p><strong>%d billet(s)</strong> — Total : %s<br>Réservation #%d</p>
+            <p>Tes billets sont en pièce jointe (un QR code par place). Tu peux aussi les retrouver à tout moment dans ton espace.</p>
+        </div>
+    `, html.EscapeString(rt.ConcertName), html.EscapeString(rt.Venue), html.EscapeString(rt.City),
+		rt.Date.Format("02/01/2006 à 15h04"), len(rt.Tickets),
+		models.FormatAmount(rt.TotalPrice, rt.Currency), rt.ReservationID)
+
+	attachment := EmailAttachment{
+		Filename:    fmt.Sprintf("billets-reservation-%d.pdf", rt.ReservationID),
+		ContentType: "application/pdf",
+		Data:        pdf,
+	}
+
+	if err := SendHTMLEmail(rt.UserEmail, subject, htmlBody, attachment); err != nil {
+		return err
+	}
+
+	log.Printf("📧 Order confirmation sent for reservation #%d (%d tickets)", reservationID, len(rt.Tickets))
+	return nil
+}
+
+// sendOrderConfirmationAsync envoie la confirmation sans bloquer le webhook :
+// un échec SMTP ne doit pas faire rejouer le paiement
+func sendOrderConfirmationAsync(reservationID int) {
+	go func() {
+		if err := SendOrderConfirmation(reservationID); err != nil {
+			log.Printf("⚠️  Order confirmation for reservation #%d not sent: %v", reservationID, err)
+		}
+	}()
+}
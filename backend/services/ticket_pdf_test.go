@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"testing"
+
+	"groupie-backend/database/dbtest"
+)
+
+func TestTicketsShowHolderName(t *testing.T) {
+	db := dbtest.Open(t)
+	t.Setenv("TICKET_SIGNING_SECRET", "test-secret")
+	if err := InitTicketSigner(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Compte créé par l'inscription : first_name / last_name, pas de name
+	var userID int
+	err := db.QueryRow(`
+		INSERT INTO users (email, first_name, last_name, role, email_verified)
+		VALUES ('ada@example.com', 'Ada', 'Lovelace', 'user', TRUE)
+		RETURNING id
+	`).Scan(&userID)
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	concertID := insertTestConcert(t, db, 10, 0)
+	reservationID := insertPaidReservation(t, db, userID, concertID, 1, 5000, "pi_test_1")
+	insertTestTicket(t, db, reservationID, concertID)
+
+	rt, err := loadReservationTickets(reservationID)
+	if err != nil {
+		t.Fatalf("loadReservationTickets: %v", err)
+	}
+	if rt.UserName != "Ada Lovelace" {
+		t.Errorf("holder = %q, want Ada Lovelace", rt.UserName)
+	}
+
+	pdf, err := renderTicketsPDF(rt)
+	if err != nil {
+		t.Fatalf("renderTicketsPDF: %v", err)
+	}
+	content := pdfContent(t, pdf)
+	for _, want := range []string{"(Titulaire)", "(Ada Lovelace)"} {
+		if !bytes.Contains(content, []byte(want)) {
+			t.Errorf("PDF does not show %s", want)
+		}
+	}
+}
+
+// pdfContent décompresse les flux du PDF, où gofpdf écrit le texte des pages.
+func pdfContent(t *testing.T, pdf []byte) []byte {
+	t.Helper()
+
+	var content []byte
+	for rest := pdf; ; {
+		start := bytes.Index(rest, []byte("stream\n"))
+		if start < 0 {
+			return content
+		}
+		rest = rest[start+len("stream\n"):]
+		end := bytes.Index(rest, []byte("endstream"))
+		if end < 0 {
+			t.Fatal("unterminated PDF stream")
+		}
+		if r, err := zlib.NewReader(bytes.NewReader(rest[:end])); err == nil {
+			inflated, _ := io.ReadAll(r)
+			content = append(content, inflated...)
+		} else {
+			content = append(content, rest[:end]...)
+		}
+		rest = rest[end+len("endstream"):]
+	}
+}
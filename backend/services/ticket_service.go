@@ -0,0 +1,278 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/models"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// ========= BILLETS (E-TICKETS) =========
+//
+// Un billet est émis par place quand la réservation passe à 'paid'. Le QR code
+// contient "GT1.<id>.<code>.<signature>" : la signature HMAC-SHA256 empêche de
+// fabriquer un billet, et le scan passe le billet à 'used' une seule fois.
+
+const ticketPayloadVersion = "GT1"
+
+var ErrTicketNotFound = errors.New("ticket not found")
+
+var ticketSigningKey []byte
+
+// InitTicketSigner charge la clé de signature des QR codes (TICKET_SIGNING_SECRET).
+// Hors APP_ENV=development, une clé manquante empêche le démarrage : sans elle,
+// n'importe qui pourrait fabriquer un billet valide.
+func InitTicketSigner() error {
+	secret := os.Getenv("TICKET_SIGNING_SECRET")
+	if secret == "" {
+		if os.Getenv("APP_ENV") != "development" {
+			return errors.New("TICKET_SIGNING_SECRET is not set")
+		}
+		log.Println("⚠️  TICKET_SIGNING_SECRET not set. Using a default secret (development only).")
+		secret = "groupie-ticket-signing-key-not-for-prod"
+	}
+	ticketSigningKey = []byte(secret)
+	return nil
+}
+
+func signTicket(ticketID int, code string) (string, error) {
+	if ticketSigningKey == nil {
+		return "", errors.New("ticket signing key not initialized, call InitTicketSigner() first")
+	}
+	mac := hmac.New(sha256.New, ticketSigningKey)
+	fmt.Fprintf(mac, "%s.%d.%s", ticketPayloadVersion, ticketID, code)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// TicketPayload retourne le contenu signé encodé dans le QR code
+func TicketPayload(ticket *models.Ticket) (string, error) {
+	signature, err := signTicket(ticket.ID, ticket.Code)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%d.%s.%s", ticketPayloadVersion, ticket.ID, ticket.Code, signature), nil
+}
+
+// verifyTicketPayload vérifie la signature et retourne l'ID et le code du billet
+func verifyTicketPayload(payload string) (int, string, bool, error) {
+	parts := strings.Split(strings.TrimSpace(payload), ".")
+	if len(parts) != 4 || parts[0] != ticketPayloadVersion {
+		return 0, "", false, nil
+	}
+
+	ticketID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, "", false, nil
+	}
+
+	expected, err := signTicket(ticketID, parts[2])
+	if err != nil {
+		return 0, "", false, err
+	}
+	if !hmac.Equal([]byte(expected), []byte(parts[3])) {
+		return 0, "", false, nil
+	}
+	return ticketID, parts[2], true, nil
+}
+
+// newTicketCode génère un identifiant aléatoire lisible (base32, 16 caractères)
+func newTicketCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate ticket code: %w", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// issueTickets crée un billet par place d'une réservation qui vient d'être payée
+func issueTickets(tx *sql.Tx, reservationID, userID, concertID int, ticketType string, quantity int) error {
+	for i := 0; i < quantity; i++ {
+		code, err := newTicketCode()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO tickets (reservation_id, user_id, concert_id, ticket_type, code, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, 'valid', NOW(), NOW())
+		`, reservationID, userID, concertID, ticketType, code)
+		if err != nil {
+			return fmt.Errorf("failed to issue ticket: %w", err)
+		}
+	}
+
+	log.Printf("🎫 %d ticket(s) issued for reservation #%d", quantity, reservationID)
+	return nil
+}
+
+// voidTickets invalide les billets encore valides d'une réservation remboursée
+func voidTickets(tx *sql.Tx, reservationID int) error {
+	_, err := tx.Exec(`
+		UPDATE tickets
+		SET status = 'void', updated_at = NOW()
+		WHERE reservation_id = $1 AND status = 'valid'
+	`, reservationID)
+	if err != nil {
+		return fmt.Errorf("failed to void tickets: %w", err)
+	}
+	return nil
+}
+
+// ========= CONSULTATION =========
+
+const ticketColumns = `
+	t.id, t.reservation_id, COALESCE(t.user_id, 0), t.concert_id,
+	COALESCE(c.name, ''), c.date, t.ticket_type, t.code, t.status, t.used_at, t.created_at
+`
+
+func scanTicket(row rowScanner) (*models.Ticket, error) {
+	var t models.Ticket
+	var concertDate, usedAt sql.NullTime
+
+	err := row.Scan(&t.ID, &t.ReservationID, &t.UserID, &t.ConcertID, &t.ConcertName, &concertDate,
+		&t.TicketType, &t.Code, &t.Status, &usedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if concertDate.Valid {
+		t.ConcertDate = &concertDate.Time
+	}
+	if usedAt.Valid {
+		t.UsedAt = &usedAt.Time
+	}
+	return &t, nil
+}
+
+// GetUserTickets liste les billets d'un utilisateur, prochains concerts d'abord
+func GetUserTickets(userID int) ([]models.Ticket, error) {
+	rows, err := database.DB.Query(`
+		SELECT `+ticketColumns+`
+		FROM tickets t
+		LEFT JOIN concerts c ON c.id = t.concert_id
+		WHERE t.user_id = $1
+		ORDER BY c.date, t.id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tickets: %w", err)
+	}
+	defer rows.Close()
+
+	tickets := []models.Ticket{}
+	for rows.Next() {
+		t, err := scanTicket(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning ticket: %w", err)
+		}
+		tickets = append(tickets, *t)
+	}
+	return tickets, rows.Err()
+}
+
+// GetTicket retourne un billet par son ID
+func GetTicket(ticketID int) (*models.Ticket, error) {
+	t, err := scanTicket(database.DB.QueryRow(`
+		SELECT `+ticketColumns+`
+		FROM tickets t
+		LEFT JOIN concerts c ON c.id = t.concert_id
+		WHERE t.id = $1
+	`, ticketID))
+	if err == sql.ErrNoRows {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching ticket: %w", err)
+	}
+	return t, nil
+}
+
+// TicketQRCode génère le QR code PNG du billet
+func TicketQRCode(ticket *models.Ticket, size int) ([]byte, error) {
+	payload, err := TicketPayload(ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	png, err := qrcode.Encode(payload, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+	return png, nil
+}
+
+// ========= CONTRÔLE À L'ENTRÉE =========
+
+// ScanTicket vérifie un QR code et marque le billet comme utilisé.
+// Le passage 'valid' → 'used' est un UPDATE conditionnel : deux scanners qui
+// lisent le même billet en même temps ne peuvent pas le valider tous les deux.
+func ScanTicket(req models.ScanRequest, staffID int) (*models.ScanResult, error) {
+	ticketID, code, ok, err := verifyTicketPayload(req.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &models.ScanResult{Reason: "invalid_signature", Detail: "QR code is not a valid ticket"}, nil
+	}
+
+	var usedID int
+	err = database.DB.QueryRow(`
+		UPDATE tickets
+		SET status = 'used', used_at = NOW(), scanned_by = $3, updated_at = NOW()
+		WHERE id = $1 AND code = $2 AND status = 'valid'
+		AND ($4 = 0 OR concert_id = $4)
+		RETURNING id
+	`, ticketID, code, staffID, req.ConcertID).Scan(&usedID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error scanning ticket: %w", err)
+	}
+
+	ticket, fetchErr := GetTicket(ticketID)
+	if errors.Is(fetchErr, ErrTicketNotFound) || (fetchErr == nil && ticket.Code != code) {
+		return &models.ScanResult{Reason: "not_found", Detail: "Ticket does not exist"}, nil
+	}
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	if err == nil {
+		log.Printf("🎟️  Ticket #%d admitted (reservation #%d)", ticket.ID, ticket.ReservationID)
+		return &models.ScanResult{Valid: true, Ticket: ticket}, nil
+	}
+
+	// Le billet n'a pas été validé : expliquer pourquoi
+	result := &models.ScanResult{Ticket: ticket}
+	switch {
+	case req.ConcertID != 0 && ticket.ConcertID != req.ConcertID:
+		result.Reason = "wrong_concert"
+		result.Detail = fmt.Sprintf("Ticket is for concert #%d", ticket.ConcertID)
+	case ticket.Status == models.TicketStatusUsed:
+		result.Reason = "already_used"
+		if ticket.UsedAt != nil {
+			result.Detail = "Ticket already scanned at " + ticket.UsedAt.Format(time.RFC3339)
+		} else {
+			result.Detail = "Ticket already scanned"
+		}
+	case ticket.Status == models.TicketStatusVoid:
+		result.Reason = "refunded"
+		result.Detail = "Reservation was refunded or cancelled"
+	default:
+		result.Reason = "not_found"
+		result.Detail = "Ticket is not valid"
+	}
+
+	log.Printf("⛔ Ticket #%d rejected: %s", ticket.ID, result.Reason)
+	return result, nil
+}
@@ -0,0 +1,475 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/models"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// ========= DOUBLE AUTHENTIFICATION (TOTP) =========
+//
+// TOTP RFC 6238 (HMAC-SHA1, 6 chiffres, pas de 30 s), compatible avec les
+// applications d'authentification usuelles. Le secret est chiffré en base
+// (AES-GCM) et chaque pas de temps n'est accepté qu'une fois. Quand la 2FA est
+// active, Login ne délivre qu'un jeton de challenge (one_time_tokens) à
+// échanger avec un code contre la session.
+
+const (
+	totpIssuer = "YNOT"
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // pas acceptés avant/après l'heure courante (dérive d'horloge)
+
+	loginChallengeTTL = 5 * time.Minute
+	recoveryCodeCount = 10
+
+	// Échecs de code tolérés par compte, tous challenges confondus, sur 15 minutes
+	maxTwoFactorFailures = 10
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp       = errors.New("two-factor setup not started, call /auth/2fa/setup first")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorLocked         = errors.New("too many two-factor attempts, try again later")
+)
+
+var totpEncryptionKey []byte
+
+// InitTwoFactor charge la clé de chiffrement des secrets TOTP (TOTP_ENCRYPTION_KEY).
+// Hors APP_ENV=development, une clé manquante empêche le démarrage.
+func InitTwoFactor() error {
+	secret := os.Getenv("TOTP_ENCRYPTION_KEY")
+	if secret == "" {
+		if os.Getenv("APP_ENV") != "development" {
+			return errors.New("TOTP_ENCRYPTION_KEY is not set")
+		}
+		log.Println("⚠️  TOTP_ENCRYPTION_KEY not set. Using a default key (development only).")
+		secret = "groupie-totp-encryption-key-not-for-prod"
+	}
+
+	// Toute longueur de secret donne une clé AES-256
+	sum := sha256.Sum256([]byte(secret))
+	totpEncryptionKey = sum[:]
+
+	if RequireAdminTwoFactor() {
+		log.Println("🔐 2FA obligatoire pour les comptes admin")
+	}
+	return nil
+}
+
+// RequireAdminTwoFactor indique si les admins doivent avoir activé la 2FA pour
+// accéder aux routes /admin (REQUIRE_ADMIN_2FA, activé par défaut)
+func RequireAdminTwoFactor() bool {
+	return os.Getenv("REQUIRE_ADMIN_2FA") != "false"
+}
+
+func totpCipher() (cipher.AEAD, error) {
+	if totpEncryptionKey == nil {
+		return nil, errors.New("two-factor encryption key not initialized, call InitTwoFactor() first")
+	}
+	block, err := aes.NewCipher(totpEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealTOTPSecret chiffre le secret ; le nonce est préfixé au texte chiffré
+func sealTOTPSecret(secret []byte) (string, error) {
+	aead, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, secret, nil)), nil
+}
+
+func openTOTPSecret(sealed string) ([]byte, error) {
+	aead, err := totpCipher()
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return nil, errors.New("malformed TOTP secret")
+	}
+	secret, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	return secret, nil
+}
+
+// totpCode calcule le code d'un pas de temps (RFC 4226 §5.3, troncature dynamique)
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// matchTOTP cherche le code dans la fenêtre autorisée et retourne le pas
+// correspondant. Un pas déjà utilisé (<= lastStep) est refusé : rejouer un
+// code intercepté ne fonctionne pas.
+func matchTOTP(secret []byte, code string, lastStep int64, now time.Time) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// normalizeTwoFactorCode retire espaces et tirets ("123 456", "abcde-fghij")
+func normalizeTwoFactorCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// newRecoveryCodes génère les codes de secours à afficher une seule fois ("xxxxx-xxxxx")
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+	}
+	return codes, nil
+}
+
+// replaceRecoveryCodes remplace les codes de secours de l'utilisateur (seul leur hash est stocké)
+func replaceRecoveryCodes(tx *sql.Tx, userID int) ([]string, error) {
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, code := range codes {
+		_, err := tx.Exec(`
+			INSERT INTO two_factor_recovery_codes (user_id, code_hash, created_at)
+			VALUES ($1, $2, NOW())
+		`, userID, hashToken(normalizeTwoFactorCode(code)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+	return codes, nil
+}
+
+// verifySecondFactor vérifie un code TOTP ou, à défaut, un code de secours
+// (consommé). La ligne utilisateur est verrouillée pour l'anti-rejeu.
+func verifySecondFactor(tx *sql.Tx, userID int, code string) error {
+	var sealed sql.NullString
+	var enabled bool
+	var lastStep int64
+	err := tx.QueryRow(`
+		SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = $1 FOR UPDATE
+	`, userID).Scan(&sealed, &enabled, &lastStep)
+	if err != nil {
+		return fmt.Errorf("error fetching two-factor settings: %w", err)
+	}
+	if !enabled || !sealed.Valid {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = normalizeTwoFactorCode(code)
+
+	secret, err := openTOTPSecret(sealed.String)
+	if err != nil {
+		return err
+	}
+	if step, ok := matchTOTP(secret, code, lastStep, time.Now()); ok {
+		_, err := tx.Exec(`UPDATE users SET totp_last_step = $1 WHERE id = $2`, step, userID)
+		return err
+	}
+
+	// Code de secours : usage unique
+	result, err := tx.Exec(`
+		UPDATE two_factor_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hashToken(code))
+	if err != nil {
+		return fmt.Errorf("failed to check recovery code: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		log.Printf("🔑 Recovery code used by user #%d", userID)
+		return nil
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+// ========= ENRÔLEMENT =========
+
+// SetupTwoFactor génère un nouveau secret (pas encore actif) et l'URI
+// otpauth:// à scanner. Rappeler setup avant /verify remplace le secret.
+func SetupTwoFactor(userID int) (*models.TwoFactorSetup, error) {
+	var email string
+	var enabled bool
+	err := database.DB.QueryRow(`SELECT email, totp_enabled FROM users WHERE id = $1`, userID).Scan(&email, &enabled)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching user: %w", err)
+	}
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	sealed, err := sealTOTPSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE users SET totp_secret = $1, totp_last_step = 0 WHERE id = $2 AND totp_enabled = FALSE
+	`, sealed, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+
+	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+	params := url.Values{}
+	params.Set("secret", encoded)
+	params.Set("issuer", totpIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", totpDigits))
+	params.Set("period", fmt.Sprintf("%d", totpPeriod))
+	uri := fmt.Sprintf("otpauth://totp/%s:%s?%s", url.PathEscape(totpIssuer), url.PathEscape(email), params.Encode())
+
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+
+	return &models.TwoFactorSetup{
+		Secret:     encoded,
+		OTPAuthURI: uri,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// EnableTwoFactor active la 2FA après vérification d'un premier code et
+// retourne les codes de secours (affichés une seule fois)
+func EnableTwoFactor(userID int, code string) ([]string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sealed sql.NullString
+	var enabled bool
+	err = tx.QueryRow(`
+		SELECT totp_secret, totp_enabled FROM users WHERE id = $1 FOR UPDATE
+	`, userID).Scan(&sealed, &enabled)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching two-factor settings: %w", err)
+	}
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if !sealed.Valid {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	secret, err := openTOTPSecret(sealed.String)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := matchTOTP(secret, normalizeTwoFactorCode(code), 0, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET totp_enabled = TRUE, totp_enabled_at = NOW(), totp_last_step = $1 WHERE id = $2
+	`, step, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor: %w", err)
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit two-factor enrollment: %w", err)
+	}
+
+	log.Printf("🔐 Two-factor enabled for user #%d", userID)
+	return codes, nil
+}
+
+// DisableTwoFactor désactive la 2FA sur présentation d'un code TOTP ou de secours
+func DisableTwoFactor(userID int, code string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := verifySecondFactor(tx, userID, code); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET totp_enabled = FALSE, totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit two-factor removal: %w", err)
+	}
+
+	log.Printf("🔓 Two-factor disabled for user #%d", userID)
+	return nil
+}
+
+// IsTwoFactorEnabled indique si l'utilisateur a activé la 2FA
+func IsTwoFactorEnabled(userID int) (bool, error) {
+	var enabled bool
+	err := database.DB.QueryRow(`SELECT totp_enabled FROM users WHERE id = $1`, userID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking two-factor: %w", err)
+	}
+	return enabled, nil
+}
+
+// ========= CONNEXION EN DEUX ÉTAPES =========
+
+// IssueLoginChallenge crée le jeton de challenge remis après un mot de passe
+// (ou une connexion OAuth) valide, à échanger contre un code sur /auth/2fa/login
+func IssueLoginChallenge(userID int, email string) (*models.TwoFactorChallenge, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Chaque challenge autorise quelques essais : sans plafond par compte, il
+	// suffirait de se reconnecter pour en obtenir de nouveaux
+	var attempts int
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(attempts), 0) FROM one_time_tokens
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+		AND created_at > NOW() - INTERVAL '15 minutes'
+	`, userID, TokenPurposeLoginChallenge).Scan(&attempts)
+	if err != nil {
+		return nil, fmt.Errorf("error checking two-factor attempts: %w", err)
+	}
+	if attempts >= maxTwoFactorFailures {
+		log.Printf("⛔ Two-factor login locked for user #%d (%d failed attempts)", userID, attempts)
+		return nil, ErrTwoFactorLocked
+	}
+
+	token, err := insertOneTimeToken(tx, userID, email, TokenPurposeLoginChallenge, loginChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit login challenge: %w", err)
+	}
+
+	return &models.TwoFactorChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int(loginChallengeTTL.Seconds()),
+	}, nil
+}
+
+// CompleteTwoFactorLogin vérifie le code associé au challenge et ouvre la session
+func CompleteTwoFactorLogin(challengeToken, code, userAgent, ipAddress string) (*models.LoginResponse, error) {
+	userID, err := VerifyOneTimeToken(challengeToken, TokenPurposeLoginChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := verifySecondFactor(tx, userID, code); err != nil {
+		return nil, err
+	}
+	if _, err := ConsumeOneTimeToken(tx, challengeToken, TokenPurposeLoginChallenge); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit two-factor login: %w", err)
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := CreateSession(user.ID, user.Role, userAgent, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
+	}, nil
+}
@@ -0,0 +1,428 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"groupie-backend/database"
+	"groupie-backend/models"
+
+	"github.com/stripe/stripe-go/v76"
+)
+
+// ========= JOURNAL DES ÉVÉNEMENTS STRIPE =========
+//
+// Chaque livraison est enregistrée dans stripe_events (clé : event.ID) avant
+// traitement. Une livraison en double d'un événement déjà traité est ignorée,
+// un échec est conservé avec son erreur pour être rejoué (retrier ou admin).
+
+const (
+	StripeEventProcessing = "processing"
+	StripeEventProcessed  = "processed"
+	StripeEventFailed     = "failed"
+	StripeEventIgnored    = "ignored"
+
+	MaxWebhookAttempts = 8
+)
+
+var (
+	ErrStripeEventNotFound         = errors.New("stripe event not found")
+	ErrStripeEventAlreadyProcessed = errors.New("event was already processed, replay it with force")
+)
+
+// HandleStripeEvent enregistre puis traite un événement reçu par webhook.
+// duplicate vaut true si l'événement a déjà été (ou est en train d'être) traité.
+func HandleStripeEvent(event stripe.Event, payload []byte) (duplicate bool, err error) {
+	claimed, err := claimStripeEvent(event, payload)
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		return true, nil
+	}
+
+	return false, processClaimedEvent(event)
+}
+
+// ReplayStripeEvent relance le traitement d'un événement enregistré, sauf s'il est
+// en cours de traitement. Un événement déjà traité n'est rejoué qu'avec force.
+func ReplayStripeEvent(eventID string, force bool) error {
+	var payload []byte
+	err := database.DB.QueryRow(`
+		UPDATE stripe_events
+		SET status = $2, updated_at = NOW()
+		WHERE id = $1 AND status <> $2 AND ($3 OR status <> $4)
+		RETURNING payload
+	`, eventID, StripeEventProcessing, force, StripeEventProcessed).Scan(&payload)
+	if err == sql.ErrNoRows {
+		var status string
+		err := database.DB.QueryRow(`SELECT status FROM stripe_events WHERE id = $1`, eventID).Scan(&status)
+		switch {
+		case err == sql.ErrNoRows:
+			return ErrStripeEventNotFound
+		case err != nil:
+			return fmt.Errorf("error loading stripe event: %w", err)
+		case status == StripeEventProcessed:
+			return ErrStripeEventAlreadyProcessed
+		}
+		return errors.New("event is already being processed")
+	}
+	if err != nil {
+		return fmt.Errorf("error loading stripe event: %w", err)
+	}
+
+	var event stripe.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		finishStripeEvent(eventID, StripeEventFailed, err)
+		return fmt.Errorf("error parsing stored event: %w", err)
+	}
+
+	log.Printf("🔁 Replaying webhook %s (%s)", event.ID, event.Type)
+	return processClaimedEvent(event)
+}
+
+// RetryFailedStripeEvents rejoue les événements en échec dont le délai de backoff est écoulé
+func RetryFailedStripeEvents() (int, error) {
+	// Un traitement interrompu (crash, redéploiement) reste bloqué en 'processing' :
+	// on le repasse en échec pour qu'il soit rejoué
+	_, err := database.DB.Exec(`
+		UPDATE stripe_events
+		SET status = $1, last_error = 'processing interrupted', next_attempt_at = NOW(), updated_at = NOW()
+		WHERE status = $2 AND updated_at < NOW() - INTERVAL '10 minutes'
+	`, StripeEventFailed, StripeEventProcessing)
+	if err != nil {
+		return 0, fmt.Errorf("error recovering stuck stripe events: %w", err)
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id FROM stripe_events
+		WHERE status = $1 AND attempts < $2 AND next_attempt_at <= NOW()
+		ORDER BY received_at
+		LIMIT 50
+	`, StripeEventFailed, MaxWebhookAttempts)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching failed stripe events: %w", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	retried := 0
+	for _, id := range ids {
+		if err := ReplayStripeEvent(id, false); err != nil {
+			log.Printf("⚠️  Retry of webhook %s failed: %v", id, err)
+			continue
+		}
+		retried++
+	}
+
+	return retried, nil
+}
+
+// ListStripeEvents retourne les derniers événements, filtrés par statut si fourni
+func ListStripeEvents(status string, limit int) ([]models.StripeEvent, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, type, status, attempts, COALESCE(last_error, ''),
+		       received_at, processed_at, next_attempt_at
+		FROM stripe_events
+		WHERE $1 = '' OR status = $1
+		ORDER BY received_at DESC
+		LIMIT $2
+	`, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching stripe events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.StripeEvent{}
+	for rows.Next() {
+		var e models.StripeEvent
+		var processedAt, nextAttemptAt sql.NullTime
+		if err := rows.Scan(&e.ID, &e.Type, &e.Status, &e.Attempts, &e.LastError,
+			&e.ReceivedAt, &processedAt, &nextAttemptAt); err != nil {
+			return nil, fmt.Errorf("error scanning stripe event: %w", err)
+		}
+		if processedAt.Valid {
+			e.ProcessedAt = &processedAt.Time
+		}
+		if nextAttemptAt.Valid && e.Status == StripeEventFailed {
+			e.NextAttemptAt = &nextAttemptAt.Time
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// StartWebhookRetrier rejoue périodiquement les événements Stripe en échec
+func StartWebhookRetrier() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		for range ticker.C {
+			count, err := RetryFailedStripeEvents()
+			if err != nil {
+				log.Printf("❌ Error retrying webhooks: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("🔁 %d webhook(s) rejoué(s) avec succès", count)
+			}
+		}
+	}()
+
+	log.Println("🔁 Webhook retrier started (runs every minute)")
+}
+
+// claimStripeEvent enregistre l'événement et le réserve pour traitement.
+// Retourne false si une autre livraison l'a déjà traité ou le traite en ce moment.
+func claimStripeEvent(event stripe.Event, payload []byte) (bool, error) {
+	result, err := database.DB.Exec(`
+		INSERT INTO stripe_events (id, type, payload, status, attempts, received_at, updated_at)
+		VALUES ($1, $2, $3, $4, 0, NOW(), NOW())
+		ON CONFLICT (id) DO NOTHING
+	`, event.ID, string(event.Type), payload, StripeEventProcessing)
+	if err != nil {
+		return false, fmt.Errorf("error recording stripe event: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 1 {
+		return true, nil
+	}
+
+	// Déjà connu : on ne le reprend que s'il avait échoué
+	result, err = database.DB.Exec(`
+		UPDATE stripe_events
+		SET status = $2, updated_at = NOW()
+		WHERE id = $1 AND status = $3
+	`, event.ID, StripeEventProcessing, StripeEventFailed)
+	if err != nil {
+		return false, fmt.Errorf("error claiming stripe event: %w", err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+func processClaimedEvent(event stripe.Event) error {
+	handled, err := dispatchStripeEvent(event)
+
+	status := StripeEventProcessed
+	if err != nil {
+		status = StripeEventFailed
+	} else if !handled {
+		status = StripeEventIgnored
+	}
+
+	if finishErr := finishStripeEvent(event.ID, status, err); finishErr != nil {
+		log.Printf("⚠️  Warning: %v", finishErr)
+	}
+
+	return err
+}
+
+// finishStripeEvent enregistre le résultat d'une tentative ; un échec est
+// reprogrammé avec un backoff exponentiel (1, 2, 4… minutes, plafonné à 6h)
+func finishStripeEvent(eventID, status string, procErr error) error {
+	var lastError sql.NullString
+	if procErr != nil {
+		lastError = sql.NullString{String: procErr.Error(), Valid: true}
+	}
+
+	_, err := database.DB.Exec(`
+		UPDATE stripe_events
+		SET status = $2,
+		    attempts = attempts + 1,
+		    last_error = $3,
+		    processed_at = CASE WHEN $2 IN ('processed', 'ignored') THEN NOW() ELSE processed_at END,
+		    next_attempt_at = NOW() + LEAST(POWER(2, attempts), 360) * INTERVAL '1 minute',
+		    updated_at = NOW()
+		WHERE id = $1
+	`, eventID, status, lastError)
+	if err != nil {
+		return fmt.Errorf("error updating stripe event %s: %w", eventID, err)
+	}
+	return nil
+}
+
+// ========= TRAITEMENT DES ÉVÉNEMENTS =========
+
+// dispatchStripeEvent applique un événement ; handled vaut false pour les types non gérés
+func dispatchStripeEvent(event stripe.Event) (handled bool, err error) {
+	if event.Data == nil {
+		return false, errors.New("event has no data")
+	}
+
+	switch event.Type {
+	case "payment_intent.succeeded":
+		return true, handlePaymentSucceeded(event)
+
+	case "payment_intent.payment_failed":
+		return true, handlePaymentFailed(event)
+
+	case "payment_intent.canceled":
+		return true, handlePaymentCanceled(event)
+
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded":
+		return true, handleCheckoutSessionCompleted(event)
+
+	case "checkout.session.expired", "checkout.session.async_payment_failed":
+		return true, handleCheckoutSessionReleased(event)
+
+	case "charge.refunded":
+		return true, handleChargeRefunded(event)
+
+	case "charge.refund.updated":
+		return true, handleRefundUpdated(event)
+
+	default:
+		log.Printf("ℹ️  Webhook event ignored: %s", event.Type)
+		return false, nil
+	}
+}
+
+// handlePaymentSucceeded traite un paiement réussi
+func handlePaymentSucceeded(event stripe.Event) error {
+	var paymentIntent stripe.PaymentIntent
+	if err := json.Unmarshal(event.Data.Raw, &paymentIntent); err != nil {
+		return fmt.Errorf("error parsing payment_intent.succeeded: %w", err)
+	}
+
+	// Récupérer l'ID de réservation depuis les metadata
+	reservationID, ok := paymentIntent.Metadata["reservation_id"]
+	if !ok {
+		log.Printf("⚠️  Payment succeeded but no reservation_id in metadata: %s", paymentIntent.ID)
+		return nil
+	}
+
+	if err := MarkReservationAsPaid(reservationID, paymentIntent.ID); err != nil {
+		return fmt.Errorf("failed to mark reservation %s as paid: %w", reservationID, err)
+	}
+
+	log.Printf("✅ PAYMENT SUCCEEDED - Reservation #%s marked as PAID (Payment Intent: %s)",
+		reservationID, paymentIntent.ID)
+	return nil
+}
+
+// handlePaymentFailed traite un échec de paiement
+func handlePaymentFailed(event stripe.Event) error {
+	var paymentIntent stripe.PaymentIntent
+	if err := json.Unmarshal(event.Data.Raw, &paymentIntent); err != nil {
+		return fmt.Errorf("error parsing payment_intent.payment_failed: %w", err)
+	}
+
+	reservationID, ok := paymentIntent.Metadata["reservation_id"]
+	if !ok {
+		log.Printf("⚠️  Payment failed but no reservation_id in metadata: %s", paymentIntent.ID)
+		return nil
+	}
+
+	failureReason := "Payment failed"
+	if paymentIntent.LastPaymentError != nil {
+		failureReason = paymentIntent.LastPaymentError.Error()
+	}
+
+	if err := MarkReservationAsFailed(reservationID, failureReason); err != nil {
+		return fmt.Errorf("failed to mark reservation %s as failed: %w", reservationID, err)
+	}
+
+	log.Printf("❌ PAYMENT FAILED - Reservation #%s marked as FAILED: %s", reservationID, failureReason)
+	return nil
+}
+
+// handlePaymentCanceled traite une annulation de paiement
+func handlePaymentCanceled(event stripe.Event) error {
+	var paymentIntent stripe.PaymentIntent
+	if err := json.Unmarshal(event.Data.Raw, &paymentIntent); err != nil {
+		return fmt.Errorf("error parsing payment_intent.canceled: %w", err)
+	}
+
+	reservationID, ok := paymentIntent.Metadata["reservation_id"]
+	if !ok {
+		log.Printf("⚠️  Payment canceled but no reservation_id in metadata: %s", paymentIntent.ID)
+		return nil
+	}
+
+	if err := MarkReservationAsFailed(reservationID, "Payment canceled by user"); err != nil {
+		return fmt.Errorf("failed to mark reservation %s as canceled: %w", reservationID, err)
+	}
+
+	log.Printf("🚫 PAYMENT CANCELED - Reservation #%s marked as CANCELED", reservationID)
+	return nil
+}
+
+// handleCheckoutSessionCompleted règle les réservations d'une session Checkout payée
+func handleCheckoutSessionCompleted(event stripe.Event) error {
+	var sess stripe.CheckoutSession
+	if err := json.Unmarshal(event.Data.Raw, &sess); err != nil {
+		return fmt.Errorf("error parsing %s: %w", event.Type, err)
+	}
+
+	if err := CompleteCheckoutSession(&sess); err != nil {
+		return err
+	}
+
+	log.Printf("✅ CHECKOUT COMPLETED - Session %s (Reservations #%s)", sess.ID, sess.Metadata["reservation_ids"])
+	return nil
+}
+
+// handleCheckoutSessionReleased libère les places d'une session expirée ou échouée
+func handleCheckoutSessionReleased(event stripe.Event) error {
+	var sess stripe.CheckoutSession
+	if err := json.Unmarshal(event.Data.Raw, &sess); err != nil {
+		return fmt.Errorf("error parsing %s: %w", event.Type, err)
+	}
+
+	reason := "Checkout session expired"
+	if event.Type == "checkout.session.async_payment_failed" {
+		reason = "Checkout payment failed"
+	}
+
+	if err := ReleaseCheckoutSession(&sess, reason); err != nil {
+		return err
+	}
+
+	log.Printf("🚫 CHECKOUT RELEASED - Session %s (Reservations #%s): %s", sess.ID, sess.Metadata["reservation_ids"], reason)
+	return nil
+}
+
+// handleChargeRefunded traite un remboursement (total ou partiel) d'une charge
+func handleChargeRefunded(event stripe.Event) error {
+	var charge stripe.Charge
+	if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+		return fmt.Errorf("error parsing charge.refunded: %w", err)
+	}
+
+	if err := SyncChargeRefunded(&charge); err != nil {
+		return fmt.Errorf("failed to sync refunded charge %s: %w", charge.ID, err)
+	}
+
+	log.Printf("💰 CHARGE REFUNDED - Charge %s (refunded: %s)", charge.ID, models.FormatAmount(charge.AmountRefunded, string(charge.Currency)))
+	return nil
+}
+
+// handleRefundUpdated met à jour le statut d'un remboursement (pending → succeeded/failed)
+func handleRefundUpdated(event stripe.Event) error {
+	var refund stripe.Refund
+	if err := json.Unmarshal(event.Data.Raw, &refund); err != nil {
+		return fmt.Errorf("error parsing charge.refund.updated: %w", err)
+	}
+
+	if err := SyncRefund(&refund); err != nil {
+		return fmt.Errorf("failed to sync refund %s: %w", refund.ID, err)
+	}
+
+	log.Printf("💰 REFUND UPDATED - Refund %s is now %s", refund.ID, refund.Status)
+	return nil
+}
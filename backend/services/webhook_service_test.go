@@ -0,0 +1,104 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"groupie-backend/database/dbtest"
+)
+
+func TestReplayOfProcessedEventRequiresForce(t *testing.T) {
+	db := dbtest.Open(t)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 10, 0)
+	reservationID := insertPaidReservation(t, db, userID, concertID, 2, 10000, "pi_test_1")
+	// Remboursée : les places sont revenues dans le stock
+	if _, err := db.Exec(`UPDATE reservations SET status = 'refunded', payment_status = 'refunded' WHERE id = $1`, reservationID); err != nil {
+		t.Fatalf("refund reservation: %v", err)
+	}
+	insertStripeEvent(t, db, "evt_paid", "payment_intent.succeeded", StripeEventProcessed,
+		fmt.Sprintf(`{"id":"pi_test_1","object":"payment_intent","metadata":{"reservation_id":"%d"}}`, reservationID))
+
+	if err := ReplayStripeEvent("evt_paid", false); !errors.Is(err, ErrStripeEventAlreadyProcessed) {
+		t.Fatalf("replay without force = %v, want ErrStripeEventAlreadyProcessed", err)
+	}
+	assertEventStatus(t, db, "evt_paid", StripeEventProcessed)
+
+	// Même forcé, le paiement rejoué ne revend pas une commande remboursée
+	if err := ReplayStripeEvent("evt_paid", true); !errors.Is(err, ErrReservationNotPayable) {
+		t.Fatalf("forced replay = %v, want ErrReservationNotPayable", err)
+	}
+	assertEventStatus(t, db, "evt_paid", StripeEventFailed)
+	assertReservation(t, db, reservationID, "refunded", "refunded")
+	assertStock(t, db, concertID, 10, 0)
+
+	var tickets int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tickets WHERE reservation_id = $1`, reservationID).Scan(&tickets); err != nil {
+		t.Fatalf("count tickets: %v", err)
+	}
+	if tickets != 0 {
+		t.Errorf("replay issued %d tickets for a refunded reservation", tickets)
+	}
+}
+
+func TestMarkReservationAsPaidOnlyAcceptsPendingOrExpired(t *testing.T) {
+	db := dbtest.Open(t)
+
+	userID := insertTestUser(t, db, "buyer@example.com")
+	concertID := insertTestConcert(t, db, 10, 0)
+
+	tests := []struct {
+		status, paymentStatus string
+		wantErr               bool
+	}{
+		{"cancelled", "failed", true},
+		{"refunded", "refunded", true},
+		{"paid", "partially_refunded", false}, // déjà payée : ignorée sans erreur
+		{"expired", "partially_refunded", true},
+	}
+	for _, tt := range tests {
+		var reservationID int
+		err := db.QueryRow(`
+			INSERT INTO reservations
+				(user_id, concert_id, ticket_type, quantity, total_price, currency, status, payment_status, expires_at)
+			VALUES ($1, $2, 'standard', 1, 5000, 'eur', $3, $4, NOW())
+			RETURNING id
+		`, userID, concertID, tt.status, tt.paymentStatus).Scan(&reservationID)
+		if err != nil {
+			t.Fatalf("insert reservation: %v", err)
+		}
+
+		err = MarkReservationAsPaid(fmt.Sprint(reservationID), "pi_test")
+		if gotErr := errors.Is(err, ErrReservationNotPayable); gotErr != tt.wantErr {
+			t.Errorf("%s / %s: MarkReservationAsPaid = %v, want not payable: %v", tt.status, tt.paymentStatus, err, tt.wantErr)
+		}
+	}
+}
+
+func insertStripeEvent(t *testing.T, db *sql.DB, id, eventType, status, object string) {
+	t.Helper()
+
+	payload := fmt.Sprintf(`{"id":%q,"object":"event","type":%q,"data":{"object":%s}}`, id, eventType, object)
+	_, err := db.Exec(`
+		INSERT INTO stripe_events (id, type, payload, status, attempts, received_at, updated_at)
+		VALUES ($1, $2, $3, $4, 1, NOW(), NOW())
+	`, id, eventType, payload, status)
+	if err != nil {
+		t.Fatalf("insert stripe event: %v", err)
+	}
+}
+
+func assertEventStatus(t *testing.T, db *sql.DB, id, want string) {
+	t.Helper()
+
+	var status string
+	if err := db.QueryRow(`SELECT status FROM stripe_events WHERE id = $1`, id).Scan(&status); err != nil {
+		t.Fatalf("read stripe event: %v", err)
+	}
+	if status != want {
+		t.Errorf("event %s is %s, want %s", id, status, want)
+	}
+}
@@ -1,5 +1,5 @@
 import { useEffect } from 'react';
-import { BrowserRouter, Routes, Route, Navigate, useLocation } from 'react-router-dom';
+import { BrowserRouter, Routes, Route, Navigate, useLocation, useNavigate } from 'react-router-dom';
 import { AnimatePresence } from 'framer-motion';
 import { useAuthStore } from './stores/useAuthStore';
 import { initSentry } from './lib/sentry';
@@ -20,6 +20,7 @@ import ArtistDetailPage from './pages/ArtistDetailPage';
 import AboutPage from './pages/AboutPage';
 import LoginPage from './pages/LoginPage';
 import TicketsPage from './pages/TicketsPage';
+import ProfilePage from './pages/ProfilePage';
 import ConcertsPage from './pages/ConcertsPage';
 import CheckoutPage from './pages/CheckoutPage';
 import RegisterPage from './pages/Register';
@@ -34,8 +35,23 @@ import Footer from './components/Footer';
 
 function AnimatedRoutes() {
   const location = useLocation();
+  const navigate = useNavigate();
   const { user } = useAuthStore();
 
+  // Android : retour de la connexion externe par deep link (com.groupie.app://auth/callback?code=...)
+  useEffect(() => {
+    if (!Capacitor.isNativePlatform()) return;
+    const listener = CapacitorApp.addListener('appUrlOpen', ({ url }) => {
+      const link = new URL(url);
+      if (link.host === 'auth' && link.pathname === '/callback') {
+        navigate(`/login${link.search}`, { replace: true });
+      }
+    });
+    return () => {
+      listener.then((handle) => handle.remove());
+    };
+  }, [navigate]);
+
   return (
     <AnimatePresence mode="wait">
       <Routes location={location} key={location.pathname}>
@@ -47,6 +63,7 @@ function AnimatedRoutes() {
           <Route path="/checkout" element={<CheckoutPage />} />
           <Route path="/about" element={<AboutPage />} />
           <Route path="/tickets" element={<TicketsPage />} />
+          <Route path="/profile" element={<ProfilePage />} />
 
           <Route path="/login" element={<LoginPage />} />
           <Route path="/register" element={<RegisterPage />} />
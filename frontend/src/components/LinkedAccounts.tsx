@@ -0,0 +1,123 @@
+import { useCallback, useEffect, useRef, useState } from 'react';
+import { Link2 } from 'lucide-react';
+import { useSearchParams } from 'react-router-dom';
+import { toast } from 'sonner';
+import { api, APIError, providerAuthURL, type LoginMethods, type LoginProvider } from '../lib/api';
+
+// Comptes Google / GitHub / OIDC liés : rattachement explicite et retrait
+export default function LinkedAccounts() {
+  const [searchParams, setSearchParams] = useSearchParams();
+  const [methods, setMethods] = useState<LoginMethods | null>(null);
+  const [providers, setProviders] = useState<LoginProvider[]>([]);
+  const [isLoading, setIsLoading] = useState(false);
+  const confirming = useRef(false);
+
+  const load = useCallback(async () => {
+    try {
+      setMethods(await api.get<LoginMethods>('/profile/identities'));
+    } catch {
+      setMethods(null);
+    }
+  }, []);
+
+  useEffect(() => {
+    load();
+    api.get<LoginProvider[]>('/auth/providers').then(setProviders).catch(() => setProviders([]));
+  }, [load]);
+
+  // Retour du fournisseur : confirmation avec la session courante
+  useEffect(() => {
+    const confirmToken = searchParams.get('link_confirm');
+    const linkError = searchParams.get('link_error');
+    if (linkError) {
+      toast.error('Le rattachement a expiré, veuillez réessayer');
+      setSearchParams({}, { replace: true });
+      return;
+    }
+    if (!confirmToken || confirming.current) return;
+    confirming.current = true;
+
+    api.post('/profile/identities/confirm', { confirm_token: confirmToken })
+      .then(() => {
+        toast.success('Compte lié');
+        load();
+      })
+      .catch((err) => toast.error(err instanceof APIError ? err.message : 'Rattachement impossible'))
+      .finally(() => setSearchParams({}, { replace: true }));
+  }, [searchParams, setSearchParams, load]);
+
+  const link = async (provider: string) => {
+    setIsLoading(true);
+    try {
+      const { link_token } = await api.post<{ link_token: string }>(`/profile/identities/${encodeURIComponent(provider)}/link`);
+      window.location.href = providerAuthURL(provider, { link: link_token });
+    } catch (err) {
+      toast.error(err instanceof APIError ? err.message : 'Une erreur est survenue');
+      setIsLoading(false);
+    }
+  };
+
+  const unlink = async (id: number) => {
+    setIsLoading(true);
+    try {
+      await api.delete(`/profile/identities/${id}`);
+      toast.success('Compte délié');
+      await load();
+    } catch (err) {
+      toast.error(err instanceof APIError ? err.message : 'Une erreur est survenue');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  if (!methods) return null;
+
+  const displayName = (name: string) => providers.find((p) => p.name === name)?.display_name ?? name;
+  const linked = new Set(methods.identities.map((i) => i.provider));
+  const canUnlink = methods.has_password || methods.identities.length > 1;
+
+  return (
+    <div className="p-4 bg-white/5 rounded-xl border border-white/10 space-y-3">
+      <div className="flex items-center gap-4">
+        <Link2 className="text-sky-400" />
+        <div>
+          <p className="text-[10px] text-slate-500 uppercase font-bold">Comptes liés</p>
+          <p className="text-white font-medium">
+            {methods.has_password ? 'Mot de passe' : 'Sans mot de passe'}
+            {methods.identities.length > 0 && ` + ${methods.identities.length} compte(s) externe(s)`}
+          </p>
+        </div>
+      </div>
+
+      {methods.identities.map((identity) => (
+        <div key={identity.id} className="flex items-center justify-between text-sm">
+          <span className="text-white">
+            {displayName(identity.provider)}
+            {identity.email && <span className="text-slate-500"> · {identity.email}</span>}
+          </span>
+          <button
+            onClick={() => unlink(identity.id)}
+            disabled={isLoading || !canUnlink}
+            title={canUnlink ? undefined : 'Dernier moyen de connexion du compte'}
+            className="text-red-300 text-xs font-black uppercase disabled:opacity-30"
+          >
+            Délier
+          </button>
+        </div>
+      ))}
+
+      <div className="flex flex-wrap gap-2">
+        {providers.filter((p) => !linked.has(p.name)).map((provider) => (
+          <button
+            key={provider.name}
+            onClick={() => link(provider.name)}
+            disabled={isLoading}
+            className="bg-white/10 text-white font-black px-3 py-2 rounded-xl text-xs uppercase hover:bg-white/20 transition-all"
+          >
+            Lier {provider.display_name}
+          </button>
+        ))}
+      </div>
+    </div>
+  );
+}
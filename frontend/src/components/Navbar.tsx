@@ -2,6 +2,7 @@ import { useState, useEffect } from 'react';
 import { Search, User, Menu, X, LogOut, ShoppingCart } from 'lucide-react';
 import { Link, useNavigate, useLocation } from 'react-router-dom';
 import { useAuthStore } from '../stores/useAuthStore';
+import { logoutSession, fetchSuggestions, type Suggestion } from '../lib/api';
 import { useCartStore } from '../stores/useCartStore';
 import CartDrawer from './CartDrawer';
 
@@ -9,11 +10,12 @@ export default function Navbar() {
   const [isScrolled, setIsScrolled] = useState(false);
   const [showSearch, setShowSearch] = useState(false);
   const [searchQuery, setSearchQuery] = useState('');
+  const [suggestions, setSuggestions] = useState<Suggestion[]>([]);
   const [mobileMenuOpen, setMobileMenuOpen] = useState(false);
   
   const navigate = useNavigate();
   const location = useLocation();
-  const { user, logout } = useAuthStore();
+  const { user } = useAuthStore();
   const { items, toggleCart } = useCartStore();
   
   const totalItems = items.reduce((acc, item) => acc + item.quantity, 0);
@@ -26,13 +28,51 @@ export default function Navbar() {
     return () => window.removeEventListener('scroll', handleScroll);
   }, []);
 
+  // Suggestions pendant la saisie, une requête par pause de frappe
+  useEffect(() => {
+    const query = searchQuery.trim();
+    if (!query) {
+      setSuggestions([]);
+      return;
+    }
+    let cancelled = false;
+    const timer = setTimeout(() => {
+      fetchSuggestions(query)
+        .then((results) => { if (!cancelled) setSuggestions(results); })
+        .catch(() => { if (!cancelled) setSuggestions([]); });
+    }, 150);
+    return () => {
+      cancelled = true;
+      clearTimeout(timer);
+    };
+  }, [searchQuery]);
+
   const handleSearchSubmit = (e: React.KeyboardEvent) => {
     if (e.key === 'Enter' && searchQuery.trim()) {
       navigate(`/artists?search=${encodeURIComponent(searchQuery)}`);
       setShowSearch(false);
+      setSuggestions([]);
     }
   };
 
+  const handleSuggestionClick = (suggestion: Suggestion) => {
+    if (suggestion.artist_id) {
+      navigate(`/artist/${suggestion.artist_id}`);
+    } else {
+      navigate(`/concerts?search=${encodeURIComponent(suggestion.label)}`);
+    }
+    setSearchQuery('');
+    setSuggestions([]);
+    setShowSearch(false);
+  };
+
+  const suggestionTypeLabels: Record<Suggestion['type'], string> = {
+    artist: 'Artiste',
+    track: 'Titre',
+    venue: 'Salle',
+    city: 'Ville',
+  };
+
   const navLinks = [
     { name: 'Accueil', href: '/' },
     { name: 'Artistes', href: '/artists' },
@@ -96,6 +136,30 @@ export default function Navbar() {
                       onBlur={() => !searchQuery && setShowSearch(false)}
                     />
                 </div>
+                {showSearch && suggestions.length > 0 && (
+                  <ul className="absolute top-full left-0 mt-2 w-64 bg-zinc-900 border border-white/10 rounded-xl shadow-xl overflow-hidden z-50">
+                    {suggestions.map((suggestion) => (
+                      <li key={`${suggestion.type}-${suggestion.label}-${suggestion.subtitle ?? ''}`}>
+                        <button
+                          type="button"
+                          onMouseDown={(e) => e.preventDefault()}
+                          onClick={() => handleSuggestionClick(suggestion)}
+                          className="w-full text-left px-4 py-2 hover:bg-white/10 flex items-center justify-between gap-2"
+                        >
+                          <span className="min-w-0">
+                            <span className="block text-sm text-white truncate">{suggestion.label}</span>
+                            {suggestion.subtitle && (
+                              <span className="block text-xs text-zinc-500 truncate">{suggestion.subtitle}</span>
+                            )}
+                          </span>
+                          <span className="text-[10px] uppercase tracking-wide text-violet-400 shrink-0">
+                            {suggestionTypeLabels[suggestion.type]}
+                          </span>
+                        </button>
+                      </li>
+                    ))}
+                  </ul>
+                )}
                 <button onClick={() => setShowSearch(!showSearch)} className="p-2.5 bg-white/5 border border-white/5 rounded-full hover:bg-white/10 hover:text-violet-400 transition-all text-zinc-300">
                   <Search className="w-5 h-5" />
                 </button>
@@ -119,7 +183,10 @@ export default function Navbar() {
                         </div>
                     </button>
                     <div className="absolute right-0 top-full mt-2 w-32 bg-zinc-900 border border-white/10 rounded-xl p-1 opacity-0 invisible group-hover:opacity-100 group-hover:visible transition-all duration-200 transform translate-y-2 group-hover:translate-y-0 shadow-xl">
-                        <button onClick={() => logout()} className="w-full flex items-center gap-2 px-3 py-2 text-sm text-red-400 hover:bg-white/5 rounded-lg transition-colors">
+                        <Link to="/profile" className="w-full flex items-center gap-2 px-3 py-2 text-sm text-slate-300 hover:bg-white/5 rounded-lg transition-colors">
+                            <User size={14} /> Profil
+                        </Link>
+                        <button onClick={() => logoutSession()} className="w-full flex items-center gap-2 px-3 py-2 text-sm text-red-400 hover:bg-white/5 rounded-lg transition-colors">
                             <LogOut size={14} /> Déconnexion
                         </button>
                     </div>
@@ -0,0 +1,120 @@
+import { useState } from 'react';
+import { KeyRound } from 'lucide-react';
+import { toast } from 'sonner';
+import { api, APIError, type TwoFactorSetup } from '../lib/api';
+import { useAuthStore } from '../stores/useAuthStore';
+
+// Activation / désactivation de la double authentification (TOTP) depuis le profil
+export default function TwoFactorSettings() {
+  const { user } = useAuthStore();
+  const [setup, setSetup] = useState<TwoFactorSetup | null>(null);
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
+  const [code, setCode] = useState('');
+  const [isLoading, setIsLoading] = useState(false);
+
+  if (!user) return null;
+  const enabled = !!user.two_factor_enabled;
+
+  const setEnabled = (value: boolean) => {
+    useAuthStore.setState({ user: { ...user, two_factor_enabled: value } });
+  };
+
+  const run = async (action: () => Promise<void>) => {
+    setIsLoading(true);
+    try {
+      await action();
+    } catch (err) {
+      toast.error(err instanceof APIError ? err.message : 'Une erreur est survenue');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  const startSetup = () => run(async () => {
+    setSetup(await api.post<TwoFactorSetup>('/auth/2fa/setup'));
+    setCode('');
+  });
+
+  const confirmSetup = (e: React.FormEvent) => {
+    e.preventDefault();
+    run(async () => {
+      const response = await api.post<{ recovery_codes: string[] }>('/auth/2fa/verify', { code });
+      setRecoveryCodes(response.recovery_codes);
+      setSetup(null);
+      setCode('');
+      setEnabled(true);
+      toast.success('Double authentification activée');
+    });
+  };
+
+  const disable = (e: React.FormEvent) => {
+    e.preventDefault();
+    run(async () => {
+      await api.post('/auth/2fa/disable', { code });
+      setCode('');
+      setRecoveryCodes(null);
+      setEnabled(false);
+      toast.success('Double authentification désactivée');
+    });
+  };
+
+  const codeInput = (
+    <input
+      type="text"
+      autoComplete="one-time-code"
+      value={code}
+      onChange={(e) => setCode(e.target.value)}
+      className="flex-1 bg-white/5 border border-white/10 rounded-xl px-4 py-3 text-white tracking-widest outline-none focus:border-violet-500"
+      placeholder="123456"
+      required
+    />
+  );
+
+  return (
+    <div className="p-4 bg-white/5 rounded-xl border border-white/10 space-y-4">
+      <div className="flex items-center gap-4">
+        <KeyRound className="text-fuchsia-400" />
+        <div>
+          <p className="text-[10px] text-slate-500 uppercase font-bold">Double authentification</p>
+          <p className="text-white font-medium">{enabled ? "Activée 🔐" : "Désactivée"}</p>
+        </div>
+      </div>
+
+      {recoveryCodes && (
+        <div className="p-4 bg-amber-500/10 border border-amber-500/30 rounded-xl">
+          <p className="text-amber-300 text-xs font-bold mb-2">
+            Codes de secours : conservez-les en lieu sûr, ils ne seront plus affichés. Chacun ne sert qu'une fois.
+          </p>
+          <div className="grid grid-cols-2 gap-1 font-mono text-sm text-white">
+            {recoveryCodes.map((c) => <span key={c}>{c}</span>)}
+          </div>
+        </div>
+      )}
+
+      {!enabled && !setup && (
+        <button onClick={startSetup} disabled={isLoading} className="bg-white text-black font-black px-4 py-2 rounded-xl text-xs uppercase hover:bg-violet-500 hover:text-white transition-all">
+          Activer la 2FA
+        </button>
+      )}
+
+      {!enabled && setup && (
+        <form onSubmit={confirmSetup} className="space-y-3">
+          <p className="text-xs text-slate-400">Scannez ce QR code avec votre application d'authentification, puis saisissez le code affiché.</p>
+          <img src={setup.qr_code} alt="QR code 2FA" className="w-48 h-48 rounded-xl bg-white p-2" />
+          <p className="text-[10px] text-slate-500 break-all">Clé manuelle : <span className="font-mono">{setup.secret}</span></p>
+          <div className="flex gap-2">
+            {codeInput}
+            <button type="submit" disabled={isLoading} className="bg-white text-black font-black px-4 rounded-xl text-xs uppercase">Vérifier</button>
+          </div>
+        </form>
+      )}
+
+      {enabled && (
+        <form onSubmit={disable} className="flex gap-2">
+          {codeInput}
+          <button type="submit" disabled={isLoading} className="bg-red-500/20 text-red-300 font-black px-4 rounded-xl text-xs uppercase">Désactiver</button>
+        </form>
+      )}
+    </div>
+  );
+}
@@ -1,3 +1,4 @@
+import { Capacitor } from '@capacitor/core'
 import { useAuthStore } from '../stores/useAuthStore'
 
 const API_BASE_URL = import.meta.env.VITE_API_URL || '/api'
@@ -8,17 +9,85 @@ interface FetchOptions extends RequestInit {
 
 class APIError extends Error {
   status: number
+  fields?: Record<string, string> // erreurs de validation par champ (admin)
   
-  constructor(message: string, status: number) {
+  constructor(message: string, status: number, fields?: Record<string, string>) {
     super(message)
     this.status = status
+    this.fields = fields
     this.name = 'APIError'
   }
 }
 
+function buildURL(endpoint: string): string {
+  // This logic robustly constructs the URL for all environments.
+  const isDev = import.meta.env.DEV;
+
+  if (isDev) {
+    // In development, always use the relative path for the Vite proxy.
+    // This ignores any VITE_API_URL in a local .env file.
+    return `/api${endpoint}`;
+  }
+
+  // In production, use the VITE_API_URL if it's set, otherwise use the proxy path.
+  // This supports both direct API calls and proxying via vercel.json.
+  const baseUrl = import.meta.env.VITE_API_URL || '';
+  return baseUrl ? `${baseUrl}/api${endpoint}` : `/api${endpoint}`;
+}
+
+// Un seul rafraîchissement à la fois : le refresh token change à chaque usage,
+// deux requêtes parallèles qui le présenteraient feraient révoquer la session.
+let refreshPromise: Promise<boolean> | null = null
+
+function refreshAccessToken(): Promise<boolean> {
+  if (refreshPromise) {
+    return refreshPromise
+  }
+
+  const refreshToken = useAuthStore.getState().refreshToken
+  if (!refreshToken) {
+    return Promise.resolve(false)
+  }
+
+  refreshPromise = fetch(buildURL('/auth/refresh'), {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ refresh_token: refreshToken }),
+  })
+    .then(async (response) => {
+      if (!response.ok) {
+        useAuthStore.getState().logout()
+        return false
+      }
+      const data: RefreshResponse = await response.json()
+      useAuthStore.getState().setTokens(data.token, data.refresh_token)
+      return true
+    })
+    .catch(() => false)
+    .finally(() => {
+      refreshPromise = null
+    })
+
+  return refreshPromise
+}
+
+// logoutSession révoque la session côté serveur puis vide le store
+export async function logoutSession(): Promise<void> {
+  const refreshToken = useAuthStore.getState().refreshToken
+  if (refreshToken) {
+    await fetch(buildURL('/auth/logout'), {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    }).catch(() => undefined)
+  }
+  useAuthStore.getState().logout()
+}
+
 async function apiRequest<T>(
   endpoint: string,
-  options: FetchOptions = {}
+  options: FetchOptions = {},
+  retried = false
 ): Promise<T> {
   const { ...fetchOptions } = options
   
@@ -32,20 +101,7 @@ async function apiRequest<T>(
     headers['Authorization'] = `Bearer ${token}`
   }
 
-  // This logic robustly constructs the URL for all environments.
-  const isDev = import.meta.env.DEV;
-  let url: string;
-
-  if (isDev) {
-    // In development, always use the relative path for the Vite proxy.
-    // This ignores any VITE_API_URL in a local .env file.
-    url = `/api${endpoint}`;
-  } else {
-    // In production, use the VITE_API_URL if it's set, otherwise use the proxy path.
-    // This supports both direct API calls and proxying via vercel.json.
-    const baseUrl = import.meta.env.VITE_API_URL || '';
-    url = baseUrl ? `${baseUrl}/api${endpoint}` : `/api${endpoint}`;
-  }
+  const url = buildURL(endpoint)
 
   const controller = new AbortController()
   const timeoutId = setTimeout(() => controller.abort(), 30000)
@@ -59,11 +115,19 @@ async function apiRequest<T>(
 
     clearTimeout(timeoutId)
 
+    // Access token expiré ou session révoquée : un essai de rafraîchissement puis on rejoue la requête
+    if (response.status === 401 && token && !retried && !endpoint.startsWith('/auth/')) {
+      if (await refreshAccessToken()) {
+        return apiRequest<T>(endpoint, options, true)
+      }
+    }
+
     if (!response.ok) {
       const errorData = await response.json().catch(() => ({ message: 'Request failed' }))
       throw new APIError(
         errorData.error || errorData.message || `HTTP ${response.status}`,
-        response.status
+        response.status,
+        errorData.fields
       )
     }
 
@@ -105,6 +169,29 @@ export const api = {
     apiRequest<T>(endpoint, { method: 'DELETE' /* , token */ }), // Removed token parameter
 }
 
+// Enveloppe des routes de liste : la page suivante se demande avec ?cursor=next_cursor
+export interface Page<T> {
+  items: T[];
+  next_cursor: string | null;
+  total: number;
+  limit: number;
+}
+
+// Autocomplétion de la barre de recherche (index en mémoire côté serveur)
+export interface Suggestion {
+  type: 'artist' | 'track' | 'venue' | 'city';
+  label: string;
+  subtitle?: string;
+  artist_id?: number;
+  concerts?: number;
+}
+
+export async function fetchSuggestions(query: string, limit = 8): Promise<Suggestion[]> {
+  const params = new URLSearchParams({ q: query, limit: String(limit) });
+  const res = await api.get<{ suggestions: Suggestion[] }>(`/search/suggest?${params}`);
+  return res.suggestions || [];
+}
+
 export interface RegisterRequest {
   email: string
   password: string
@@ -119,6 +206,8 @@ export interface LoginRequest {
 
 export interface AuthResponse {
   token: string
+  refresh_token: string
+  expires_in: number
   user: {
     id: number
     email: string
@@ -126,7 +215,96 @@ export interface AuthResponse {
     last_name: string  // ✅ Changé
     role: string
     email_verified: boolean // ✅ Ajouté pour ton vérificateur
+    two_factor_enabled: boolean
+  }
+}
+
+// Fournisseur de connexion externe (GET /auth/providers)
+export interface LoginProvider {
+  name: string;
+  display_name: string;
+}
+
+// URL de départ de la connexion (ou du rattachement) via un fournisseur externe :
+// navigation complète, pas un appel fetch
+export function providerAuthURL(provider: string, params: Record<string, string> = {}): string {
+  const query = new URLSearchParams(params).toString();
+  const path = `/api/auth/${encodeURIComponent(provider)}${query ? `?${query}` : ''}`;
+  const baseUrl = import.meta.env.DEV ? '' : (import.meta.env.VITE_API_URL || '');
+  return baseUrl ? `${baseUrl}${path}` : path;
+}
+
+// Connexion externe en cours : state et verifier PKCE restent côté client,
+// le serveur ne remet qu'un code de passation qui leur est lié
+const OAUTH_PENDING_KEY = 'oauth_pending';
+const OAUTH_PENDING_TTL = 10 * 60 * 1000;
+
+function randomBase64URL(size: number): string {
+  const bytes = crypto.getRandomValues(new Uint8Array(size));
+  return btoa(String.fromCharCode(...bytes)).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+async function s256(verifier: string): Promise<string> {
+  const digest = new Uint8Array(await crypto.subtle.digest('SHA-256', new TextEncoder().encode(verifier)));
+  return btoa(String.fromCharCode(...digest)).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+export async function startProviderLogin(provider: string): Promise<void> {
+  const state = randomBase64URL(32);
+  const verifier = randomBase64URL(32);
+  localStorage.setItem(OAUTH_PENDING_KEY, JSON.stringify({ state, verifier, created_at: Date.now() }));
+
+  const params: Record<string, string> = { client_state: state, code_challenge: await s256(verifier) };
+  // Sur Android, le retour se fait par deep link vers l'app
+  if (Capacitor.getPlatform() === 'android') params.platform = 'android';
+
+  // Capacitor ouvre les URLs externes dans le navigateur système
+  window.location.href = providerAuthURL(provider, params);
+}
+
+export async function exchangeProviderCode(code: string, state: string): Promise<LoginResponse> {
+  const raw = localStorage.getItem(OAUTH_PENDING_KEY);
+  localStorage.removeItem(OAUTH_PENDING_KEY);
+
+  const pending = raw ? JSON.parse(raw) as { state: string; verifier: string; created_at: number } : null;
+  if (!pending || pending.state !== state || Date.now() - pending.created_at > OAUTH_PENDING_TTL) {
+    throw new APIError('Tentative de connexion inconnue ou expirée, veuillez réessayer', 400);
   }
+  return api.post<LoginResponse>('/auth/exchange', { code, state, code_verifier: pending.verifier });
+}
+
+export interface UserIdentity {
+  id: number;
+  provider: string;
+  email?: string;
+  created_at: string;
+  last_login_at?: string;
+}
+
+export interface LoginMethods {
+  has_password: boolean;
+  identities: UserIdentity[];
+}
+
+export interface TwoFactorChallenge {
+  two_factor_required: true
+  challenge_token: string
+  expires_in: number
+}
+
+// Login renvoie un challenge à la place des tokens quand la 2FA est active
+export type LoginResponse = AuthResponse | TwoFactorChallenge
+
+export interface TwoFactorSetup {
+  secret: string
+  otpauth_uri: string
+  qr_code: string
+}
+
+export interface RefreshResponse {
+  token: string
+  refresh_token: string
+  expires_in: number
 }
 
 export interface CreatePaymentIntentRequest {
@@ -30,6 +30,27 @@ export function formatCurrency(amount: number, currency: string = 'EUR'): string
   }).format(amount);
 }
 
+// Nombre d'unités mineures par unité de la devise (100 pour EUR, 1 pour JPY)
+export function minorUnitDivisor(currency: string): number {
+  const decimals = new Intl.NumberFormat('fr-FR', {
+    style: 'currency',
+    currency: currency.toUpperCase(),
+  }).resolvedOptions().maximumFractionDigits ?? 2;
+  return Math.pow(10, decimals);
+}
+
+// Formate un montant en unités mineures (centimes, ou unités pour JPY) tel que renvoyé par l'API
+export function formatMinorAmount(amount: number, currency: string = 'EUR'): string {
+  return formatCurrency(amount / minorUnitDivisor(currency), currency.toUpperCase());
+}
+
+// Formate un total par devise, ex. { eur: 12000, usd: 5000 } → "120,00 € · 50,00 $US"
+export function formatRevenueByCurrency(revenue: Record<string, number> | null | undefined): string {
+  const entries = Object.entries(revenue ?? {});
+  if (entries.length === 0) return formatMinorAmount(0);
+  return entries.map(([currency, amount]) => formatMinorAmount(amount, currency)).join(' · ');
+}
+
 export function formatDate(date: string | Date): string {
   return new Intl.DateTimeFormat('fr-FR', {
     year: 'numeric',
@@ -23,6 +23,7 @@ import {
   ArcElement
 } from 'chart.js';
 import { useAuthApi } from '@/hooks/useAuthApi'; // Import the new hook
+import { formatRevenueByCurrency, minorUnitDivisor } from '@/lib/utils';
 ChartJS.register(
   CategoryScale,
   LinearScale,
@@ -39,17 +40,18 @@ interface DashboardStats {
   total_artists: number;
   total_concerts: number;
   total_users: number;
-  total_revenue: number;
+  total_revenue: Record<string, number>; // par devise, en unités mineures
   recent_bookings: number;
   upcoming_concerts: number;
   popular_artists: {
     artist_name: string;
     artist_image: string;
     total_bookings: number;
-    total_revenue: number;
+    total_revenue: Record<string, number>;
   }[];
   revenue_by_month: {
     month: string;
+    currency: string;
     revenue: number;
   }[];
   bookings_by_status: Record<string, number>;
@@ -89,18 +91,26 @@ export default function AdminDashboardPage() {
     );
   }
 
+  // Une courbe par devise : les montants de devises différentes ne s'additionnent pas
+  const revenueMonths = [...new Set((stats.revenue_by_month ?? []).map(d => d.month))];
+  const revenueCurrencies = [...new Set((stats.revenue_by_month ?? []).map(d => d.currency))];
+  const revenueColors = ['168, 85, 247', '34, 197, 94', '59, 130, 246', '234, 179, 8'];
   const revenueChartData = {
-    labels: stats.revenue_by_month.map(d => d.month),
-    datasets: [
-      {
-        label: 'Revenue (€)',
-        data: stats.revenue_by_month.map(d => d.revenue),
-        borderColor: 'rgb(168, 85, 247)',
-        backgroundColor: 'rgba(168, 85, 247, 0.1)',
+    labels: revenueMonths,
+    datasets: revenueCurrencies.map((currency, i) => {
+      const divisor = minorUnitDivisor(currency);
+      const color = revenueColors[i % revenueColors.length];
+      return {
+        label: `Revenue (${currency.toUpperCase()})`,
+        data: revenueMonths.map(month =>
+          (stats.revenue_by_month.find(d => d.month === month && d.currency === currency)?.revenue ?? 0) / divisor
+        ),
+        borderColor: `rgb(${color})`,
+        backgroundColor: `rgba(${color}, 0.1)`,
         tension: 0.4,
         fill: true,
-      },
-    ],
+      };
+    }),
   };
 
   const bookingsChartData = {
@@ -161,7 +171,7 @@ export default function AdminDashboardPage() {
             </CardHeader>
             <CardContent>
               <div className="text-3xl font-bold text-white">
-                {stats.total_revenue.toFixed(2)}€
+                {formatRevenueByCurrency(stats.total_revenue)}
               </div>
               <p className="text-xs text-slate-400 mt-1">
                 +{stats.recent_bookings} réservations (7j)
@@ -277,7 +287,7 @@ export default function AdminDashboardPage() {
                     </div>
                   </div>
                   <Badge variant="outline" className="text-green-400 border-green-400">
-                    {artist.total_revenue.toFixed(2)}€
+                    {formatRevenueByCurrency(artist.total_revenue)}
                   </Badge>
                 </div>
               ))}
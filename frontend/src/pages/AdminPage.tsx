@@ -32,7 +32,8 @@ import {
   CreditCard,
   LayoutDashboard
 } from 'lucide-react';
-import { api } from '@/lib/api';
+import { api, APIError, type Page } from '@/lib/api';
+import { formatMinorAmount, minorUnitDivisor } from '@/lib/utils';
 import { useNavigate } from 'react-router-dom';
 import { toast } from 'sonner';
 import { useAuthApi } from '@/hooks/useAuthApi'; // Import the new hook
@@ -42,20 +43,28 @@ interface Artist {
   id: number;
   name: string;
   image: string;
+  genre?: string;
   members: string[];
-  creation_date: number;
-  first_album: string;
+  creationDate: number;
+  firstAlbum: string;
 }
 
 interface Concert {
   id: number;
   artist_id: number;
+  name: string;
   artist_name: string;
   artist_image: string;
-  location: string;
+  venue: string;
+  city: string;
   date: string;
-  available_tickets: number;
-  price: number;
+  standard_price: number; // en unités mineures de currency
+  vip_price: number;
+  currency: string;
+  available_standard: number;
+  available_vip: number;
+  lat?: number; // position de la salle (carte, recherche par rayon)
+  lng?: number;
 }
 
 interface User {
@@ -76,11 +85,46 @@ interface Payment {
   concert_location: string;
   concert_date: string;
   tickets: number;
-  total_price: number;
+  total_price: number; // en unités mineures de currency
+  currency: string;
   payment_status: string;
   created_at: string;
 }
 
+// Message d'erreur d'un formulaire, avec le détail par champ renvoyé par l'API
+function describeSaveError(error: unknown, fallback: string): string {
+  if (error instanceof APIError && error.fields) {
+    return Object.entries(error.fields).map(([field, message]) => `${field} : ${message}`).join(' · ');
+  }
+  if (error instanceof APIError && error.status === 409) {
+    return error.message;
+  }
+  return fallback;
+}
+
+// Convertit un montant saisi (ex. 49.90) en unités mineures pour l'API
+function toMinorUnits(value: FormDataEntryValue | null, currency: string): number {
+  let divisor = 100;
+  try {
+    divisor = minorUnitDivisor(currency);
+  } catch {
+    // devise inconnue : l'API renverra l'erreur sur le champ currency
+  }
+  return Math.round(Number(value) * divisor);
+}
+
+// Coordonnée facultative : un champ vide n'envoie rien
+function toCoordinate(value: FormDataEntryValue | null): number | undefined {
+  const raw = String(value ?? '').trim();
+  return raw === '' ? undefined : Number(raw);
+}
+
+// Valeur d'un input datetime-local (heure locale) pour une date ISO
+function toDateTimeLocal(iso: string): string {
+  const date = new Date(iso);
+  return new Date(date.getTime() - date.getTimezoneOffset() * 60000).toISOString().slice(0, 16);
+}
+
 export default function AdminPage() {
   const navigate = useNavigate();
   const [activeTab, setActiveTab] = useState('dashboard');
@@ -92,6 +136,8 @@ export default function AdminPage() {
   const [users, setUsers] = useState<User[]>([]);
   const [payments, setPayments] = useState<Payment[]>([]);
   const [loading, setLoading] = useState(true);
+  const [nextCursors, setNextCursors] = useState<Record<string, string | null>>({});
+  const [totals, setTotals] = useState<Record<string, number>>({});
 
   // Dialog States
   const [showArtistDialog, setShowArtistDialog] = useState(false);
@@ -104,46 +150,105 @@ export default function AdminPage() {
   }, [activeTab]);
 
   // --- Chargement des données ---
+  const listEndpoints: Record<string, string> = {
+    artists: '/admin/artists',
+    concerts: '/admin/concerts',
+    users: '/admin/users',
+    payments: '/admin/payments',
+  };
+
+  // Charge une page d'une liste et retient son curseur pour "Charger plus"
+  const fetchPage = async <T,>(tab: string, cursor?: string | null): Promise<T[]> => {
+    const params = new URLSearchParams({ limit: '50' });
+    if (cursor) params.set('cursor', cursor);
+    const page = await authApi.get<Page<T>>(`${listEndpoints[tab]}?${params}`);
+    setNextCursors(prev => ({ ...prev, [tab]: page.next_cursor }));
+    setTotals(prev => ({ ...prev, [tab]: page.total }));
+    return page.items || [];
+  };
+
+  // Le select du formulaire concert a besoin de tous les artistes
+  const fetchAllArtists = async (): Promise<Artist[]> => {
+    const all: Artist[] = [];
+    let cursor: string | null = null;
+    do {
+      const params = new URLSearchParams({ limit: '200' });
+      if (cursor) params.set('cursor', cursor);
+      const page: Page<Artist> = await authApi.get<Page<Artist>>(`/admin/artists?${params}`);
+      all.push(...(page.items || []));
+      cursor = page.next_cursor;
+    } while (cursor);
+    return all;
+  };
+
   const loadData = async () => {
     setLoading(true);
     try {
-      // NOTE: On assume ici que l'API renvoie directement le tableau (sans .data wrapper)
-      // Si votre API renvoie { data: [...] }, rajoutez .data après le await.
       switch (activeTab) {
+        case 'artists':
+          setArtists(await fetchPage<Artist>('artists'));
+          break;
+        case 'concerts': {
+          setConcerts(await fetchPage<Concert>('concerts'));
+          setArtists(await fetchAllArtists());
+          break;
+        }
+        case 'users':
+          setUsers(await fetchPage<User>('users'));
+          break;
+        case 'payments':
+          setPayments(await fetchPage<Payment>('payments'));
+          break;
+      }
+    } catch (error) {
+      console.error('Error loading data:', error);
+      toast.error("Erreur lors du chargement des données !");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const loadMore = async (tab: string) => {
+    const cursor = nextCursors[tab];
+    if (!cursor) return;
+    try {
+      switch (tab) {
         case 'artists': {
-          const artistsRes = await authApi.get<Artist[]>('/admin/artists');
-          setArtists(artistsRes || []);
+          const more = await fetchPage<Artist>(tab, cursor);
+          setArtists(prev => [...prev, ...more]);
           break;
         }
         case 'concerts': {
-          const concertsRes = await authApi.get<Concert[]>('/admin/concerts');
-          // On charge aussi les artistes pour le select du formulaire concert
-          if (artists.length === 0) {
-             const allArtists = await authApi.get<Artist[]>('/admin/artists');
-             setArtists(allArtists || []);
-          }
-          setConcerts(concertsRes || []);
+          const more = await fetchPage<Concert>(tab, cursor);
+          setConcerts(prev => [...prev, ...more]);
           break;
         }
         case 'users': {
-          const usersRes = await authApi.get<User[]>('/admin/users');
-          setUsers(usersRes || []);
+          const more = await fetchPage<User>(tab, cursor);
+          setUsers(prev => [...prev, ...more]);
           break;
         }
         case 'payments': {
-          const paymentsRes = await authApi.get<Payment[]>('/admin/payments');
-          setPayments(paymentsRes || []);
+          const more = await fetchPage<Payment>(tab, cursor);
+          setPayments(prev => [...prev, ...more]);
           break;
         }
       }
     } catch (error) {
-      console.error('Error loading data:', error);
+      console.error('Error loading more data:', error);
       toast.error("Erreur lors du chargement des données !");
-    } finally {
-      setLoading(false);
     }
   };
 
+  const renderLoadMore = (tab: string, shown: number) => (
+    nextCursors[tab] ? (
+      <div className="flex items-center justify-center gap-4 py-4">
+        <span className="text-sm text-slate-400">{shown} / {totals[tab] ?? shown}</span>
+        <Button variant="outline" onClick={() => loadMore(tab)}>Charger plus</Button>
+      </div>
+    ) : null
+  );
+
   // --- Actions ---
 
   const handleDeleteArtist = async (id: number) => {
@@ -154,7 +259,7 @@ export default function AdminPage() {
       toast.success("Artiste supprimé avec succès !");
     } catch (error) {
       console.error('Error deleting artist:', error);
-      toast.error("Erreur lors de la suppression de l'artiste !");
+      toast.error(describeSaveError(error, "Erreur lors de la suppression de l'artiste !"));
     }
   };
 
@@ -166,7 +271,7 @@ export default function AdminPage() {
       toast.success("Concert supprimé avec succès !");
     } catch (error) {
       console.error('Error deleting concert:', error);
-      toast.error("Erreur lors de la suppression du concert !");
+      toast.error(describeSaveError(error, "Erreur lors de la suppression du concert !"));
     }
   };
 
@@ -181,9 +286,10 @@ export default function AdminPage() {
     const artistData = {
       name: formData.get('name'),
       image: formData.get('image'),
+      genre: formData.get('genre'),
       members: membersArray,
-      creation_date: Number(formData.get('creation_date')),
-      first_album: formData.get('first_album'),
+      creationDate: Number(formData.get('creationDate')),
+      firstAlbum: formData.get('firstAlbum'),
     };
 
     try {
@@ -198,7 +304,7 @@ export default function AdminPage() {
       loadData();
     } catch (error) {
       console.error('Error saving artist:', error);
-      toast.error("Erreur lors de la sauvegarde de l'artiste !");
+      toast.error(describeSaveError(error, "Erreur lors de la sauvegarde de l'artiste !"));
     }
   };
 
@@ -206,12 +312,20 @@ export default function AdminPage() {
     e.preventDefault();
     const formData = new FormData(e.currentTarget);
     
+    const currency = ((formData.get('currency') as string) || 'eur').toLowerCase();
     const concertData = {
       artist_id: Number(formData.get('artist_id')),
-      location: formData.get('location'),
-      date: formData.get('date'), // Format YYYY-MM-DD
-      available_tickets: Number(formData.get('available_tickets')),
-      price: Number(formData.get('price')),
+      name: formData.get('name'),
+      venue: formData.get('venue'),
+      city: formData.get('city'),
+      date: new Date(formData.get('date') as string).toISOString(), // datetime-local → RFC 3339
+      currency,
+      standard_price: toMinorUnits(formData.get('standard_price'), currency),
+      vip_price: toMinorUnits(formData.get('vip_price'), currency),
+      available_standard: Number(formData.get('available_standard')),
+      available_vip: Number(formData.get('available_vip')),
+      lat: toCoordinate(formData.get('lat')),
+      lng: toCoordinate(formData.get('lng')),
     };
 
     try {
@@ -226,7 +340,7 @@ export default function AdminPage() {
       loadData();
     } catch (error) {
       console.error('Error saving concert:', error);
-      toast.error("Erreur lors de la sauvegarde du concert !");
+      toast.error(describeSaveError(error, "Erreur lors de la sauvegarde du concert !"));
     }
   };
 
@@ -306,8 +420,8 @@ export default function AdminPage() {
                           <TableCell className="text-slate-400">
                             {Array.isArray(artist.members) ? artist.members.length : 0} membre(s)
                           </TableCell>
-                          <TableCell className="text-slate-400">{artist.creation_date}</TableCell>
-                          <TableCell className="text-slate-400">{artist.first_album}</TableCell>
+                          <TableCell className="text-slate-400">{artist.creationDate}</TableCell>
+                          <TableCell className="text-slate-400">{artist.firstAlbum}</TableCell>
                           <TableCell>
                             <div className="flex gap-2">
                               <Button size="sm" variant="outline" onClick={() => { setEditingArtist(artist); setShowArtistDialog(true); }}>
@@ -323,6 +437,7 @@ export default function AdminPage() {
                     </TableBody>
                   </Table>
                 </div>
+                {renderLoadMore('artists', artists.length)}
               </CardContent>
             </Card>
           </TabsContent>
@@ -348,8 +463,8 @@ export default function AdminPage() {
                         <TableHead className="text-slate-300">Artiste</TableHead>
                         <TableHead className="text-slate-300">Lieu</TableHead>
                         <TableHead className="text-slate-300">Date</TableHead>
-                        <TableHead className="text-slate-300">Tickets</TableHead>
-                        <TableHead className="text-slate-300">Prix</TableHead>
+                        <TableHead className="text-slate-300">Places (std / VIP)</TableHead>
+                        <TableHead className="text-slate-300">Prix (std / VIP)</TableHead>
                         <TableHead className="text-slate-300">Actions</TableHead>
                       </TableRow>
                     </TableHeader>
@@ -362,10 +477,12 @@ export default function AdminPage() {
                               <span className="font-semibold text-white">{concert.artist_name}</span>
                             </div>
                           </TableCell>
-                          <TableCell className="text-slate-400">{concert.location}</TableCell>
+                          <TableCell className="text-slate-400">{concert.venue}, {concert.city}</TableCell>
                           <TableCell className="text-slate-400">{new Date(concert.date).toLocaleDateString('fr-FR')}</TableCell>
-                          <TableCell className="text-slate-400">{concert.available_tickets}</TableCell>
-                          <TableCell className="text-green-400 font-semibold">{concert.price}€</TableCell>
+                          <TableCell className="text-slate-400">{concert.available_standard} / {concert.available_vip}</TableCell>
+                          <TableCell className="text-green-400 font-semibold">
+                            {formatMinorAmount(concert.standard_price, concert.currency)} / {formatMinorAmount(concert.vip_price, concert.currency)}
+                          </TableCell>
                           <TableCell>
                             <div className="flex gap-2">
                               <Button size="sm" variant="outline" onClick={() => { setEditingConcert(concert); setShowConcertDialog(true); }}>
@@ -381,6 +498,7 @@ export default function AdminPage() {
                     </TableBody>
                   </Table>
                 </div>
+                {renderLoadMore('concerts', concerts.length)}
               </CardContent>
             </Card> {/* <--- C'EST CETTE LIGNE QUI MANQUAIT ! */}
           </TabsContent>
@@ -422,6 +540,7 @@ export default function AdminPage() {
                     </TableBody>
                   </Table>
                 </div>
+                {renderLoadMore('users', users.length)}
               </CardContent>
             </Card>
           </TabsContent>
@@ -455,7 +574,7 @@ export default function AdminPage() {
                             <div className="text-sm text-slate-400">{payment.concert_location}</div>
                           </TableCell>
                           <TableCell className="text-slate-400">{payment.tickets}</TableCell>
-                          <TableCell className="text-green-400 font-semibold">{payment.total_price}€</TableCell>
+                          <TableCell className="text-green-400 font-semibold">{formatMinorAmount(payment.total_price, payment.currency)}</TableCell>
                           <TableCell>
                             <Badge className={
                               payment.payment_status === 'succeeded' ? 'bg-green-500' :
@@ -470,6 +589,7 @@ export default function AdminPage() {
                     </TableBody>
                   </Table>
                 </div>
+                {renderLoadMore('payments', payments.length)}
               </CardContent>
             </Card>
           </TabsContent>
@@ -496,6 +616,10 @@ export default function AdminPage() {
               <Label htmlFor="image">Image URL</Label>
               <Input id="image" name="image" defaultValue={editingArtist?.image} required className="bg-slate-800 border-slate-600" />
             </div>
+            <div className="space-y-2">
+              <Label htmlFor="genre">Genre</Label>
+              <Input id="genre" name="genre" defaultValue={editingArtist?.genre} placeholder="Rap FR" className="bg-slate-800 border-slate-600" />
+            </div>
             <div className="space-y-2">
               <Label htmlFor="members">Membres (séparés par des virgules)</Label>
               <Textarea 
@@ -508,12 +632,12 @@ export default function AdminPage() {
             </div>
             <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
               <div className="space-y-2">
-                <Label htmlFor="creation_date">Année de création</Label>
-                <Input id="creation_date" name="creation_date" type="number" defaultValue={editingArtist?.creation_date} required className="bg-slate-800 border-slate-600" />
+                <Label htmlFor="creationDate">Année de création</Label>
+                <Input id="creationDate" name="creationDate" type="number" defaultValue={editingArtist?.creationDate} required className="bg-slate-800 border-slate-600" />
               </div>
               <div className="space-y-2">
-                <Label htmlFor="first_album">Premier Album</Label>
-                <Input id="first_album" name="first_album" defaultValue={editingArtist?.first_album} required className="bg-slate-800 border-slate-600" />
+                <Label htmlFor="firstAlbum">Premier Album</Label>
+                <Input id="firstAlbum" name="firstAlbum" defaultValue={editingArtist?.firstAlbum} required className="bg-slate-800 border-slate-600" />
               </div>
             </div>
             <DialogFooter>
@@ -547,29 +671,64 @@ export default function AdminPage() {
               </select>
             </div>
             <div className="space-y-2">
-              <Label htmlFor="location">Lieu</Label>
-              <Input id="location" name="location" defaultValue={editingConcert?.location} required className="bg-slate-800 border-slate-600" />
+              <Label htmlFor="concert_name">Nom (par défaut : l'artiste)</Label>
+              <Input id="concert_name" name="name" defaultValue={editingConcert?.name} className="bg-slate-800 border-slate-600" />
             </div>
-            <div className="space-y-2">
-              <Label htmlFor="date">Date</Label>
-              <Input 
-                id="date" 
-                name="date" 
-                type="date" 
-                // Formatage simple pour l'input date si la date arrive au format ISO
-                defaultValue={editingConcert?.date ? new Date(editingConcert.date).toISOString().split('T')[0] : ''} 
-                required 
-                className="bg-slate-800 border-slate-600 text-white" 
-              />
+            <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+              <div className="space-y-2">
+                <Label htmlFor="venue">Salle</Label>
+                <Input id="venue" name="venue" defaultValue={editingConcert?.venue} required className="bg-slate-800 border-slate-600" />
+              </div>
+              <div className="space-y-2">
+                <Label htmlFor="city">Ville</Label>
+                <Input id="city" name="city" defaultValue={editingConcert?.city} required className="bg-slate-800 border-slate-600" />
+              </div>
+            </div>
+            <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+              <div className="space-y-2">
+                <Label htmlFor="date">Date et heure</Label>
+                <Input 
+                  id="date" 
+                  name="date" 
+                  type="datetime-local" 
+                  defaultValue={editingConcert?.date ? toDateTimeLocal(editingConcert.date) : ''} 
+                  required 
+                  className="bg-slate-800 border-slate-600 text-white" 
+                />
+              </div>
+              <div className="space-y-2">
+                <Label htmlFor="currency">Devise</Label>
+                <Input id="currency" name="currency" maxLength={3} defaultValue={editingConcert?.currency ?? 'eur'} required className="bg-slate-800 border-slate-600" />
+              </div>
+            </div>
+            <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+              <div className="space-y-2">
+                <Label htmlFor="standard_price">Prix standard</Label>
+                <Input id="standard_price" name="standard_price" type="number" step="0.01" min="0" defaultValue={editingConcert ? editingConcert.standard_price / minorUnitDivisor(editingConcert.currency) : ''} required className="bg-slate-800 border-slate-600" />
+              </div>
+              <div className="space-y-2">
+                <Label htmlFor="vip_price">Prix VIP</Label>
+                <Input id="vip_price" name="vip_price" type="number" step="0.01" min="0" defaultValue={editingConcert ? editingConcert.vip_price / minorUnitDivisor(editingConcert.currency) : ''} required className="bg-slate-800 border-slate-600" />
+              </div>
+            </div>
+            <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+              <div className="space-y-2">
+                <Label htmlFor="available_standard">Places standard en vente</Label>
+                <Input id="available_standard" name="available_standard" type="number" min="0" defaultValue={editingConcert?.available_standard} required className="bg-slate-800 border-slate-600" />
+              </div>
+              <div className="space-y-2">
+                <Label htmlFor="available_vip">Places VIP en vente</Label>
+                <Input id="available_vip" name="available_vip" type="number" min="0" defaultValue={editingConcert?.available_vip} required className="bg-slate-800 border-slate-600" />
+              </div>
             </div>
             <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
               <div className="space-y-2">
-                <Label htmlFor="available_tickets">Tickets Disponibles</Label>
-                <Input id="available_tickets" name="available_tickets" type="number" defaultValue={editingConcert?.available_tickets} required className="bg-slate-800 border-slate-600" />
+                <Label htmlFor="lat">Latitude de la salle</Label>
+                <Input id="lat" name="lat" type="number" step="any" min="-90" max="90" defaultValue={editingConcert?.lat ?? ''} placeholder="48.8386" className="bg-slate-800 border-slate-600" />
               </div>
               <div className="space-y-2">
-                <Label htmlFor="price">Prix (€)</Label>
-                <Input id="price" name="price" type="number" step="0.01" defaultValue={editingConcert?.price} required className="bg-slate-800 border-slate-600" />
+                <Label htmlFor="lng">Longitude de la salle</Label>
+                <Input id="lng" name="lng" type="number" step="any" min="-180" max="180" defaultValue={editingConcert?.lng ?? ''} placeholder="2.3784" className="bg-slate-800 border-slate-600" />
               </div>
             </div>
             <DialogFooter>
@@ -1,13 +1,29 @@
-import { useState, useEffect } from 'react';
-import { Mail, Lock, Eye, EyeOff, Chrome, Github, ArrowRight } from 'lucide-react';
+import { useState, useEffect, useRef } from 'react';
+import { Mail, Lock, Eye, EyeOff, Chrome, Github, ArrowRight, ShieldCheck, KeyRound } from 'lucide-react';
 import { Link, useNavigate, useSearchParams } from 'react-router-dom';
-import { api, APIError } from '../lib/api';
+import { api, APIError, type AuthResponse, type LoginResponse, type LoginProvider, startProviderLogin, exchangeProviderCode } from '../lib/api';
 import { useAuthStore } from '../stores/useAuthStore';
 import { toast } from 'sonner';
 
+const PROVIDER_ICONS: Record<string, typeof Chrome> = {
+  google: Chrome,
+  github: Github,
+};
+
+// Codes d'erreur renvoyés par /api/auth/{provider}/callback
+const OAUTH_ERRORS: Record<string, string> = {
+  account_exists: 'Un compte existe déjà avec cette adresse : connectez-vous, puis liez ce fournisseur depuis votre profil',
+  email_not_verified: "Ce fournisseur n'a pas communiqué d'adresse email vérifiée",
+  invalid_state: 'La tentative de connexion a expiré, veuillez réessayer',
+  invalid_request: 'La tentative de connexion a expiré, veuillez réessayer',
+  access_denied: 'Connexion annulée',
+  two_factor_locked: 'Trop de tentatives, réessayez dans quelques minutes',
+  provider_unavailable: 'Ce fournisseur de connexion est indisponible',
+};
+
 export default function LoginPage() {
   const navigate = useNavigate();
-  const [searchParams] = useSearchParams();
+  const [searchParams, setSearchParams] = useSearchParams();
   const { login } = useAuthStore();
 
   // States
@@ -16,24 +32,50 @@ export default function LoginPage() {
   const [showPassword, setShowPassword] = useState(false);
   const [isLoading, setIsLoading] = useState(false);
   const [error, setError] = useState('');
+  // Connexion en deux étapes : challenge reçu après le mot de passe (ou un fournisseur externe)
+  const [challengeToken, setChallengeToken] = useState<string | null>(null);
+  const [code, setCode] = useState('');
+  const [providers, setProviders] = useState<LoginProvider[]>([]);
 
-  // ✅ LOGIQUE DE CAPTURE DU TOKEN GOOGLE
+  // Fournisseurs de connexion configurés côté serveur (Google, GitHub, OIDC...)
   useEffect(() => {
-    const token = searchParams.get('token');
-    const userJson = searchParams.get('user');
-
-    if (token && userJson) {
-      try {
-        const userData = JSON.parse(decodeURIComponent(userJson));
-        login(token, userData);
-        toast.success('Ravi de vous revoir via Google !');
-        navigate('/');
-      } catch (err) {
-        console.error("Erreur parsing Google User:", err);
-        toast.error("Erreur lors de la connexion Google");
-      }
+    api.get<LoginProvider[]>('/auth/providers').then(setProviders).catch(() => setProviders([]));
+  }, []);
+
+  // ✅ RETOUR DU FOURNISSEUR (Google, GitHub, OIDC) : échange du code de passation
+  const exchanging = useRef(false);
+  useEffect(() => {
+    const oauthError = searchParams.get('error');
+    if (oauthError) {
+      setError(OAUTH_ERRORS[oauthError] ?? 'La connexion a échoué, veuillez réessayer');
+      return;
     }
-  }, [searchParams, login, navigate]);
+
+    const code = searchParams.get('code');
+    const state = searchParams.get('state');
+    if (!code || !state || exchanging.current) return;
+    exchanging.current = true;
+
+    // Le code ne sert qu'une fois : on le retire de l'URL et de l'historique
+    setSearchParams({}, { replace: true });
+    setIsLoading(true);
+    exchangeProviderCode(code, state)
+      .then((response) => {
+        if ('two_factor_required' in response) {
+          setChallengeToken(response.challenge_token);
+          return;
+        }
+        login(response.token, response.user, response.refresh_token);
+        toast.success('Ravi de vous revoir !');
+        navigate('/');
+      })
+      .catch((err) => {
+        const message = err instanceof APIError ? err.message : 'Erreur lors de la connexion externe';
+        setError(message);
+        toast.error(message);
+      })
+      .finally(() => setIsLoading(false));
+  }, [searchParams, setSearchParams, login, navigate]);
 
   // Connexion Classique (Email/Password)
   const handleSubmit = async (e: React.FormEvent) => {
@@ -41,8 +83,12 @@ export default function LoginPage() {
     setError('');
     setIsLoading(true);
     try {
-      const response = await api.post<{ token: string; user: any }>('/auth/login', { email, password });
-      login(response.token, response.user);
+      const response = await api.post<LoginResponse>('/auth/login', { email, password });
+      if ('two_factor_required' in response) {
+        setChallengeToken(response.challenge_token);
+        return;
+      }
+      login(response.token, response.user, response.refresh_token);
       toast.success('Ravi de vous revoir !');
       navigate('/'); 
     } catch (err) {
@@ -54,18 +100,33 @@ export default function LoginPage() {
     }
   };
 
-  // ✅ LOGIQUE DU BOUTON GOOGLE
-  const handleGoogleLogin = () => {
-    const isDev = import.meta.env.DEV;
-    let googleAuthUrl: string;
-
-    if (isDev) {
-      googleAuthUrl = `/api/auth/google`;
-    } else {
-      const baseUrl = import.meta.env.VITE_API_URL || '';
-      googleAuthUrl = baseUrl ? `${baseUrl}/api/auth/google` : `/api/auth/google`;
+  // Second facteur : code TOTP ou code de secours
+  const handleTwoFactorSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    setIsLoading(true);
+    try {
+      const response = await api.post<AuthResponse>('/auth/2fa/login', { challenge_token: challengeToken, code });
+      login(response.token, response.user, response.refresh_token);
+      toast.success('Ravi de vous revoir !');
+      navigate('/');
+    } catch (err) {
+      const message = err instanceof APIError ? err.message : 'Code invalide';
+      setError(message);
+      toast.error(message);
+      // Challenge expiré ou épuisé : retour au formulaire de connexion
+      if (err instanceof APIError && message.includes('reconnecter')) {
+        setChallengeToken(null);
+        setCode('');
+      }
+    } finally {
+      setIsLoading(false);
     }
-    window.location.href = googleAuthUrl;
+  };
+
+  // ✅ REDIRECTION VERS LE FOURNISSEUR
+  const handleProviderLogin = (provider: string) => {
+    startProviderLogin(provider).catch(() => toast.error('Connexion externe indisponible'));
   };
 
   return (
@@ -93,6 +154,33 @@ export default function LoginPage() {
             </div>
           )}
 
+          {challengeToken ? (
+            <form onSubmit={handleTwoFactorSubmit} className="space-y-6">
+              <div className="space-y-2">
+                <label className="block text-xs font-black text-slate-400 uppercase">Code de vérification</label>
+                <div className="relative">
+                  <ShieldCheck className="absolute left-4 top-1/2 -translate-y-1/2 w-5 h-5 text-slate-500" />
+                  <input
+                    type="text"
+                    inputMode="text"
+                    autoComplete="one-time-code"
+                    autoFocus
+                    value={code}
+                    onChange={(e) => setCode(e.target.value)}
+                    className="w-full bg-white/5 border border-white/10 rounded-xl pl-12 pr-4 py-4 text-white tracking-widest outline-none focus:border-violet-500 transition-colors"
+                    placeholder="123456"
+                    required
+                  />
+                </div>
+                <p className="text-xs text-slate-500">Code de votre application d'authentification, ou un code de secours.</p>
+              </div>
+
+              <button type="submit" disabled={isLoading} className="w-full bg-white text-black font-black py-4 rounded-xl flex items-center justify-center gap-2 hover:bg-violet-500 hover:text-white transition-all">
+                {isLoading ? "CHARGEMENT..." : "VÉRIFIER"}
+                <ArrowRight className="w-5 h-5" />
+              </button>
+            </form>
+          ) : (
           <form onSubmit={handleSubmit} className="space-y-6">
             <div className="space-y-2">
               <label className="block text-xs font-black text-slate-400 uppercase">Email</label>
@@ -140,25 +228,32 @@ export default function LoginPage() {
               <ArrowRight className="w-5 h-5" />
             </button>
           </form>
+          )}
 
+          {providers.length > 0 && (
+          <>
           <div className="relative my-8 text-center">
             <span className="px-4 bg-transparent text-[10px] uppercase font-black text-slate-500">Ou continuer avec</span>
           </div>
 
           <div className="grid grid-cols-2 gap-4">
-            <button 
-              type="button"
-              onClick={handleGoogleLogin} 
-              className="flex items-center justify-center gap-3 px-6 py-3 rounded-xl bg-white/5 border border-white/10 text-white hover:bg-white/10 transition-all"
-            >
-              <Chrome size={18} />
-              <span className="text-xs font-black uppercase">Google</span>
-            </button>
-            <button disabled className="opacity-30 cursor-not-allowed flex items-center justify-center gap-3 px-6 py-3 rounded-xl bg-white/5 border border-white/10 text-white">
-              <Github size={18} />
-              <span className="text-xs font-black uppercase">GitHub</span>
-            </button>
+            {providers.map((provider) => {
+              const Icon = PROVIDER_ICONS[provider.name] ?? KeyRound;
+              return (
+                <button
+                  key={provider.name}
+                  type="button"
+                  onClick={() => handleProviderLogin(provider.name)}
+                  className="flex items-center justify-center gap-3 px-6 py-3 rounded-xl bg-white/5 border border-white/10 text-white hover:bg-white/10 transition-all"
+                >
+                  <Icon size={18} />
+                  <span className="text-xs font-black uppercase">{provider.display_name}</span>
+                </button>
+              );
+            })}
           </div>
+          </>
+          )}
 
           <p className="mt-8 text-center text-slate-500 text-xs font-bold uppercase">
             Nouveau ? <Link to="/register" className="text-violet-400 hover:underline ml-1">Créer un compte</Link>
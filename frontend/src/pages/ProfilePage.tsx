@@ -1,5 +1,7 @@
 import { useAuthStore } from '../stores/useAuthStore';
 import { User, Mail, Shield, Calendar } from 'lucide-react';
+import TwoFactorSettings from '../components/TwoFactorSettings';
+import LinkedAccounts from '../components/LinkedAccounts';
 
 export default function ProfilePage() {
   const { user } = useAuthStore(); // On récupère l'utilisateur connecté
@@ -37,6 +39,10 @@ export default function ProfilePage() {
               </p>
             </div>
           </div>
+
+          <TwoFactorSettings />
+
+          <LinkedAccounts />
         </div>
       </div>
     </div>
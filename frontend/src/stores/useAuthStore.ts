@@ -9,6 +9,7 @@ interface User {
   email: string
   role: string
   email_verified: boolean
+  two_factor_enabled?: boolean
   is_admin?: boolean
 }
 
@@ -21,8 +22,10 @@ interface DecodedToken {
 interface AuthState {
   user: User | null
   token: string | null
+  refreshToken: string | null
   isAuthenticated: boolean
-  login: (token: string, user: User) => void
+  login: (token: string, user: User, refreshToken?: string | null) => void
+  setTokens: (token: string, refreshToken: string) => void
   logout: () => void
   checkAuth: () => void
 }
@@ -32,20 +35,26 @@ export const useAuthStore = create<AuthState>()(
     (set, get) => ({
       user: null,
       token: null,
+      refreshToken: null,
       isAuthenticated: false,
 
-      login: (token, user) => {
+      login: (token, user, refreshToken = null) => {
         console.log('✅ Login effectué avec succès:', user);
-        set({ token, user, isAuthenticated: true })
+        set({ token, refreshToken, user, isAuthenticated: true })
+      },
+
+      // Rotation : chaque rafraîchissement remplace les deux tokens
+      setTokens: (token, refreshToken) => {
+        set({ token, refreshToken, isAuthenticated: true })
       },
 
       logout: () => {
         console.log('🚪 Déconnexion...');
-        set({ token: null, user: null, isAuthenticated: false })
+        set({ token: null, refreshToken: null, user: null, isAuthenticated: false })
       },
 
       checkAuth: () => {
-        const { token } = get()
+        const { token, refreshToken } = get()
         if (!token) {
           set({ isAuthenticated: false, user: null })
           return
@@ -55,7 +64,8 @@ export const useAuthStore = create<AuthState>()(
           const decoded: DecodedToken = jwtDecode(token)
           const currentTime = Date.now() / 1000
           
-          if (decoded.exp < currentTime) {
+          // Access token expiré : api.ts le renouvelle au prochain appel tant qu'il reste un refresh token
+          if (decoded.exp < currentTime && !refreshToken) {
             console.log('⏰ Token expiré, déconnexion...');
             get().logout()
           } else {